@@ -0,0 +1,31 @@
+package activity
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/activity"
+)
+
+// ErrResultPending is returned by activities which are completed asynchronously. The activity is not completed when
+// it returns, instead another process completes it using the activity's task token, see GetTaskToken. The backend
+// keeps the activity task until it's completed, or until one of its timeouts expires.
+var ErrResultPending = activity.ErrResultPending
+
+// GetTaskToken returns a token identifying the current activity task. Pass it to the process completing the activity
+// asynchronously, which can use client.CompleteActivity to complete it and client.HeartbeatActivity to record
+// heartbeats.
+func GetTaskToken(ctx context.Context) string {
+	as := activity.GetActivityState(ctx)
+	if as.TaskToken == nil {
+		as.Logger.Error("local activities cannot be completed asynchronously")
+		return ""
+	}
+
+	token, err := as.TaskToken.Encode()
+	if err != nil {
+		as.Logger.Error("encoding task token", "error", err)
+		return ""
+	}
+
+	return token
+}
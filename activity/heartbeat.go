@@ -0,0 +1,40 @@
+package activity
+
+import (
+	"context"
+
+	"github.com/cschleiden/go-workflows/internal/activity"
+)
+
+// RecordHeartbeat reports that the activity is still making progress. details are stored with the activity task
+// and sent to the backend with the next heartbeat. Activities with a heartbeat timeout have to call RecordHeartbeat
+// more often than the timeout, otherwise the attempt times out. If the activity fails or times out and is retried, the next
+// attempt can retrieve the last recorded details using GetHeartbeatDetails.
+//
+// If the workflow instance that scheduled the activity is canceled, the activity's context is canceled on the
+// next heartbeat.
+func RecordHeartbeat(ctx context.Context, details interface{}) {
+	as := activity.GetActivityState(ctx)
+
+	p, err := as.Converter.To(details)
+	if err != nil {
+		as.Logger.Error("converting heartbeat details", "error", err)
+		return
+	}
+
+	as.Heartbeat.Record(p)
+}
+
+// GetHeartbeatDetails retrieves the details last recorded via RecordHeartbeat, either by the current or a previous
+// attempt of the activity, and stores them in the value pointed to by v. If no details have been recorded, v is
+// left unchanged.
+func GetHeartbeatDetails(ctx context.Context, v interface{}) error {
+	as := activity.GetActivityState(ctx)
+
+	details := as.Heartbeat.Details()
+	if details == nil {
+		return nil
+	}
+
+	return as.Converter.From(details, v)
+}
@@ -3,10 +3,13 @@ package backend
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/cschleiden/go-workflows/internal/converter"
 	core "github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/schedule"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/metrics"
@@ -16,6 +19,11 @@ import (
 
 var ErrInstanceNotFound = errors.New("workflow instance not found")
 var ErrInstanceAlreadyExists = errors.New("workflow instance already exists")
+var ErrInstanceNotActive = errors.New("workflow instance is not active")
+var ErrActivityTaskNotFound = errors.New("activity task not found")
+var ErrActivityCanceled = errors.New("activity canceled")
+var ErrScheduleNotFound = errors.New("schedule not found")
+var ErrScheduleAlreadyExists = errors.New("schedule already exists")
 
 const TracerName = "go-workflow"
 
@@ -27,9 +35,31 @@ type Backend interface {
 	// CancelWorkflowInstance cancels a running workflow instance
 	CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance, cancelEvent *history.Event) error
 
+	// TerminateWorkflowInstance terminates a running workflow instance without executing any more workflow
+	// code. The given event is added to the history of the instance, any pending events, timers, and activities
+	// which have not been started yet are removed. If the instance is a sub-workflow, its parent will be notified.
+	//
+	// If subWorkflows is set, all running sub-workflow instances of the instance are terminated recursively, as part
+	// of the same transaction.
+	//
+	// If the instance is not active anymore, or the given execution is not the current execution of the instance,
+	// ErrInstanceNotActive is returned.
+	TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event, subWorkflows bool) error
+
 	// GetWorkflowInstanceState returns the state of the given workflow instance
 	GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error)
 
+	// ListWorkflowInstances returns up to count workflow instances matching the given filter, ordered by creation
+	// time, newest first. When afterInstanceID is given, only instances after that instance are returned.
+	ListWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter, afterInstanceID string, count int) ([]*WorkflowInstanceInfo, error)
+
+	// CountWorkflowInstances returns the number of workflow instances matching the given filter
+	CountWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter) (int64, error)
+
+	// ListWorkflowInstanceExecutions returns all executions of the given workflow instance, the current execution
+	// first, followed by past executions which have been continued as new or replaced, newest first.
+	ListWorkflowInstanceExecutions(ctx context.Context, instanceID string) ([]*WorkflowInstanceInfo, error)
+
 	// GetWorkflowInstanceHistory returns the workflow history for the given instance. When lastSequenceID
 	// is given, only events after that event are returned. Otherwise the full history is returned.
 	GetWorkflowInstanceHistory(ctx context.Context, instance *workflow.Instance, lastSequenceID *int64) ([]*history.Event, error)
@@ -39,8 +69,19 @@ type Backend interface {
 	// If the given instance does not exist, it will return an error
 	SignalWorkflow(ctx context.Context, instanceID string, event *history.Event) error
 
-	// GetWorkflowInstance returns a pending workflow task or nil if there are no pending worflow executions
-	GetWorkflowTask(ctx context.Context) (*task.Workflow, error)
+	// SignalWithStartWorkflow signals the given workflow instance, creating it using startedEvent if it doesn't
+	// exist, yet. Creating the instance and adding the signal happens atomically, so the first workflow task of a new
+	// instance sees both events. It returns the instance which has been signaled.
+	//
+	// If the instance has finished, a new execution is started if the ID reuse policy of startedEvent allows it.
+	// Otherwise, ErrInstanceNotActive is returned and the signal is not added.
+	SignalWithStartWorkflow(ctx context.Context, instance *workflow.Instance, startedEvent, signalEvent *history.Event) (*workflow.Instance, error)
+
+	// GetWorkflowInstance returns a pending workflow task of an instance on one of the given queues, or nil if there
+	// are no pending worflow executions. If no queues are given, tasks from the default queue are returned.
+	//
+	// Activities which have exceeded one of their timeouts are failed before looking for a task.
+	GetWorkflowTask(ctx context.Context, queues []workflow.Queue) (*task.Workflow, error)
 
 	// ExtendWorkflowTask extends the lock of a workflow task
 	ExtendWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error
@@ -54,14 +95,73 @@ type Backend interface {
 		ctx context.Context, task *task.Workflow, instance *workflow.Instance, state core.WorkflowInstanceState,
 		executedEvents, activityEvents, timerEvents []*history.Event, workflowEvents []history.WorkflowEvent) error
 
-	// GetActivityTask returns a pending activity task or nil if there are no pending activities
-	GetActivityTask(ctx context.Context) (*task.Activity, error)
+	// AbandonWorkflowTask releases the lock of a workflow task retrieved using GetWorkflowTask without completing
+	// it. Its new events are kept, and the task can be retrieved again right away by any worker.
+	AbandonWorkflowTask(ctx context.Context, task *task.Workflow) error
+
+	// GetActivityTask returns a pending activity task routed to one of the given queues, or nil if there are no
+	// pending activities. If no queues are given, tasks from the default queue are returned.
+	GetActivityTask(ctx context.Context, queues []workflow.Queue) (*task.Activity, error)
 
-	// CompleteActivityTask completes an activity task retrieved using GetActivityTask
+	// CompleteActivityTask completes an activity task retrieved using GetActivityTask. Activity tasks can be completed
+	// by any caller knowing the workflow instance and the id of the task, e.g., from a task token, even while a
+	// worker is still executing them.
+	//
+	// If the activity task has timed out in the meantime, ErrActivityTaskNotFound is returned.
 	CompleteActivityTask(ctx context.Context, instance *workflow.Instance, activityID string, event *history.Event) error
 
-	// ExtendActivityTask extends the lock of an activity task
-	ExtendActivityTask(ctx context.Context, activityID string) error
+	// SetActivityTaskPending marks an activity task retrieved using GetActivityTask as waiting for asynchronous
+	// completion. The task is not returned by GetActivityTask again, it's kept until it's completed using
+	// CompleteActivityTask, or until it times out.
+	//
+	// If the activity task has been completed or has timed out in the meantime, ErrActivityTaskNotFound is returned.
+	SetActivityTaskPending(ctx context.Context, activityID string) error
+
+	// ExtendActivityTask extends the lock of an activity task and records a heartbeat for it. Heartbeats can be
+	// recorded by any caller knowing the id of the task, e.g., from a task token. If heartbeatDetails
+	// are given, they replace the details stored with the activity task and are returned with the task if it is
+	// retrieved again, or included in the ActivityFailed event if the activity times out.
+	//
+	// If the activity task has timed out in the meantime, ErrActivityTaskNotFound is returned. If the workflow
+	// instance has been canceled after the activity was scheduled, or is not active anymore, ErrActivityCanceled
+	// is returned.
+	ExtendActivityTask(ctx context.Context, activityID string, heartbeatDetails payload.Payload) error
+
+	// AbandonActivityTask releases the lock of an activity task retrieved using GetActivityTask without completing
+	// it. Recorded heartbeat details are kept, and the task can be retrieved again right away by any worker.
+	//
+	// If the activity task has been completed or has timed out in the meantime, ErrActivityTaskNotFound is returned.
+	AbandonActivityTask(ctx context.Context, task *task.Activity) error
+
+	// CreateSchedule creates a new schedule
+	//
+	// If a schedule with the same id already exists, ErrScheduleAlreadyExists is returned.
+	CreateSchedule(ctx context.Context, s *schedule.Schedule) error
+
+	// GetSchedule returns the schedule with the given id
+	//
+	// If the schedule does not exist, ErrScheduleNotFound is returned.
+	GetSchedule(ctx context.Context, id string) (*schedule.Schedule, error)
+
+	// PauseSchedule pauses the given schedule. Paused schedules are not returned by GetScheduleTask.
+	PauseSchedule(ctx context.Context, id string) error
+
+	// ResumeSchedule resumes the given schedule, its next run will be at nextRunAt
+	ResumeSchedule(ctx context.Context, id string, nextRunAt time.Time) error
+
+	// DeleteSchedule deletes the given schedule. Workflow instances started by the schedule are not affected.
+	DeleteSchedule(ctx context.Context, id string) error
+
+	// GetScheduleTask returns a schedule which is not paused and has a run due or a buffered run, or nil if there
+	// is none. The schedule is locked until it is completed using CompleteScheduleTask, or the workflow lock
+	// timeout expires.
+	GetScheduleTask(ctx context.Context) (*schedule.Schedule, error)
+
+	// CompleteScheduleTask stores the next run, buffered run, and last instance of a schedule retrieved using
+	// GetScheduleTask, and releases its lock.
+	//
+	// If the schedule has been deleted or its lock has expired in the meantime, ErrScheduleNotFound is returned.
+	CompleteScheduleTask(ctx context.Context, s *schedule.Schedule) error
 
 	// Logger returns the configured logger for the backend
 	Logger() log.Logger
@@ -75,3 +175,18 @@ type Backend interface {
 	// Converter returns the configured converter for the backend
 	Converter() converter.Converter
 }
+
+// ActivityLimiter is implemented by backends which can enforce activity limits across all workers.
+type ActivityLimiter interface {
+	// AcquireActivitySlot acquires one of limit execution slots of the given activity for holder, and returns
+	// false if all slots are taken. Acquiring a slot again extends it, slots which are not extended within lease
+	// are released.
+	AcquireActivitySlot(ctx context.Context, activityName, holder string, limit int, lease time.Duration) (bool, error)
+
+	// ReleaseActivitySlot releases a slot acquired using AcquireActivitySlot
+	ReleaseActivitySlot(ctx context.Context, activityName, holder string) error
+
+	// TakeActivityToken takes a token from the bucket of the given activity, which is refilled with rate tokens
+	// per second up to burst tokens. If no token is available, it returns the time to wait before trying again.
+	TakeActivityToken(ctx context.Context, activityName string, rate float64, burst int) (time.Duration, error)
+}
@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+// WorkflowInstanceFilter restricts the workflow instances returned by ListWorkflowInstances and counted by
+// CountWorkflowInstances. Fields with zero values do not restrict the result, all other fields need to match.
+type WorkflowInstanceFilter struct {
+	// WorkflowName matches instances of the workflow with the given name
+	WorkflowName string
+
+	// State matches instances whose current execution is in the given state
+	State *core.WorkflowInstanceState
+
+	// CreatedAfter and CreatedBefore match instances created in [CreatedAfter, CreatedBefore)
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// CompletedAfter and CompletedBefore match instances completed in [CompletedAfter, CompletedBefore). Only
+	// finished instances match if either is set.
+	CompletedAfter  time.Time
+	CompletedBefore time.Time
+
+	// ParentInstanceID matches sub-workflow instances of the given parent instance
+	ParentInstanceID string
+
+	// Metadata matches instances with all of the given metadata entries
+	Metadata map[string]string
+
+	// SearchAttributes matches instances with all of the given search attributes. Attributes need to have the same
+	// type and value, keyword lists need to contain all given keywords.
+	SearchAttributes core.SearchAttributes
+}
+
+// WorkflowInstanceInfo describes a workflow instance returned by ListWorkflowInstances
+type WorkflowInstanceInfo struct {
+	// Instance is the current execution of the workflow instance
+	Instance *core.WorkflowInstance
+
+	WorkflowName string
+
+	State core.WorkflowInstanceState
+
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+
+	Metadata *core.WorkflowMetadata
+
+	SearchAttributes core.SearchAttributes
+}
+
+// Matches returns whether the given instance matches the filter
+func (f *WorkflowInstanceFilter) Matches(i *WorkflowInstanceInfo) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.WorkflowName != "" && i.WorkflowName != f.WorkflowName {
+		return false
+	}
+
+	if f.State != nil && i.State != *f.State {
+		return false
+	}
+
+	if !f.CreatedAfter.IsZero() && i.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+
+	if !f.CreatedBefore.IsZero() && !i.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+
+	if !f.CompletedAfter.IsZero() || !f.CompletedBefore.IsZero() {
+		if i.CompletedAt == nil {
+			return false
+		}
+
+		if !f.CompletedAfter.IsZero() && i.CompletedAt.Before(f.CompletedAfter) {
+			return false
+		}
+
+		if !f.CompletedBefore.IsZero() && !i.CompletedAt.Before(f.CompletedBefore) {
+			return false
+		}
+	}
+
+	if f.ParentInstanceID != "" && (i.Instance == nil || i.Instance.ParentInstanceID != f.ParentInstanceID) {
+		return false
+	}
+
+	for k, v := range f.Metadata {
+		if i.Metadata == nil || (*i.Metadata)[k] != v {
+			return false
+		}
+	}
+
+	if !i.SearchAttributes.Matches(f.SearchAttributes) {
+		return false
+	}
+
+	return true
+}
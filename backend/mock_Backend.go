@@ -12,12 +12,18 @@ import (
 
 	log "github.com/cschleiden/go-workflows/log"
 
+	payload "github.com/cschleiden/go-workflows/internal/payload"
+
+	schedule "github.com/cschleiden/go-workflows/internal/schedule"
+
 	metrics "github.com/cschleiden/go-workflows/metrics"
 
 	mock "github.com/stretchr/testify/mock"
 
 	task "github.com/cschleiden/go-workflows/internal/task"
 
+	time "time"
+
 	trace "go.opentelemetry.io/otel/trace"
 )
 
@@ -26,6 +32,34 @@ type MockBackend struct {
 	mock.Mock
 }
 
+// AbandonActivityTask provides a mock function with given fields: ctx, _a1
+func (_m *MockBackend) AbandonActivityTask(ctx context.Context, _a1 *task.Activity) error {
+	ret := _m.Called(ctx, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *task.Activity) error); ok {
+		r0 = rf(ctx, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// AbandonWorkflowTask provides a mock function with given fields: ctx, _a1
+func (_m *MockBackend) AbandonWorkflowTask(ctx context.Context, _a1 *task.Workflow) error {
+	ret := _m.Called(ctx, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *task.Workflow) error); ok {
+		r0 = rf(ctx, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CancelWorkflowInstance provides a mock function with given fields: ctx, instance, cancelEvent
 func (_m *MockBackend) CancelWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, cancelEvent *history.Event) error {
 	ret := _m.Called(ctx, instance, cancelEvent)
@@ -54,6 +88,20 @@ func (_m *MockBackend) CompleteActivityTask(ctx context.Context, instance *core.
 	return r0
 }
 
+// CompleteScheduleTask provides a mock function with given fields: ctx, s
+func (_m *MockBackend) CompleteScheduleTask(ctx context.Context, s *schedule.Schedule) error {
+	ret := _m.Called(ctx, s)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *schedule.Schedule) error); ok {
+		r0 = rf(ctx, s)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CompleteWorkflowTask provides a mock function with given fields: ctx, _a1, instance, state, executedEvents, activityEvents, timerEvents, workflowEvents
 func (_m *MockBackend) CompleteWorkflowTask(ctx context.Context, _a1 *task.Workflow, instance *core.WorkflowInstance, state core.WorkflowInstanceState, executedEvents []*history.Event, activityEvents []*history.Event, timerEvents []*history.Event, workflowEvents []history.WorkflowEvent) error {
 	ret := _m.Called(ctx, _a1, instance, state, executedEvents, activityEvents, timerEvents, workflowEvents)
@@ -84,6 +132,44 @@ func (_m *MockBackend) Converter() converter.Converter {
 	return r0
 }
 
+// CountWorkflowInstances provides a mock function with given fields: ctx, filter
+func (_m *MockBackend) CountWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter) (int64, error) {
+	ret := _m.Called(ctx, filter)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *WorkflowInstanceFilter) (int64, error)); ok {
+		return rf(ctx, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *WorkflowInstanceFilter) int64); ok {
+		r0 = rf(ctx, filter)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *WorkflowInstanceFilter) error); ok {
+		r1 = rf(ctx, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateSchedule provides a mock function with given fields: ctx, s
+func (_m *MockBackend) CreateSchedule(ctx context.Context, s *schedule.Schedule) error {
+	ret := _m.Called(ctx, s)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *schedule.Schedule) error); ok {
+		r0 = rf(ctx, s)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateWorkflowInstance provides a mock function with given fields: ctx, instance, event
 func (_m *MockBackend) CreateWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, event *history.Event) error {
 	ret := _m.Called(ctx, instance, event)
@@ -98,13 +184,27 @@ func (_m *MockBackend) CreateWorkflowInstance(ctx context.Context, instance *cor
 	return r0
 }
 
-// ExtendActivityTask provides a mock function with given fields: ctx, activityID
-func (_m *MockBackend) ExtendActivityTask(ctx context.Context, activityID string) error {
-	ret := _m.Called(ctx, activityID)
+// DeleteSchedule provides a mock function with given fields: ctx, id
+func (_m *MockBackend) DeleteSchedule(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
 
 	var r0 error
 	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
-		r0 = rf(ctx, activityID)
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ExtendActivityTask provides a mock function with given fields: ctx, activityID, heartbeatDetails
+func (_m *MockBackend) ExtendActivityTask(ctx context.Context, activityID string, heartbeatDetails payload.Payload) error {
+	ret := _m.Called(ctx, activityID, heartbeatDetails)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, payload.Payload) error); ok {
+		r0 = rf(ctx, activityID, heartbeatDetails)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -126,20 +226,72 @@ func (_m *MockBackend) ExtendWorkflowTask(ctx context.Context, taskID string, in
 	return r0
 }
 
-// GetActivityTask provides a mock function with given fields: ctx
-func (_m *MockBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
-	ret := _m.Called(ctx)
+// GetActivityTask provides a mock function with given fields: ctx, queues
+func (_m *MockBackend) GetActivityTask(ctx context.Context, queues []core.Queue) (*task.Activity, error) {
+	ret := _m.Called(ctx, queues)
 
 	var r0 *task.Activity
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) (*task.Activity, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, []core.Queue) (*task.Activity, error)); ok {
+		return rf(ctx, queues)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []core.Queue) *task.Activity); ok {
+		r0 = rf(ctx, queues)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*task.Activity)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []core.Queue) error); ok {
+		r1 = rf(ctx, queues)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetSchedule provides a mock function with given fields: ctx, id
+func (_m *MockBackend) GetSchedule(ctx context.Context, id string) (*schedule.Schedule, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 *schedule.Schedule
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*schedule.Schedule, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *schedule.Schedule); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*schedule.Schedule)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetScheduleTask provides a mock function with given fields: ctx
+func (_m *MockBackend) GetScheduleTask(ctx context.Context) (*schedule.Schedule, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *schedule.Schedule
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (*schedule.Schedule, error)); ok {
 		return rf(ctx)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) *task.Activity); ok {
+	if rf, ok := ret.Get(0).(func(context.Context) *schedule.Schedule); ok {
 		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*task.Activity)
+			r0 = ret.Get(0).(*schedule.Schedule)
 		}
 	}
 
@@ -202,25 +354,77 @@ func (_m *MockBackend) GetWorkflowInstanceState(ctx context.Context, instance *c
 	return r0, r1
 }
 
-// GetWorkflowTask provides a mock function with given fields: ctx
-func (_m *MockBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, error) {
-	ret := _m.Called(ctx)
+// GetWorkflowTask provides a mock function with given fields: ctx, queues
+func (_m *MockBackend) GetWorkflowTask(ctx context.Context, queues []core.Queue) (*task.Workflow, error) {
+	ret := _m.Called(ctx, queues)
 
 	var r0 *task.Workflow
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) (*task.Workflow, error)); ok {
-		return rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, []core.Queue) (*task.Workflow, error)); ok {
+		return rf(ctx, queues)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) *task.Workflow); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, []core.Queue) *task.Workflow); ok {
+		r0 = rf(ctx, queues)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*task.Workflow)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, []core.Queue) error); ok {
+		r1 = rf(ctx, queues)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListWorkflowInstanceExecutions provides a mock function with given fields: ctx, instanceID
+func (_m *MockBackend) ListWorkflowInstanceExecutions(ctx context.Context, instanceID string) ([]*WorkflowInstanceInfo, error) {
+	ret := _m.Called(ctx, instanceID)
+
+	var r0 []*WorkflowInstanceInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*WorkflowInstanceInfo, error)); ok {
+		return rf(ctx, instanceID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*WorkflowInstanceInfo); ok {
+		r0 = rf(ctx, instanceID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*WorkflowInstanceInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, instanceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListWorkflowInstances provides a mock function with given fields: ctx, filter, afterInstanceID, count
+func (_m *MockBackend) ListWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter, afterInstanceID string, count int) ([]*WorkflowInstanceInfo, error) {
+	ret := _m.Called(ctx, filter, afterInstanceID, count)
+
+	var r0 []*WorkflowInstanceInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *WorkflowInstanceFilter, string, int) ([]*WorkflowInstanceInfo, error)); ok {
+		return rf(ctx, filter, afterInstanceID, count)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *WorkflowInstanceFilter, string, int) []*WorkflowInstanceInfo); ok {
+		r0 = rf(ctx, filter, afterInstanceID, count)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*WorkflowInstanceInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *WorkflowInstanceFilter, string, int) error); ok {
+		r1 = rf(ctx, filter, afterInstanceID, count)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -260,6 +464,74 @@ func (_m *MockBackend) Metrics() metrics.Client {
 	return r0
 }
 
+// PauseSchedule provides a mock function with given fields: ctx, id
+func (_m *MockBackend) PauseSchedule(ctx context.Context, id string) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResumeSchedule provides a mock function with given fields: ctx, id, nextRunAt
+func (_m *MockBackend) ResumeSchedule(ctx context.Context, id string, nextRunAt time.Time) error {
+	ret := _m.Called(ctx, id, nextRunAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, id, nextRunAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SignalWithStartWorkflow provides a mock function with given fields: ctx, instance, startedEvent, signalEvent
+func (_m *MockBackend) SignalWithStartWorkflow(ctx context.Context, instance *core.WorkflowInstance, startedEvent *history.Event, signalEvent *history.Event) (*core.WorkflowInstance, error) {
+	ret := _m.Called(ctx, instance, startedEvent, signalEvent)
+
+	var r0 *core.WorkflowInstance
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *core.WorkflowInstance, *history.Event, *history.Event) (*core.WorkflowInstance, error)); ok {
+		return rf(ctx, instance, startedEvent, signalEvent)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *core.WorkflowInstance, *history.Event, *history.Event) *core.WorkflowInstance); ok {
+		r0 = rf(ctx, instance, startedEvent, signalEvent)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*core.WorkflowInstance)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *core.WorkflowInstance, *history.Event, *history.Event) error); ok {
+		r1 = rf(ctx, instance, startedEvent, signalEvent)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetActivityTaskPending provides a mock function with given fields: ctx, activityID
+func (_m *MockBackend) SetActivityTaskPending(ctx context.Context, activityID string) error {
+	ret := _m.Called(ctx, activityID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, activityID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SignalWorkflow provides a mock function with given fields: ctx, instanceID, event
 func (_m *MockBackend) SignalWorkflow(ctx context.Context, instanceID string, event *history.Event) error {
 	ret := _m.Called(ctx, instanceID, event)
@@ -274,6 +546,20 @@ func (_m *MockBackend) SignalWorkflow(ctx context.Context, instanceID string, ev
 	return r0
 }
 
+// TerminateWorkflowInstance provides a mock function with given fields: ctx, instance, event, subWorkflows
+func (_m *MockBackend) TerminateWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, event *history.Event, subWorkflows bool) error {
+	ret := _m.Called(ctx, instance, event, subWorkflows)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *core.WorkflowInstance, *history.Event, bool) error); ok {
+		r0 = rf(ctx, instance, event, subWorkflows)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Tracer provides a mock function with given fields:
 func (_m *MockBackend) Tracer() trace.Tracer {
 	ret := _m.Called()
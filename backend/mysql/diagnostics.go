@@ -3,6 +3,8 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/cschleiden/go-workflows/diag"
@@ -80,13 +82,20 @@ func (mb *mysqlBackend) GetWorkflowInstance(ctx context.Context, instanceID stri
 	}
 	defer tx.Rollback()
 
-	res := tx.QueryRowContext(ctx, "SELECT instance_id, execution_id, created_at, completed_at FROM instances WHERE instance_id = ?", instanceID)
+	res := tx.QueryRowContext(
+		ctx,
+		"SELECT instance_id, execution_id, parent_instance_id, parent_schedule_event_id, search_attributes, created_at, completed_at FROM instances WHERE instance_id = ?",
+		instanceID,
+	)
 
 	var id, executionID string
+	var parentInstanceID *string
+	var parentEventID *int64
+	var searchAttributesJson *string
 	var createdAt time.Time
 	var completedAt *time.Time
 
-	err = res.Scan(&id, &executionID, &createdAt, &completedAt)
+	err = res.Scan(&id, &executionID, &parentInstanceID, &parentEventID, &searchAttributesJson, &createdAt, &completedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -100,11 +109,26 @@ func (mb *mysqlBackend) GetWorkflowInstance(ctx context.Context, instanceID stri
 		state = core.WorkflowInstanceStateFinished
 	}
 
+	var instance *core.WorkflowInstance
+	if parentInstanceID != nil {
+		instance = core.NewSubWorkflowInstance(id, executionID, *parentInstanceID, *parentEventID)
+	} else {
+		instance = core.NewWorkflowInstance(id, executionID)
+	}
+
+	var searchAttributes core.SearchAttributes
+	if searchAttributesJson != nil {
+		if err := json.Unmarshal([]byte(*searchAttributesJson), &searchAttributes); err != nil {
+			return nil, fmt.Errorf("unmarshaling search attributes: %w", err)
+		}
+	}
+
 	return &diag.WorkflowInstanceRef{
-		Instance:    core.NewWorkflowInstance(id, executionID),
-		CreatedAt:   createdAt,
-		CompletedAt: completedAt,
-		State:       state,
+		Instance:         instance,
+		CreatedAt:        createdAt,
+		CompletedAt:      completedAt,
+		State:            state,
+		SearchAttributes: searchAttributes,
 	}, nil
 }
 
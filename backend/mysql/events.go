@@ -8,15 +8,21 @@ import (
 	"github.com/cschleiden/go-workflows/internal/history"
 )
 
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
 func insertPendingEvents(ctx context.Context, tx *sql.Tx, instanceID string, newEvents []*history.Event) error {
-	return insertEvents(ctx, tx, "pending_events", instanceID, newEvents)
+	return insertEvents(ctx, tx, "pending_events", []string{"instance_id"}, []interface{}{instanceID}, newEvents)
 }
 
-func insertHistoryEvents(ctx context.Context, tx *sql.Tx, instanceID string, historyEvents []*history.Event) error {
-	return insertEvents(ctx, tx, "history", instanceID, historyEvents)
+func insertHistoryEvents(ctx context.Context, tx *sql.Tx, instanceID, executionID string, historyEvents []*history.Event) error {
+	return insertEvents(ctx, tx, "history", []string{"instance_id", "execution_id"}, []interface{}{instanceID, executionID}, historyEvents)
 }
 
-func insertEvents(ctx context.Context, tx *sql.Tx, tableName string, instanceID string, events []*history.Event) error {
+// insertEvents inserts the given events into tableName. instanceColumns and instanceValues identify the
+// workflow instance (or execution) the events belong to.
+func insertEvents(ctx context.Context, tx *sql.Tx, tableName string, instanceColumns []string, instanceValues []interface{}, events []*history.Event) error {
 	const batchSize = 20
 	for batchStart := 0; batchStart < len(events); batchStart += batchSize {
 		batchEnd := batchStart + batchSize
@@ -25,10 +31,11 @@ func insertEvents(ctx context.Context, tx *sql.Tx, tableName string, instanceID
 		}
 		batchEvents := events[batchStart:batchEnd]
 
-		query := "INSERT INTO `" + tableName + "` (event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)" +
-			strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?)", len(batchEvents)-1)
+		values := "(" + strings.Repeat("?, ", len(instanceColumns)) + "?, ?, ?, ?, ?, ?, ?)"
+		query := "INSERT INTO `" + tableName + "` (" + strings.Join(instanceColumns, ", ") + ", event_id, sequence_id, event_type, timestamp, schedule_event_id, attributes, visible_at) VALUES " +
+			values + strings.Repeat(", "+values, len(batchEvents)-1)
 
-		args := make([]interface{}, 0, len(batchEvents)*7)
+		args := make([]interface{}, 0, len(batchEvents)*(len(instanceColumns)+7))
 
 		for _, newEvent := range batchEvents {
 			a, err := history.SerializeAttributes(newEvent.Attributes)
@@ -36,7 +43,8 @@ func insertEvents(ctx context.Context, tx *sql.Tx, tableName string, instanceID
 				return err
 			}
 
-			args = append(args, newEvent.ID, newEvent.SequenceID, instanceID, newEvent.Type, newEvent.Timestamp, newEvent.ScheduleEventID, a, newEvent.VisibleAt)
+			args = append(args, instanceValues...)
+			args = append(args, newEvent.ID, newEvent.SequenceID, newEvent.Type, newEvent.Timestamp, newEvent.ScheduleEventID, a, newEvent.VisibleAt)
 		}
 
 		_, err := tx.ExecContext(
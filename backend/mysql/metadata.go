@@ -0,0 +1,32 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+// replaceMetadata indexes the metadata of the current execution of the workflow instance in instance_metadata, so
+// that instances can be filtered by metadata without scanning all instances. Metadata is also stored as JSON with
+// the instance.
+func replaceMetadata(ctx context.Context, tx *sql.Tx, instanceID string, metadata *core.WorkflowMetadata) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `instance_metadata` WHERE instance_id = ?", instanceID); err != nil {
+		return fmt.Errorf("removing metadata index: %w", err)
+	}
+
+	if metadata == nil {
+		return nil
+	}
+
+	for name, value := range *metadata {
+		if _, err := tx.ExecContext(
+			ctx, "INSERT INTO `instance_metadata` (instance_id, name, value) VALUES (?, ?, ?)", instanceID, name, value,
+		); err != nil {
+			return fmt.Errorf("indexing metadata: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/metrics"
@@ -72,13 +73,19 @@ func (b *mysqlBackend) CreateWorkflowInstance(ctx context.Context, instance *wor
 	}
 	defer tx.Rollback()
 
-	// Create workflow instance
-	if err := createInstance(ctx, tx, instance, event.Attributes.(*history.ExecutionStartedAttributes).Metadata, false); err != nil {
+	// Create workflow instance, or start a new execution of an existing instance
+	a := event.Attributes.(*history.ExecutionStartedAttributes)
+	if err := startExecution(ctx, tx, instance, a, a.IDReusePolicy); err != nil {
 		return err
 	}
 
 	// Initial history is empty, store only new events
-	if err := insertPendingEvents(ctx, tx, instance.InstanceID, []*history.Event{event}); err != nil {
+	events := []*history.Event{event}
+	if timeoutEvent := history.NewExecutionTimeoutEvent(event); timeoutEvent != nil {
+		events = append(events, timeoutEvent)
+	}
+
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, events); err != nil {
 		return fmt.Errorf("inserting new event: %w", err)
 	}
 
@@ -131,6 +138,260 @@ func (b *mysqlBackend) CancelWorkflowInstance(ctx context.Context, instance *wor
 		return fmt.Errorf("inserting cancellation event: %w", err)
 	}
 
+	if err := startDelayedInstance(ctx, tx, instanceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// startDelayedInstance starts the given instance right away, if its start has been delayed. Used when canceling
+// instances, their workflow observes the cancellation.
+func startDelayedInstance(ctx context.Context, tx *sql.Tx, instanceID string) error {
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE `pending_events` SET visible_at = NULL WHERE instance_id = ? AND event_type = ?",
+		instanceID, history.EventType_WorkflowExecutionStarted,
+	); err != nil {
+		return fmt.Errorf("starting delayed workflow instance: %w", err)
+	}
+
+	return nil
+}
+
+// instanceActive returns ErrInstanceNotFound or ErrInstanceNotActive if the current execution of the given workflow
+// instance is not active
+func instanceActive(ctx context.Context, tx *sql.Tx, instanceID string) error {
+	var completedAt *time.Time
+	if err := tx.QueryRowContext(
+		ctx, "SELECT completed_at FROM `instances` WHERE instance_id = ?", instanceID,
+	).Scan(&completedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("reading workflow instance: %w", err)
+	}
+
+	if completedAt != nil {
+		return backend.ErrInstanceNotActive
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event, subWorkflows bool) error {
+	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only the current execution of the instance can be terminated
+	var executionID string
+	if err := tx.QueryRowContext(
+		ctx, "SELECT execution_id FROM `instances` WHERE instance_id = ? LIMIT 1 FOR UPDATE", instance.InstanceID,
+	).Scan(&executionID); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return err
+	}
+
+	if executionID != instance.ExecutionID {
+		return backend.ErrInstanceNotActive
+	}
+
+	if subWorkflows {
+		if err := terminateInstanceTree(ctx, tx, instance.InstanceID, event); err != nil {
+			return err
+		}
+	} else if err := terminateInstance(ctx, tx, instance.InstanceID, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// terminateInstanceTree terminates the given workflow instance, and all of its running sub-workflow instances
+func terminateInstanceTree(ctx context.Context, tx *sql.Tx, instanceID string, event *history.Event) error {
+	if err := terminateInstance(ctx, tx, instanceID, event); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT instance_id FROM `instances` WHERE parent_instance_id = ? AND completed_at IS NULL", instanceID)
+	if err != nil {
+		return fmt.Errorf("finding sub-workflow instances: %w", err)
+	}
+
+	subInstanceIDs := make([]string, 0)
+	for rows.Next() {
+		var subInstanceID string
+		if err := rows.Scan(&subInstanceID); err != nil {
+			rows.Close()
+			return err
+		}
+
+		subInstanceIDs = append(subInstanceIDs, subInstanceID)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	a := event.Attributes.(*history.ExecutionTerminatedAttributes)
+	for _, subInstanceID := range subInstanceIDs {
+		if err := terminateInstanceTree(
+			ctx, tx, subInstanceID, history.NewWorkflowTerminationEvent(event.Timestamp, a.Reason),
+		); err != nil {
+			return fmt.Errorf("terminating sub-workflow instance %s: %w", subInstanceID, err)
+		}
+	}
+
+	return nil
+}
+
+// terminateInstance terminates the current execution of the given workflow instance
+func terminateInstance(ctx context.Context, tx *sql.Tx, instanceID string, event *history.Event) error {
+	// Terminate the current execution of the instance
+	res := tx.QueryRowContext(
+		ctx, "SELECT execution_id, parent_instance_id, parent_schedule_event_id, completed_at FROM `instances` WHERE instance_id = ? LIMIT 1 FOR UPDATE", instanceID)
+
+	var executionID string
+	var parentInstanceID *string
+	var parentEventID *int64
+	var completedAt *time.Time
+	if err := res.Scan(&executionID, &parentInstanceID, &parentEventID, &completedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return err
+	}
+
+	if completedAt != nil {
+		return backend.ErrInstanceNotActive
+	}
+
+	now := time.Now()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE `instances` SET completed_at = ? WHERE instance_id = ?", now, instanceID); err != nil {
+		return fmt.Errorf("completing workflow instance: %w", err)
+	}
+
+	// Add termination event to the end of the history
+	row := tx.QueryRowContext(ctx, "SELECT sequence_id FROM `history` WHERE instance_id = ? AND execution_id = ? ORDER BY id DESC LIMIT 1", instanceID, executionID)
+	if err := row.Scan(&event.SequenceID); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("getting most recent sequence id: %w", err)
+	}
+	event.SequenceID++
+
+	if err := insertHistoryEvents(ctx, tx, instanceID, executionID, []*history.Event{event}); err != nil {
+		return fmt.Errorf("inserting termination event: %w", err)
+	}
+
+	// Remove pending events and timers
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `pending_events` WHERE instance_id = ?", instanceID); err != nil {
+		return fmt.Errorf("removing pending events: %w", err)
+	}
+
+	// Remove activities which haven't been picked up by a worker, yet, or are waiting for asynchronous completion.
+	// Running activities will still complete.
+	if _, err := tx.ExecContext(
+		ctx, "DELETE FROM `activities` WHERE instance_id = ? AND (locked_until IS NULL OR locked_until < ? OR completion_pending)", instanceID, now,
+	); err != nil {
+		return fmt.Errorf("removing pending activities: %w", err)
+	}
+
+	// Notify parent, if it's still running
+	if parentInstanceID != nil {
+		a := event.Attributes.(*history.ExecutionTerminatedAttributes)
+
+		res := tx.QueryRowContext(ctx, "SELECT 1 FROM `instances` WHERE instance_id = ? AND completed_at IS NULL", *parentInstanceID)
+		if err := res.Scan(new(int)); err != nil {
+			if err != sql.ErrNoRows {
+				return err
+			}
+		} else {
+			if err := insertPendingEvents(ctx, tx, *parentInstanceID, []*history.Event{
+				history.NewSubWorkflowTerminatedEvent(now, *parentEventID, a),
+			}); err != nil {
+				return fmt.Errorf("notifying parent workflow instance: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// timeoutWorkflowInstances terminates all workflow instances, and their sub-workflow instances, which have exceeded
+// their execution timeout and are terminated on timeout. Instances canceled on timeout are canceled by the worker
+// executing the timeout event.
+func (b *mysqlBackend) timeoutWorkflowInstances(ctx context.Context, now time.Time) error {
+	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT pe.instance_id, pe.attributes
+			FROM pending_events pe
+				INNER JOIN instances i ON i.instance_id = pe.instance_id
+			WHERE pe.event_type = ? AND pe.visible_at <= ? AND i.completed_at IS NULL`,
+		history.EventType_WorkflowExecutionTimedOut, now,
+	)
+	if err != nil {
+		return fmt.Errorf("finding timed out workflow instances: %w", err)
+	}
+
+	instanceIDs := make([]string, 0)
+	for rows.Next() {
+		var instanceID string
+		var attributes []byte
+		if err := rows.Scan(&instanceID, &attributes); err != nil {
+			rows.Close()
+			return fmt.Errorf("scanning timed out workflow instance: %w", err)
+		}
+
+		a, err := history.DeserializeAttributes(history.EventType_WorkflowExecutionTimedOut, attributes)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("deserializing attributes: %w", err)
+		}
+
+		if a.(*history.ExecutionTimedOutAttributes).Action == core.ExecutionTimeoutActionTerminate {
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+
+	for _, instanceID := range instanceIDs {
+		if err := terminateInstanceTree(ctx, tx, instanceID, history.NewWorkflowTimeoutTerminationEvent(now)); err != nil {
+			if errors.Is(err, backend.ErrInstanceNotActive) {
+				// Already terminated as sub-workflow of another timed out instance
+				continue
+			}
+
+			return fmt.Errorf("terminating workflow instance %s: %w", instanceID, err)
+		}
+	}
+
 	return tx.Commit()
 }
 
@@ -145,15 +406,17 @@ func (b *mysqlBackend) GetWorkflowInstanceHistory(ctx context.Context, instance
 	if lastSequenceID != nil {
 		historyEvents, err = tx.QueryContext(
 			ctx,
-			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? AND sequence_id > ? ORDER BY sequence_id",
+			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? AND execution_id = ? AND sequence_id > ? ORDER BY sequence_id",
 			instance.InstanceID,
+			instance.ExecutionID,
 			*lastSequenceID,
 		)
 	} else {
 		historyEvents, err = tx.QueryContext(
 			ctx,
-			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? ORDER BY sequence_id",
+			"SELECT event_id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? AND execution_id = ? ORDER BY sequence_id",
 			instance.InstanceID,
+			instance.ExecutionID,
 		)
 	}
 	if err != nil {
@@ -197,26 +460,27 @@ func (b *mysqlBackend) GetWorkflowInstanceHistory(ctx context.Context, instance
 func (b *mysqlBackend) GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error) {
 	row := b.db.QueryRowContext(
 		ctx,
-		"SELECT completed_at FROM instances WHERE instance_id = ? AND execution_id = ?",
+		"SELECT execution_id, completed_at FROM instances WHERE instance_id = ?",
 		instance.InstanceID,
-		instance.ExecutionID,
 	)
 
+	var executionID string
 	var completedAt sql.NullTime
-	if err := row.Scan(&completedAt); err != nil {
+	if err := row.Scan(&executionID, &completedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return core.WorkflowInstanceStateActive, backend.ErrInstanceNotFound
 		}
 	}
 
-	if completedAt.Valid {
+	// If the instance has been continued as new, the requested execution is finished
+	if completedAt.Valid || executionID != instance.ExecutionID {
 		return core.WorkflowInstanceStateFinished, nil
 	}
 
 	return core.WorkflowInstanceStateActive, nil
 }
 
-func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, metadata *workflow.Metadata, ignoreDuplicate bool) error {
+func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, a *history.ExecutionStartedAttributes, ignoreDuplicate bool) error {
 	var parentInstanceID *string
 	var parentEventID *int64
 	if wfi.SubWorkflow() {
@@ -227,38 +491,102 @@ func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, met
 		parentEventID = &n
 	}
 
-	metadataJson, err := json.Marshal(metadata)
+	metadataJson, err := json.Marshal(a.Metadata)
 	if err != nil {
 		return fmt.Errorf("marshaling metadata: %w", err)
 	}
 
 	res, err := tx.ExecContext(
 		ctx,
-		"INSERT IGNORE INTO `instances` (instance_id, execution_id, parent_instance_id, parent_schedule_event_id, metadata) VALUES (?, ?, ?, ?, ?)",
+		"INSERT IGNORE INTO `instances` (instance_id, execution_id, workflow_name, parent_instance_id, parent_schedule_event_id, metadata, queue, priority) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
 		wfi.InstanceID,
 		wfi.ExecutionID,
+		a.Name,
 		parentInstanceID,
 		parentEventID,
 		string(metadataJson),
+		string(a.Queue.OrDefault()),
+		a.Priority,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting workflow instance: %w", err)
 	}
 
-	if !ignoreDuplicate {
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return err
-		}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
 
-		if rows != 1 {
-			return backend.ErrInstanceAlreadyExists
+	if rows != 1 {
+		if ignoreDuplicate {
+			return nil
 		}
+
+		return backend.ErrInstanceAlreadyExists
+	}
+
+	if err := replaceMetadata(ctx, tx, wfi.InstanceID, a.Metadata); err != nil {
+		return err
+	}
+
+	if err := upsertSearchAttributes(ctx, tx, wfi.InstanceID, a.SearchAttributes); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// continueInstance replaces the current execution of the workflow instance with a new execution
+func continueInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, a *history.ExecutionStartedAttributes) error {
+	if err := archiveExecution(ctx, tx, wfi.InstanceID); err != nil {
+		return err
+	}
+
+	metadataJson, err := json.Marshal(a.Metadata)
+	if err != nil {
+		return fmt.Errorf("marshaling metadata: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE `instances` SET execution_id = ?, workflow_name = ?, metadata = ?, search_attributes = NULL, queue = ?, priority = ?, completed_at = NULL WHERE instance_id = ?",
+		wfi.ExecutionID,
+		a.Name,
+		string(metadataJson),
+		string(a.Queue.OrDefault()),
+		a.Priority,
+		wfi.InstanceID,
+	); err != nil {
+		return fmt.Errorf("continuing workflow instance: %w", err)
+	}
+
+	// Results and timers of the previous execution are not delivered to the new execution. Events without a schedule
+	// event id, like signals, are kept.
+	if _, err := tx.ExecContext(
+		ctx, "DELETE FROM `pending_events` WHERE instance_id = ? AND schedule_event_id != 0", wfi.InstanceID,
+	); err != nil {
+		return fmt.Errorf("removing pending events of previous execution: %w", err)
+	}
+
+	// Activities of the previous execution are discarded, running activities can't complete anymore
+	if _, err := tx.ExecContext(
+		ctx, "DELETE FROM `activities` WHERE instance_id = ? AND execution_id != ?", wfi.InstanceID, wfi.ExecutionID,
+	); err != nil {
+		return fmt.Errorf("removing activities of previous execution: %w", err)
+	}
+
+	if err := replaceMetadata(ctx, tx, wfi.InstanceID, a.Metadata); err != nil {
+		return err
+	}
+
+	// The new execution starts with the search attributes it has been continued with
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `instance_search_attributes` WHERE instance_id = ?", wfi.InstanceID); err != nil {
+		return fmt.Errorf("removing search attributes of previous execution: %w", err)
+	}
+
+	return upsertSearchAttributes(ctx, tx, wfi.InstanceID, a.SearchAttributes)
+}
+
 // SignalWorkflow signals a running workflow instance
 func (b *mysqlBackend) SignalWorkflow(ctx context.Context, instanceID string, event *history.Event) error {
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
@@ -282,8 +610,7 @@ func (b *mysqlBackend) SignalWorkflow(ctx context.Context, instanceID string, ev
 	return tx.Commit()
 }
 
-// GetWorkflowInstance returns a pending workflow task or nil if there are no pending worflow executions
-func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, error) {
+func (b *mysqlBackend) SignalWithStartWorkflow(ctx context.Context, instance *workflow.Instance, startedEvent, signalEvent *history.Event) (*workflow.Instance, error) {
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -292,24 +619,119 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 	}
 	defer tx.Rollback()
 
-	// Lock next workflow task by finding an unlocked instance with new events to process.
+	events := []*history.Event{startedEvent, signalEvent}
+	if timeoutEvent := history.NewExecutionTimeoutEvent(startedEvent); timeoutEvent != nil {
+		events = append(events, timeoutEvent)
+	}
+
+	// Running instances are signaled, finished instances are replaced by a new execution if the policy allows it.
+	// The insert waits for concurrent transactions creating the same instance, if the instance already exists, it
+	// is visible afterwards.
+	a := startedEvent.Attributes.(*history.ExecutionStartedAttributes)
+	policy := a.IDReusePolicy
+	if policy == core.IDReusePolicyTerminateIfRunning {
+		policy = core.IDReusePolicyAllowDuplicate
+	}
+
+	if err := startExecution(ctx, tx, instance, a, policy); err != nil {
+		if !errors.Is(err, backend.ErrInstanceAlreadyExists) {
+			return nil, err
+		}
+
+		// Signal the existing instance, unless it has finished and can't be replaced
+		res := tx.QueryRowContext(ctx, "SELECT execution_id, parent_instance_id, parent_schedule_event_id, completed_at FROM `instances` WHERE instance_id = ? LIMIT 1", instance.InstanceID)
+
+		var executionID string
+		var parentInstanceID *string
+		var parentEventID *int64
+		var completedAt *time.Time
+		if err := res.Scan(&executionID, &parentInstanceID, &parentEventID, &completedAt); err != nil {
+			return nil, fmt.Errorf("reading existing workflow instance: %w", err)
+		}
+
+		if completedAt != nil {
+			return nil, backend.ErrInstanceNotActive
+		}
+
+		if parentInstanceID != nil {
+			instance = core.NewSubWorkflowInstance(instance.InstanceID, executionID, *parentInstanceID, *parentEventID)
+		} else {
+			instance = core.NewWorkflowInstance(instance.InstanceID, executionID)
+		}
+
+		events = []*history.Event{signalEvent}
+	}
+
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, events); err != nil {
+		return nil, fmt.Errorf("inserting new events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("signaling workflow instance: %w", err)
+	}
+
+	return instance, nil
+}
+
+// GetWorkflowInstance returns a pending workflow task or nil if there are no pending worflow executions
+func (b *mysqlBackend) GetWorkflowTask(ctx context.Context, queues []workflow.Queue) (*task.Workflow, error) {
 	now := time.Now()
+
+	// Fail activities which have timed out, this adds new events for their workflow instances
+	if err := b.timeoutActivities(ctx, now); err != nil {
+		return nil, fmt.Errorf("timing out activities: %w", err)
+	}
+
+	// Terminate workflow instances which have exceeded their execution timeout
+	if err := b.timeoutWorkflowInstances(ctx, now); err != nil {
+		return nil, fmt.Errorf("timing out workflow instances: %w", err)
+	}
+
+	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	queuePlaceholders, queueArgs := queuesCondition(queues)
+
+	starvedBefore := now.Add(-b.options.PriorityStarvationTimeout)
+	args := append(queueArgs,
+		now,          // event.visible_at
+		now,          // locked_until
+		now,          // sticky_until
+		b.workerName, // worker
+		history.EventType_WorkflowExecutionStarted, now, // delayed start
+		starvedBefore, starvedBefore, // priority starvation
+	)
+
+	// Lock next workflow task by finding an unlocked instance on one of the queues with new events to process.
+	// Instances with a higher priority go first, unless an instance has been waiting for longer than the starvation
+	// timeout, then the instance waiting the longest goes first.
 	row := tx.QueryRowContext(
 		ctx,
 		`SELECT i.id, i.instance_id, i.execution_id, i.parent_instance_id, i.parent_schedule_event_id, i.metadata, i.sticky_until
 			FROM instances i
 			INNER JOIN pending_events pe ON i.instance_id = pe.instance_id
 			WHERE
-				i.completed_at IS NULL
+				i.queue IN (`+queuePlaceholders+`)
+				AND i.completed_at IS NULL
 				AND (pe.visible_at IS NULL OR pe.visible_at <= ?)
 				AND (i.locked_until IS NULL OR i.locked_until < ?)
 				AND (i.sticky_until IS NULL OR i.sticky_until < ? OR i.worker = ?)
+				AND NOT EXISTS (
+					SELECT 1 FROM pending_events spe
+						WHERE spe.instance_id = i.instance_id AND spe.event_type = ? AND spe.visible_at > ?
+				)
+			ORDER BY
+				COALESCE(pe.visible_at, pe.timestamp) <= ? DESC,
+				CASE WHEN COALESCE(pe.visible_at, pe.timestamp) <= ? THEN 0 ELSE i.priority END DESC,
+				COALESCE(pe.visible_at, pe.timestamp)
 			LIMIT 1
 			FOR UPDATE OF i SKIP LOCKED`,
-		now,          // event.visible_at
-		now,          // locked_until
-		now,          // sticky_until
-		b.workerName, // worker
+		args...,
 	)
 
 	var id int
@@ -414,7 +836,7 @@ func (b *mysqlBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, err
 	}
 
 	// Get most recent sequence id
-	row = tx.QueryRowContext(ctx, "SELECT sequence_id FROM `history` WHERE instance_id = ? ORDER BY id DESC LIMIT 1", instanceID)
+	row = tx.QueryRowContext(ctx, "SELECT sequence_id FROM `history` WHERE instance_id = ? AND execution_id = ? ORDER BY id DESC LIMIT 1", instanceID, executionID)
 	if err := row.Scan(
 		&t.LastSequenceID,
 	); err != nil {
@@ -460,7 +882,7 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 
 	res, err := tx.ExecContext(
 		ctx,
-		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE instance_id = ? AND execution_id = ? AND worker = ? AND completed_at IS NULL`,
 		time.Now().Add(b.options.StickyTimeout),
 		completedAt,
 		instance.InstanceID,
@@ -475,6 +897,12 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 	if err != nil {
 		return fmt.Errorf("checking for unlocked workflow instances: %w", err)
 	} else if changedRows != 1 {
+		// The instance might have been terminated while the task was being executed
+		res := tx.QueryRowContext(ctx, "SELECT 1 FROM instances WHERE instance_id = ? AND execution_id = ? AND completed_at IS NOT NULL", instance.InstanceID, instance.ExecutionID)
+		if err := res.Scan(new(int)); err == nil {
+			return backend.ErrInstanceNotActive
+		}
+
 		return errors.New("could not find workflow instance to unlock")
 	}
 
@@ -496,7 +924,7 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 	}
 
 	// Insert new events generated during this workflow execution to the history
-	if err := insertHistoryEvents(ctx, tx, instance.InstanceID, executedEvents); err != nil {
+	if err := insertHistoryEvents(ctx, tx, instance.InstanceID, instance.ExecutionID, executedEvents); err != nil {
 		return fmt.Errorf("inserting new history events: %w", err)
 	}
 
@@ -518,33 +946,122 @@ func (b *mysqlBackend) CompleteWorkflowTask(
 			if err := removeFutureEvent(ctx, tx, instance.InstanceID, event.ScheduleEventID); err != nil {
 				return fmt.Errorf("removing future event: %w", err)
 			}
+
+		case history.EventType_SearchAttributesUpserted:
+			a := event.Attributes.(*history.SearchAttributesUpsertedAttributes)
+			if err := upsertSearchAttributes(ctx, tx, instance.InstanceID, a.SearchAttributes); err != nil {
+				return fmt.Errorf("upserting search attributes: %w", err)
+			}
 		}
 	}
 
 	// Insert new workflow events
 	groupedEvents := history.EventsByWorkflowInstanceID(workflowEvents)
 
+	// Errors delivering signals or cancellations to other workflow instances, by target instance
+	deliveryErrors := make(map[string]error)
+	continued := false
+
 	for targetInstanceID, events := range groupedEvents {
+		var timeoutEvent *history.Event
+		started := false
+
 		for _, m := range events {
 			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted {
 				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
-				// Create new instance
-				if err := createInstance(ctx, tx, m.WorkflowInstance, a.Metadata, true); err != nil {
-					return err
+				if targetInstanceID == instance.InstanceID {
+					// Workflow instance continued as new, start new execution
+					if err := continueInstance(ctx, tx, m.WorkflowInstance, a); err != nil {
+						return err
+					}
+
+					continued = true
+				} else {
+					// Create new instance
+					if err := createInstance(ctx, tx, m.WorkflowInstance, a, true); err != nil {
+						return err
+					}
+
+					timeoutEvent = history.NewExecutionTimeoutEvent(m.HistoryEvent)
 				}
 
+				started = true
 				break
 			}
 		}
 
 		historyEvents := []*history.Event{}
+		terminations := []*history.Event{}
+		startDelayed := false
 		for _, m := range events {
+			switch m.HistoryEvent.Type {
+			case history.EventType_WorkflowExecutionTerminated:
+				if targetInstanceID != instance.InstanceID {
+					// Sub-workflow instances are terminated according to their parent close policy
+					terminations = append(terminations, m.HistoryEvent)
+					continue
+				}
+
+			case history.EventType_SignalReceived, history.EventType_WorkflowExecutionCanceled:
+				if started || targetInstanceID == instance.InstanceID {
+					break
+				}
+
+				// Signals and cancellations are only delivered to active instances
+				if _, checked := deliveryErrors[targetInstanceID]; !checked {
+					err := instanceActive(ctx, tx, targetInstanceID)
+					if err != nil && !errors.Is(err, backend.ErrInstanceNotFound) && !errors.Is(err, backend.ErrInstanceNotActive) {
+						return err
+					}
+
+					deliveryErrors[targetInstanceID] = err
+				}
+
+				if deliveryErrors[targetInstanceID] != nil {
+					continue
+				}
+
+				startDelayed = startDelayed || m.HistoryEvent.Type == history.EventType_WorkflowExecutionCanceled
+			}
+
 			historyEvents = append(historyEvents, m.HistoryEvent)
 		}
 
+		// Time out new instances once they exceed their execution timeout
+		if timeoutEvent != nil {
+			historyEvents = append(historyEvents, timeoutEvent)
+		}
+
 		if err := insertPendingEvents(ctx, tx, targetInstanceID, historyEvents); err != nil {
 			return fmt.Errorf("inserting messages: %w", err)
 		}
+
+		if startDelayed {
+			if err := startDelayedInstance(ctx, tx, targetInstanceID); err != nil {
+				return err
+			}
+		}
+
+		for _, event := range terminations {
+			if err := terminateInstanceTree(ctx, tx, targetInstanceID, event); err != nil &&
+				!errors.Is(err, backend.ErrInstanceNotActive) && !errors.Is(err, backend.ErrInstanceNotFound) {
+				return fmt.Errorf("terminating sub-workflow instance: %w", err)
+			}
+		}
+	}
+
+	// Complete requests to signal or cancel other workflow instances, unless this execution has ended
+	if state != core.WorkflowInstanceStateFinished && !continued {
+		results := []*history.Event{}
+		for _, event := range executedEvents {
+			if targetInstanceID, ok := history.ExternalWorkflowRequestTarget(event); ok {
+				results = append(results, history.NewExternalWorkflowResultEvent(time.Now(), event, deliveryErrors[targetInstanceID]))
+			}
+		}
+
+		if err := insertPendingEvents(ctx, tx, instance.InstanceID, results); err != nil {
+			return fmt.Errorf("completing workflow requests: %w", err)
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -583,8 +1100,23 @@ func (b *mysqlBackend) ExtendWorkflowTask(ctx context.Context, taskID string, in
 	return tx.Commit()
 }
 
+func (b *mysqlBackend) AbandonWorkflowTask(ctx context.Context, task *task.Workflow) error {
+	// Unlock instance, and don't keep it sticky to this worker
+	if _, err := b.db.ExecContext(
+		ctx,
+		`UPDATE instances SET locked_until = NULL, sticky_until = NULL WHERE instance_id = ? AND execution_id = ? AND worker = ?`,
+		task.WorkflowInstance.InstanceID,
+		task.WorkflowInstance.ExecutionID,
+		b.workerName,
+	); err != nil {
+		return fmt.Errorf("abandoning workflow task: %w", err)
+	}
+
+	return nil
+}
+
 // GetActivityTask returns a pending activity task or nil if there are no pending activities
-func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
+func (b *mysqlBackend) GetActivityTask(ctx context.Context, queues []workflow.Queue) (*task.Activity, error) {
 	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
 		Isolation: sql.LevelReadCommitted,
 	})
@@ -593,29 +1125,40 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 	}
 	defer tx.Rollback()
 
-	// Lock next activity
+	queuePlaceholders, queueArgs := queuesCondition(queues)
+
+	// Lock next activity on one of the queues. Activities with a higher priority go first, unless an activity has
+	// been waiting for longer than the starvation timeout, then the activity waiting the longest goes first.
 	now := time.Now()
+	starvedBefore := now.Add(-b.options.PriorityStarvationTimeout)
 	res := tx.QueryRowContext(
 		ctx,
 		`SELECT activities.id, activity_id, activities.instance_id, activities.execution_id,
-			instances.metadata, event_type, timestamp, schedule_event_id, attributes, visible_at
+			instances.metadata, event_type, timestamp, schedule_event_id, attributes, visible_at, heartbeat_details
 			FROM activities
 				INNER JOIN instances ON activities.instance_id = instances.instance_id
-			WHERE activities.locked_until IS NULL OR activities.locked_until < ?
+			WHERE
+				activities.queue IN (`+queuePlaceholders+`)
+				AND (activities.locked_until IS NULL OR activities.locked_until < ?)
+				AND NOT activities.completion_pending
+			ORDER BY
+				COALESCE(activities.visible_at, activities.timestamp) <= ? DESC,
+				CASE WHEN COALESCE(activities.visible_at, activities.timestamp) <= ? THEN 0 ELSE activities.priority END DESC,
+				COALESCE(activities.visible_at, activities.timestamp)
 			LIMIT 1
 			FOR UPDATE SKIP LOCKED`,
-		now,
+		append(queueArgs, now, starvedBefore, starvedBefore)...,
 	)
 
 	var id int64
 	var instanceID, executionID string
-	var attributes []byte
+	var attributes, heartbeatDetails []byte
 	var metadataJson sql.NullString
 	event := &history.Event{}
 
 	if err := res.Scan(
 		&id, &event.ID, &instanceID, &executionID, &metadataJson, &event.Type,
-		&event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt); err != nil {
+		&event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt, &heartbeatDetails); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -633,18 +1176,29 @@ func (b *mysqlBackend) GetActivityTask(ctx context.Context) (*task.Activity, err
 		return nil, fmt.Errorf("deserializing attributes: %w", err)
 	}
 
+	// A previous worker might have recorded heartbeat details before it gave up the task
+	if heartbeatDetails != nil {
+		a.(*history.ActivityScheduledAttributes).HeartbeatDetails = heartbeatDetails
+	}
+
 	event.Attributes = a
 
 	if _, err := tx.ExecContext(
 		ctx,
-		`UPDATE activities SET locked_until = ?, worker = ? WHERE id = ?`,
+		`UPDATE activities SET locked_until = ?, worker = ?, started_at = ?, last_heartbeat_at = NULL WHERE id = ?`,
 		now.Add(b.options.ActivityLockTimeout),
 		b.workerName,
+		now,
 		id,
 	); err != nil {
 		return nil, fmt.Errorf("locking activity: %w", err)
 	}
 
+	// The activity has been started, start-to-close and heartbeat timeouts apply from now on
+	if err := updateActivityTimeout(ctx, tx, id, a.(*history.ActivityScheduledAttributes), event.Timestamp, &now, nil); err != nil {
+		return nil, err
+	}
+
 	t := &task.Activity{
 		ID:               event.ID,
 		WorkflowInstance: core.NewWorkflowInstance(instanceID, executionID),
@@ -669,14 +1223,14 @@ func (b *mysqlBackend) CompleteActivityTask(ctx context.Context, instance *workf
 	}
 	defer tx.Rollback()
 
-	// Remove activity
+	// Remove activity. Activities can be completed by any process knowing their task token, not only by the worker
+	// executing them, e.g., by a webhook before the activity has returned ErrResultPending.
 	if res, err := tx.ExecContext(
 		ctx,
-		`DELETE FROM activities WHERE activity_id = ? AND instance_id = ? AND execution_id = ? AND worker = ?`,
+		`DELETE FROM activities WHERE activity_id = ? AND instance_id = ? AND execution_id = ?`,
 		id,
 		instance.InstanceID,
 		instance.ExecutionID,
-		b.workerName,
 	); err != nil {
 		return fmt.Errorf("completing activity: %w", err)
 	} else {
@@ -686,7 +1240,7 @@ func (b *mysqlBackend) CompleteActivityTask(ctx context.Context, instance *workf
 		}
 
 		if affected == 0 {
-			return errors.New("could not find locked activity")
+			return backend.ErrActivityTaskNotFound
 		}
 	}
 
@@ -702,53 +1256,124 @@ func (b *mysqlBackend) CompleteActivityTask(ctx context.Context, instance *workf
 	return nil
 }
 
-func (b *mysqlBackend) ExtendActivityTask(ctx context.Context, activityID string) error {
+func (b *mysqlBackend) SetActivityTaskPending(ctx context.Context, activityID string) error {
+	res, err := b.db.ExecContext(
+		ctx,
+		`UPDATE activities SET locked_until = NULL, completion_pending = TRUE WHERE activity_id = ? AND worker = ?`,
+		activityID,
+		b.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("marking activity as pending: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("checking for pending activity: %w", err)
+	} else if n == 0 {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) ExtendActivityTask(ctx context.Context, activityID string, heartbeatDetails payload.Payload) error {
 	tx, err := b.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	until := time.Now().Add(b.options.ActivityLockTimeout)
-	res, err := tx.ExecContext(
+	row := tx.QueryRowContext(
 		ctx,
-		`UPDATE activities SET locked_until = ? WHERE activity_id = ? AND worker = ?`,
-		until,
+		`SELECT id, instance_id, execution_id, schedule_event_id, timestamp, attributes, started_at
+			FROM activities WHERE activity_id = ? FOR UPDATE`,
 		activityID,
-		b.workerName,
 	)
-	if err != nil {
+
+	var id, scheduleEventID int64
+	var instanceID, executionID string
+	var scheduledAt time.Time
+	var attributes []byte
+	var startedAt *time.Time
+	if err := row.Scan(&id, &instanceID, &executionID, &scheduleEventID, &scheduledAt, &attributes, &startedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrActivityTaskNotFound
+		}
+
+		return fmt.Errorf("finding activity to extend: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE activities SET locked_until = ?, last_heartbeat_at = ?, heartbeat_details = COALESCE(?, heartbeat_details) WHERE id = ?`,
+		now.Add(b.options.ActivityLockTimeout),
+		now,
+		[]byte(heartbeatDetails),
+		id,
+	); err != nil {
 		return fmt.Errorf("extending activity lock: %w", err)
 	}
 
-	if rowsAffected, err := res.RowsAffected(); err != nil {
-		return fmt.Errorf("determining if activity was extended: %w", err)
-	} else if rowsAffected == 0 {
-		return errors.New("could not extend activity")
+	a, err := history.DeserializeAttributes(history.EventType_ActivityScheduled, attributes)
+	if err != nil {
+		return fmt.Errorf("deserializing attributes: %w", err)
 	}
 
-	return tx.Commit()
+	if err := updateActivityTimeout(ctx, tx, id, a.(*history.ActivityScheduledAttributes), scheduledAt, startedAt, &now); err != nil {
+		return err
+	}
+
+	canceled, err := activityCanceled(ctx, tx, instanceID, executionID, scheduleEventID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if canceled {
+		return backend.ErrActivityCanceled
+	}
+
+	return nil
 }
 
-func scheduleActivity(ctx context.Context, tx *sql.Tx, instance *core.WorkflowInstance, event *history.Event) error {
-	a, err := history.SerializeAttributes(event.Attributes)
+func (b *mysqlBackend) AbandonActivityTask(ctx context.Context, task *task.Activity) error {
+	tx, err := b.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = tx.ExecContext(
+	var id int64
+	if err := tx.QueryRowContext(
 		ctx,
-		`INSERT INTO activities
-			(activity_id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		event.ID,
-		instance.InstanceID,
-		instance.ExecutionID,
-		event.Type,
-		event.Timestamp,
-		event.ScheduleEventID,
-		a,
-		event.VisibleAt,
-	)
+		"SELECT id FROM activities WHERE activity_id = ? AND worker = ? AND NOT completion_pending FOR UPDATE",
+		task.ID,
+		b.workerName,
+	).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrActivityTaskNotFound
+		}
+
+		return fmt.Errorf("finding activity to abandon: %w", err)
+	}
 
-	return err
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE activities SET locked_until = NULL, worker = NULL, started_at = NULL, last_heartbeat_at = NULL WHERE id = ?`,
+		id,
+	); err != nil {
+		return fmt.Errorf("abandoning activity task: %w", err)
+	}
+
+	// The activity is not running anymore, only the schedule-to-close timeout applies until it's started again
+	a := task.Event.Attributes.(*history.ActivityScheduledAttributes)
+	if err := updateActivityTimeout(ctx, tx, id, a, task.Event.Timestamp, nil, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
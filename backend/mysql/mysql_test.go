@@ -99,6 +99,14 @@ func TestMySqlBackendE2E(t *testing.T) {
 
 var _ test.TestBackend = (*mysqlBackend)(nil)
 
+func (mb *mysqlBackend) OtherProcess() backend.Backend {
+	return &mysqlBackend{
+		db:         mb.db,
+		workerName: fmt.Sprintf("worker-%v", uuid.NewString()),
+		options:    mb.options,
+	}
+}
+
 func (mb *mysqlBackend) GetFutureEvents(ctx context.Context) ([]*history.Event, error) {
 	tx, err := mb.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"strings"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+// queuesCondition returns the placeholders and arguments for matching the given queues in an IN clause. If no
+// queues are given, the default queue is matched.
+func queuesCondition(queues []core.Queue) (string, []interface{}) {
+	if len(queues) == 0 {
+		queues = []core.Queue{core.QueueDefault}
+	}
+
+	args := make([]interface{}, 0, len(queues))
+	for _, q := range queues {
+		args = append(args, string(q))
+	}
+
+	return "?" + strings.Repeat(", ?", len(queues)-1), args
+}
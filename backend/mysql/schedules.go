@@ -0,0 +1,238 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/schedule"
+)
+
+const scheduleColumns = "schedule_id, spec, workflow_name, inputs, paused, created_at, next_run_at, buffered_run_at, last_instance_id, last_execution_id, due_at"
+
+func (b *mysqlBackend) CreateSchedule(ctx context.Context, s *schedule.Schedule) error {
+	spec, err := json.Marshal(s.Spec)
+	if err != nil {
+		return fmt.Errorf("marshaling schedule spec: %w", err)
+	}
+
+	inputs, err := json.Marshal(s.Inputs)
+	if err != nil {
+		return fmt.Errorf("marshaling schedule inputs: %w", err)
+	}
+
+	res, err := b.db.ExecContext(
+		ctx,
+		"INSERT IGNORE INTO `schedules` (schedule_id, spec, workflow_name, inputs, paused, created_at, next_run_at, due_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		s.ID,
+		spec,
+		s.WorkflowName,
+		inputs,
+		s.Paused,
+		s.CreatedAt,
+		s.NextRunAt,
+		s.DueAt,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting schedule: %w", err)
+	}
+
+	if rows, err := res.RowsAffected(); err != nil {
+		return err
+	} else if rows != 1 {
+		return backend.ErrScheduleAlreadyExists
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) GetSchedule(ctx context.Context, id string) (*schedule.Schedule, error) {
+	row := b.db.QueryRowContext(ctx, "SELECT "+scheduleColumns+" FROM `schedules` WHERE schedule_id = ?", id)
+
+	s, err := scanSchedule(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, backend.ErrScheduleNotFound
+		}
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (b *mysqlBackend) PauseSchedule(ctx context.Context, id string) error {
+	return b.updateSchedule(ctx, id, "UPDATE `schedules` SET paused = TRUE WHERE schedule_id = ?", id)
+}
+
+func (b *mysqlBackend) ResumeSchedule(ctx context.Context, id string, nextRunAt time.Time) error {
+	return b.updateSchedule(ctx, id, "UPDATE `schedules` SET paused = FALSE, next_run_at = ?, due_at = ? WHERE schedule_id = ?", nextRunAt, nextRunAt, id)
+}
+
+// updateSchedule executes the given update for an existing schedule. MySQL only reports changed rows as affected,
+// so the schedule is locked first to check whether it exists.
+func (b *mysqlBackend) updateSchedule(ctx context.Context, id string, query string, args ...interface{}) error {
+	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRowContext(ctx, "SELECT 1 FROM `schedules` WHERE schedule_id = ? FOR UPDATE", id).Scan(new(int)); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrScheduleNotFound
+		}
+
+		return fmt.Errorf("locking schedule: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("updating schedule: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (b *mysqlBackend) DeleteSchedule(ctx context.Context, id string) error {
+	res, err := b.db.ExecContext(ctx, "DELETE FROM `schedules` WHERE schedule_id = ?", id)
+	if err != nil {
+		return fmt.Errorf("deleting schedule: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("checking for deleted schedules: %w", err)
+	} else if n != 1 {
+		return backend.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+func (b *mysqlBackend) GetScheduleTask(ctx context.Context) (*schedule.Schedule, error) {
+	tx, err := b.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock next due schedule
+	now := time.Now()
+	row := tx.QueryRowContext(
+		ctx,
+		`SELECT `+scheduleColumns+` FROM schedules
+			WHERE
+				paused = FALSE
+				AND (locked_until IS NULL OR locked_until < ?)
+				AND due_at <= ?
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED`,
+		now, // locked_until
+		now, // due_at
+	)
+
+	s, err := scanSchedule(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("finding schedule to lock: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE schedules SET locked_until = ?, worker = ? WHERE schedule_id = ?`,
+		now.Add(b.options.WorkflowLockTimeout),
+		b.workerName,
+		s.ID,
+	); err != nil {
+		return nil, fmt.Errorf("locking schedule: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (b *mysqlBackend) CompleteScheduleTask(ctx context.Context, s *schedule.Schedule) error {
+	var lastInstanceID, lastExecutionID *string
+	if s.LastInstance != nil {
+		lastInstanceID = &s.LastInstance.InstanceID
+		lastExecutionID = &s.LastInstance.ExecutionID
+	}
+
+	res, err := b.db.ExecContext(
+		ctx,
+		`UPDATE schedules
+			SET next_run_at = ?, buffered_run_at = ?, last_instance_id = ?, last_execution_id = ?, due_at = ?, locked_until = NULL, worker = NULL
+			WHERE schedule_id = ? AND worker = ?`,
+		s.NextRunAt,
+		s.BufferedRunAt,
+		lastInstanceID,
+		lastExecutionID,
+		s.DueAt,
+		s.ID,
+		b.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("completing schedule task: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("checking for completed schedules: %w", err)
+	} else if n != 1 {
+		return backend.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+func scanSchedule(row *sql.Row) (*schedule.Schedule, error) {
+	s := &schedule.Schedule{}
+
+	var spec, inputs []byte
+	var nextRunAt, bufferedRunAt *time.Time
+	var dueAt time.Time
+	var lastInstanceID, lastExecutionID *string
+	if err := row.Scan(
+		&s.ID, &spec, &s.WorkflowName, &inputs, &s.Paused, &s.CreatedAt, &nextRunAt, &bufferedRunAt,
+		&lastInstanceID, &lastExecutionID, &dueAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(spec, &s.Spec); err != nil {
+		return nil, fmt.Errorf("unmarshaling schedule spec: %w", err)
+	}
+
+	if err := json.Unmarshal(inputs, &s.Inputs); err != nil {
+		return nil, fmt.Errorf("unmarshaling schedule inputs: %w", err)
+	}
+
+	if nextRunAt != nil {
+		s.NextRunAt = nextRunAt.UTC()
+	}
+
+	if bufferedRunAt != nil {
+		t := bufferedRunAt.UTC()
+		s.BufferedRunAt = &t
+	}
+
+	if lastInstanceID != nil {
+		s.LastInstance = core.NewWorkflowInstance(*lastInstanceID, *lastExecutionID)
+	}
+
+	s.CreatedAt = s.CreatedAt.UTC()
+	s.DueAt = dueAt.UTC()
+
+	return s, nil
+}
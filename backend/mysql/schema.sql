@@ -2,9 +2,13 @@ CREATE TABLE IF NOT EXISTS `instances` (
   `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
   `instance_id` NVARCHAR(128) NOT NULL,
   `execution_id` NVARCHAR(128) NOT NULL,
+  `workflow_name` NVARCHAR(256) NULL,
   `parent_instance_id` NVARCHAR(128) NULL,
   `parent_schedule_event_id` BIGINT NULL,
   `metadata` BLOB NULL,
+  `search_attributes` BLOB NULL,
+  `queue` NVARCHAR(128) NOT NULL DEFAULT 'default',
+  `priority` INT NOT NULL DEFAULT 0,
   `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `completed_at` DATETIME NULL,
   `locked_until` DATETIME NULL,
@@ -13,7 +17,49 @@ CREATE TABLE IF NOT EXISTS `instances` (
 
   UNIQUE INDEX `idx_instances_instance_id` (`instance_id`),
   INDEX `idx_instances_locked_until_completed_at` (`completed_at`, `locked_until`, `sticky_until`, `worker`),
-  INDEX `idx_instances_parent_instance_id` (`parent_instance_id`)
+  INDEX `idx_instances_queue` (`queue`, `completed_at`),
+  INDEX `idx_instances_parent_instance_id` (`parent_instance_id`),
+  INDEX `idx_instances_created_at` (`created_at`, `instance_id`),
+  INDEX `idx_instances_workflow_name_created_at` (`workflow_name`, `created_at`)
+);
+
+
+CREATE TABLE IF NOT EXISTS `executions` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `instance_id` NVARCHAR(128) NOT NULL,
+  `execution_id` NVARCHAR(128) NOT NULL,
+  `workflow_name` NVARCHAR(256) NULL,
+  `parent_instance_id` NVARCHAR(128) NULL,
+  `parent_schedule_event_id` BIGINT NULL,
+  `metadata` BLOB NULL,
+  `search_attributes` BLOB NULL,
+  `created_at` DATETIME NOT NULL,
+  `completed_at` DATETIME NULL,
+
+  UNIQUE INDEX `idx_executions_instance_id_execution_id` (`instance_id`, `execution_id`)
+);
+
+
+CREATE TABLE IF NOT EXISTS `instance_search_attributes` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `instance_id` NVARCHAR(128) NOT NULL,
+  `name` NVARCHAR(128) NOT NULL,
+  `type` INT NOT NULL,
+  `value` NVARCHAR(256) NOT NULL,
+
+  UNIQUE INDEX `idx_instance_search_attributes_instance_id_name_value` (`instance_id`, `name`, `value`),
+  INDEX `idx_instance_search_attributes_name_type_value` (`name`, `type`, `value`)
+);
+
+
+CREATE TABLE IF NOT EXISTS `instance_metadata` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `instance_id` NVARCHAR(128) NOT NULL,
+  `name` NVARCHAR(128) NOT NULL,
+  `value` TEXT NOT NULL,
+
+  UNIQUE INDEX `idx_instance_metadata_instance_id_name` (`instance_id`, `name`),
+  INDEX `idx_instance_metadata_name_value` (`name`, `value`(255))
 );
 
 
@@ -29,7 +75,8 @@ CREATE TABLE IF NOT EXISTS `pending_events` (
   `visible_at` DATETIME NULL,
 
   INDEX `idx_pending_events_instance_id` (`instance_id`),
-  INDEX `idx_pending_events_instance_id_visible_at_schedule_event_id` (`instance_id`, `visible_at`, `schedule_event_id`)
+  INDEX `idx_pending_events_instance_id_visible_at_schedule_event_id` (`instance_id`, `visible_at`, `schedule_event_id`),
+  INDEX `idx_pending_events_event_type_visible_at` (`event_type`, `visible_at`)
 );
 
 
@@ -38,14 +85,15 @@ CREATE TABLE IF NOT EXISTS `history` (
   `event_id` NVARCHAR(64) NOT NULL,
   `sequence_id` BIGINT NOT NULL,
   `instance_id` NVARCHAR(128) NOT NULL,
+  `execution_id` NVARCHAR(128) NOT NULL,
   `event_type` INT NOT NULL,
   `timestamp` DATETIME NOT NULL,
   `schedule_event_id` BIGINT NOT NULL,
   `attributes` BLOB NOT NULL,
   `visible_at` DATETIME NULL, -- Is this required?
 
-  INDEX `idx_history_instance_id` (`instance_id`),
-  INDEX `idx_history_instance_id_sequence_id` (`instance_id`, `sequence_id`)
+  INDEX `idx_history_instance_id_execution_id` (`instance_id`, `execution_id`),
+  INDEX `idx_history_instance_id_execution_id_sequence_id` (`instance_id`, `execution_id`, `sequence_id`)
 );
 
 
@@ -61,7 +109,54 @@ CREATE TABLE IF NOT EXISTS `activities` (
   `visible_at` DATETIME NULL,
   `locked_until` DATETIME NULL,
   `worker` NVARCHAR(64) NULL,
+  `started_at` DATETIME NULL,
+  `last_heartbeat_at` DATETIME NULL,
+  `timeout_at` DATETIME NULL,
+  `heartbeat_details` BLOB NULL,
+  `completion_pending` BOOLEAN NOT NULL DEFAULT FALSE,
+  `queue` NVARCHAR(128) NOT NULL DEFAULT 'default',
+  `priority` INT NOT NULL DEFAULT 0,
 
   UNIQUE INDEX `idx_activities_instance_id` (`instance_id`, `activity_id`, `execution_id`, `worker`),
-  INDEX `idx_activities_locked_until` (`locked_until`)
-);
\ No newline at end of file
+  INDEX `idx_activities_locked_until` (`locked_until`),
+  INDEX `idx_activities_queue_locked_until` (`queue`, `locked_until`),
+  INDEX `idx_activities_timeout_at` (`timeout_at`)
+);
+
+
+CREATE TABLE IF NOT EXISTS `schedules` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `schedule_id` NVARCHAR(128) NOT NULL,
+  `spec` BLOB NOT NULL,
+  `workflow_name` NVARCHAR(256) NOT NULL,
+  `inputs` BLOB NOT NULL,
+  `paused` BOOLEAN NOT NULL DEFAULT FALSE,
+  `created_at` DATETIME NOT NULL,
+  `next_run_at` DATETIME NULL,
+  `buffered_run_at` DATETIME NULL,
+  `due_at` DATETIME NOT NULL,
+  `last_instance_id` NVARCHAR(128) NULL,
+  `last_execution_id` NVARCHAR(128) NULL,
+  `locked_until` DATETIME NULL,
+  `worker` NVARCHAR(64) NULL,
+
+  UNIQUE INDEX `idx_schedules_schedule_id` (`schedule_id`),
+  INDEX `idx_schedules_due_at` (`due_at`)
+);
+
+
+CREATE TABLE IF NOT EXISTS `activity_limits` (
+  `activity_name` NVARCHAR(256) NOT NULL PRIMARY KEY,
+  `tokens` DOUBLE NOT NULL DEFAULT 0,
+  `updated_at` DATETIME(6) NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS `activity_slots` (
+  `activity_name` NVARCHAR(256) NOT NULL,
+  `holder` NVARCHAR(128) NOT NULL,
+  `expires_at` DATETIME(6) NOT NULL,
+
+  PRIMARY KEY (`activity_name`, `holder`),
+  INDEX `idx_activity_slots_expires_at` (`expires_at`)
+);
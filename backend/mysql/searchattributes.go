@@ -0,0 +1,59 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+// upsertSearchAttributes adds the given search attributes to the workflow instance, or replaces existing ones with
+// the same names. Attributes are stored as JSON with the instance, and indexed by value in instance_search_attributes.
+func upsertSearchAttributes(ctx context.Context, tx *sql.Tx, instanceID string, attributes core.SearchAttributes) error {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	var data *string
+	if err := tx.QueryRowContext(ctx, "SELECT search_attributes FROM `instances` WHERE instance_id = ? FOR UPDATE", instanceID).Scan(&data); err != nil {
+		return fmt.Errorf("reading search attributes: %w", err)
+	}
+
+	var stored core.SearchAttributes
+	if data != nil {
+		if err := json.Unmarshal([]byte(*data), &stored); err != nil {
+			return fmt.Errorf("unmarshaling search attributes: %w", err)
+		}
+	}
+
+	updated, err := json.Marshal(stored.Upsert(attributes))
+	if err != nil {
+		return fmt.Errorf("marshaling search attributes: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE `instances` SET search_attributes = ? WHERE instance_id = ?", string(updated), instanceID); err != nil {
+		return fmt.Errorf("storing search attributes: %w", err)
+	}
+
+	for name, value := range attributes {
+		if _, err := tx.ExecContext(
+			ctx, "DELETE FROM `instance_search_attributes` WHERE instance_id = ? AND name = ?", instanceID, name,
+		); err != nil {
+			return fmt.Errorf("removing search attribute index: %w", err)
+		}
+
+		for _, v := range value.IndexValues() {
+			if _, err := tx.ExecContext(
+				ctx,
+				"INSERT IGNORE INTO `instance_search_attributes` (instance_id, name, type, value) VALUES (?, ?, ?, ?)",
+				instanceID, name, value.Type(), v,
+			); err != nil {
+				return fmt.Errorf("indexing search attribute: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
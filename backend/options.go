@@ -33,6 +33,10 @@ type Options struct {
 	// ActivityLockTimeout determines how long an activity task can be locked for. If the activity task is not completed
 	// by that timeframe, it's considered abandoned and another worker might pick it up
 	ActivityLockTimeout time.Duration
+
+	// PriorityStarvationTimeout determines how long a task can wait before it's handed out ahead of tasks with a
+	// higher priority. Tasks which exceed it are handed out oldest first, so that low priority tasks are not starved.
+	PriorityStarvationTimeout time.Duration
 }
 
 var DefaultOptions Options = Options{
@@ -40,6 +44,8 @@ var DefaultOptions Options = Options{
 	WorkflowLockTimeout: time.Minute,
 	ActivityLockTimeout: time.Minute * 2,
 
+	PriorityStarvationTimeout: time.Minute * 5,
+
 	Logger:         logger.NewDefaultLogger(),
 	Metrics:        mi.NewNoopMetricsClient(),
 	TracerProvider: trace.NewNoopTracerProvider(),
@@ -54,6 +60,12 @@ func WithStickyTimeout(timeout time.Duration) BackendOption {
 	}
 }
 
+func WithPriorityStarvationTimeout(timeout time.Duration) BackendOption {
+	return func(o *Options) {
+		o.PriorityStarvationTimeout = timeout
+	}
+}
+
 func WithLogger(logger log.Logger) BackendOption {
 	return func(o *Options) {
 		o.Logger = logger
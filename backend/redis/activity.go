@@ -2,15 +2,24 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/redis/go-redis/v9"
 )
 
-func (rb *redisBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
-	activityTask, err := rb.activityQueue.Dequeue(ctx, rb.rdb, rb.options.ActivityLockTimeout, rb.options.BlockTimeout)
+func (rb *redisBackend) GetActivityTask(ctx context.Context, queues []workflow.Queue) (*task.Activity, error) {
+	activityTask, err := rb.activityQueue.Dequeue(
+		ctx, rb.rdb, queues, rb.options.ActivityLockTimeout, rb.options.PriorityStarvationTimeout, rb.options.BlockTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -24,37 +33,621 @@ func (rb *redisBackend) GetActivityTask(ctx context.Context) (*task.Activity, er
 		return nil, fmt.Errorf("reading workflow instance for activity task: %w", err)
 	}
 
+	timedOut, err := rb.startActivity(ctx, activityTask.Data.Instance, activityTask.Data.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	// A previous worker might have recorded heartbeat details before it gave up the task
+	heartbeatDetails, err := rb.rdb.HGet(ctx, activityKey(activityTask.Data.Instance.InstanceID, activityTask.ID), "heartbeat_details").Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("reading heartbeat details: %w", err)
+	}
+
+	if heartbeatDetails != "" {
+		activityTask.Data.Event.Attributes.(*history.ActivityScheduledAttributes).HeartbeatDetails = payload.Payload(heartbeatDetails)
+	}
+
+	if instanceState.State == core.WorkflowInstanceStateFinished ||
+		instanceState.Instance.ExecutionID != activityTask.Data.Instance.ExecutionID || timedOut {
+		// Workflow instance has been terminated or continued as new, or the activity has timed out, discard the
+		// activity task
+		if _, err := rb.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.ZRem(ctx, activityTimeoutsKey(), activityKey(activityTask.Data.Instance.InstanceID, activityTask.ID))
+			p.Del(ctx, activityKey(activityTask.Data.Instance.InstanceID, activityTask.ID))
+
+			_, err := rb.activityQueue.Complete(ctx, p, activityTask.Queue, activityTask.Priority, activityTask.TaskID)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("discarding activity task: %w", err)
+		}
+
+		return nil, nil
+	}
+
 	return &task.Activity{
 		WorkflowInstance: activityTask.Data.Instance,
 		Metadata:         instanceState.Metadata,
-		ID:               activityTask.TaskID, // Use the queue generated ID here
+		ID:               queuedTaskID(activityTask.Queue, activityTask.Priority, activityTask.TaskID), // Use the queue generated ID here
 		Event:            activityTask.Data.Event,
 	}, nil
 }
 
-func (rb *redisBackend) ExtendActivityTask(ctx context.Context, activityID string) error {
+// SetActivityTaskPending removes the activity task from the queue, so it's not recovered by other workers when its
+// lock expires. The task is kept until it's completed, times out, or its workflow instance is terminated.
+func (rb *redisBackend) SetActivityTaskPending(ctx context.Context, activityID string) error {
+	queue, priority, taskID := parseQueuedTaskID(activityID)
+
+	// Only the worker holding the lock of the task can hand it off
+	owned, err := rb.activityQueue.Owned(ctx, rb.rdb, queue, priority, taskID)
+	if err != nil {
+		return err
+	}
+
+	if !owned {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	activityTask, err := rb.activityQueue.Data(ctx, rb.rdb, queue, priority, taskID)
+	if err != nil {
+		return err
+	}
+
+	if activityTask == nil {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	data, err := json.Marshal(activityTask)
+	if err != nil {
+		return fmt.Errorf("marshaling activity task: %w", err)
+	}
+
+	instanceID := activityTask.Data.Instance.InstanceID
+
+	state, err := readActivityTimeoutState(ctx, rb.rdb, activityTask.Data.Instance, activityTask.ID)
+	if err != nil {
+		return err
+	}
+
+	p := rb.rdb.TxPipeline()
+
+	p.Set(ctx, pendingActivityKey(activityID), string(data), 0)
+
+	// Track the pending task, so that it's removed when the activity times out or the instance is terminated
+	p.SAdd(ctx, pendingActivitiesKey(instanceID), activityID)
+
+	if state != nil {
+		p.HSet(ctx, activityKey(instanceID, activityTask.ID), "pending_task", activityID)
+	}
+
+	if _, err := rb.activityQueue.Complete(ctx, p, queue, priority, taskID); err != nil {
+		return err
+	}
+
+	if _, err := p.Exec(ctx); err != nil {
+		return fmt.Errorf("marking activity as pending: %w", err)
+	}
+
+	return nil
+}
+
+// activityTask returns the given activity task, either from the queue or, if it is waiting for asynchronous
+// completion, from its pending record. Returns nil if the task does not exist.
+func (rb *redisBackend) activityTask(ctx context.Context, activityID string) (*TaskItem[activityData], bool, error) {
+	queue, priority, taskID := parseQueuedTaskID(activityID)
+
+	activityTask, err := rb.activityQueue.Data(ctx, rb.rdb, queue, priority, taskID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if activityTask != nil {
+		return activityTask, false, nil
+	}
+
+	data, err := rb.rdb.Get(ctx, pendingActivityKey(activityID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+
+		return nil, false, fmt.Errorf("reading pending activity: %w", err)
+	}
+
+	var pendingTask TaskItem[activityData]
+	if err := json.Unmarshal([]byte(data), &pendingTask); err != nil {
+		return nil, false, fmt.Errorf("unmarshaling pending activity: %w", err)
+	}
+
+	return &pendingTask, true, nil
+}
+
+func (rb *redisBackend) ExtendActivityTask(ctx context.Context, activityID string, heartbeatDetails payload.Payload) error {
+	activityTask, pending, err := rb.activityTask(ctx, activityID)
+	if err != nil {
+		return err
+	}
+
+	if activityTask == nil {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	state, err := readActivityTimeoutState(ctx, rb.rdb, activityTask.Data.Instance, activityTask.ID)
+	if err != nil {
+		return err
+	}
+
+	if state != nil && state.TimedOut {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	canceled, err := rb.activityCanceled(ctx, activityTask.Data.Instance, activityTask.Data.Event.SequenceID)
+	if err != nil {
+		return err
+	}
+
 	p := rb.rdb.Pipeline()
 
-	if err := rb.activityQueue.Extend(ctx, p, activityID); err != nil {
+	if heartbeatDetails != nil {
+		p.HSet(ctx, activityKey(activityTask.Data.Instance.InstanceID, activityTask.ID), "heartbeat_details", string(heartbeatDetails))
+	}
+
+	if state != nil {
+		now := time.Now()
+		state.LastHeartbeatAt = &now
+		if err := updateActivityTimeoutP(ctx, p, state); err != nil {
+			return err
+		}
+	}
+
+	// Pending tasks are not in the queue anymore, there is no lock to extend
+	if !pending {
+		if err := rb.activityQueue.Extend(ctx, p, activityTask.Queue, activityTask.Priority, activityTask.TaskID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.Exec(ctx); err != nil {
 		return err
 	}
 
-	_, err := p.Exec(ctx)
-	return err
+	if canceled {
+		return backend.ErrActivityCanceled
+	}
+
+	return nil
+}
+
+func (rb *redisBackend) AbandonActivityTask(ctx context.Context, task *task.Activity) error {
+	queue, priority, taskID := parseQueuedTaskID(task.ID)
+
+	activityTask, err := rb.activityQueue.Data(ctx, rb.rdb, queue, priority, taskID)
+	if err != nil {
+		return err
+	}
+
+	if activityTask == nil {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	state, err := readActivityTimeoutState(ctx, rb.rdb, activityTask.Data.Instance, activityTask.ID)
+	if err != nil {
+		return err
+	}
+
+	if state != nil && state.TimedOut {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	if _, err := rb.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		// The activity is not running anymore, only the schedule-to-close timeout applies until it's started again
+		if state != nil {
+			state.StartedAt = nil
+			state.LastHeartbeatAt = nil
+			if err := updateActivityTimeoutP(ctx, p, state); err != nil {
+				return err
+			}
+		}
+
+		rb.activityQueue.Release(ctx, p, queue, priority, taskID)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("abandoning activity task: %w", err)
+	}
+
+	return nil
+}
+
+// activityCanceled returns true if the workflow instance which scheduled the activity with the given history sequence
+// id is not active anymore, or if it has been canceled after the activity was scheduled. Activities scheduled after the
+// cancellation, for example for cleanup, are not affected.
+func (rb *redisBackend) activityCanceled(ctx context.Context, instance *core.WorkflowInstance, scheduledSequenceID int64) (bool, error) {
+	instanceState, err := readInstance(ctx, rb.rdb, instance.InstanceID)
+	if err != nil {
+		if errors.Is(err, backend.ErrInstanceNotFound) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	if instanceState.State == core.WorkflowInstanceStateFinished || instanceState.Instance.ExecutionID != instance.ExecutionID {
+		return true, nil
+	}
+
+	// A sequence id of 0 marks a cancellation the workflow has not seen, yet
+	canceledSequenceID, err := rb.rdb.Get(ctx, canceledKey(instance.InstanceID, instance.ExecutionID)).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("checking for workflow cancellation: %w", err)
+	}
+
+	return canceledSequenceID == 0 || canceledSequenceID > scheduledSequenceID, nil
 }
 
 func (rb *redisBackend) CompleteActivityTask(ctx context.Context, instance *core.WorkflowInstance, activityID string, event *history.Event) error {
-	p := rb.rdb.TxPipeline()
+	// Watch the pending record of the activity, only one caller can complete a pending activity
+	for {
+		err := rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			return rb.completeActivityTask(ctx, tx, instance, activityID, event)
+		}, pendingActivityKey(activityID))
+
+		if err == redis.TxFailedErr {
+			continue
+		}
+
+		return err
+	}
+}
+
+func (rb *redisBackend) completeActivityTask(
+	ctx context.Context, tx *redis.Tx, instance *core.WorkflowInstance, activityID string, event *history.Event,
+) error {
+	activityTask, pending, err := rb.activityTask(ctx, activityID)
+	if err != nil {
+		return err
+	}
+
+	// Any caller knowing the instance and the id of the activity task can complete it, e.g., using a task token
+	if activityTask == nil || activityTask.Data.Instance.InstanceID != instance.InstanceID ||
+		activityTask.Data.Instance.ExecutionID != instance.ExecutionID {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	// Stop tracking the timeout of the activity. If the activity has already timed out, the result is discarded.
+	claimed, err := claimActivityCmd.Run(ctx, rb.rdb, []string{
+		activityTimeoutsKey(),
+		activityKey(instance.InstanceID, activityTask.ID),
+	}).Int()
+	if err != nil {
+		return fmt.Errorf("claiming activity: %w", err)
+	}
 
-	if err := rb.addWorkflowInstanceEventP(ctx, p, instance, event); err != nil {
+	instanceState, err := readInstance(ctx, rb.rdb, instance.InstanceID)
+	if err != nil {
 		return err
 	}
 
-	// Unlock activity
-	if _, err := rb.activityQueue.Complete(ctx, p, activityID); err != nil {
+	if _, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		if pending {
+			p.Del(ctx, pendingActivityKey(activityID))
+			p.SRem(ctx, pendingActivitiesKey(instance.InstanceID), activityID)
+		}
+
+		// Results of activities scheduled by a previous execution of a workflow instance continued as new are discarded
+		if claimed == 1 && instanceState.Instance.ExecutionID == activityTask.Data.Instance.ExecutionID {
+			if err := rb.addWorkflowInstanceEventP(ctx, p, instance, instanceState.Queue, instanceState.Priority, event); err != nil {
+				return err
+			}
+		}
+
+		// Unlock activity
+		if !pending {
+			if _, err := rb.activityQueue.Complete(ctx, p, activityTask.Queue, activityTask.Priority, activityTask.TaskID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	_, err := p.Exec(ctx)
-	return err
+	if claimed == 0 {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	return nil
+}
+
+type activityTimeoutState struct {
+	Instance        *core.WorkflowInstance `json:"instance,omitempty"`
+	Event           *history.Event         `json:"event,omitempty"`
+	StartedAt       *time.Time             `json:"started_at,omitempty"`
+	LastHeartbeatAt *time.Time             `json:"last_heartbeat_at,omitempty"`
+
+	TimedOut         bool            `json:"-"`
+	HeartbeatDetails payload.Payload `json:"-"`
+
+	// PendingTask is the id of the activity task, if it's waiting for asynchronous completion
+	PendingTask string `json:"-"`
+}
+
+func (s *activityTimeoutState) deadline() (time.Time, error) {
+	a, ok := s.Event.Attributes.(*history.ActivityScheduledAttributes)
+	if !ok {
+		return time.Time{}, fmt.Errorf("unexpected activity event attributes: %T", s.Event.Attributes)
+	}
+
+	deadline, _ := a.Timeouts.Deadline(s.Event.Timestamp, s.StartedAt, s.LastHeartbeatAt)
+	return deadline, nil
+}
+
+// trackActivityTimeoutP starts tracking the timeouts of the given activity, if it has any
+func trackActivityTimeoutP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) error {
+	a, ok := event.Attributes.(*history.ActivityScheduledAttributes)
+	if !ok || a.Timeouts == nil {
+		return nil
+	}
+
+	state := &activityTimeoutState{
+		Instance: instance,
+		Event:    event,
+	}
+
+	deadline, err := state.deadline()
+	if err != nil {
+		return err
+	}
+
+	if deadline.IsZero() {
+		return nil
+	}
+
+	key := activityKey(instance.InstanceID, event.ID)
+
+	if err := writeActivityTimeoutStateP(ctx, p, key, state); err != nil {
+		return err
+	}
+
+	p.ZAdd(ctx, activityTimeoutsKey(), redis.Z{
+		Member: key,
+		Score:  float64(deadline.UnixMilli()),
+	})
+
+	return nil
+}
+
+// startActivity records the start of the given activity. Returns true if the activity has already timed out.
+func (rb *redisBackend) startActivity(ctx context.Context, instance *core.WorkflowInstance, event *history.Event) (bool, error) {
+	state, err := readActivityTimeoutState(ctx, rb.rdb, instance, event.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if state == nil {
+		return false, nil
+	}
+
+	if state.TimedOut {
+		return true, nil
+	}
+
+	now := time.Now()
+	state.StartedAt = &now
+	state.LastHeartbeatAt = nil
+
+	if _, err := rb.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		return updateActivityTimeoutP(ctx, p, state)
+	}); err != nil {
+		return false, fmt.Errorf("updating activity timeout: %w", err)
+	}
+
+	return false, nil
+}
+
+// updateActivityTimeoutP stores the given state and recalculates the deadline of the activity. The deadline is only
+// updated if the activity has not timed out in the meantime.
+func updateActivityTimeoutP(ctx context.Context, p redis.Pipeliner, state *activityTimeoutState) error {
+	deadline, err := state.deadline()
+	if err != nil {
+		return err
+	}
+
+	key := activityKey(state.Instance.InstanceID, state.Event.ID)
+
+	if err := writeActivityTimeoutStateP(ctx, p, key, state); err != nil {
+		return err
+	}
+
+	if deadline.IsZero() {
+		p.ZRem(ctx, activityTimeoutsKey(), key)
+	} else {
+		p.ZAddXX(ctx, activityTimeoutsKey(), redis.Z{
+			Member: key,
+			Score:  float64(deadline.UnixMilli()),
+		})
+	}
+
+	return nil
+}
+
+func writeActivityTimeoutStateP(ctx context.Context, p redis.Pipeliner, key string, state *activityTimeoutState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshaling activity state: %w", err)
+	}
+
+	p.HSet(ctx, key, "state", string(data))
+
+	return nil
+}
+
+func readActivityTimeoutState(ctx context.Context, rdb redis.UniversalClient, instance *core.WorkflowInstance, activityID string) (*activityTimeoutState, error) {
+	return readActivityTimeoutStateByKey(ctx, rdb, activityKey(instance.InstanceID, activityID))
+}
+
+func readActivityTimeoutStateByKey(ctx context.Context, rdb redis.UniversalClient, key string) (*activityTimeoutState, error) {
+	vals, err := rdb.HMGet(ctx, key, "state", "timed_out", "heartbeat_details", "pending_task").Result()
+	if err != nil {
+		return nil, fmt.Errorf("reading activity state: %w", err)
+	}
+
+	data, ok := vals[0].(string)
+	if !ok {
+		// No timeouts are tracked for this activity
+		return nil, nil
+	}
+
+	var state activityTimeoutState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, fmt.Errorf("unmarshaling activity state: %w", err)
+	}
+
+	state.TimedOut = vals[1] != nil
+
+	if details, ok := vals[2].(string); ok {
+		state.HeartbeatDetails = payload.Payload(details)
+	}
+
+	if pendingTask, ok := vals[3].(string); ok {
+		state.PendingTask = pendingTask
+	}
+
+	return &state, nil
+}
+
+// Stop tracking the timeout of an activity. Returns 0 if the activity has already timed out.
+//
+// KEYS[1] - activity timeouts zset
+// KEYS[2] - activity state key
+var claimActivityCmd = redis.NewScript(`
+	if redis.call("HEXISTS", KEYS[2], "timed_out") == 1 then
+		return 0
+	end
+
+	redis.call("ZREM", KEYS[1], KEYS[2])
+	redis.call("DEL", KEYS[2])
+	return 1
+`)
+
+// Time out an activity, unless it has been completed in the meantime:
+// - Mark the activity as timed out
+// - Remove the activity task, if it's waiting for asynchronous completion
+// - Add the timeout event to pending event stream for workflow instance
+// - Try to queue workflow task for workflow instance
+//
+// KEYS[1] - activity timeouts zset
+// KEYS[2] - activity state key
+// KEYS[3] - pending events stream
+// KEYS[4] - workflow task queue stream
+// KEYS[5] - workflow task queue set
+// KEYS[6] - workflow task queue priorities set
+// KEYS[7] - pending activity key
+// KEYS[8] - pending activities set of the workflow instance
+// ARGV[1] - workflow instance id
+// ARGV[2] - serialized timeout event, empty if no event should be added
+// ARGV[3] - priority of the workflow instance
+// ARGV[4] - id of the activity task waiting for asynchronous completion, empty if none
+var timeoutActivityCmd = redis.NewScript(`
+	local removed = redis.call("ZREM", KEYS[1], KEYS[2])
+	if removed == 0 then
+		return 0
+	end
+
+	redis.call("HSET", KEYS[2], "timed_out", 1)
+
+	if ARGV[4] ~= "" then
+		redis.call("DEL", KEYS[7])
+		redis.call("SREM", KEYS[8], ARGV[4])
+	end
+
+	if ARGV[2] ~= "" then
+		redis.call("XADD", KEYS[3], "*", "event", ARGV[2])
+
+		local added = redis.call("SADD", KEYS[5], ARGV[1])
+		if added == 1 then
+			redis.call("SADD", KEYS[6], ARGV[3])
+			redis.call("XADD", KEYS[4], "*", "id", ARGV[1], "data", "")
+		end
+	end
+
+	return 1
+`)
+
+// timeoutActivities fails all activities of active workflow instances which have exceeded one of their timeouts
+func (rb *redisBackend) timeoutActivities(ctx context.Context, now time.Time) error {
+	keys, err := rb.rdb.ZRangeByScore(ctx, activityTimeoutsKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("finding timed out activities: %w", err)
+	}
+
+	for _, key := range keys {
+		state, err := readActivityTimeoutStateByKey(ctx, rb.rdb, key)
+		if err != nil {
+			return err
+		}
+
+		var eventData string
+		var instanceID string
+		var pendingTask string
+		var priority int
+		queueKeys := rb.workflowQueue.Keys(core.QueueDefault, priority)
+
+		if state != nil {
+			instanceID = state.Instance.InstanceID
+			pendingTask = state.PendingTask
+
+			instanceState, err := readInstance(ctx, rb.rdb, instanceID)
+			if err != nil && !errors.Is(err, backend.ErrInstanceNotFound) {
+				return err
+			}
+
+			if instanceState != nil {
+				priority = instanceState.Priority
+				queueKeys = rb.workflowQueue.Keys(instanceState.Queue, priority)
+			}
+
+			// Only notify the workflow instance if the execution that scheduled the activity is still running
+			if instanceState != nil &&
+				instanceState.State == core.WorkflowInstanceStateActive &&
+				instanceState.Instance.ExecutionID == state.Instance.ExecutionID {
+				a := state.Event.Attributes.(*history.ActivityScheduledAttributes)
+				_, timeoutType := a.Timeouts.Deadline(state.Event.Timestamp, state.StartedAt, state.LastHeartbeatAt)
+
+				heartbeatDetails := a.HeartbeatDetails
+				if state.HeartbeatDetails != nil {
+					heartbeatDetails = state.HeartbeatDetails
+				}
+
+				data, err := json.Marshal(history.NewActivityTimedOutEvent(now, state.Event.ScheduleEventID, timeoutType, heartbeatDetails))
+				if err != nil {
+					return fmt.Errorf("marshaling activity timeout event: %w", err)
+				}
+
+				eventData = string(data)
+			}
+		}
+
+		if _, err := timeoutActivityCmd.Run(ctx, rb.rdb, []string{
+			activityTimeoutsKey(),
+			key,
+			pendingEventsKey(instanceID),
+			queueKeys.StreamKey,
+			queueKeys.SetKey,
+			queueKeys.PrioritiesKey,
+			pendingActivityKey(pendingTask),
+			pendingActivitiesKey(instanceID),
+		}, instanceID, eventData, priority, pendingTask).Result(); err != nil && err != redis.Nil {
+			return fmt.Errorf("timing out activity: %w", err)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,102 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ backend.ActivityLimiter = (*redisBackend)(nil)
+
+// Acquire or extend an execution slot of an activity. Returns 1 if the slot is held.
+//
+// KEYS[1] - activity slots zset
+// ARGV[1] - holder
+// ARGV[2] - limit
+// ARGV[3] - current time in milliseconds
+// ARGV[4] - expiration of the slot in milliseconds
+var acquireActivitySlotCmd = redis.NewScript(`
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[3])
+
+	if redis.call("ZSCORE", KEYS[1], ARGV[1]) == false and redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+		return 0
+	end
+
+	redis.call("ZADD", KEYS[1], ARGV[4], ARGV[1])
+	redis.call("PEXPIREAT", KEYS[1], ARGV[4])
+	return 1
+`)
+
+func (rb *redisBackend) AcquireActivitySlot(ctx context.Context, activityName, holder string, limit int, lease time.Duration) (bool, error) {
+	now := time.Now()
+
+	acquired, err := acquireActivitySlotCmd.Run(ctx, rb.rdb, []string{activitySlotsKey(activityName)},
+		holder, limit, now.UnixMilli(), now.Add(lease).UnixMilli(),
+	).Int()
+	if err != nil {
+		return false, fmt.Errorf("acquiring activity slot: %w", err)
+	}
+
+	return acquired == 1, nil
+}
+
+func (rb *redisBackend) ReleaseActivitySlot(ctx context.Context, activityName, holder string) error {
+	if err := rb.rdb.ZRem(ctx, activitySlotsKey(activityName), holder).Err(); err != nil {
+		return fmt.Errorf("releasing activity slot: %w", err)
+	}
+
+	return nil
+}
+
+// Take a token from the bucket of an activity. Returns 0 if a token was taken, otherwise the number of
+// milliseconds until the next token is available.
+//
+// KEYS[1] - activity token bucket hash
+// ARGV[1] - rate in tokens per second
+// ARGV[2] - burst
+// ARGV[3] - current time in milliseconds
+var takeActivityTokenCmd = redis.NewScript(`
+	local rate = tonumber(ARGV[1])
+	local burst = tonumber(ARGV[2])
+	local now = tonumber(ARGV[3])
+
+	local tokens = burst
+	local updatedAt = now
+
+	local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated_at")
+	if bucket[1] then
+		tokens = tonumber(bucket[1])
+		updatedAt = tonumber(bucket[2])
+		if now > updatedAt then
+			tokens = math.min(burst, tokens + (now - updatedAt) / 1000 * rate)
+			updatedAt = now
+		end
+	end
+
+	if tokens < 1 then
+		return math.max(1, math.ceil((1 - tokens) / rate * 1000))
+	end
+
+	redis.call("HSET", KEYS[1], "tokens", tostring(tokens - 1), "updated_at", updatedAt)
+	-- Drop the bucket once it would be full again
+	redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000))
+	return 0
+`)
+
+func (rb *redisBackend) TakeActivityToken(ctx context.Context, activityName string, rate float64, burst int) (time.Duration, error) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	wait, err := takeActivityTokenCmd.Run(ctx, rb.rdb, []string{activityTokensKey(activityName)},
+		rate, burst, time.Now().UnixMilli(),
+	).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("taking activity token: %w", err)
+	}
+
+	return time.Duration(wait) * time.Millisecond, nil
+}
@@ -85,9 +85,10 @@ func (rb *redisBackend) GetWorkflowTree(ctx context.Context, instanceID string)
 
 func mapWorkflowInstance(instance *instanceState) *diag.WorkflowInstanceRef {
 	return &diag.WorkflowInstanceRef{
-		Instance:    instance.Instance,
-		CreatedAt:   instance.CreatedAt,
-		CompletedAt: instance.CompletedAt,
-		State:       instance.State,
+		Instance:         instance.Instance,
+		CreatedAt:        instance.CreatedAt,
+		CompletedAt:      instance.CompletedAt,
+		State:            instance.State,
+		SearchAttributes: instance.SearchAttributes,
 	}
 }
@@ -61,12 +61,23 @@ func addEventsToHistoryStreamP(ctx context.Context, p redis.Pipeliner, streamKey
 // ARGV[1] - timestamp
 // ARGV[2] - Instance ID
 // ARGV[3] - event payload
+// ARGV[4] - queue of the workflow instance
+// ARGV[5] - priority of the workflow instance
 var addFutureEventCmd = redis.NewScript(`
 	redis.call("ZADD", KEYS[1], ARGV[1], KEYS[2])
-	return redis.call("HSET", KEYS[2], "instance", ARGV[2], "event", ARGV[3])
+	return redis.call("HSET", KEYS[2], "instance", ARGV[2], "event", ARGV[3], "queue", ARGV[4], "priority", ARGV[5])
 `)
 
-func addFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) error {
+func addFutureEventP(
+	ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, queue core.Queue, priority int, event *history.Event,
+) error {
+	return addFutureEventWithKeyP(ctx, p, futureEventKey(instance.InstanceID, instance.ExecutionID, event.ScheduleEventID), instance, queue, priority, event)
+}
+
+// addFutureEventWithKeyP schedules a future event which isn't associated with other events via its ScheduleEventID
+func addFutureEventWithKeyP(
+	ctx context.Context, p redis.Pipeliner, key string, instance *core.WorkflowInstance, queue core.Queue, priority int, event *history.Event,
+) error {
 	eventData, err := json.Marshal(event)
 	if err != nil {
 		return err
@@ -74,10 +85,12 @@ func addFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.Work
 
 	addFutureEventCmd.Run(
 		ctx, p,
-		[]string{futureEventsKey(), futureEventKey(instance.InstanceID, event.ScheduleEventID)},
+		[]string{futureEventsKey(), key},
 		strconv.FormatInt(event.VisibleAt.UnixMilli(), 10),
 		instance.InstanceID,
 		string(eventData),
+		string(queue.OrDefault()),
+		priority,
 	)
 
 	return nil
@@ -92,6 +105,6 @@ var removeFutureEventCmd = redis.NewScript(`
 
 // removeFutureEvent removes a scheduled future event for the given event. Events are associated via their ScheduleEventID
 func removeFutureEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) {
-	key := futureEventKey(instance.InstanceID, event.ScheduleEventID)
+	key := futureEventKey(instance.InstanceID, instance.ExecutionID, event.ScheduleEventID)
 	removeFutureEventCmd.Run(ctx, p, []string{futureEventsKey(), key})
 }
@@ -0,0 +1,101 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/redis/go-redis/v9"
+)
+
+// The instance state contains the current execution of a workflow instance. When an execution is replaced, by
+// continuing as new or by starting a new execution with the ID of a finished instance, its state is moved to the
+// list of past executions. Histories are stored per execution, so past executions remain inspectable.
+
+func (rb *redisBackend) ListWorkflowInstanceExecutions(ctx context.Context, instanceID string) ([]*backend.WorkflowInstanceInfo, error) {
+	state, err := readInstance(ctx, rb.rdb, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	executions := []*backend.WorkflowInstanceInfo{instanceInfo(state)}
+
+	values, err := rb.rdb.LRange(ctx, instanceExecutionsKey(instanceID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("listing workflow instance executions: %w", err)
+	}
+
+	for _, v := range values {
+		var state instanceState
+		if err := json.Unmarshal([]byte(v), &state); err != nil {
+			return nil, fmt.Errorf("unmarshaling instance state: %w", err)
+		}
+
+		executions = append(executions, instanceInfo(&state))
+	}
+
+	return executions, nil
+}
+
+// executionFailed returns whether the given, finished execution has failed, or has been canceled or terminated
+func executionFailed(ctx context.Context, rdb redis.Cmdable, instance *core.WorkflowInstance) (bool, error) {
+	msgs, err := rdb.XRevRangeN(ctx, historyKey(instance.InstanceID, instance.ExecutionID), "+", "-", 1).Result()
+	if err != nil {
+		return false, fmt.Errorf("reading last history event: %w", err)
+	}
+
+	if len(msgs) == 0 {
+		return false, nil
+	}
+
+	var event *history.Event
+	if err := json.Unmarshal([]byte(msgs[0].Values["event"].(string)), &event); err != nil {
+		return false, fmt.Errorf("unmarshaling event: %w", err)
+	}
+
+	return history.ExecutionFailed(event), nil
+}
+
+// reuseInstanceP removes the given, finished execution of a workflow instance, so that a new execution can be created
+// using createInstanceP. Pending events of the previous execution are discarded.
+func reuseInstanceP(ctx context.Context, p redis.Pipeliner, state *instanceState) error {
+	instanceID := state.Instance.InstanceID
+
+	if err := archiveExecutionP(ctx, p, state); err != nil {
+		return err
+	}
+
+	// Remove the instance from all indexes, apart from the creation index which is updated with the new execution
+	p.ZRem(ctx, instancesByWorkflowName(state.WorkflowName), instanceID)
+	p.ZRem(ctx, instancesByState(state.State), instanceID)
+
+	if state.Instance.SubWorkflow() {
+		p.ZRem(ctx, instancesByParent(state.Instance.ParentInstanceID), instanceID)
+	}
+
+	for name, value := range state.SearchAttributes {
+		for _, v := range value.IndexValues() {
+			p.ZRem(ctx, instancesBySearchAttribute(name, value.Type(), v), instanceID)
+		}
+	}
+
+	p.Del(ctx, pendingEventsKey(instanceID), instanceKey(instanceID))
+	removeExecutionTimeoutP(ctx, p, state.Instance)
+
+	return nil
+}
+
+// archiveExecutionP adds the given execution to the past executions of the workflow instance
+func archiveExecutionP(ctx context.Context, p redis.Pipeliner, state *instanceState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshaling instance state: %w", err)
+	}
+
+	p.LPush(ctx, instanceExecutionsKey(state.Instance.InstanceID), string(b))
+
+	return nil
+}
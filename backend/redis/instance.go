@@ -3,7 +3,9 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/cschleiden/go-workflows/backend"
@@ -14,49 +16,219 @@ import (
 )
 
 func (rb *redisBackend) CreateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event) error {
-	state, err := readInstance(ctx, rb.rdb, instance.InstanceID)
-	if err != nil && err != backend.ErrInstanceNotFound {
-		return err
-	}
+	a := event.Attributes.(*history.ExecutionStartedAttributes)
 
-	if state != nil {
-		return backend.ErrInstanceAlreadyExists
+	key := instanceKey(instance.InstanceID)
+
+	// Watch the instance, the transaction fails if it's created or replaced concurrently
+	for {
+		err := rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			state, err := readInstancePipelineCmd(tx.Get(ctx, key))
+			if err != nil && !errors.Is(err, backend.ErrInstanceNotFound) {
+				return err
+			}
+
+			var termination *instanceTermination
+
+			if state != nil {
+				allowed, err := a.IDReusePolicy.AllowsReuse(state.State, func() (bool, error) {
+					return executionFailed(ctx, tx, state.Instance)
+				})
+				if err != nil {
+					return err
+				}
+
+				if !allowed {
+					return backend.ErrInstanceAlreadyExists
+				}
+
+				if state.State == core.WorkflowInstanceStateActive {
+					termination, err = rb.readTermination(
+						ctx, instance.InstanceID, history.NewWorkflowTerminationEvent(time.Now(), "replaced by new execution"), true)
+					if err != nil {
+						if errors.Is(err, backend.ErrInstanceNotActive) {
+							// Execution finished concurrently, try again
+							return redis.TxFailedErr
+						}
+
+						return fmt.Errorf("terminating running execution: %w", err)
+					}
+
+					state = termination.state
+				}
+			}
+
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				if termination != nil {
+					if err := rb.terminateInstanceP(ctx, p, termination); err != nil {
+						return fmt.Errorf("terminating running execution: %w", err)
+					}
+				}
+
+				if state != nil {
+					// Replace the finished execution
+					if err := reuseInstanceP(ctx, p, state); err != nil {
+						return err
+					}
+				}
+
+				if err := createInstanceP(ctx, p, instance, a, false); err != nil {
+					return err
+				}
+
+				if err := rb.addStartedEventP(ctx, p, instance, event); err != nil {
+					return fmt.Errorf("queueing workflow task: %w", err)
+				}
+
+				return nil
+			})
+
+			return err
+		}, key)
+
+		if err == redis.TxFailedErr {
+			continue
+		}
+
+		if err != nil {
+			if errors.Is(err, backend.ErrInstanceAlreadyExists) {
+				return err
+			}
+
+			return fmt.Errorf("creating workflow instance: %w", err)
+		}
+
+		break
 	}
 
-	p := rb.rdb.TxPipeline()
+	rb.options.Logger.Debug("Created new workflow instance")
+
+	return nil
+}
 
-	if err := createInstanceP(ctx, p, instance, event.Attributes.(*history.ExecutionStartedAttributes).Metadata, false); err != nil {
+// addStartedEventP adds the event starting a new execution to the pending events of the instance. Executions with a
+// delayed start are started by a future event once the event becomes visible.
+func (rb *redisBackend) addStartedEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) error {
+	if err := addExecutionTimeoutP(ctx, p, instance, event); err != nil {
 		return err
 	}
 
-	// Create event stream
-	eventData, err := json.Marshal(event)
-	if err != nil {
-		return err
+	a := event.Attributes.(*history.ExecutionStartedAttributes)
+
+	if event.VisibleAt != nil {
+		return addFutureEventP(ctx, p, instance, a.Queue, a.Priority, event)
 	}
 
-	p.XAdd(ctx, &redis.XAddArgs{
-		Stream: pendingEventsKey(instance.InstanceID),
-		ID:     "*",
-		Values: map[string]interface{}{
-			"event": string(eventData),
-		},
-	})
+	return rb.addWorkflowInstanceEventP(ctx, p, instance, a.Queue, a.Priority, event)
+}
 
-	// Queue workflow instance task
-	if err := rb.workflowQueue.Enqueue(ctx, p, instance.InstanceID, nil); err != nil {
-		return fmt.Errorf("queueing workflow task: %w", err)
+// addExecutionTimeoutP schedules the timeout of the instance started by the given event, if it has an execution
+// timeout. Instances terminated on timeout are terminated by the backend, instances canceled on timeout receive a
+// future event.
+func addExecutionTimeoutP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, startedEvent *history.Event) error {
+	timeoutEvent := history.NewExecutionTimeoutEvent(startedEvent)
+	if timeoutEvent == nil {
+		return nil
 	}
 
-	if _, err := p.Exec(ctx); err != nil {
-		return fmt.Errorf("creating workflow instance: %w", err)
+	a := startedEvent.Attributes.(*history.ExecutionStartedAttributes)
+
+	if a.ExecutionTimeoutAction == core.ExecutionTimeoutActionTerminate {
+		p.ZAdd(ctx, executionTimeoutsKey(), redis.Z{
+			Member: instance.InstanceID,
+			Score:  float64(timeoutEvent.VisibleAt.UnixMilli()),
+		})
+
+		return nil
 	}
 
-	rb.options.Logger.Debug("Created new workflow instance")
+	return addFutureEventWithKeyP(ctx, p, executionTimeoutKey(instance.InstanceID), instance, a.Queue, a.Priority, timeoutEvent)
+}
+
+func removeExecutionTimeoutP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance) {
+	removeFutureEventCmd.Run(ctx, p, []string{futureEventsKey(), executionTimeoutKey(instance.InstanceID)})
+	p.ZRem(ctx, executionTimeoutsKey(), instance.InstanceID)
+}
+
+// timeoutWorkflowInstances terminates all workflow instances, and their sub-workflow instances, which have exceeded
+// their execution timeout and are terminated on timeout
+func (rb *redisBackend) timeoutWorkflowInstances(ctx context.Context, now time.Time) error {
+	instanceIDs, err := rb.rdb.ZRangeByScore(ctx, executionTimeoutsKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("finding timed out workflow instances: %w", err)
+	}
+
+	for _, instanceID := range instanceIDs {
+		if err := rb.terminateTimedOutInstance(ctx, instanceID, now); err != nil {
+			return fmt.Errorf("terminating workflow instance %s: %w", instanceID, err)
+		}
+	}
 
 	return nil
 }
 
+func (rb *redisBackend) terminateTimedOutInstance(ctx context.Context, instanceID string, now time.Time) error {
+	// Watch the instance, the transaction fails if it finishes or is terminated concurrently
+	for {
+		err := rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			terminations, err := rb.readTerminationTree(ctx, instanceID, history.NewWorkflowTimeoutTerminationEvent(now), true)
+			if err != nil {
+				if errors.Is(err, backend.ErrInstanceNotFound) || errors.Is(err, backend.ErrInstanceNotActive) {
+					// Instance has finished before it timed out
+					return tx.ZRem(ctx, executionTimeoutsKey(), instanceID).Err()
+				}
+
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				for _, t := range terminations {
+					if err := rb.terminateInstanceP(ctx, p, t); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+
+			return err
+		}, instanceKey(instanceID))
+
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+
+		return err
+	}
+}
+
+// Starts an instance with a delayed start right away, by moving its future started event to the pending events
+//
+// KEYS[1] - future event zset key
+// KEYS[2] - future event key of the started event
+// KEYS[3] - pending events stream
+var startDelayedInstanceCmd = redis.NewScript(`
+	local eventData = redis.call("HGET", KEYS[2], "event")
+	if eventData then
+		redis.call("XADD", KEYS[3], "*", "event", eventData)
+		redis.call("DEL", KEYS[2])
+		redis.call("ZREM", KEYS[1], KEYS[2])
+	end
+
+	return 0
+`)
+
+func startDelayedInstanceP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance) {
+	startDelayedInstanceCmd.Run(ctx, p, []string{
+		futureEventsKey(),
+		futureEventKey(instance.InstanceID, instance.ExecutionID, 0),
+		pendingEventsKey(instance.InstanceID),
+	})
+}
+
 func (rb *redisBackend) GetWorkflowInstanceHistory(ctx context.Context, instance *core.WorkflowInstance, lastSequenceID *int64) ([]*history.Event, error) {
 	start := "-"
 
@@ -64,7 +236,7 @@ func (rb *redisBackend) GetWorkflowInstanceHistory(ctx context.Context, instance
 		start = "(" + historyID(*lastSequenceID)
 	}
 
-	msgs, err := rb.rdb.XRange(ctx, historyKey(instance.InstanceID), start, "+").Result()
+	msgs, err := rb.rdb.XRange(ctx, historyKey(instance.InstanceID, instance.ExecutionID), start, "+").Result()
 	if err != nil {
 		return nil, err
 	}
@@ -88,19 +260,30 @@ func (rb *redisBackend) GetWorkflowInstanceState(ctx context.Context, instance *
 		return core.WorkflowInstanceStateActive, err
 	}
 
+	// If the instance has been continued as new, the requested execution is finished
+	if instanceState.Instance.ExecutionID != instance.ExecutionID {
+		return core.WorkflowInstanceStateFinished, nil
+	}
+
 	return instanceState.State, nil
 }
 
 func (rb *redisBackend) CancelWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, event *history.Event) error {
 	// Read the instance to check if it exists
-	_, err := readInstance(ctx, rb.rdb, instance.InstanceID)
+	state, err := readInstance(ctx, rb.rdb, instance.InstanceID)
 	if err != nil {
 		return err
 	}
 
 	// Cancel instance
 	if cmds, err := rb.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
-		return rb.addWorkflowInstanceEventP(ctx, p, instance, event)
+		// Record the cancellation, running activities are canceled on their next heartbeat
+		markCanceledP(ctx, p, state.Instance)
+
+		// Instances with a delayed start are started right away, their workflow observes the cancellation
+		startDelayedInstanceP(ctx, p, state.Instance)
+
+		return rb.addWorkflowInstanceEventP(ctx, p, instance, state.Queue, state.Priority, event)
 	}); err != nil {
 		fmt.Println(cmds)
 		return fmt.Errorf("adding cancellation event to workflow instance: %w", err)
@@ -109,28 +292,261 @@ func (rb *redisBackend) CancelWorkflowInstance(ctx context.Context, instance *co
 	return nil
 }
 
+// instanceTermination is the state read to terminate a workflow instance as part of a transaction
+type instanceTermination struct {
+	state *instanceState
+	event *history.Event
+
+	// timers are the timer events which might still be scheduled
+	timers []*history.Event
+
+	// pendingActivities are the activity tasks waiting for asynchronous completion
+	pendingActivities []string
+
+	// parentState is the state of the parent workflow instance to notify, if any
+	parentState *instanceState
+}
+
+// readTermination reads the state required to terminate the given workflow instance. If notifyParent is set, the
+// parent workflow instance is notified of the termination, if it's still running.
+func (rb *redisBackend) readTermination(
+	ctx context.Context, instanceID string, event *history.Event, notifyParent bool,
+) (*instanceTermination, error) {
+	state, err := readInstance(ctx, rb.rdb, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.State == core.WorkflowInstanceStateFinished {
+		return nil, backend.ErrInstanceNotActive
+	}
+
+	// Find all timers which might still be scheduled
+	h, err := rb.GetWorkflowInstanceHistory(ctx, state.Instance, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting workflow history: %w", err)
+	}
+
+	t := &instanceTermination{
+		state: state,
+		event: event,
+	}
+
+	for _, e := range h {
+		if e.Type == history.EventType_TimerScheduled {
+			t.timers = append(t.timers, e)
+		}
+	}
+
+	t.pendingActivities, err = rb.rdb.SMembers(ctx, pendingActivitiesKey(instanceID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("reading pending activities: %w", err)
+	}
+
+	if notifyParent && state.Instance.SubWorkflow() {
+		parentState, err := readInstance(ctx, rb.rdb, state.Instance.ParentInstanceID)
+		if err != nil && !errors.Is(err, backend.ErrInstanceNotFound) {
+			return nil, fmt.Errorf("reading parent workflow instance: %w", err)
+		}
+
+		if parentState != nil && parentState.State == core.WorkflowInstanceStateActive {
+			t.parentState = parentState
+		}
+	}
+
+	return t, nil
+}
+
+// readTerminationTree reads the state required to terminate the given workflow instance, and all of its running
+// sub-workflow instances. Sub-workflow instances don't notify their parents, which are terminated along with them.
+func (rb *redisBackend) readTerminationTree(
+	ctx context.Context, instanceID string, event *history.Event, notifyParent bool,
+) ([]*instanceTermination, error) {
+	t, err := rb.readTermination(ctx, instanceID, event, notifyParent)
+	if err != nil {
+		return nil, err
+	}
+
+	terminations := []*instanceTermination{t}
+
+	subInstanceIDs, err := rb.rdb.ZRange(ctx, instancesByParent(instanceID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("finding sub-workflow instances: %w", err)
+	}
+
+	a := event.Attributes.(*history.ExecutionTerminatedAttributes)
+	for _, subInstanceID := range subInstanceIDs {
+		state, err := readInstance(ctx, rb.rdb, subInstanceID)
+		if err != nil {
+			if errors.Is(err, backend.ErrInstanceNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		// The index is not updated when a sub-workflow instance finishes
+		if state.State != core.WorkflowInstanceStateActive || state.Instance.ParentInstanceID != instanceID {
+			continue
+		}
+
+		subTerminations, err := rb.readTerminationTree(
+			ctx, subInstanceID, history.NewWorkflowTerminationEvent(event.Timestamp, a.Reason), false,
+		)
+		if err != nil {
+			if errors.Is(err, backend.ErrInstanceNotActive) {
+				continue
+			}
+
+			return nil, fmt.Errorf("terminating sub-workflow instance %s: %w", subInstanceID, err)
+		}
+
+		terminations = append(terminations, subTerminations...)
+	}
+
+	return terminations, nil
+}
+
+func (rb *redisBackend) TerminateWorkflowInstance(ctx context.Context, instance *core.WorkflowInstance, event *history.Event, subWorkflows bool) error {
+	var terminations []*instanceTermination
+	if subWorkflows {
+		var err error
+		terminations, err = rb.readTerminationTree(ctx, instance.InstanceID, event, true)
+		if err != nil {
+			return err
+		}
+	} else {
+		t, err := rb.readTermination(ctx, instance.InstanceID, event, true)
+		if err != nil {
+			return err
+		}
+
+		terminations = []*instanceTermination{t}
+	}
+
+	// Only the current execution of the instance can be terminated
+	if terminations[0].state.Instance.ExecutionID != instance.ExecutionID {
+		return backend.ErrInstanceNotActive
+	}
+
+	// Terminate the instance and its sub-workflow instances in a single transaction
+	p := rb.rdb.TxPipeline()
+
+	for _, t := range terminations {
+		if err := rb.terminateInstanceP(ctx, p, t); err != nil {
+			return err
+		}
+	}
+
+	if _, err := p.Exec(ctx); err != nil {
+		return fmt.Errorf("terminating workflow instance: %w", err)
+	}
+
+	return nil
+}
+
+// terminateInstanceP adds the commands terminating a workflow instance to the given pipeline
+func (rb *redisBackend) terminateInstanceP(ctx context.Context, p redis.Pipeliner, t *instanceTermination) error {
+	state := t.state
+	event := t.event
+
+	// Use the stored instance, it contains the parent information and the current execution
+	instance := state.Instance
+
+	// Add termination event to the end of the history
+	event.SequenceID = state.LastSequenceID + 1
+	if err := addEventsToHistoryStreamP(ctx, p, historyKey(instance.InstanceID, instance.ExecutionID), []*history.Event{event}); err != nil {
+		return fmt.Errorf("adding termination event to history: %w", err)
+	}
+
+	// Remove pending events and timers. Activity tasks are discarded when they are dequeued, activity tasks waiting
+	// for asynchronous completion are removed.
+	p.Del(ctx, pendingEventsKey(instance.InstanceID))
+
+	for _, activityID := range t.pendingActivities {
+		p.Del(ctx, pendingActivityKey(activityID))
+	}
+
+	p.Del(ctx, pendingActivitiesKey(instance.InstanceID))
+
+	for _, e := range t.timers {
+		removeFutureEventP(ctx, p, instance, e)
+	}
+
+	// Remove the started event of an instance with a delayed start
+	removeFutureEventCmd.Run(ctx, p, []string{futureEventsKey(), futureEventKey(instance.InstanceID, instance.ExecutionID, 0)})
+	removeExecutionTimeoutP(ctx, p, instance)
+
+	now := time.Now()
+	state.State = core.WorkflowInstanceStateFinished
+	state.CompletedAt = &now
+	state.LastSequenceID = event.SequenceID
+
+	if err := updateInstanceP(ctx, p, instance.InstanceID, state); err != nil {
+		return fmt.Errorf("updating workflow instance: %w", err)
+	}
+
+	// Notify parent, if it's still running
+	if t.parentState != nil {
+		a := event.Attributes.(*history.ExecutionTerminatedAttributes)
+		if err := rb.addWorkflowInstanceEventP(
+			ctx, p, t.parentState.Instance, t.parentState.Queue, t.parentState.Priority,
+			history.NewSubWorkflowTerminatedEvent(now, instance.ParentEventID, a),
+		); err != nil {
+			return fmt.Errorf("notifying parent workflow instance: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// markCanceledP records a requested cancellation of the given workflow instance. Until the workflow has seen the
+// cancellation, all of its activities are canceled.
+func markCanceledP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance) {
+	p.SetNX(ctx, canceledKey(instance.InstanceID, instance.ExecutionID), 0, 0)
+}
+
+// recordCancellationP records the sequence id of the cancellation event in the workflow instance's history. Only
+// activities scheduled before the cancellation are canceled from then on.
+func recordCancellationP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) {
+	p.Set(ctx, canceledKey(instance.InstanceID, instance.ExecutionID), event.SequenceID, 0)
+}
+
 type instanceState struct {
-	Instance *core.WorkflowInstance     `json:"instance,omitempty"`
-	State    core.WorkflowInstanceState `json:"state,omitempty"`
+	Instance     *core.WorkflowInstance     `json:"instance,omitempty"`
+	WorkflowName string                     `json:"workflow_name,omitempty"`
+	State        core.WorkflowInstanceState `json:"state,omitempty"`
 
 	Metadata *core.WorkflowMetadata `json:"metadata,omitempty"`
 
+	SearchAttributes core.SearchAttributes `json:"search_attributes,omitempty"`
+
+	// Queue is the queue workflow tasks of the instance are routed to
+	Queue core.Queue `json:"queue,omitempty"`
+
+	// Priority is the priority of workflow tasks of the instance
+	Priority int `json:"priority,omitempty"`
+
 	CreatedAt   time.Time  `json:"created_at,omitempty"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 
 	LastSequenceID int64 `json:"last_sequence_id,omitempty"`
 }
 
-func createInstanceP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, metadata *core.WorkflowMetadata, ignoreDuplicate bool) error {
+func createInstanceP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, a *history.ExecutionStartedAttributes, ignoreDuplicate bool) error {
 	key := instanceKey(instance.InstanceID)
 
 	createdAt := time.Now()
 
 	b, err := json.Marshal(&instanceState{
-		Instance:  instance,
-		State:     core.WorkflowInstanceStateActive,
-		Metadata:  metadata,
-		CreatedAt: createdAt,
+		Instance:         instance,
+		WorkflowName:     a.Name,
+		State:            core.WorkflowInstanceStateActive,
+		Metadata:         a.Metadata,
+		SearchAttributes: a.SearchAttributes,
+		Queue:            a.Queue.OrDefault(),
+		Priority:         a.Priority,
+		CreatedAt:        createdAt,
 	})
 	if err != nil {
 		return fmt.Errorf("marshaling instance state: %w", err)
@@ -138,14 +554,65 @@ func createInstanceP(ctx context.Context, p redis.Pipeliner, instance *core.Work
 
 	p.SetNX(ctx, key, string(b), 0)
 
-	p.ZAdd(ctx, instancesByCreation(), redis.Z{
+	// Secondary indexes used for listing instances, all scored by creation time
+	z := redis.Z{
 		Member: instance.InstanceID,
 		Score:  float64(createdAt.UnixMilli()),
-	})
+	}
+
+	p.ZAdd(ctx, instancesByCreation(), z)
+	p.ZAdd(ctx, instancesByWorkflowName(a.Name), z)
+	p.ZAdd(ctx, instancesByState(core.WorkflowInstanceStateActive), z)
+
+	if instance.SubWorkflow() {
+		p.ZAdd(ctx, instancesByParent(instance.ParentInstanceID), z)
+	}
+
+	for name, value := range a.SearchAttributes {
+		for _, v := range value.IndexValues() {
+			p.ZAdd(ctx, instancesBySearchAttribute(name, value.Type(), v), z)
+		}
+	}
 
 	return nil
 }
 
+// upsertSearchAttributesP adds the given search attributes to the instance state, or replaces existing ones with
+// the same names, and updates their indexes. The instance state needs to be written afterwards.
+func upsertSearchAttributesP(ctx context.Context, p redis.Pipeliner, state *instanceState, attributes core.SearchAttributes) {
+	instanceID := state.Instance.InstanceID
+
+	for name, value := range attributes {
+		if old, ok := state.SearchAttributes[name]; ok {
+			for _, v := range old.IndexValues() {
+				p.ZRem(ctx, instancesBySearchAttribute(name, old.Type(), v), instanceID)
+			}
+		}
+
+		for _, v := range value.IndexValues() {
+			p.ZAdd(ctx, instancesBySearchAttribute(name, value.Type(), v), redis.Z{
+				Member: instanceID,
+				Score:  float64(state.CreatedAt.UnixMilli()),
+			})
+		}
+	}
+
+	state.SearchAttributes = state.SearchAttributes.Upsert(attributes)
+}
+
+// replaceSearchAttributesP replaces all search attributes of the instance state with the given attributes, and
+// updates their indexes. The instance state needs to be written afterwards.
+func replaceSearchAttributesP(ctx context.Context, p redis.Pipeliner, state *instanceState, attributes core.SearchAttributes) {
+	for name, value := range state.SearchAttributes {
+		for _, v := range value.IndexValues() {
+			p.ZRem(ctx, instancesBySearchAttribute(name, value.Type(), v), state.Instance.InstanceID)
+		}
+	}
+
+	state.SearchAttributes = nil
+	upsertSearchAttributesP(ctx, p, state, attributes)
+}
+
 func updateInstanceP(ctx context.Context, p redis.Pipeliner, instanceID string, state *instanceState) error {
 	key := instanceKey(instanceID)
 
@@ -156,11 +623,34 @@ func updateInstanceP(ctx context.Context, p redis.Pipeliner, instanceID string,
 
 	p.Set(ctx, key, string(b), 0)
 
-	// CreatedAt does not change, so skip updating the instancesByCreation() ZSET
+	// CreatedAt does not change, so skip updating the instancesByCreation() ZSET, only move the instance to the
+	// index of its current state
+	for _, s := range []core.WorkflowInstanceState{core.WorkflowInstanceStateActive, core.WorkflowInstanceStateFinished} {
+		if s == state.State {
+			p.ZAdd(ctx, instancesByState(s), redis.Z{Member: instanceID, Score: float64(state.CreatedAt.UnixMilli())})
+		} else {
+			p.ZRem(ctx, instancesByState(s), instanceID)
+		}
+	}
 
 	return nil
 }
 
+// instanceQueue returns the queue and the priority of workflow tasks of the given workflow instance. Events for
+// instances which don't exist are queued on the default queue.
+func instanceQueue(ctx context.Context, rdb redis.UniversalClient, instanceID string) (core.Queue, int, error) {
+	state, err := readInstance(ctx, rdb, instanceID)
+	if err != nil {
+		if errors.Is(err, backend.ErrInstanceNotFound) {
+			return core.QueueDefault, 0, nil
+		}
+
+		return "", 0, err
+	}
+
+	return state.Queue.OrDefault(), state.Priority, nil
+}
+
 func readInstance(ctx context.Context, rdb redis.UniversalClient, instanceID string) (*instanceState, error) {
 	p := rdb.Pipeline()
 
@@ -2,22 +2,45 @@ package redis
 
 import (
 	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/core"
 )
 
 func instanceKey(instanceID string) string {
 	return fmt.Sprintf("instance:%v", instanceID)
 }
 
+// instanceExecutionsKey stores past executions of a workflow instance, newest first
+func instanceExecutionsKey(instanceID string) string {
+	return fmt.Sprintf("instance-executions:%v", instanceID)
+}
+
 func instancesByCreation() string {
 	return "instances-by-creation"
 }
 
+func instancesByWorkflowName(workflowName string) string {
+	return fmt.Sprintf("instances-by-workflow-name:%v", workflowName)
+}
+
+func instancesByParent(parentInstanceID string) string {
+	return fmt.Sprintf("instances-by-parent:%v", parentInstanceID)
+}
+
+func instancesByState(state core.WorkflowInstanceState) string {
+	return fmt.Sprintf("instances-by-state:%v", state)
+}
+
+func instancesBySearchAttribute(name string, t core.SearchAttributeType, value string) string {
+	return fmt.Sprintf("instances-by-search-attribute:%v:%v:%v", name, t, value)
+}
+
 func pendingEventsKey(instanceID string) string {
 	return fmt.Sprintf("pending-events:%v", instanceID)
 }
 
-func historyKey(instanceID string) string {
-	return fmt.Sprintf("history:%v", instanceID)
+func historyKey(instanceID, executionID string) string {
+	return fmt.Sprintf("history:%v:%v", instanceID, executionID)
 }
 
 func historyID(sequenceID int64) string {
@@ -28,6 +51,72 @@ func futureEventsKey() string {
 	return "future-events"
 }
 
-func futureEventKey(instanceID string, scheduleEventID int64) string {
-	return fmt.Sprintf("future-event:%v:%v", instanceID, scheduleEventID)
+// futureEventKey stores a future event of an execution of a workflow instance, e.g., a timer
+func futureEventKey(instanceID, executionID string, scheduleEventID int64) string {
+	return fmt.Sprintf("future-event:%v:%v:%v", instanceID, executionID, scheduleEventID)
+}
+
+func executionTimeoutKey(instanceID string) string {
+	return fmt.Sprintf("future-event:%v:execution-timeout", instanceID)
+}
+
+// executionTimeoutsKey stores the workflow instances which are terminated when they exceed their execution timeout,
+// scored by the time they time out
+func executionTimeoutsKey() string {
+	return "execution-timeouts"
+}
+
+func activityTimeoutsKey() string {
+	return "activity-timeouts"
+}
+
+func activityKey(instanceID, activityID string) string {
+	return fmt.Sprintf("activity:%v:%v", instanceID, activityID)
+}
+
+// pendingActivityKey stores an activity task which is waiting for asynchronous completion
+func pendingActivityKey(taskID string) string {
+	return fmt.Sprintf("pending-activity:%v", taskID)
+}
+
+// pendingActivitiesKey stores the activity tasks of a workflow instance which are waiting for asynchronous completion
+func pendingActivitiesKey(instanceID string) string {
+	return fmt.Sprintf("pending-activities:%v", instanceID)
+}
+
+func canceledKey(instanceID, executionID string) string {
+	return fmt.Sprintf("canceled:%v:%v", instanceID, executionID)
+}
+
+// scheduleKeyPrefix is the prefix of schedule keys, for scripts which need to build them
+func scheduleKeyPrefix() string {
+	return "schedule:"
+}
+
+func scheduleKey(scheduleID string) string {
+	return scheduleKeyPrefix() + scheduleID
+}
+
+// scheduleLockKeyPrefix is the prefix of schedule lock keys, for scripts which need to build them
+func scheduleLockKeyPrefix() string {
+	return "schedule-lock:"
+}
+
+// scheduleLockKey stores the worker holding the lock of a schedule, it expires with the lock
+func scheduleLockKey(scheduleID string) string {
+	return scheduleLockKeyPrefix() + scheduleID
+}
+
+func schedulesKey() string {
+	return "schedules"
+}
+
+// activitySlotsKey stores the holders of the execution slots of an activity, scored by their expiration
+func activitySlotsKey(activityName string) string {
+	return fmt.Sprintf("activity-slots:%v", activityName)
+}
+
+// activityTokensKey stores the token bucket of an activity
+func activityTokensKey(activityName string) string {
+	return fmt.Sprintf("activity-tokens:%v", activityName)
 }
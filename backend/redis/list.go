@@ -0,0 +1,231 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/redis/go-redis/v9"
+)
+
+// Instances are listed by scanning the most selective secondary index matching the filter, newest first. All
+// indexes are scored by creation time, so created time ranges restrict the scanned range, all other conditions
+// are checked on the stored instance state.
+
+const listBatchSize = 100
+
+func (rb *redisBackend) ListWorkflowInstances(ctx context.Context, filter *backend.WorkflowInstanceFilter, afterInstanceID string, count int) ([]*backend.WorkflowInstanceInfo, error) {
+	instances := make([]*backend.WorkflowInstanceInfo, 0)
+
+	if count <= 0 {
+		return instances, nil
+	}
+
+	err := rb.scanInstances(ctx, filter, afterInstanceID, func(i *backend.WorkflowInstanceInfo) bool {
+		instances = append(instances, i)
+
+		return len(instances) < count
+	})
+
+	return instances, err
+}
+
+func (rb *redisBackend) CountWorkflowInstances(ctx context.Context, filter *backend.WorkflowInstanceFilter) (int64, error) {
+	key, indexed := instanceIndex(filter)
+	min, max, exact := scoreRange(filter)
+
+	if indexed && exact {
+		count, err := rb.rdb.ZCount(ctx, key, min, max).Result()
+		if err != nil {
+			return 0, fmt.Errorf("counting workflow instances: %w", err)
+		}
+
+		return count, nil
+	}
+
+	var count int64
+	err := rb.scanInstances(ctx, filter, "", func(*backend.WorkflowInstanceInfo) bool {
+		count++
+
+		return true
+	})
+
+	return count, err
+}
+
+// scanInstances calls f for every instance matching the filter, newest first, until f returns false
+func (rb *redisBackend) scanInstances(
+	ctx context.Context, filter *backend.WorkflowInstanceFilter, afterInstanceID string, f func(*backend.WorkflowInstanceInfo) bool,
+) error {
+	key, _ := instanceIndex(filter)
+	min, max, _ := scoreRange(filter)
+
+	var afterScore float64
+	if afterInstanceID != "" {
+		score, err := rb.rdb.ZScore(ctx, instancesByCreation(), afterInstanceID).Result()
+		if err != nil {
+			if err == redis.Nil {
+				// Unknown cursor, there are no instances after it
+				return nil
+			}
+
+			return fmt.Errorf("getting instance score for %v: %w", afterInstanceID, err)
+		}
+
+		afterScore = score
+		if filter == nil || filter.CreatedBefore.IsZero() || int64(score) < filter.CreatedBefore.UnixMilli() {
+			max = fmt.Sprint(int64(score))
+		}
+	}
+
+	var offset int64
+	for {
+		result, err := rb.rdb.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+			Key:     key,
+			Start:   min,
+			Stop:    max,
+			ByScore: true,
+			Rev:     true,
+			Offset:  offset,
+			Count:   listBatchSize,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("scanning workflow instances: %w", err)
+		}
+
+		if len(result) == 0 {
+			return nil
+		}
+
+		keys := make([]string, 0, len(result))
+		for _, z := range result {
+			instanceID := z.Member.(string)
+
+			// Skip the cursor and instances before it, which share its creation time
+			if afterInstanceID != "" && z.Score == afterScore && instanceID >= afterInstanceID {
+				continue
+			}
+
+			keys = append(keys, instanceKey(instanceID))
+		}
+
+		if len(keys) > 0 {
+			values, err := rb.rdb.MGet(ctx, keys...).Result()
+			if err != nil {
+				return fmt.Errorf("getting workflow instances: %w", err)
+			}
+
+			for _, v := range values {
+				data, ok := v.(string)
+				if !ok {
+					// Index entries are not removed together with instances
+					continue
+				}
+
+				var state instanceState
+				if err := json.Unmarshal([]byte(data), &state); err != nil {
+					return fmt.Errorf("unmarshaling instance state: %w", err)
+				}
+
+				info := instanceInfo(&state)
+
+				if !filter.Matches(info) {
+					continue
+				}
+
+				if !f(info) {
+					return nil
+				}
+			}
+		}
+
+		if len(result) < listBatchSize {
+			return nil
+		}
+
+		offset += int64(len(result))
+	}
+}
+
+func instanceInfo(state *instanceState) *backend.WorkflowInstanceInfo {
+	return &backend.WorkflowInstanceInfo{
+		Instance:         state.Instance,
+		WorkflowName:     state.WorkflowName,
+		State:            state.State,
+		CreatedAt:        state.CreatedAt,
+		CompletedAt:      state.CompletedAt,
+		Metadata:         state.Metadata,
+		SearchAttributes: state.SearchAttributes,
+	}
+}
+
+// instanceIndex returns the most selective index for the given filter, and whether the index alone matches all
+// conditions of the filter besides the creation time range
+func instanceIndex(filter *backend.WorkflowInstanceFilter) (string, bool) {
+	if filter == nil {
+		return instancesByCreation(), true
+	}
+
+	// Search attribute indexes, one per indexed value
+	var searchAttributeKeys []string
+	for name, value := range filter.SearchAttributes {
+		for _, v := range value.IndexValues() {
+			searchAttributeKeys = append(searchAttributeKeys, instancesBySearchAttribute(name, value.Type(), v))
+		}
+	}
+
+	conditions := len(searchAttributeKeys)
+	if filter.ParentInstanceID != "" {
+		conditions++
+	}
+	if filter.WorkflowName != "" {
+		conditions++
+	}
+	if filter.State != nil {
+		conditions++
+	}
+
+	exact := conditions <= 1 && len(searchAttributeKeys) == len(filter.SearchAttributes) &&
+		filter.CompletedAfter.IsZero() && filter.CompletedBefore.IsZero() && len(filter.Metadata) == 0
+
+	switch {
+	case filter.ParentInstanceID != "":
+		return instancesByParent(filter.ParentInstanceID), exact
+	case len(searchAttributeKeys) > 0:
+		return searchAttributeKeys[0], exact
+	case filter.WorkflowName != "":
+		return instancesByWorkflowName(filter.WorkflowName), exact
+	case filter.State != nil:
+		return instancesByState(*filter.State), exact
+	default:
+		return instancesByCreation(), exact
+	}
+}
+
+// scoreRange returns the score range for the creation time range of the filter. Scores have millisecond
+// precision, the range is only exact if the bounds are whole milliseconds.
+func scoreRange(filter *backend.WorkflowInstanceFilter) (string, string, bool) {
+	min, max, exact := "-inf", "+inf", true
+
+	if filter == nil {
+		return min, max, exact
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		min = fmt.Sprint(filter.CreatedAfter.UnixMilli())
+		exact = exact && filter.CreatedAfter.Equal(filter.CreatedAfter.Truncate(time.Millisecond))
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		if filter.CreatedBefore.Equal(filter.CreatedBefore.Truncate(time.Millisecond)) {
+			max = fmt.Sprintf("(%v", filter.CreatedBefore.UnixMilli())
+		} else {
+			max = fmt.Sprint(filter.CreatedBefore.UnixMilli())
+			exact = false
+		}
+	}
+
+	return min, max, exact
+}
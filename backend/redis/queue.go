@@ -4,18 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 type taskQueue[T any] struct {
 	tasktype   string
-	setKey     string
-	streamKey  string
 	groupName  string
 	workerName string
+
+	// groups tracks the streams for which this worker has made sure the consumer group exists
+	groups sync.Map
 }
 
 type TaskItem[T any] struct {
@@ -25,34 +31,29 @@ type TaskItem[T any] struct {
 	// ID is the provided id
 	ID string
 
+	// Queue is the queue the task has been enqueued to
+	Queue core.Queue
+
+	// Priority is the priority the task has been enqueued with
+	Priority int
+
 	// Optional data stored with a task, needs to be serializable
 	Data T
 }
 
 type KeyInfo struct {
-	StreamKey string
-	SetKey    string
+	StreamKey     string
+	SetKey        string
+	PrioritiesKey string
 }
 
 func newTaskQueue[T any](rdb redis.UniversalClient, tasktype string) (*taskQueue[T], error) {
 	tq := &taskQueue[T]{
 		tasktype:   tasktype,
-		setKey:     "task-set:" + tasktype,
-		streamKey:  "task-stream:" + tasktype,
 		groupName:  "task-workers",
 		workerName: uuid.NewString(),
 	}
 
-	// Create the consumer group
-	_, err := rdb.XGroupCreateMkStream(context.Background(), tq.streamKey, tq.groupName, "0").Result()
-	if err != nil {
-		// Ugly, check since there is no UPSERT for consumer groups. Might replace with a script
-		// using XINFO & XGROUP CREATE atomically
-		if err.Error() != "BUSYGROUP Consumer Group name already exists" {
-			return nil, fmt.Errorf("creating task queue: %w", err)
-		}
-	}
-
 	// Pre-load script
 	cmds := map[string]*redis.StringCmd{
 		"enqueueCmd":  enqueueCmd.Load(context.Background(), rdb),
@@ -67,55 +68,310 @@ func newTaskQueue[T any](rdb redis.UniversalClient, tasktype string) (*taskQueue
 		}
 	}
 
+	if err := tq.migrateLegacyTasks(context.Background(), rdb); err != nil {
+		return nil, err
+	}
+
 	return tq, nil
 }
 
-func (q *taskQueue[T]) Keys() KeyInfo {
+// Moves all tasks from the legacy stream to the stream of the default queue and priority, and removes the legacy keys
+//
+// KEYS[1] = legacy stream
+// KEYS[2] = legacy set
+// KEYS[3] = set
+// KEYS[4] = stream
+// KEYS[5] = priorities set
+var migrateLegacyTasksCmd = redis.NewScript(
+	`local msgs = redis.call("XRANGE", KEYS[1], "-", "+")
+	for _, msg in ipairs(msgs) do
+		local fields = {}
+		for i = 1, #msg[2], 2 do
+			fields[msg[2][i]] = msg[2][i + 1]
+		end
+
+		local added = redis.call("SADD", KEYS[3], fields["id"])
+		if added == 1 then
+			redis.call("SADD", KEYS[5], "0")
+			redis.call("XADD", KEYS[4], "*", "id", fields["id"], "data", fields["data"] or "")
+		end
+	end
+
+	redis.call("DEL", KEYS[1], KEYS[2])
+
+	return #msgs
+`)
+
+// migrateLegacyTasks moves tasks enqueued before tasks were routed to queues, when all tasks of a type shared a
+// single stream, to the default queue. Tasks which were locked by a worker are handed out again.
+func (q *taskQueue[T]) migrateLegacyTasks(ctx context.Context, rdb redis.UniversalClient) error {
+	keys := q.Keys(core.QueueDefault, 0)
+
+	if _, err := migrateLegacyTasksCmd.Run(ctx, rdb, []string{
+		"task-stream:" + q.tasktype,
+		"task-set:" + q.tasktype,
+		keys.SetKey,
+		keys.StreamKey,
+		keys.PrioritiesKey,
+	}).Result(); err != nil && err != redis.Nil {
+		return fmt.Errorf("migrating legacy tasks: %w", err)
+	}
+
+	return nil
+}
+
+// Keys returns the keys for tasks with the given priority on the given queue. Every queue uses a separate stream for
+// each priority, the set of queued ids and the set of priorities in use are shared by all priorities of a queue.
+func (q *taskQueue[T]) Keys(queue core.Queue, priority int) KeyInfo {
+	prefixes := q.KeyPrefixes()
+
+	return KeyInfo{
+		StreamKey:     prefixes.StreamKey + string(queue.OrDefault()) + ":" + strconv.Itoa(priority),
+		SetKey:        prefixes.SetKey + string(queue.OrDefault()),
+		PrioritiesKey: prefixes.PrioritiesKey + string(queue.OrDefault()),
+	}
+}
+
+// KeyPrefixes returns the prefixes of the keys, for scripts which need to build the keys for a queue. Stream keys
+// are built as `<prefix><queue>:<priority>`, the other keys as `<prefix><queue>`.
+func (q *taskQueue[T]) KeyPrefixes() KeyInfo {
 	return KeyInfo{
-		StreamKey: q.streamKey,
-		SetKey:    q.setKey,
+		StreamKey:     "task-stream:" + q.tasktype + ":",
+		SetKey:        "task-set:" + q.tasktype + ":",
+		PrioritiesKey: "task-priorities:" + q.tasktype + ":",
+	}
+}
+
+// ensureGroup creates the consumer group for the given stream, if it hasn't been created, yet
+func (q *taskQueue[T]) ensureGroup(ctx context.Context, rdb redis.UniversalClient, streamKey string) error {
+	if _, ok := q.groups.Load(streamKey); ok {
+		return nil
+	}
+
+	// Start at the beginning of the stream, tasks might have been enqueued before the group is created
+	_, err := rdb.XGroupCreateMkStream(ctx, streamKey, q.groupName, "0").Result()
+	if err != nil {
+		// Ugly, check since there is no UPSERT for consumer groups. Might replace with a script
+		// using XINFO & XGROUP CREATE atomically
+		if err.Error() != "BUSYGROUP Consumer Group name already exists" {
+			return fmt.Errorf("creating task queue: %w", err)
+		}
 	}
+
+	q.groups.Store(streamKey, struct{}{})
+
+	return nil
 }
 
 // KEYS[1] = set
 // KEYS[2] = stream
+// KEYS[3] = priorities set
 // ARGV[1] = caller provided id of the task
 // ARGV[2] = additional data to store with the task
+// ARGV[3] = priority of the task
 var enqueueCmd = redis.NewScript(
 	// Prevent duplicates by checking a set first
 	`local added = redis.call("SADD", KEYS[1], ARGV[1])
 	if added == 1 then
+		redis.call("SADD", KEYS[3], ARGV[3])
 		redis.call("XADD", KEYS[2], "*", "id", ARGV[1], "data", ARGV[2])
 	end
 
 	return true
 `)
 
-func (q *taskQueue[T]) Enqueue(ctx context.Context, p redis.Pipeliner, id string, data *T) error {
+func (q *taskQueue[T]) Enqueue(ctx context.Context, p redis.Pipeliner, queue core.Queue, priority int, id string, data *T) error {
 	ds, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
 
-	enqueueCmd.Run(ctx, p, []string{q.setKey, q.streamKey}, id, string(ds))
+	keys := q.Keys(queue, priority)
+	enqueueCmd.Run(ctx, p, []string{keys.SetKey, keys.StreamKey, keys.PrioritiesKey}, id, string(ds), priority)
 
 	return nil
 }
 
-func (q *taskQueue[T]) Dequeue(ctx context.Context, rdb redis.UniversalClient, lockTimeout, timeout time.Duration) (*TaskItem[T], error) {
+// taskLane is the stream of tasks with a single priority on a queue
+type taskLane struct {
+	queue     core.Queue
+	priority  int
+	streamKey string
+}
+
+// lanes returns the lanes of the given queues, highest priority first
+func (q *taskQueue[T]) lanes(ctx context.Context, rdb redis.UniversalClient, queues []core.Queue) ([]taskLane, error) {
+	lanes := make([]taskLane, 0, len(queues))
+
+	for _, queue := range queues {
+		priorities, err := rdb.SMembers(ctx, q.Keys(queue, 0).PrioritiesKey).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("reading task priorities: %w", err)
+		}
+
+		// Always include the default priority, so that there is a stream to wait on
+		queuePriorities := map[int]bool{0: true}
+		for _, p := range priorities {
+			priority, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("parsing task priority: %w", err)
+			}
+
+			queuePriorities[priority] = true
+		}
+
+		for priority := range queuePriorities {
+			lanes = append(lanes, taskLane{
+				queue:     queue.OrDefault(),
+				priority:  priority,
+				streamKey: q.Keys(queue, priority).StreamKey,
+			})
+		}
+	}
+
+	sort.SliceStable(lanes, func(i, j int) bool {
+		return lanes[i].priority > lanes[j].priority
+	})
+
+	return lanes, nil
+}
+
+// Dequeue returns a task from one of the given queues, or nil if there is no task within the timeout. If no queues
+// are given, tasks are dequeued from the default queue.
+//
+// Tasks with a higher priority are returned first. Tasks which have been waiting for longer than the starvation
+// timeout are returned before any other task, oldest first.
+//
+// If no tasks are waiting, Dequeue blocks for up to timeout on the lanes which exist at that point. Tasks in lanes
+// created in the meantime, i.e., for a priority which wasn't used before, are only seen by the next call.
+func (q *taskQueue[T]) Dequeue(
+	ctx context.Context, rdb redis.UniversalClient, queues []core.Queue, lockTimeout, starvationTimeout, timeout time.Duration,
+) (*TaskItem[T], error) {
+	if len(queues) == 0 {
+		queues = []core.Queue{core.QueueDefault}
+	}
+
+	lanes, err := q.lanes(ctx, rdb, queues)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lane := range lanes {
+		if err := q.ensureGroup(ctx, rdb, lane.streamKey); err != nil {
+			return nil, err
+		}
+	}
+
 	// Try to recover abandoned messages
-	task, err := q.recover(ctx, rdb, lockTimeout)
+	for _, lane := range lanes {
+		task, err := q.recover(ctx, rdb, lane.queue, lane.priority, lockTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("checking for abandoned tasks: %w", err)
+		}
+
+		if task != nil {
+			return task, nil
+		}
+	}
+
+	// Pick the lane to read the next task from
+	lane, err := q.nextLane(ctx, rdb, lanes, starvationTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("checking for abandoned tasks: %w", err)
+		return nil, err
+	}
+
+	if lane != nil {
+		task, err := q.read(ctx, rdb, []taskLane{*lane}, -1)
+		if err != nil || task != nil {
+			return task, err
+		}
+	}
+
+	// No tasks are waiting, wait for new tasks in all lanes at once
+	return q.read(ctx, rdb, lanes, timeout)
+}
+
+// nextLane returns the lane with the task to hand out next, or nil if there are no tasks waiting in any of the
+// given lanes. Lanes have to be sorted by priority.
+func (q *taskQueue[T]) nextLane(
+	ctx context.Context, rdb redis.UniversalClient, lanes []taskLane, starvationTimeout time.Duration,
+) (*taskLane, error) {
+	// Find the first task in each stream which has not been delivered to the group, yet
+	groupCmds := make([]*redis.XInfoGroupsCmd, len(lanes))
+	if _, err := rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, lane := range lanes {
+			groupCmds[i] = p.XInfoGroups(ctx, lane.streamKey)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("reading task queue groups: %w", err)
 	}
 
-	if task != nil {
-		return task, nil
+	rangeCmds := make([]*redis.XMessageSliceCmd, len(lanes))
+	if _, err := rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, lane := range lanes {
+			lastDeliveredID := "0-0"
+			for _, group := range groupCmds[i].Val() {
+				if group.Name == q.groupName {
+					lastDeliveredID = group.LastDeliveredID
+				}
+			}
+
+			rangeCmds[i] = p.XRangeN(ctx, lane.streamKey, "("+lastDeliveredID, "+", 1)
+		}
+
+		return nil
+	}); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("reading waiting tasks: %w", err)
+	}
+
+	starvedBefore := time.Now().Add(-starvationTimeout).UnixMilli()
+
+	var next, starved *taskLane
+	var starvedSince int64
+	for i := range lanes {
+		msgs := rangeCmds[i].Val()
+		if len(msgs) == 0 {
+			continue
+		}
+
+		// Stream ids start with the time the task was enqueued
+		waitingSince, err := strconv.ParseInt(strings.SplitN(msgs[0].ID, "-", 2)[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing task id: %w", err)
+		}
+
+		if waitingSince <= starvedBefore && (starved == nil || waitingSince < starvedSince) {
+			starved, starvedSince = &lanes[i], waitingSince
+		}
+
+		if next == nil {
+			next = &lanes[i]
+		}
+	}
+
+	if starved != nil {
+		return starved, nil
+	}
+
+	return next, nil
+}
+
+// read reads the next task from one of the given lanes. A negative timeout doesn't wait for new tasks.
+func (q *taskQueue[T]) read(ctx context.Context, rdb redis.UniversalClient, lanes []taskLane, timeout time.Duration) (*TaskItem[T], error) {
+	streams := make([]string, 0, 2*len(lanes))
+	streamLanes := make(map[string]taskLane, len(lanes))
+	for _, lane := range lanes {
+		streams = append(streams, lane.streamKey)
+		streamLanes[lane.streamKey] = lane
+	}
+	for range lanes {
+		streams = append(streams, ">")
 	}
 
-	// Check for new tasks
 	ids, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
-		Streams:  []string{q.streamKey, ">"},
+		Streams:  streams,
 		Group:    q.groupName,
 		Consumer: q.workerName,
 		Count:    1,
@@ -125,19 +381,66 @@ func (q *taskQueue[T]) Dequeue(ctx context.Context, rdb redis.UniversalClient, l
 		return nil, fmt.Errorf("dequeueing task: %w", err)
 	}
 
-	if len(ids) == 0 || len(ids[0].Messages) == 0 || err == redis.Nil {
+	if err == redis.Nil {
 		return nil, nil
 	}
 
-	msg := ids[0].Messages[0]
-	return msgToTaskItem[T](&msg)
+	// Every stream with new tasks returns one. Hand out the task with the highest priority, and release the others
+	var task *TaskItem[T]
+	var released []taskLane
+	var releasedIDs []string
+	for _, stream := range ids {
+		if len(stream.Messages) == 0 {
+			continue
+		}
+
+		lane := streamLanes[stream.Stream]
+		if task != nil && task.Priority >= lane.priority {
+			released = append(released, lane)
+			releasedIDs = append(releasedIDs, stream.Messages[0].ID)
+			continue
+		}
+
+		if task != nil {
+			released = append(released, streamLanes[q.Keys(task.Queue, task.Priority).StreamKey])
+			releasedIDs = append(releasedIDs, task.TaskID)
+		}
+
+		task, err = msgToTaskItem[T](lane.queue, lane.priority, &stream.Messages[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(released) > 0 {
+		if _, err := rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+			for i, lane := range released {
+				q.Release(ctx, p, lane.queue, lane.priority, releasedIDs[i])
+			}
+
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("releasing tasks: %w", err)
+		}
+	}
+
+	return task, nil
+}
+
+// releasedIdle is the idle time of tasks which have been released. It exceeds any lock timeout.
+const releasedIdle = time.Hour * 24 * 365
+
+// Release gives up the lock of a task without completing it. The task is marked as idle for long enough to be
+// recovered by the next worker dequeueing from the queue.
+func (q *taskQueue[T]) Release(ctx context.Context, p redis.Pipeliner, queue core.Queue, priority int, taskID string) {
+	p.Do(ctx, "XCLAIM", q.Keys(queue, priority).StreamKey, q.groupName, q.workerName, 0, taskID, "IDLE", releasedIdle.Milliseconds(), "JUSTID")
 }
 
-func (q *taskQueue[T]) Extend(ctx context.Context, p redis.Pipeliner, taskID string) error {
+func (q *taskQueue[T]) Extend(ctx context.Context, p redis.Pipeliner, queue core.Queue, priority int, taskID string) error {
 	// Claiming a message resets the idle timer. Don't use the `JUSTID` variant, we
 	// want to increase the retry counter.
 	_, err := p.XClaim(ctx, &redis.XClaimArgs{
-		Stream:   q.streamKey,
+		Stream:   q.Keys(queue, priority).StreamKey,
 		Group:    q.groupName,
 		Consumer: q.workerName,
 		Messages: []string{taskID},
@@ -150,6 +453,23 @@ func (q *taskQueue[T]) Extend(ctx context.Context, p redis.Pipeliner, taskID str
 	return nil
 }
 
+// Owned returns whether the given task is locked by this worker
+func (q *taskQueue[T]) Owned(ctx context.Context, rdb redis.UniversalClient, queue core.Queue, priority int, taskID string) (bool, error) {
+	pending, err := rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   q.Keys(queue, priority).StreamKey,
+		Group:    q.groupName,
+		Start:    taskID,
+		End:      taskID,
+		Count:    1,
+		Consumer: q.workerName,
+	}).Result()
+	if err != nil && err != redis.Nil {
+		return false, fmt.Errorf("checking task lock: %w", err)
+	}
+
+	return len(pending) > 0, nil
+}
+
 // We need TaskIDs for the stream and caller provided IDs for the set. So first look up
 // the ID in the stream using the TaskID, then remove from the set and the stream
 // KEYS[1] = set
@@ -171,8 +491,9 @@ var completeCmd = redis.NewScript(
 	return redis.call("XDEL", KEYS[2], ARGV[1])
 `)
 
-func (q *taskQueue[T]) Complete(ctx context.Context, p redis.Pipeliner, taskID string) (*redis.Cmd, error) {
-	cmd := completeCmd.Run(ctx, p, []string{q.setKey, q.streamKey}, taskID, q.groupName)
+func (q *taskQueue[T]) Complete(ctx context.Context, p redis.Pipeliner, queue core.Queue, priority int, taskID string) (*redis.Cmd, error) {
+	keys := q.Keys(queue, priority)
+	cmd := completeCmd.Run(ctx, p, []string{keys.SetKey, keys.StreamKey}, taskID, q.groupName)
 	if err := cmd.Err(); err != nil && err != redis.Nil {
 		return nil, fmt.Errorf("completing task: %w", err)
 	}
@@ -180,20 +501,29 @@ func (q *taskQueue[T]) Complete(ctx context.Context, p redis.Pipeliner, taskID s
 	return cmd, nil
 }
 
-func (q *taskQueue[T]) Data(ctx context.Context, p redis.Pipeliner, taskID string) (*TaskItem[T], error) {
-	msg, err := p.XRange(ctx, q.streamKey, taskID, taskID).Result()
+// Data returns the task item for the given task id or nil if the task does not exist
+func (q *taskQueue[T]) Data(
+	ctx context.Context, rdb redis.UniversalClient, queue core.Queue, priority int, taskID string,
+) (*TaskItem[T], error) {
+	msg, err := rdb.XRange(ctx, q.Keys(queue, priority).StreamKey, taskID, taskID).Result()
 	if err != nil && err != redis.Nil {
 		return nil, fmt.Errorf("finding task: %w", err)
 	}
 
-	return msgToTaskItem[T](&msg[0])
+	if len(msg) == 0 {
+		return nil, nil
+	}
+
+	return msgToTaskItem[T](queue.OrDefault(), priority, &msg[0])
 }
 
-func (q *taskQueue[T]) recover(ctx context.Context, rdb redis.UniversalClient, idleTimeout time.Duration) (*TaskItem[T], error) {
+func (q *taskQueue[T]) recover(
+	ctx context.Context, rdb redis.UniversalClient, queue core.Queue, priority int, idleTimeout time.Duration,
+) (*TaskItem[T], error) {
 	// Ignore the start argument, we are deleting tasks as they are completed, so we'll always
 	// start this scan from the beginning.
 	msgs, _, err := rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
-		Stream:   q.streamKey,
+		Stream:   q.Keys(queue, priority).StreamKey,
 		Group:    q.groupName,
 		Consumer: q.workerName,
 		MinIdle:  idleTimeout,
@@ -209,10 +539,10 @@ func (q *taskQueue[T]) recover(ctx context.Context, rdb redis.UniversalClient, i
 		return nil, nil
 	}
 
-	return msgToTaskItem[T](&msgs[0])
+	return msgToTaskItem[T](queue.OrDefault(), priority, &msgs[0])
 }
 
-func msgToTaskItem[T any](msg *redis.XMessage) (*TaskItem[T], error) {
+func msgToTaskItem[T any](queue core.Queue, priority int, msg *redis.XMessage) (*TaskItem[T], error) {
 	id := msg.Values["id"].(string)
 	data := msg.Values["data"].(string)
 
@@ -224,8 +554,39 @@ func msgToTaskItem[T any](msg *redis.XMessage) (*TaskItem[T], error) {
 	}
 
 	return &TaskItem[T]{
-		TaskID: msg.ID,
-		ID:     id,
-		Data:   t,
+		TaskID:   msg.ID,
+		ID:       id,
+		Queue:    queue,
+		Priority: priority,
+		Data:     t,
 	}, nil
 }
+
+// queuedTaskID returns the id of a task handed out to workers, which also identifies the stream of the task
+func queuedTaskID(queue core.Queue, priority int, taskID string) string {
+	return string(queue.OrDefault()) + "/" + strconv.Itoa(priority) + "/" + taskID
+}
+
+// parseQueuedTaskID returns the queue, the priority, and the stream id of a task id created using queuedTaskID.
+// Stream ids and priorities never contain a slash, queue names might.
+func parseQueuedTaskID(id string) (core.Queue, int, string) {
+	i := strings.LastIndex(id, "/")
+	if i < 0 {
+		return core.QueueDefault, 0, id
+	}
+
+	taskID := id[i+1:]
+	id = id[:i]
+
+	i = strings.LastIndex(id, "/")
+	if i < 0 {
+		return core.QueueDefault, 0, taskID
+	}
+
+	priority, err := strconv.Atoi(id[i+1:])
+	if err != nil {
+		return core.QueueDefault, 0, taskID
+	}
+
+	return core.Queue(id[:i]), priority, taskID
+}
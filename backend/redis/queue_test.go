@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 )
@@ -25,6 +26,8 @@ func Test_TaskQueue(t *testing.T) {
 
 	lockTimeout := time.Millisecond * 10
 	blockTimeout := time.Millisecond * 10
+	starvationTimeout := time.Minute
+	queues := []core.Queue{core.QueueDefault}
 
 	tests := []struct {
 		name string
@@ -47,14 +50,94 @@ func Test_TaskQueue(t *testing.T) {
 				ctx := context.Background()
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "t1", nil)
 				})
 				require.NoError(t, err)
 
-				task, err := q.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				task, err := q.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 				require.Equal(t, "t1", task.ID)
+				require.Equal(t, core.QueueDefault, task.Queue)
+			},
+		},
+		{
+			name: "Dequeue only from given queues",
+			f: func(t *testing.T) {
+				q, err := newTaskQueue[any](client, "test")
+				require.NoError(t, err)
+
+				ctx := context.Background()
+
+				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
+					return q.Enqueue(ctx, p, "other", 0, "t1", nil)
+				})
+				require.NoError(t, err)
+
+				task, err := q.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
+				require.NoError(t, err)
+				require.Nil(t, task)
+
+				task, err = q.Dequeue(ctx, client, []core.Queue{core.QueueDefault, "other"}, lockTimeout, starvationTimeout, blockTimeout)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, "t1", task.ID)
+				require.Equal(t, core.Queue("other"), task.Queue)
+			},
+		},
+		{
+			name: "Dequeue higher priority first",
+			f: func(t *testing.T) {
+				q, err := newTaskQueue[any](client, "test")
+				require.NoError(t, err)
+
+				ctx := context.Background()
+
+				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
+					if err := q.Enqueue(ctx, p, core.QueueDefault, 0, "low", nil); err != nil {
+						return err
+					}
+
+					return q.Enqueue(ctx, p, core.QueueDefault, 10, "high", nil)
+				})
+				require.NoError(t, err)
+
+				task, err := q.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, "high", task.ID)
+				require.Equal(t, 10, task.Priority)
+
+				task, err = q.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, "low", task.ID)
+			},
+		},
+		{
+			name: "Dequeue starved tasks first",
+			f: func(t *testing.T) {
+				q, err := newTaskQueue[any](client, "test")
+				require.NoError(t, err)
+
+				ctx := context.Background()
+
+				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "low", nil)
+				})
+				require.NoError(t, err)
+
+				time.Sleep(time.Millisecond * 10)
+
+				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
+					return q.Enqueue(ctx, p, core.QueueDefault, 10, "high", nil)
+				})
+				require.NoError(t, err)
+
+				task, err := q.Dequeue(ctx, client, queues, lockTimeout, time.Millisecond*5, blockTimeout)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, "low", task.ID)
 			},
 		},
 		{
@@ -66,27 +149,27 @@ func Test_TaskQueue(t *testing.T) {
 				ctx := context.Background()
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "t1", nil)
 				})
 				require.NoError(t, err)
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "t1", nil)
 				})
 				require.NoError(t, err)
 
-				task, err := q.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				task, err := q.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					_, err := q.Complete(ctx, p, task.TaskID)
+					_, err := q.Complete(ctx, p, task.Queue, task.Priority, task.TaskID)
 					return err
 				})
 				require.NoError(t, err)
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "t1", nil)
 				})
 				require.NoError(t, err)
 			},
@@ -105,14 +188,14 @@ func Test_TaskQueue(t *testing.T) {
 				require.NoError(t, err)
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", &foo{
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "t1", &foo{
 						Count: 1,
 						Name:  "bar",
 					})
 				})
 				require.NoError(t, err)
 
-				task, err := q.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				task, err := q.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 				require.Equal(t, "t1", task.ID)
@@ -120,6 +203,31 @@ func Test_TaskQueue(t *testing.T) {
 				require.Equal(t, "bar", task.Data.Name)
 			},
 		},
+		{
+			name: "Legacy tasks are moved to the default queue",
+			f: func(t *testing.T) {
+				ctx := context.Background()
+
+				// Tasks enqueued before tasks were routed to queues
+				require.NoError(t, client.SAdd(ctx, "task-set:test", "t1").Err())
+				require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{
+					Stream: "task-stream:test",
+					Values: map[string]interface{}{"id": "t1", "data": ""},
+				}).Err())
+
+				q, err := newTaskQueue[any](client, "test")
+				require.NoError(t, err)
+
+				n, err := client.Exists(ctx, "task-stream:test", "task-set:test").Result()
+				require.NoError(t, err)
+				require.Zero(t, n)
+
+				task, err := q.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, "t1", task.ID)
+			},
+		},
 		{
 			name: "Simple enqueue/dequeue different worker",
 			f: func(t *testing.T) {
@@ -128,7 +236,7 @@ func Test_TaskQueue(t *testing.T) {
 				ctx := context.Background()
 
 				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "t1", nil)
 				})
 				require.NoError(t, err)
 
@@ -136,7 +244,7 @@ func Test_TaskQueue(t *testing.T) {
 				require.NoError(t, err)
 
 				// Dequeue using second worker
-				task, err := q2.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				task, err := q2.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 				require.Equal(t, "t1", task.ID)
@@ -151,17 +259,17 @@ func Test_TaskQueue(t *testing.T) {
 				ctx := context.Background()
 
 				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "t1", nil)
 				})
 				require.NoError(t, err)
 
-				task, err := q.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				task, err := q.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 
 				// Complete task
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					_, err := q2.Complete(ctx, p, task.TaskID)
+					_, err := q2.Complete(ctx, p, task.Queue, task.Priority, task.TaskID)
 					return err
 				})
 				require.NoError(t, err)
@@ -169,7 +277,7 @@ func Test_TaskQueue(t *testing.T) {
 				time.Sleep(time.Millisecond * 10)
 
 				// Try to recover using second worker
-				task2, err := q2.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				task2, err := q2.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.Nil(t, task2)
 			},
@@ -182,14 +290,14 @@ func Test_TaskQueue(t *testing.T) {
 				ctx := context.Background()
 
 				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "t1", nil)
 				})
 				require.NoError(t, err)
 
 				q2, _ := newTaskQueue[any](client, "test")
 				require.NoError(t, err)
 
-				task, err := q2.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				task, err := q2.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 				require.Equal(t, "t1", task.ID)
@@ -197,7 +305,7 @@ func Test_TaskQueue(t *testing.T) {
 				time.Sleep(time.Millisecond * 10)
 
 				// Assume q2 crashed, recover from other worker
-				recoveredTask, err := q.Dequeue(ctx, client, time.Millisecond*1, blockTimeout)
+				recoveredTask, err := q.Dequeue(ctx, client, queues, time.Millisecond*1, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 				require.Equal(t, task, recoveredTask)
@@ -211,7 +319,7 @@ func Test_TaskQueue(t *testing.T) {
 				ctx := context.Background()
 
 				_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q.Enqueue(ctx, p, "t1", nil)
+					return q.Enqueue(ctx, p, core.QueueDefault, 0, "t1", nil)
 				})
 				require.NoError(t, err)
 
@@ -219,7 +327,7 @@ func Test_TaskQueue(t *testing.T) {
 				q2, _ := newTaskQueue[any](client, "test")
 				require.NoError(t, err)
 
-				task, err := q2.Dequeue(ctx, client, lockTimeout, blockTimeout)
+				task, err := q2.Dequeue(ctx, client, queues, lockTimeout, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 				require.Equal(t, "t1", task.ID)
@@ -227,12 +335,12 @@ func Test_TaskQueue(t *testing.T) {
 				time.Sleep(time.Millisecond * 5)
 
 				_, err = client.Pipelined(ctx, func(p redis.Pipeliner) error {
-					return q2.Extend(ctx, p, task.TaskID)
+					return q2.Extend(ctx, p, task.Queue, task.Priority, task.TaskID)
 				})
 				require.NoError(t, err)
 
 				// Use large lock timeout
-				recoveredTask, err := q.Dequeue(ctx, client, time.Second*2, blockTimeout)
+				recoveredTask, err := q.Dequeue(ctx, client, queues, time.Second*2, starvationTimeout, blockTimeout)
 				require.NoError(t, err)
 				require.Nil(t, recoveredTask)
 			},
@@ -12,6 +12,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/metrics"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -62,8 +63,9 @@ func NewRedisBackend(client redis.UniversalClient, opts ...RedisBackendOption) (
 	}
 
 	rb := &redisBackend{
-		rdb:     client,
-		options: options,
+		rdb:        client,
+		options:    options,
+		workerName: uuid.NewString(),
 
 		workflowQueue: workflowQueue,
 		activityQueue: activityQueue,
@@ -73,12 +75,20 @@ func NewRedisBackend(client redis.UniversalClient, opts ...RedisBackendOption) (
 	// them, loads them. This doesn't work when using (transactional) pipelines, so eagerly load them on startup.
 	ctx := context.Background()
 	cmds := map[string]*redis.StringCmd{
-		"addEventsToStreamCmd":   addEventsToStreamCmd.Load(ctx, rb.rdb),
-		"addFutureEventCmd":      addFutureEventCmd.Load(ctx, rb.rdb),
-		"futureEventsCmd":        futureEventsCmd.Load(ctx, rb.rdb),
-		"removeFutureEventCmd":   removeFutureEventCmd.Load(ctx, rb.rdb),
-		"removePendingEventsCmd": removePendingEventsCmd.Load(ctx, rb.rdb),
-		"requeueInstanceCmd":     requeueInstanceCmd.Load(ctx, rb.rdb),
+		"acquireActivitySlotCmd":   acquireActivitySlotCmd.Load(ctx, rb.rdb),
+		"addEventsToStreamCmd":     addEventsToStreamCmd.Load(ctx, rb.rdb),
+		"addFutureEventCmd":        addFutureEventCmd.Load(ctx, rb.rdb),
+		"claimActivityCmd":         claimActivityCmd.Load(ctx, rb.rdb),
+		"claimScheduleCmd":         claimScheduleCmd.Load(ctx, rb.rdb),
+		"createScheduleCmd":        createScheduleCmd.Load(ctx, rb.rdb),
+		"timeoutActivityCmd":       timeoutActivityCmd.Load(ctx, rb.rdb),
+		"futureEventsCmd":          futureEventsCmd.Load(ctx, rb.rdb),
+		"removeExecutionEventsCmd": removeExecutionEventsCmd.Load(ctx, rb.rdb),
+		"removeFutureEventCmd":     removeFutureEventCmd.Load(ctx, rb.rdb),
+		"removePendingEventsCmd":   removePendingEventsCmd.Load(ctx, rb.rdb),
+		"requeueInstanceCmd":       requeueInstanceCmd.Load(ctx, rb.rdb),
+		"startDelayedInstanceCmd":  startDelayedInstanceCmd.Load(ctx, rb.rdb),
+		"takeActivityTokenCmd":     takeActivityTokenCmd.Load(ctx, rb.rdb),
 	}
 	for name, cmd := range cmds {
 		// fmt.Println(name, cmd.Val())
@@ -95,6 +105,9 @@ type redisBackend struct {
 	rdb     redis.UniversalClient
 	options *RedisOptions
 
+	// workerName identifies the locks held by this backend, e.g., of schedules
+	workerName string
+
 	workflowQueue *taskQueue[any]
 	activityQueue *taskQueue[activityData]
 }
@@ -116,6 +116,17 @@ var _ log.Logger = (*nullLogger)(nil)
 
 var _ test.TestBackend = (*redisBackend)(nil)
 
+func (rb *redisBackend) OtherProcess() backend.Backend {
+	b, err := NewRedisBackend(rb.rdb, func(o *RedisOptions) {
+		*o = *rb.options
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
 // GetFutureEvents
 func (rb *redisBackend) GetFutureEvents(ctx context.Context) ([]*history.Event, error) {
 	r, err := rb.rdb.ZRangeByScore(ctx, futureEventsKey(), &redis.ZRangeBy{
@@ -0,0 +1,237 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/schedule"
+	"github.com/redis/go-redis/v9"
+)
+
+// Schedules are stored as JSON in a key per schedule. Schedules which are not paused are kept in a sorted set,
+// scored by the time they are due next. Locked schedules are scored by the end of their lock.
+
+// Create a schedule, unless it already exists
+//
+// KEYS[1] - schedule key
+// KEYS[2] - schedules zset
+// ARGV[1] - serialized schedule
+// ARGV[2] - due score, empty if the schedule is paused
+// ARGV[3] - schedule id
+var createScheduleCmd = redis.NewScript(`
+	if not redis.call("SET", KEYS[1], ARGV[1], "NX") then
+		return 0
+	end
+
+	if ARGV[2] ~= "" then
+		redis.call("ZADD", KEYS[2], ARGV[2], ARGV[3])
+	end
+
+	return 1
+`)
+
+// Lock the next due schedule by moving its score to the end of the lock, and recording the worker holding the lock.
+// Schedules which are still locked, e.g., because they were resumed while being processed, are moved to the end of
+// their lock.
+//
+// KEYS[1] - schedules zset
+// ARGV[1] - current time
+// ARGV[2] - locked until
+// ARGV[3] - lock timeout in milliseconds
+// ARGV[4] - worker name
+// ARGV[5] - schedule key prefix
+// ARGV[6] - schedule lock key prefix
+//
+// Note: this does not work with Redis Cluster since not all keys are passed into the script.
+var claimScheduleCmd = redis.NewScript(`
+	local ids = redis.call("ZRANGE", KEYS[1], "-inf", ARGV[1], "BYSCORE", "LIMIT", 0, 1)
+	if #ids == 0 then
+		return nil
+	end
+
+	local data = redis.call("GET", ARGV[5] .. ids[1])
+	if not data then
+		redis.call("ZREM", KEYS[1], ids[1])
+		return nil
+	end
+
+	local lockKey = ARGV[6] .. ids[1]
+	local lockTTL = redis.call("PTTL", lockKey)
+	if lockTTL > 0 then
+		redis.call("ZADD", KEYS[1], tonumber(ARGV[1]) + lockTTL, ids[1])
+		return nil
+	end
+
+	redis.call("SET", lockKey, ARGV[4], "PX", ARGV[3])
+	redis.call("ZADD", KEYS[1], ARGV[2], ids[1])
+	return data
+`)
+
+func (rb *redisBackend) CreateSchedule(ctx context.Context, s *schedule.Schedule) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshaling schedule: %w", err)
+	}
+
+	due := ""
+	if !s.Paused {
+		due = strconv.FormatInt(s.DueAt.UnixMilli(), 10)
+	}
+
+	created, err := createScheduleCmd.Run(ctx, rb.rdb, []string{scheduleKey(s.ID), schedulesKey()}, string(data), due, s.ID).Int()
+	if err != nil {
+		return fmt.Errorf("creating schedule: %w", err)
+	}
+
+	if created == 0 {
+		return backend.ErrScheduleAlreadyExists
+	}
+
+	return nil
+}
+
+func (rb *redisBackend) GetSchedule(ctx context.Context, id string) (*schedule.Schedule, error) {
+	return readSchedule(ctx, rb.rdb, id)
+}
+
+func (rb *redisBackend) PauseSchedule(ctx context.Context, id string) error {
+	return rb.updateSchedule(ctx, id, false, func(s *schedule.Schedule) {
+		s.Paused = true
+	})
+}
+
+func (rb *redisBackend) ResumeSchedule(ctx context.Context, id string, nextRunAt time.Time) error {
+	return rb.updateSchedule(ctx, id, false, func(s *schedule.Schedule) {
+		s.Paused = false
+		s.NextRunAt = nextRunAt
+		s.DueAt = nextRunAt
+	})
+}
+
+func (rb *redisBackend) DeleteSchedule(ctx context.Context, id string) error {
+	p := rb.rdb.TxPipeline()
+	deleted := p.Del(ctx, scheduleKey(id))
+	p.Del(ctx, scheduleLockKey(id))
+	p.ZRem(ctx, schedulesKey(), id)
+	if _, err := p.Exec(ctx); err != nil {
+		return fmt.Errorf("deleting schedule: %w", err)
+	}
+
+	if deleted.Val() == 0 {
+		return backend.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+func (rb *redisBackend) GetScheduleTask(ctx context.Context) (*schedule.Schedule, error) {
+	now := time.Now()
+
+	data, err := claimScheduleCmd.Run(ctx, rb.rdb, []string{schedulesKey()},
+		now.UnixMilli(),
+		now.Add(rb.options.WorkflowLockTimeout).UnixMilli(),
+		rb.options.WorkflowLockTimeout.Milliseconds(),
+		rb.workerName,
+		scheduleKeyPrefix(),
+		scheduleLockKeyPrefix(),
+	).Text()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("locking schedule: %w", err)
+	}
+
+	var s schedule.Schedule
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("unmarshaling schedule: %w", err)
+	}
+
+	return &s, nil
+}
+
+func (rb *redisBackend) CompleteScheduleTask(ctx context.Context, s *schedule.Schedule) error {
+	return rb.updateSchedule(ctx, s.ID, true, func(stored *schedule.Schedule) {
+		stored.NextRunAt = s.NextRunAt
+		stored.BufferedRunAt = s.BufferedRunAt
+		stored.LastInstance = s.LastInstance
+		stored.DueAt = s.DueAt
+	})
+}
+
+// updateSchedule applies the given update to the stored schedule, and schedules it according to its new state. If
+// unlock is set, the schedule has to be locked by this worker, and its lock is released.
+func (rb *redisBackend) updateSchedule(ctx context.Context, id string, unlock bool, update func(s *schedule.Schedule)) error {
+	key := scheduleKey(id)
+	lockKey := scheduleLockKey(id)
+
+	return rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		if unlock {
+			worker, err := tx.Get(ctx, lockKey).Result()
+			if err != nil && err != redis.Nil {
+				return fmt.Errorf("reading schedule lock: %w", err)
+			}
+
+			// The lock has expired, and the schedule might have been locked by another worker
+			if worker != rb.workerName {
+				return backend.ErrScheduleNotFound
+			}
+		}
+
+		s, err := readSchedule(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		update(s)
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("marshaling schedule: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Set(ctx, key, string(data), 0)
+
+			if unlock {
+				p.Del(ctx, lockKey)
+			}
+
+			if s.Paused {
+				p.ZRem(ctx, schedulesKey(), id)
+			} else {
+				p.ZAdd(ctx, schedulesKey(), redis.Z{Score: float64(s.DueAt.UnixMilli()), Member: id})
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("updating schedule: %w", err)
+		}
+
+		return nil
+	}, key, lockKey)
+}
+
+func readSchedule(ctx context.Context, rdb redis.Cmdable, id string) (*schedule.Schedule, error) {
+	data, err := rdb.Get(ctx, scheduleKey(id)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, backend.ErrScheduleNotFound
+		}
+
+		return nil, fmt.Errorf("reading schedule: %w", err)
+	}
+
+	var s schedule.Schedule
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("unmarshaling schedule: %w", err)
+	}
+
+	return &s, nil
+}
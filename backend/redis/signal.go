@@ -2,8 +2,11 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/tracing"
 	"github.com/redis/go-redis/v9"
@@ -26,7 +29,7 @@ func (rb *redisBackend) SignalWorkflow(ctx context.Context, instanceID string, e
 	defer span.End()
 
 	if _, err = rb.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
-		if err := rb.addWorkflowInstanceEventP(ctx, p, instanceState.Instance, event); err != nil {
+		if err := rb.addWorkflowInstanceEventP(ctx, p, instanceState.Instance, instanceState.Queue, instanceState.Priority, event); err != nil {
 			return fmt.Errorf("adding event to stream: %w", err)
 		}
 
@@ -37,3 +40,91 @@ func (rb *redisBackend) SignalWorkflow(ctx context.Context, instanceID string, e
 
 	return nil
 }
+
+func (rb *redisBackend) SignalWithStartWorkflow(ctx context.Context, instance *core.WorkflowInstance, startedEvent, signalEvent *history.Event) (*core.WorkflowInstance, error) {
+	key := instanceKey(instance.InstanceID)
+	a := startedEvent.Attributes.(*history.ExecutionStartedAttributes)
+
+	// Running instances are signaled, finished instances are replaced by a new execution if the policy allows it
+	policy := a.IDReusePolicy
+	if policy == core.IDReusePolicyTerminateIfRunning {
+		policy = core.IDReusePolicyAllowDuplicate
+	}
+
+	// Watch the instance, the transaction fails if it's created concurrently
+	for {
+		var signaled *core.WorkflowInstance
+
+		err := rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			state, err := readInstancePipelineCmd(tx.Get(ctx, key))
+			if err != nil && !errors.Is(err, backend.ErrInstanceNotFound) {
+				return err
+			}
+
+			reuse := false
+			if state != nil {
+				reuse, err = policy.AllowsReuse(state.State, func() (bool, error) {
+					return executionFailed(ctx, tx, state.Instance)
+				})
+				if err != nil {
+					return err
+				}
+
+				// Finished instances which can't be replaced are not signaled
+				if !reuse && state.State == core.WorkflowInstanceStateFinished {
+					return backend.ErrInstanceNotActive
+				}
+			}
+
+			_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				if state != nil {
+					if !reuse {
+						signaled = state.Instance
+
+						return rb.addWorkflowInstanceEventP(ctx, p, state.Instance, state.Queue, state.Priority, signalEvent)
+					}
+
+					if err := reuseInstanceP(ctx, p, state); err != nil {
+						return err
+					}
+				}
+
+				signaled = instance
+
+				if err := createInstanceP(ctx, p, instance, a, false); err != nil {
+					return err
+				}
+
+				if err := addExecutionTimeoutP(ctx, p, instance, startedEvent); err != nil {
+					return err
+				}
+
+				if startedEvent.VisibleAt != nil {
+					if err := addFutureEventP(ctx, p, instance, a.Queue, a.Priority, startedEvent); err != nil {
+						return err
+					}
+				} else if err := addEventToStreamP(ctx, p, pendingEventsKey(instance.InstanceID), startedEvent); err != nil {
+					return err
+				}
+
+				return rb.addWorkflowInstanceEventP(ctx, p, instance, a.Queue, a.Priority, signalEvent)
+			})
+
+			return err
+		}, key)
+
+		if err == redis.TxFailedErr {
+			continue
+		}
+
+		if err != nil {
+			if errors.Is(err, backend.ErrInstanceNotActive) {
+				return nil, err
+			}
+
+			return nil, fmt.Errorf("signaling workflow instance: %w", err)
+		}
+
+		return signaled, nil
+	}
+}
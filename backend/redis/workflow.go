@@ -3,14 +3,17 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
+	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/internal/tracing"
+	"github.com/cschleiden/go-workflows/workflow"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -19,13 +22,14 @@ import (
 // Find all due future events. For each event:
 // - Look up event data
 // - Add to pending event stream for workflow instance
-// - Try to queue workflow task for workflow instance
+// - Try to queue workflow task for workflow instance, on the queue and with the priority of the instance
 // - Remove event from future event set and delete event data
 //
 // KEYS[1] - future event set key
-// KEYS[2] - workflow task queue stream
-// KEYS[3] - workflow task queue set
 // ARGV[1] - current timestamp for zrange
+// ARGV[2] - workflow task queue stream key prefix
+// ARGV[3] - workflow task queue set key prefix
+// ARGV[4] - workflow task queue priorities set key prefix
 //
 // Note: this does not work with Redis Cluster since not all keys are passed into the script.
 var futureEventsCmd = redis.NewScript(`
@@ -40,9 +44,20 @@ var futureEventsCmd = redis.NewScript(`
 		redis.call("XADD", pending_events_key, "*", "event", eventData)
 
 		-- Try to queue workflow task
-		local already_queued = redis.call("SADD", KEYS[3], instanceID)
+		local queue = redis.call("HGET", events[i], "queue")
+		if not queue then
+			queue = "default"
+		end
+
+		local priority = redis.call("HGET", events[i], "priority")
+		if not priority then
+			priority = "0"
+		end
+
+		local already_queued = redis.call("SADD", ARGV[3] .. queue, instanceID)
 		if already_queued ~= 0 then
-			redis.call("XADD", KEYS[2], "*", "id", instanceID, "data", "")
+			redis.call("SADD", ARGV[4] .. queue, priority)
+			redis.call("XADD", ARGV[2] .. queue .. ":" .. priority, "*", "id", instanceID, "data", "")
 		end
 
 		-- Delete event hash data
@@ -53,23 +68,32 @@ var futureEventsCmd = redis.NewScript(`
 	return #events
 `)
 
-func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, error) {
+func (rb *redisBackend) GetWorkflowTask(ctx context.Context, queues []workflow.Queue) (*task.Workflow, error) {
 	// Check for future events
 	now := time.Now().UnixMilli()
 	nowStr := strconv.FormatInt(now, 10)
 
-	queueKeys := rb.workflowQueue.Keys()
+	queueKeyPrefixes := rb.workflowQueue.KeyPrefixes()
 
 	if _, err := futureEventsCmd.Run(ctx, rb.rdb, []string{
 		futureEventsKey(),
-		queueKeys.StreamKey,
-		queueKeys.SetKey,
-	}, nowStr).Result(); err != nil && err != redis.Nil {
+	}, nowStr, queueKeyPrefixes.StreamKey, queueKeyPrefixes.SetKey, queueKeyPrefixes.PrioritiesKey).Result(); err != nil && err != redis.Nil {
 		return nil, fmt.Errorf("checking future events: %w", err)
 	}
 
+	// Fail activities which have timed out, this adds new events for their workflow instances
+	if err := rb.timeoutActivities(ctx, time.UnixMilli(now)); err != nil {
+		return nil, fmt.Errorf("timing out activities: %w", err)
+	}
+
+	// Terminate workflow instances which have exceeded their execution timeout
+	if err := rb.timeoutWorkflowInstances(ctx, time.UnixMilli(now)); err != nil {
+		return nil, fmt.Errorf("timing out workflow instances: %w", err)
+	}
+
 	// Try to get a workflow task, this locks the instance when it dequeues one
-	instanceTask, err := rb.workflowQueue.Dequeue(ctx, rb.rdb, rb.options.WorkflowLockTimeout, rb.options.BlockTimeout)
+	instanceTask, err := rb.workflowQueue.Dequeue(
+		ctx, rb.rdb, queues, rb.options.WorkflowLockTimeout, rb.options.PriorityStarvationTimeout, rb.options.BlockTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +113,19 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 		return nil, fmt.Errorf("reading event stream: %w", err)
 	}
 
+	if len(msgs) == 0 {
+		// Pending events have been removed after the task was queued, for example, because the instance
+		// was terminated. Release the task, there is nothing left to do.
+		if _, err := rb.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			_, err := rb.workflowQueue.Complete(ctx, p, instanceTask.Queue, instanceTask.Priority, instanceTask.TaskID)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("completing empty workflow task: %w", err)
+		}
+
+		return nil, nil
+	}
+
 	newEvents := make([]*history.Event, 0, len(msgs))
 	for _, msg := range msgs {
 		var event *history.Event
@@ -100,8 +137,21 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 		newEvents = append(newEvents, event)
 	}
 
+	if instanceState.LastSequenceID == 0 && !startsExecution(newEvents) {
+		// An instance with a delayed start might receive events, for example signals, before it has been started.
+		// Keep them pending, the instance is queued again when its started event becomes visible.
+		if _, err := rb.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			_, err := rb.workflowQueue.Complete(ctx, p, instanceTask.Queue, instanceTask.Priority, instanceTask.TaskID)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("completing workflow task: %w", err)
+		}
+
+		return nil, nil
+	}
+
 	return &task.Workflow{
-		ID:                    instanceTask.TaskID,
+		ID:                    queuedTaskID(instanceTask.Queue, instanceTask.Priority, instanceTask.TaskID),
 		WorkflowInstance:      instanceState.Instance,
 		WorkflowInstanceState: instanceState.State,
 		Metadata:              instanceState.Metadata,
@@ -111,14 +161,39 @@ func (rb *redisBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, er
 	}, nil
 }
 
+func startsExecution(events []*history.Event) bool {
+	for _, event := range events {
+		if event.Type == history.EventType_WorkflowExecutionStarted {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (rb *redisBackend) ExtendWorkflowTask(ctx context.Context, taskID string, instance *core.WorkflowInstance) error {
+	queue, priority, taskID := parseQueuedTaskID(taskID)
+
 	_, err := rb.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
-		return rb.workflowQueue.Extend(ctx, p, taskID)
+		return rb.workflowQueue.Extend(ctx, p, queue, priority, taskID)
 	})
 
 	return err
 }
 
+func (rb *redisBackend) AbandonWorkflowTask(ctx context.Context, task *task.Workflow) error {
+	queue, priority, taskID := parseQueuedTaskID(task.ID)
+
+	if _, err := rb.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		rb.workflowQueue.Release(ctx, p, queue, priority, taskID)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("abandoning workflow task: %w", err)
+	}
+
+	return nil
+}
+
 // Remove all pending events before (and including) a given message id
 // KEYS[1] - pending events stream key
 // ARGV[1] - message id
@@ -129,15 +204,40 @@ var removePendingEventsCmd = redis.NewScript(`
 	return removed
 `)
 
+// Remove all pending events which belong to commands of the current execution, e.g., activity results and fired
+// timers. Used when the workflow instance is continued as new, events without a schedule event id like signals are
+// kept for the new execution.
+// KEYS[1] - pending events stream key
+var removeExecutionEventsCmd = redis.NewScript(`
+	local msgs = redis.call("XRANGE", KEYS[1], "-", "+")
+	local removed = 0
+	for i = 1, #msgs do
+		local fields = msgs[i][2]
+		for j = 1, #fields, 2 do
+			if fields[j] == "event" then
+				local event = cjson.decode(fields[j + 1])
+				if event["seid"] then
+					removed = removed + redis.call("XDEL", KEYS[1], msgs[i][1])
+				end
+			end
+		end
+	end
+
+	return removed
+`)
+
 // KEYS[1] - pending events
 // KEYS[2] - task queue stream
 // KEYS[3] - task queue set
+// KEYS[4] - task queue priorities set
 // ARGV[1] - Instance ID
+// ARGV[2] - priority of the instance
 var requeueInstanceCmd = redis.NewScript(`
 	local pending_events = redis.call("XLEN", KEYS[1])
 	if pending_events > 0 then
 		local added = redis.call("SADD", KEYS[3], ARGV[1])
 		if added == 1 then
+			redis.call("SADD", KEYS[4], ARGV[2])
 			redis.call("XADD", KEYS[2], "*", "id", ARGV[1], "data", "")
 		end
 	end
@@ -153,18 +253,185 @@ func (rb *redisBackend) CompleteWorkflowTask(
 	executedEvents, activityEvents, timerEvents []*history.Event,
 	workflowEvents []history.WorkflowEvent,
 ) error {
+	// Watch the workflow instance and the instances receiving events from it, the transaction fails if one of them is
+	// created, terminated, or finishes while the task is being completed
+	watchKeys := []string{instanceKey(instance.InstanceID)}
+	for targetInstanceID := range history.EventsByWorkflowInstanceID(workflowEvents) {
+		if targetInstanceID != instance.InstanceID {
+			watchKeys = append(watchKeys, instanceKey(targetInstanceID))
+		}
+	}
+
+	for {
+		err := rb.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			return rb.completeWorkflowTask(ctx, tx, task, instance, state, executedEvents, activityEvents, timerEvents, workflowEvents)
+		}, watchKeys...)
+
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+
+		return err
+	}
+}
+
+func (rb *redisBackend) completeWorkflowTask(
+	ctx context.Context,
+	tx *redis.Tx,
+	task *task.Workflow,
+	instance *core.WorkflowInstance,
+	state core.WorkflowInstanceState,
+	executedEvents, activityEvents, timerEvents []*history.Event,
+	workflowEvents []history.WorkflowEvent,
+) error {
+	taskQueue, taskPriority, taskID := parseQueuedTaskID(task.ID)
+
 	instanceState, err := readInstance(ctx, rb.rdb, instance.InstanceID)
 	if err != nil {
 		return err
 	}
 
+	if instanceState.State == core.WorkflowInstanceStateFinished && task.WorkflowInstanceState != core.WorkflowInstanceStateFinished {
+		// The instance has been terminated while the task was being executed, only release the task
+		if _, err := rb.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			_, err := rb.workflowQueue.Complete(ctx, p, taskQueue, taskPriority, taskID)
+			return err
+		}); err != nil {
+			return fmt.Errorf("completing workflow task: %w", err)
+		}
+
+		return backend.ErrInstanceNotActive
+	}
+
+	// Check if the workflow instance is continued as new
+	var continuedInstance *core.WorkflowInstance
+	var continuedMetadata *core.WorkflowMetadata
+	var continuedQueue core.Queue
+	var continuedPriority int
+	var continuedSearchAttributes core.SearchAttributes
+	for _, m := range workflowEvents {
+		if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted && m.WorkflowInstance.InstanceID == instance.InstanceID {
+			a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
+			continuedInstance = m.WorkflowInstance
+			continuedMetadata = a.Metadata
+			continuedQueue = a.Queue.OrDefault()
+			continuedPriority = a.Priority
+			continuedSearchAttributes = a.SearchAttributes
+		}
+	}
+
+	// Timers of an execution which is continued as new are removed, they must not fire for the new execution
+	var continuedTimers []*history.Event
+	if continuedInstance != nil {
+		h, err := rb.GetWorkflowInstanceHistory(ctx, instance, nil)
+		if err != nil {
+			return fmt.Errorf("getting workflow history: %w", err)
+		}
+
+		for _, e := range append(h, executedEvents...) {
+			if e.Type == history.EventType_TimerScheduled {
+				continuedTimers = append(continuedTimers, e)
+			}
+		}
+	}
+
+	// Signals and cancellations are only delivered to active instances. Read the current executions of instances
+	// receiving them, unless they are created by this task. Workflow tasks for other instances are queued on their
+	// respective queues, with their respective priorities.
+	groupedEvents := history.EventsByWorkflowInstanceID(workflowEvents)
+	deliveryTargets := make(map[string]*core.WorkflowInstance)
+	deliveryErrors := make(map[string]error)
+	targetQueues := make(map[string]core.Queue)
+	targetPriorities := make(map[string]int)
+	for targetInstanceID, events := range groupedEvents {
+		if targetInstanceID == instance.InstanceID {
+			continue
+		}
+
+		created, delivered := false, false
+		for _, m := range events {
+			switch m.HistoryEvent.Type {
+			case history.EventType_WorkflowExecutionStarted:
+				created = true
+				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
+				targetQueues[targetInstanceID] = a.Queue.OrDefault()
+				targetPriorities[targetInstanceID] = a.Priority
+			case history.EventType_SignalReceived, history.EventType_WorkflowExecutionCanceled:
+				delivered = true
+			}
+		}
+
+		if created {
+			continue
+		}
+
+		if !delivered {
+			queue, priority, err := instanceQueue(ctx, rb.rdb, targetInstanceID)
+			if err != nil {
+				return err
+			}
+
+			targetQueues[targetInstanceID] = queue
+			targetPriorities[targetInstanceID] = priority
+			continue
+		}
+
+		targetState, err := readInstancePipelineCmd(tx.Get(ctx, instanceKey(targetInstanceID)))
+		if err != nil {
+			if !errors.Is(err, backend.ErrInstanceNotFound) {
+				return err
+			}
+
+			deliveryErrors[targetInstanceID] = err
+			continue
+		}
+
+		if targetState.State != core.WorkflowInstanceStateActive {
+			deliveryErrors[targetInstanceID] = backend.ErrInstanceNotActive
+			continue
+		}
+
+		deliveryTargets[targetInstanceID] = targetState.Instance
+		targetQueues[targetInstanceID] = targetState.Queue
+		targetPriorities[targetInstanceID] = targetState.Priority
+	}
+
+	// Sub-workflow instances are terminated according to their parent close policy, as part of the same transaction
+	terminations := make([]*instanceTermination, 0)
+	terminatedInstances := make(map[string]bool)
+	for targetInstanceID, events := range groupedEvents {
+		if targetInstanceID == instance.InstanceID {
+			continue
+		}
+
+		for _, m := range events {
+			if m.HistoryEvent.Type != history.EventType_WorkflowExecutionTerminated {
+				continue
+			}
+
+			terminatedInstances[targetInstanceID] = true
+
+			// The parent is this workflow instance, which is closing, so it's not notified
+			ts, err := rb.readTerminationTree(ctx, targetInstanceID, m.HistoryEvent, false)
+			if err != nil {
+				if errors.Is(err, backend.ErrInstanceNotActive) || errors.Is(err, backend.ErrInstanceNotFound) {
+					continue
+				}
+
+				return fmt.Errorf("terminating sub-workflow instance: %w", err)
+			}
+
+			terminations = append(terminations, ts...)
+		}
+	}
+
 	// Check-point the workflow. We guarantee that no other worker is working on this workflow instance at this point via the
-	// task queue, so we don't need to WATCH the keys, we just need to make sure all commands are executed atomically to prevent
-	// a worker crashing in the middle of this execution.
-	p := rb.rdb.TxPipeline()
+	// task queue. The keys are watched for changes made by clients, e.g., terminations, and all commands are executed
+	// atomically to prevent a worker crashing in the middle of this execution.
+	p := tx.TxPipeline()
 
 	// Add executed events to the history
-	if err := addEventsToHistoryStreamP(ctx, p, historyKey(instance.InstanceID), executedEvents); err != nil {
+	if err := addEventsToHistoryStreamP(ctx, p, historyKey(instance.InstanceID, instance.ExecutionID), executedEvents); err != nil {
 		return fmt.Errorf("serializing : %w", err)
 	}
 
@@ -172,31 +439,65 @@ func (rb *redisBackend) CompleteWorkflowTask(
 		switch event.Type {
 		case history.EventType_TimerCanceled:
 			removeFutureEventP(ctx, p, instance, event)
+
+		case history.EventType_SearchAttributesUpserted:
+			a := event.Attributes.(*history.SearchAttributesUpsertedAttributes)
+			upsertSearchAttributesP(ctx, p, instanceState, a.SearchAttributes)
+
+		case history.EventType_WorkflowExecutionCanceled:
+			recordCancellationP(ctx, p, instance, event)
 		}
 	}
 
 	// Schedule timers
 	for _, timerEvent := range timerEvents {
-		if err := addFutureEventP(ctx, p, instance, timerEvent); err != nil {
+		if err := addFutureEventP(ctx, p, instance, instanceState.Queue, instanceState.Priority, timerEvent); err != nil {
 			return err
 		}
 	}
 
 	// Send new workflow events to the respective streams
-	groupedEvents := history.EventsByWorkflowInstanceID(workflowEvents)
 	for targetInstanceID, events := range groupedEvents {
+		if _, failed := deliveryErrors[targetInstanceID]; failed {
+			// Nothing to deliver, the instance does not exist or is not active anymore
+			continue
+		}
+
 		// Insert pending events for target instance
 		for _, m := range events {
 			m := m
 
-			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted {
+			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionTerminated && targetInstanceID != instance.InstanceID {
+				// Terminations are added below
+				continue
+			}
+
+			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted && targetInstanceID != instance.InstanceID {
 				// Create new instance
 				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
-				if err := createInstanceP(ctx, p, m.WorkflowInstance, a.Metadata, true); err != nil {
+				if err := createInstanceP(ctx, p, m.WorkflowInstance, a, true); err != nil {
+					return err
+				}
+
+				if err := addExecutionTimeoutP(ctx, p, m.WorkflowInstance, m.HistoryEvent); err != nil {
 					return err
 				}
 			}
 
+			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionCanceled {
+				canceledInstance := m.WorkflowInstance
+				if targetInstance, ok := deliveryTargets[targetInstanceID]; ok {
+					// Cancellations requested by other workflow instances don't know the current execution
+					canceledInstance = targetInstance
+
+					startDelayedInstanceP(ctx, p, canceledInstance)
+				} else if targetInstanceID == instance.InstanceID {
+					canceledInstance = instance
+				}
+
+				markCanceledP(ctx, p, canceledInstance)
+			}
+
 			// Add pending event to stream
 			if err := addEventToStreamP(ctx, p, pendingEventsKey(targetInstanceID), m.HistoryEvent); err != nil {
 				return err
@@ -204,37 +505,84 @@ func (rb *redisBackend) CompleteWorkflowTask(
 		}
 
 		// Try to queue workflow task
-		if targetInstanceID != instance.InstanceID {
-			if err := rb.workflowQueue.Enqueue(ctx, p, targetInstanceID, nil); err != nil {
+		if targetInstanceID != instance.InstanceID && !terminatedInstances[targetInstanceID] {
+			if err := rb.workflowQueue.Enqueue(
+				ctx, p, targetQueues[targetInstanceID], targetPriorities[targetInstanceID], targetInstanceID, nil); err != nil {
 				return fmt.Errorf("enqueuing workflow task: %w", err)
 			}
 		}
 	}
 
+	// Complete requests to signal or cancel other workflow instances, unless this execution has ended
+	if state != core.WorkflowInstanceStateFinished && continuedInstance == nil {
+		for _, event := range executedEvents {
+			if targetInstanceID, ok := history.ExternalWorkflowRequestTarget(event); ok {
+				result := history.NewExternalWorkflowResultEvent(time.Now(), event, deliveryErrors[targetInstanceID])
+				if err := addEventToStreamP(ctx, p, pendingEventsKey(instance.InstanceID), result); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, t := range terminations {
+		if err := rb.terminateInstanceP(ctx, p, t); err != nil {
+			return fmt.Errorf("terminating sub-workflow instance: %w", err)
+		}
+	}
+
 	instanceState.State = state
 
 	if state == core.WorkflowInstanceStateFinished {
 		t := time.Now()
 		instanceState.CompletedAt = &t
+
+		if continuedInstance == nil {
+			removeExecutionTimeoutP(ctx, p, instance)
+		}
 	}
 
 	if len(executedEvents) > 0 {
 		instanceState.LastSequenceID = executedEvents[len(executedEvents)-1].SequenceID
 	}
 
+	if continuedInstance != nil {
+		// Keep the finished execution
+		if err := archiveExecutionP(ctx, p, instanceState); err != nil {
+			return err
+		}
+
+		// Workflow instance continued as new, start new execution with a fresh history
+		instanceState.Instance = continuedInstance
+		instanceState.State = core.WorkflowInstanceStateActive
+		instanceState.Metadata = continuedMetadata
+		instanceState.Queue = continuedQueue
+		instanceState.Priority = continuedPriority
+		instanceState.CompletedAt = nil
+		instanceState.LastSequenceID = 0
+
+		// The new execution starts with the search attributes it has been continued with
+		replaceSearchAttributesP(ctx, p, instanceState, continuedSearchAttributes)
+	}
+
 	if err := updateInstanceP(ctx, p, instance.InstanceID, instanceState); err != nil {
 		return fmt.Errorf("updating workflow instance: %w", err)
 	}
 
 	// Store activity data
 	for _, activityEvent := range activityEvents {
-		if err := rb.activityQueue.Enqueue(ctx, p, activityEvent.ID, &activityData{
+		a := activityEvent.Attributes.(*history.ActivityScheduledAttributes)
+		if err := rb.activityQueue.Enqueue(ctx, p, a.Queue, a.Priority, activityEvent.ID, &activityData{
 			Instance: instance,
 			ID:       activityEvent.ID,
 			Event:    activityEvent,
 		}); err != nil {
 			return fmt.Errorf("queueing activity task: %w", err)
 		}
+
+		if err := trackActivityTimeoutP(ctx, p, instance, activityEvent); err != nil {
+			return fmt.Errorf("tracking activity timeout: %w", err)
+		}
 	}
 
 	// Remove executed pending events
@@ -243,22 +591,36 @@ func (rb *redisBackend) CompleteWorkflowTask(
 		removePendingEventsCmd.Run(ctx, p, []string{pendingEventsKey(instance.InstanceID)}, lastPendingEventMessageID)
 	}
 
+	if continuedInstance != nil {
+		// Results and timers of the previous execution are not delivered to the new execution
+		for _, e := range continuedTimers {
+			removeFutureEventP(ctx, p, instance, e)
+		}
+
+		removeExecutionEventsCmd.Run(ctx, p, []string{pendingEventsKey(instance.InstanceID)})
+	}
+
 	// Complete workflow task and unlock instance.
-	completeCmd, err := rb.workflowQueue.Complete(ctx, p, task.ID)
+	completeCmd, err := rb.workflowQueue.Complete(ctx, p, taskQueue, taskPriority, taskID)
 	if err != nil {
 		return fmt.Errorf("completing workflow task: %w", err)
 	}
 
 	// If there are pending events, queue the instance again
-	keyInfo := rb.workflowQueue.Keys()
+	keyInfo := rb.workflowQueue.Keys(instanceState.Queue, instanceState.Priority)
 	requeueInstanceCmd.Run(ctx, p,
-		[]string{pendingEventsKey(instance.InstanceID), keyInfo.StreamKey, keyInfo.SetKey},
-		instance.InstanceID,
+		[]string{pendingEventsKey(instance.InstanceID), keyInfo.StreamKey, keyInfo.SetKey, keyInfo.PrioritiesKey},
+		instance.InstanceID, instanceState.Priority,
 	)
 
 	// Commit transaction
 	executedCmds, err := p.Exec(ctx)
 	if err != nil {
+		if errors.Is(err, redis.TxFailedErr) {
+			// A watched key has changed, try again
+			return err
+		}
+
 		if err := completeCmd.Err(); err != nil && err == redis.Nil {
 			return fmt.Errorf("could not complete workflow task: %w", err)
 		}
@@ -284,14 +646,16 @@ func (rb *redisBackend) CompleteWorkflowTask(
 	return nil
 }
 
-func (rb *redisBackend) addWorkflowInstanceEventP(ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, event *history.Event) error {
+func (rb *redisBackend) addWorkflowInstanceEventP(
+	ctx context.Context, p redis.Pipeliner, instance *core.WorkflowInstance, queue core.Queue, priority int, event *history.Event,
+) error {
 	// Add event to pending events for instance
 	if err := addEventToStreamP(ctx, p, pendingEventsKey(instance.InstanceID), event); err != nil {
 		return err
 	}
 
 	// Queue workflow task
-	if err := rb.workflowQueue.Enqueue(ctx, p, instance.InstanceID, nil); err != nil {
+	if err := rb.workflowQueue.Enqueue(ctx, p, queue, priority, instance.InstanceID, nil); err != nil {
 		return fmt.Errorf("queueing workflow: %w", err)
 	}
 
@@ -3,6 +3,8 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"time"
 
 	"github.com/cschleiden/go-workflows/internal/history"
 )
@@ -13,10 +15,14 @@ func scheduleActivity(ctx context.Context, tx *sql.Tx, instanceID, executionID s
 		return err
 	}
 
+	a := event.Attributes.(*history.ActivityScheduledAttributes)
+	timeoutAt, _ := a.Timeouts.Deadline(event.Timestamp, nil, nil)
+
 	_, err = tx.ExecContext(
 		ctx,
 		`INSERT INTO activities
-			(id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			(id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at, timeout_at, queue, priority)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		event.ID,
 		instanceID,
 		executionID,
@@ -25,7 +31,142 @@ func scheduleActivity(ctx context.Context, tx *sql.Tx, instanceID, executionID s
 		event.ScheduleEventID,
 		attributes,
 		event.VisibleAt,
+		nullTime(timeoutAt),
+		string(a.Queue.OrDefault()),
+		a.Priority,
 	)
 
 	return err
 }
+
+// updateActivityTimeout recalculates the deadline of the given activity after it has been started or has sent a
+// heartbeat.
+func updateActivityTimeout(
+	ctx context.Context, tx *sql.Tx, id string, a *history.ActivityScheduledAttributes,
+	scheduledAt time.Time, startedAt, lastHeartbeatAt *time.Time,
+) error {
+	if a.Timeouts == nil {
+		return nil
+	}
+
+	timeoutAt, _ := a.Timeouts.Deadline(scheduledAt, startedAt, lastHeartbeatAt)
+
+	if _, err := tx.ExecContext(ctx, "UPDATE activities SET timeout_at = ? WHERE id = ?", nullTime(timeoutAt), id); err != nil {
+		return fmt.Errorf("updating activity timeout: %w", err)
+	}
+
+	return nil
+}
+
+// timeoutActivities fails all activities of active workflow instances which have exceeded one of their timeouts.
+// The activities are removed, and an ActivityFailed event is added to their workflow instances.
+func (sb *sqliteBackend) timeoutActivities(ctx context.Context, now time.Time) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT a.id, a.instance_id, a.schedule_event_id, a.timestamp, a.attributes, a.started_at, a.last_heartbeat_at, a.heartbeat_details
+			FROM activities a
+				INNER JOIN instances i ON i.id = a.instance_id AND i.execution_id = a.execution_id
+			WHERE a.timeout_at <= ? AND i.completed_at IS NULL`,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("finding timed out activities: %w", err)
+	}
+
+	type timedOutActivity struct {
+		id, instanceID   string
+		scheduleEventID  int64
+		scheduledAt      time.Time
+		attributes       []byte
+		startedAt        *time.Time
+		lastHeartbeatAt  *time.Time
+		heartbeatDetails []byte
+	}
+
+	var activities []timedOutActivity
+	for rows.Next() {
+		var a timedOutActivity
+		if err := rows.Scan(&a.id, &a.instanceID, &a.scheduleEventID, &a.scheduledAt, &a.attributes, &a.startedAt, &a.lastHeartbeatAt, &a.heartbeatDetails); err != nil {
+			rows.Close()
+			return fmt.Errorf("scanning timed out activity: %w", err)
+		}
+
+		activities = append(activities, a)
+	}
+
+	rows.Close()
+
+	if len(activities) == 0 {
+		return nil
+	}
+
+	for _, ta := range activities {
+		attributes, err := history.DeserializeAttributes(history.EventType_ActivityScheduled, ta.attributes)
+		if err != nil {
+			return fmt.Errorf("deserializing attributes: %w", err)
+		}
+
+		a := attributes.(*history.ActivityScheduledAttributes)
+		_, timeoutType := a.Timeouts.Deadline(ta.scheduledAt, ta.startedAt, ta.lastHeartbeatAt)
+
+		heartbeatDetails := a.HeartbeatDetails
+		if ta.heartbeatDetails != nil {
+			heartbeatDetails = ta.heartbeatDetails
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM activities WHERE id = ?", ta.id); err != nil {
+			return fmt.Errorf("removing timed out activity: %w", err)
+		}
+
+		if err := insertPendingEvents(ctx, tx, ta.instanceID, []*history.Event{
+			history.NewActivityTimedOutEvent(now, ta.scheduleEventID, timeoutType, heartbeatDetails),
+		}); err != nil {
+			return fmt.Errorf("inserting activity timeout event: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// activityCanceled returns true if the workflow instance which scheduled the activity with the given schedule event
+// id is not active anymore, or if it has been canceled after the activity was scheduled. Activities scheduled after the
+// cancellation, for example for cleanup, are not affected. Events are ordered by their history sequence ids, a pending
+// cancellation has not been seen by the workflow yet and is always ordered after the activity.
+func activityCanceled(ctx context.Context, tx *sql.Tx, instanceID, executionID string, scheduleEventID int64) (bool, error) {
+	row := tx.QueryRowContext(
+		ctx,
+		`SELECT
+			EXISTS (SELECT 1 FROM instances WHERE id = ? AND (completed_at IS NOT NULL OR execution_id != ?))
+			OR EXISTS (SELECT 1 FROM pending_events WHERE instance_id = ? AND event_type = ?)
+			OR EXISTS (
+				SELECT 1 FROM history c
+				INNER JOIN history s ON s.instance_id = c.instance_id AND s.execution_id = c.execution_id
+				WHERE c.instance_id = ? AND c.execution_id = ? AND c.event_type = ?
+					AND s.event_type = ? AND s.schedule_event_id = ? AND c.sequence_id > s.sequence_id
+			)`,
+		instanceID, executionID,
+		instanceID, history.EventType_WorkflowExecutionCanceled,
+		instanceID, executionID, history.EventType_WorkflowExecutionCanceled, history.EventType_ActivityScheduled, scheduleEventID,
+	)
+
+	var canceled bool
+	if err := row.Scan(&canceled); err != nil {
+		return false, fmt.Errorf("checking for workflow cancellation: %w", err)
+	}
+
+	return canceled, nil
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
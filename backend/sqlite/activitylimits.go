@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/ratelimit"
+)
+
+var _ backend.ActivityLimiter = (*sqliteBackend)(nil)
+
+func (sb *sqliteBackend) AcquireActivitySlot(ctx context.Context, activityName, holder string, limit int, lease time.Duration) (bool, error) {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	// Release expired slots
+	if _, err := tx.ExecContext(
+		ctx, "DELETE FROM `activity_slots` WHERE activity_name = ? AND expires_at <= ?", activityName, now,
+	); err != nil {
+		return false, fmt.Errorf("releasing expired activity slots: %w", err)
+	}
+
+	// Extend slot if it's already held
+	res, err := tx.ExecContext(
+		ctx,
+		"UPDATE `activity_slots` SET expires_at = ? WHERE activity_name = ? AND holder = ?",
+		now.Add(lease), activityName, holder,
+	)
+	if err != nil {
+		return false, fmt.Errorf("extending activity slot: %w", err)
+	}
+
+	if rows, err := res.RowsAffected(); err != nil {
+		return false, err
+	} else if rows == 0 {
+		var taken int
+		if err := tx.QueryRowContext(
+			ctx, "SELECT COUNT(*) FROM `activity_slots` WHERE activity_name = ?", activityName,
+		).Scan(&taken); err != nil {
+			return false, fmt.Errorf("counting activity slots: %w", err)
+		}
+
+		if taken >= limit {
+			return false, nil
+		}
+
+		if _, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO `activity_slots` (activity_name, holder, expires_at) VALUES (?, ?, ?)",
+			activityName, holder, now.Add(lease),
+		); err != nil {
+			return false, fmt.Errorf("inserting activity slot: %w", err)
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+func (sb *sqliteBackend) ReleaseActivitySlot(ctx context.Context, activityName, holder string) error {
+	if _, err := sb.db.ExecContext(
+		ctx, "DELETE FROM `activity_slots` WHERE activity_name = ? AND holder = ?", activityName, holder,
+	); err != nil {
+		return fmt.Errorf("releasing activity slot: %w", err)
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) TakeActivityToken(ctx context.Context, activityName string, rate float64, burst int) (time.Duration, error) {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	// Insert row for a full bucket if there is none yet, this also locks the database for writing
+	if _, err := tx.ExecContext(
+		ctx, "INSERT OR IGNORE INTO `activity_limits` (activity_name) VALUES (?)", activityName,
+	); err != nil {
+		return 0, fmt.Errorf("inserting activity limit: %w", err)
+	}
+
+	var tokens float64
+	var updatedAt sql.NullTime
+	if err := tx.QueryRowContext(
+		ctx, "SELECT tokens, updated_at FROM `activity_limits` WHERE activity_name = ?", activityName,
+	).Scan(&tokens, &updatedAt); err != nil {
+		return 0, fmt.Errorf("reading activity limit: %w", err)
+	}
+
+	bucket := ratelimit.NewBucket(burst, now)
+	if updatedAt.Valid {
+		bucket = ratelimit.Bucket{Tokens: tokens, UpdatedAt: updatedAt.Time}
+	}
+
+	if wait := bucket.Take(now, rate, burst); wait > 0 {
+		return wait, nil
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE `activity_limits` SET tokens = ?, updated_at = ? WHERE activity_name = ?",
+		bucket.Tokens, bucket.UpdatedAt.UTC(), activityName,
+	); err != nil {
+		return 0, fmt.Errorf("updating activity limit: %w", err)
+	}
+
+	return 0, tx.Commit()
+}
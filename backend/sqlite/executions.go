@@ -0,0 +1,203 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/workflow"
+)
+
+// The instances table contains the current execution of every workflow instance. When an execution is replaced, by
+// continuing as new or by starting a new execution with the ID of a finished instance, it's moved to the executions
+// table. Histories are stored per execution, so past executions remain inspectable.
+
+func (sb *sqliteBackend) ListWorkflowInstanceExecutions(ctx context.Context, instanceID string) ([]*backend.WorkflowInstanceInfo, error) {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := scanInstanceInfo(tx.QueryRowContext(
+		ctx,
+		`SELECT id, execution_id, workflow_name, parent_instance_id, parent_schedule_event_id, metadata, search_attributes, created_at, completed_at
+			FROM instances WHERE id = ?`,
+		instanceID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, backend.ErrInstanceNotFound
+		}
+
+		return nil, err
+	}
+
+	executions := []*backend.WorkflowInstanceInfo{current}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT instance_id, execution_id, workflow_name, parent_instance_id, parent_schedule_event_id, metadata, search_attributes, created_at, completed_at
+			FROM executions WHERE instance_id = ? ORDER BY rowid DESC`,
+		instanceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing workflow instance executions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		info, err := scanInstanceInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		executions = append(executions, info)
+	}
+
+	return executions, rows.Err()
+}
+
+// startExecution creates the given workflow instance. If an instance with the same ID exists, a new execution is
+// started if the given policy allows it, otherwise ErrInstanceAlreadyExists is returned.
+func startExecution(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, a *history.ExecutionStartedAttributes, policy core.IDReusePolicy) error {
+	err := createInstance(ctx, tx, wfi, a, false)
+	if err != backend.ErrInstanceAlreadyExists {
+		return err
+	}
+
+	var executionID string
+	var completedAt *time.Time
+	if err := tx.QueryRowContext(
+		ctx, "SELECT execution_id, completed_at FROM `instances` WHERE id = ?", wfi.InstanceID,
+	).Scan(&executionID, &completedAt); err != nil {
+		return fmt.Errorf("reading existing workflow instance: %w", err)
+	}
+
+	state := core.WorkflowInstanceStateActive
+	if completedAt != nil {
+		state = core.WorkflowInstanceStateFinished
+	}
+
+	allowed, err := policy.AllowsReuse(state, func() (bool, error) {
+		return executionFailed(ctx, tx, wfi.InstanceID, executionID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return backend.ErrInstanceAlreadyExists
+	}
+
+	if state == core.WorkflowInstanceStateActive {
+		if err := terminateInstance(
+			ctx, tx, wfi.InstanceID, history.NewWorkflowTerminationEvent(time.Now(), "replaced by new execution"),
+		); err != nil {
+			return fmt.Errorf("terminating running execution: %w", err)
+		}
+	}
+
+	return reuseInstance(ctx, tx, wfi, a)
+}
+
+// executionFailed returns whether the given, finished execution has failed, or has been canceled or terminated
+func executionFailed(ctx context.Context, tx *sql.Tx, instanceID, executionID string) (bool, error) {
+	lastEvent, err := scanEvent(tx.QueryRowContext(
+		ctx,
+		"SELECT id, sequence_id, instance_id, event_type, timestamp, schedule_event_id, attributes, visible_at FROM `history` WHERE instance_id = ? AND execution_id = ? ORDER BY sequence_id DESC LIMIT 1",
+		instanceID, executionID,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("reading last history event: %w", err)
+	}
+
+	return history.ExecutionFailed(lastEvent), nil
+}
+
+// reuseInstance replaces the finished, current execution of the workflow instance with a new execution. Pending
+// events and activities of the previous execution are discarded.
+func reuseInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, a *history.ExecutionStartedAttributes) error {
+	if err := archiveExecution(ctx, tx, wfi.InstanceID); err != nil {
+		return err
+	}
+
+	var parentInstanceID *string
+	var parentEventID *int64
+	if wfi.SubWorkflow() {
+		i := wfi.ParentInstanceID
+		parentInstanceID = &i
+
+		n := wfi.ParentEventID
+		parentEventID = &n
+	}
+
+	metadataJson, err := json.Marshal(a.Metadata)
+	if err != nil {
+		return fmt.Errorf("marshaling metadata: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE instances SET
+			execution_id = ?, workflow_name = ?, parent_instance_id = ?, parent_schedule_event_id = ?, metadata = ?, search_attributes = NULL,
+			queue = ?, priority = ?, created_at = CURRENT_TIMESTAMP, completed_at = NULL, locked_until = NULL, sticky_until = NULL, worker = NULL
+			WHERE id = ?`,
+		wfi.ExecutionID,
+		a.Name,
+		parentInstanceID,
+		parentEventID,
+		string(metadataJson),
+		string(a.Queue.OrDefault()),
+		a.Priority,
+		wfi.InstanceID,
+	); err != nil {
+		return fmt.Errorf("starting new workflow instance execution: %w", err)
+	}
+
+	if err := replaceMetadata(ctx, tx, wfi.InstanceID, a.Metadata); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `instance_search_attributes` WHERE instance_id = ?", wfi.InstanceID); err != nil {
+		return fmt.Errorf("removing search attributes: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `pending_events` WHERE instance_id = ?", wfi.InstanceID); err != nil {
+		return fmt.Errorf("removing pending events: %w", err)
+	}
+
+	// Running activities of the previous execution can't complete anymore
+	if _, err := tx.ExecContext(
+		ctx, "DELETE FROM `activities` WHERE instance_id = ? AND execution_id != ?", wfi.InstanceID, wfi.ExecutionID,
+	); err != nil {
+		return fmt.Errorf("removing activities: %w", err)
+	}
+
+	return upsertSearchAttributes(ctx, tx, wfi.InstanceID, a.SearchAttributes)
+}
+
+// archiveExecution copies the current execution of the workflow instance to the executions table
+func archiveExecution(ctx context.Context, tx *sql.Tx, instanceID string) error {
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO executions (instance_id, execution_id, workflow_name, parent_instance_id, parent_schedule_event_id, metadata, search_attributes, created_at, completed_at)
+			SELECT id, execution_id, workflow_name, parent_instance_id, parent_schedule_event_id, metadata, search_attributes, created_at, completed_at
+			FROM instances WHERE id = ?`,
+		instanceID,
+	); err != nil {
+		return fmt.Errorf("archiving workflow instance execution: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+func (sb *sqliteBackend) ListWorkflowInstances(ctx context.Context, filter *backend.WorkflowInstanceFilter, afterInstanceID string, count int) ([]*backend.WorkflowInstanceInfo, error) {
+	where, args := instanceFilter(filter)
+
+	if afterInstanceID != "" {
+		where = append(where,
+			"(i.created_at < (SELECT created_at FROM instances WHERE id = ?) OR (i.created_at = (SELECT created_at FROM instances WHERE id = ?) AND i.id < ?))")
+		args = append(args, afterInstanceID, afterInstanceID, afterInstanceID)
+	}
+
+	args = append(args, count)
+
+	rows, err := sb.db.QueryContext(
+		ctx,
+		`SELECT i.id, i.execution_id, i.workflow_name, i.parent_instance_id, i.parent_schedule_event_id, i.metadata, i.search_attributes, i.created_at, i.completed_at
+		FROM instances i`+whereClause(where)+`
+		ORDER BY i.created_at DESC, i.id DESC
+		LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing workflow instances: %w", err)
+	}
+	defer rows.Close()
+
+	instances := make([]*backend.WorkflowInstanceInfo, 0)
+
+	for rows.Next() {
+		info, err := scanInstanceInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		instances = append(instances, info)
+	}
+
+	return instances, rows.Err()
+}
+
+func (sb *sqliteBackend) CountWorkflowInstances(ctx context.Context, filter *backend.WorkflowInstanceFilter) (int64, error) {
+	where, args := instanceFilter(filter)
+
+	var count int64
+	if err := sb.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM instances i"+whereClause(where), args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting workflow instances: %w", err)
+	}
+
+	return count, nil
+}
+
+// scanInstanceInfo scans id, execution_id, workflow_name, parent_instance_id, parent_schedule_event_id, metadata,
+// search_attributes, created_at, and completed_at of an instance or execution
+func scanInstanceInfo(row Scanner) (*backend.WorkflowInstanceInfo, error) {
+	var id, executionID string
+	var workflowName, parentInstanceID, metadataJson, searchAttributesJson *string
+	var parentEventID *int64
+	var createdAt time.Time
+	var completedAt *time.Time
+
+	if err := row.Scan(&id, &executionID, &workflowName, &parentInstanceID, &parentEventID, &metadataJson, &searchAttributesJson, &createdAt, &completedAt); err != nil {
+		return nil, fmt.Errorf("scanning workflow instance: %w", err)
+	}
+
+	info := &backend.WorkflowInstanceInfo{
+		CreatedAt:   createdAt,
+		CompletedAt: completedAt,
+	}
+
+	if parentInstanceID != nil {
+		info.Instance = core.NewSubWorkflowInstance(id, executionID, *parentInstanceID, *parentEventID)
+	} else {
+		info.Instance = core.NewWorkflowInstance(id, executionID)
+	}
+
+	if workflowName != nil {
+		info.WorkflowName = *workflowName
+	}
+
+	if completedAt != nil {
+		info.State = core.WorkflowInstanceStateFinished
+	}
+
+	if metadataJson != nil {
+		if err := json.Unmarshal([]byte(*metadataJson), &info.Metadata); err != nil {
+			return nil, fmt.Errorf("unmarshaling metadata: %w", err)
+		}
+	}
+
+	if searchAttributesJson != nil {
+		if err := json.Unmarshal([]byte(*searchAttributesJson), &info.SearchAttributes); err != nil {
+			return nil, fmt.Errorf("unmarshaling search attributes: %w", err)
+		}
+	}
+
+	return info, nil
+}
+
+// instanceFilter returns the conditions and arguments for the given filter. created_at is stored by sqlite with
+// second precision in UTC, completed_at as formatted by the driver, so both are compared after normalization.
+// completed_at is indexed by its normalized value.
+func instanceFilter(filter *backend.WorkflowInstanceFilter) ([]string, []interface{}) {
+	where := []string{}
+	args := []interface{}{}
+
+	if filter == nil {
+		return where, args
+	}
+
+	if filter.WorkflowName != "" {
+		where = append(where, "i.workflow_name = ?")
+		args = append(args, filter.WorkflowName)
+	}
+
+	if filter.State != nil {
+		if *filter.State == core.WorkflowInstanceStateFinished {
+			where = append(where, "i.completed_at IS NOT NULL")
+		} else {
+			where = append(where, "i.completed_at IS NULL")
+		}
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		where = append(where, "i.created_at >= datetime(?)")
+		args = append(args, filter.CreatedAfter.UTC())
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		where = append(where, "i.created_at < datetime(?)")
+		args = append(args, filter.CreatedBefore.UTC())
+	}
+
+	if !filter.CompletedAfter.IsZero() {
+		where = append(where, "julianday(i.completed_at) >= julianday(?)")
+		args = append(args, filter.CompletedAfter.UTC())
+	}
+
+	if !filter.CompletedBefore.IsZero() {
+		where = append(where, "julianday(i.completed_at) < julianday(?)")
+		args = append(args, filter.CompletedBefore.UTC())
+	}
+
+	if filter.ParentInstanceID != "" {
+		where = append(where, "i.parent_instance_id = ?")
+		args = append(args, filter.ParentInstanceID)
+	}
+
+	for k, v := range filter.Metadata {
+		where = append(where, "i.id IN (SELECT instance_id FROM instance_metadata WHERE name = ? AND value = ?)")
+		args = append(args, k, v)
+	}
+
+	for name, v := range filter.SearchAttributes {
+		values := v.IndexValues()
+		if len(values) == 0 {
+			// Empty keyword lists are not indexed, only check the type
+			where = append(where, "json_extract(i.search_attributes, ?) = ?")
+			args = append(args, metadataPath(name)+".type", v.Type().String())
+			continue
+		}
+
+		for _, value := range values {
+			where = append(where,
+				"EXISTS (SELECT 1 FROM instance_search_attributes sa WHERE sa.instance_id = i.id AND sa.name = ? AND sa.type = ? AND sa.value = ?)")
+			args = append(args, name, v.Type(), value)
+		}
+	}
+
+	return where, args
+}
+
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(where, " AND ")
+}
+
+// metadataPath returns the JSON path for the given search attribute name
+func metadataPath(key string) string {
+	key = strings.ReplaceAll(key, `\`, `\\`)
+	key = strings.ReplaceAll(key, `"`, `\"`)
+
+	return `$."` + key + `"`
+}
@@ -0,0 +1,212 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/schedule"
+)
+
+const scheduleColumns = "id, spec, workflow_name, inputs, paused, created_at, next_run_at, buffered_run_at, last_instance_id, last_execution_id, due_at"
+
+func (sb *sqliteBackend) CreateSchedule(ctx context.Context, s *schedule.Schedule) error {
+	spec, err := json.Marshal(s.Spec)
+	if err != nil {
+		return fmt.Errorf("marshaling schedule spec: %w", err)
+	}
+
+	inputs, err := json.Marshal(s.Inputs)
+	if err != nil {
+		return fmt.Errorf("marshaling schedule inputs: %w", err)
+	}
+
+	res, err := sb.db.ExecContext(
+		ctx,
+		"INSERT OR IGNORE INTO `schedules` (id, spec, workflow_name, inputs, paused, created_at, next_run_at, due_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		s.ID,
+		string(spec),
+		s.WorkflowName,
+		inputs,
+		s.Paused,
+		s.CreatedAt.UTC(),
+		s.NextRunAt.UTC(),
+		s.DueAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("inserting schedule: %w", err)
+	}
+
+	if rows, err := res.RowsAffected(); err != nil {
+		return err
+	} else if rows != 1 {
+		return backend.ErrScheduleAlreadyExists
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) GetSchedule(ctx context.Context, id string) (*schedule.Schedule, error) {
+	row := sb.db.QueryRowContext(ctx, "SELECT "+scheduleColumns+" FROM `schedules` WHERE id = ?", id)
+
+	s, err := scanSchedule(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, backend.ErrScheduleNotFound
+		}
+
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (sb *sqliteBackend) PauseSchedule(ctx context.Context, id string) error {
+	res, err := sb.db.ExecContext(ctx, "UPDATE `schedules` SET paused = 1 WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("pausing schedule: %w", err)
+	}
+
+	return scheduleAffected(res)
+}
+
+func (sb *sqliteBackend) ResumeSchedule(ctx context.Context, id string, nextRunAt time.Time) error {
+	res, err := sb.db.ExecContext(ctx, "UPDATE `schedules` SET paused = 0, next_run_at = ?, due_at = ? WHERE id = ?", nextRunAt.UTC(), nextRunAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("resuming schedule: %w", err)
+	}
+
+	return scheduleAffected(res)
+}
+
+func (sb *sqliteBackend) DeleteSchedule(ctx context.Context, id string) error {
+	res, err := sb.db.ExecContext(ctx, "DELETE FROM `schedules` WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("deleting schedule: %w", err)
+	}
+
+	return scheduleAffected(res)
+}
+
+func (sb *sqliteBackend) GetScheduleTask(ctx context.Context) (*schedule.Schedule, error) {
+	now := time.Now().UTC()
+
+	// Lock next due schedule
+	// (work around missing LIMIT support in sqlite driver for UPDATE statements by using sub-query)
+	row := sb.db.QueryRowContext(
+		ctx,
+		`UPDATE schedules
+			SET locked_until = ?, worker = ?
+			WHERE rowid = (
+				SELECT rowid FROM schedules
+					WHERE
+						paused = 0
+						AND (locked_until IS NULL OR locked_until < ?)
+						AND due_at <= ?
+					LIMIT 1
+			) RETURNING `+scheduleColumns,
+		now.Add(sb.options.WorkflowLockTimeout),
+		sb.workerName,
+		now, // locked_until
+		now, // due_at
+	)
+
+	s, err := scanSchedule(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("locking schedule: %w", err)
+	}
+
+	return s, nil
+}
+
+func (sb *sqliteBackend) CompleteScheduleTask(ctx context.Context, s *schedule.Schedule) error {
+	var bufferedRunAt *time.Time
+	if s.BufferedRunAt != nil {
+		t := s.BufferedRunAt.UTC()
+		bufferedRunAt = &t
+	}
+
+	var lastInstanceID, lastExecutionID *string
+	if s.LastInstance != nil {
+		lastInstanceID = &s.LastInstance.InstanceID
+		lastExecutionID = &s.LastInstance.ExecutionID
+	}
+
+	res, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE schedules
+			SET next_run_at = ?, buffered_run_at = ?, last_instance_id = ?, last_execution_id = ?, due_at = ?, locked_until = NULL, worker = NULL
+			WHERE id = ? AND worker = ?`,
+		s.NextRunAt.UTC(),
+		bufferedRunAt,
+		lastInstanceID,
+		lastExecutionID,
+		s.DueAt.UTC(),
+		s.ID,
+		sb.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("completing schedule task: %w", err)
+	}
+
+	return scheduleAffected(res)
+}
+
+func scanSchedule(row Scanner) (*schedule.Schedule, error) {
+	s := &schedule.Schedule{}
+
+	var spec string
+	var inputs []byte
+	var nextRunAt, bufferedRunAt *time.Time
+	var dueAt time.Time
+	var lastInstanceID, lastExecutionID *string
+	if err := row.Scan(
+		&s.ID, &spec, &s.WorkflowName, &inputs, &s.Paused, &s.CreatedAt, &nextRunAt, &bufferedRunAt,
+		&lastInstanceID, &lastExecutionID, &dueAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(spec), &s.Spec); err != nil {
+		return nil, fmt.Errorf("unmarshaling schedule spec: %w", err)
+	}
+
+	if err := json.Unmarshal(inputs, &s.Inputs); err != nil {
+		return nil, fmt.Errorf("unmarshaling schedule inputs: %w", err)
+	}
+
+	if nextRunAt != nil {
+		s.NextRunAt = nextRunAt.UTC()
+	}
+
+	if bufferedRunAt != nil {
+		t := bufferedRunAt.UTC()
+		s.BufferedRunAt = &t
+	}
+
+	if lastInstanceID != nil {
+		s.LastInstance = core.NewWorkflowInstance(*lastInstanceID, *lastExecutionID)
+	}
+
+	s.CreatedAt = s.CreatedAt.UTC()
+	s.DueAt = dueAt.UTC()
+
+	return s, nil
+}
+
+func scheduleAffected(res sql.Result) error {
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("checking for updated schedules: %w", err)
+	} else if n != 1 {
+		return backend.ErrScheduleNotFound
+	}
+
+	return nil
+}
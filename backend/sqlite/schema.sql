@@ -1,9 +1,13 @@
 CREATE TABLE IF NOT EXISTS `instances` (
   `id` TEXT PRIMARY KEY,
   `execution_id` TEXT NO NULL,
+  `workflow_name` TEXT NULL,
   `parent_instance_id` TEXT NULL,
   `parent_schedule_event_id` INTEGER NULL,
   `metadata` TEXT NULL,
+  `search_attributes` TEXT NULL,
+  `queue` TEXT NOT NULL DEFAULT 'default',
+  `priority` INTEGER NOT NULL DEFAULT 0,
   `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
   `completed_at` DATETIME NULL,
   `locked_until` DATETIME NULL,
@@ -12,7 +16,43 @@ CREATE TABLE IF NOT EXISTS `instances` (
 );
 
 CREATE INDEX IF NOT EXISTS `idx_instances_locked_until_completed_at` ON `instances` (`locked_until`, `sticky_until`, `completed_at`, `worker`);
+CREATE INDEX IF NOT EXISTS `idx_instances_queue` ON `instances` (`queue`, `completed_at`);
 CREATE INDEX IF NOT EXISTS `idx_instances_parent_instance_id` ON `instances` (`parent_instance_id`);
+CREATE INDEX IF NOT EXISTS `idx_instances_created_at` ON `instances` (`created_at`, `id`);
+CREATE INDEX IF NOT EXISTS `idx_instances_workflow_name_created_at` ON `instances` (`workflow_name`, `created_at`);
+CREATE INDEX IF NOT EXISTS `idx_instances_completed_at` ON `instances` (julianday(`completed_at`));
+
+CREATE TABLE IF NOT EXISTS `executions` (
+  `instance_id` TEXT NOT NULL,
+  `execution_id` TEXT NOT NULL,
+  `workflow_name` TEXT NULL,
+  `parent_instance_id` TEXT NULL,
+  `parent_schedule_event_id` INTEGER NULL,
+  `metadata` TEXT NULL,
+  `search_attributes` TEXT NULL,
+  `created_at` DATETIME NOT NULL,
+  `completed_at` DATETIME NULL,
+  PRIMARY KEY(`instance_id`, `execution_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `instance_search_attributes` (
+  `instance_id` TEXT NOT NULL,
+  `name` TEXT NOT NULL,
+  `type` INTEGER NOT NULL,
+  `value` TEXT NOT NULL,
+  PRIMARY KEY(`instance_id`, `name`, `value`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_instance_search_attributes_name_type_value` ON `instance_search_attributes` (`name`, `type`, `value`);
+
+CREATE TABLE IF NOT EXISTS `instance_metadata` (
+  `instance_id` TEXT NOT NULL,
+  `name` TEXT NOT NULL,
+  `value` TEXT NOT NULL,
+  PRIMARY KEY(`instance_id`, `name`)
+);
+
+CREATE INDEX IF NOT EXISTS `idx_instance_metadata_name_value` ON `instance_metadata` (`name`, `value`);
 
 CREATE TABLE IF NOT EXISTS `pending_events` (
   `id` TEXT,
@@ -27,11 +67,13 @@ CREATE TABLE IF NOT EXISTS `pending_events` (
 );
 
 CREATE INDEX IF NOT EXISTS `idx_pending_events_instance_id_visible_at_schedule_event_id` ON `pending_events` (`instance_id`, `visible_at`, `schedule_event_id`);
+CREATE INDEX IF NOT EXISTS `idx_pending_events_event_type_visible_at` ON `pending_events` (`event_type`, `visible_at`);
 
 CREATE TABLE IF NOT EXISTS `history` (
   `id` TEXT,
   `sequence_id` INTEGER NOT NULL,
   `instance_id` TEXT NOT NULL,
+  `execution_id` TEXT NOT NULL,
   `event_type` INTEGER NOT NULL,
   `timestamp` DATETIME NOT NULL,
   `schedule_event_id` INT NOT NULL,
@@ -40,7 +82,7 @@ CREATE TABLE IF NOT EXISTS `history` (
   PRIMARY KEY(`id`, `instance_id`)
 );
 
-CREATE INDEX IF NOT EXISTS `idx_history_instance_sequence_id` ON `history` (`instance_id`, `sequence_id`);
+CREATE INDEX IF NOT EXISTS `idx_history_instance_execution_sequence_id` ON `history` (`instance_id`, `execution_id`, `sequence_id`);
 
 CREATE TABLE IF NOT EXISTS `activities` (
   `id` TEXT PRIMARY KEY,
@@ -52,5 +94,46 @@ CREATE TABLE IF NOT EXISTS `activities` (
   `attributes` BLOB NOT NULL,
   `visible_at` DATETIME NULL,
   `locked_until` DATETIME NULL,
+  `worker` TEXT NULL,
+  `started_at` DATETIME NULL,
+  `last_heartbeat_at` DATETIME NULL,
+  `timeout_at` DATETIME NULL,
+  `heartbeat_details` BLOB NULL,
+  `completion_pending` INTEGER NOT NULL DEFAULT 0,
+  `queue` TEXT NOT NULL DEFAULT 'default',
+  `priority` INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS `idx_activities_timeout_at` ON `activities` (`timeout_at`);
+CREATE INDEX IF NOT EXISTS `idx_activities_queue_locked_until` ON `activities` (`queue`, `locked_until`);
+
+CREATE TABLE IF NOT EXISTS `schedules` (
+  `id` TEXT PRIMARY KEY,
+  `spec` TEXT NOT NULL,
+  `workflow_name` TEXT NOT NULL,
+  `inputs` BLOB NOT NULL,
+  `paused` INTEGER NOT NULL DEFAULT 0,
+  `created_at` DATETIME NOT NULL,
+  `next_run_at` DATETIME NULL,
+  `buffered_run_at` DATETIME NULL,
+  `due_at` DATETIME NOT NULL,
+  `last_instance_id` TEXT NULL,
+  `last_execution_id` TEXT NULL,
+  `locked_until` DATETIME NULL,
   `worker` TEXT NULL
-);
\ No newline at end of file
+);
+
+CREATE INDEX IF NOT EXISTS `idx_schedules_due_at` ON `schedules` (`due_at`);
+
+CREATE TABLE IF NOT EXISTS `activity_limits` (
+  `activity_name` TEXT PRIMARY KEY,
+  `tokens` REAL NOT NULL DEFAULT 0,
+  `updated_at` DATETIME NULL
+);
+
+CREATE TABLE IF NOT EXISTS `activity_slots` (
+  `activity_name` TEXT NOT NULL,
+  `holder` TEXT NOT NULL,
+  `expires_at` DATETIME NOT NULL,
+  PRIMARY KEY(`activity_name`, `holder`)
+);
@@ -15,6 +15,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/metrics"
@@ -87,12 +88,18 @@ func (sb *sqliteBackend) CreateWorkflowInstance(ctx context.Context, instance *w
 	}
 	defer tx.Rollback()
 
-	// Create workflow instance
-	if err := createInstance(ctx, tx, instance, event.Attributes.(*history.ExecutionStartedAttributes).Metadata, false); err != nil {
+	// Create workflow instance, or start a new execution of an existing instance
+	a := event.Attributes.(*history.ExecutionStartedAttributes)
+	if err := startExecution(ctx, tx, instance, a, a.IDReusePolicy); err != nil {
 		return err
 	}
 
-	if err := insertPendingEvents(ctx, tx, instance.InstanceID, []*history.Event{event}); err != nil {
+	events := []*history.Event{event}
+	if timeoutEvent := history.NewExecutionTimeoutEvent(event); timeoutEvent != nil {
+		events = append(events, timeoutEvent)
+	}
+
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, events); err != nil {
 		return fmt.Errorf("inserting new event: %w", err)
 	}
 
@@ -103,7 +110,7 @@ func (sb *sqliteBackend) CreateWorkflowInstance(ctx context.Context, instance *w
 	return nil
 }
 
-func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, metadata *workflow.Metadata, ignoreDuplicate bool) error {
+func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, a *history.ExecutionStartedAttributes, ignoreDuplicate bool) error {
 	var parentInstanceID *string
 	var parentEventID *int64
 	if wfi.SubWorkflow() {
@@ -114,38 +121,102 @@ func createInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, met
 		parentEventID = &n
 	}
 
-	metadataJson, err := json.Marshal(metadata)
+	metadataJson, err := json.Marshal(a.Metadata)
 	if err != nil {
 		return fmt.Errorf("marshaling metadata: %w", err)
 	}
 
 	res, err := tx.ExecContext(
 		ctx,
-		"INSERT OR IGNORE INTO `instances` (id, execution_id, parent_instance_id, parent_schedule_event_id, metadata) VALUES (?, ?, ?, ?, ?)",
+		"INSERT OR IGNORE INTO `instances` (id, execution_id, workflow_name, parent_instance_id, parent_schedule_event_id, metadata, queue, priority) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
 		wfi.InstanceID,
 		wfi.ExecutionID,
+		a.Name,
 		parentInstanceID,
 		parentEventID,
 		string(metadataJson),
+		string(a.Queue.OrDefault()),
+		a.Priority,
 	)
 	if err != nil {
 		return fmt.Errorf("inserting workflow instance: %w", err)
 	}
 
-	if !ignoreDuplicate {
-		rows, err := res.RowsAffected()
-		if err != nil {
-			return err
-		}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
 
-		if rows != 1 {
-			return backend.ErrInstanceAlreadyExists
+	if rows != 1 {
+		if ignoreDuplicate {
+			return nil
 		}
+
+		return backend.ErrInstanceAlreadyExists
+	}
+
+	if err := replaceMetadata(ctx, tx, wfi.InstanceID, a.Metadata); err != nil {
+		return err
+	}
+
+	if err := upsertSearchAttributes(ctx, tx, wfi.InstanceID, a.SearchAttributes); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// continueInstance replaces the current execution of the workflow instance with a new execution
+func continueInstance(ctx context.Context, tx *sql.Tx, wfi *workflow.Instance, a *history.ExecutionStartedAttributes) error {
+	if err := archiveExecution(ctx, tx, wfi.InstanceID); err != nil {
+		return err
+	}
+
+	metadataJson, err := json.Marshal(a.Metadata)
+	if err != nil {
+		return fmt.Errorf("marshaling metadata: %w", err)
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE `instances` SET execution_id = ?, workflow_name = ?, metadata = ?, search_attributes = NULL, queue = ?, priority = ?, completed_at = NULL WHERE id = ?",
+		wfi.ExecutionID,
+		a.Name,
+		string(metadataJson),
+		string(a.Queue.OrDefault()),
+		a.Priority,
+		wfi.InstanceID,
+	); err != nil {
+		return fmt.Errorf("continuing workflow instance: %w", err)
+	}
+
+	// Results and timers of the previous execution are not delivered to the new execution. Events without a schedule
+	// event id, like signals, are kept.
+	if _, err := tx.ExecContext(
+		ctx, "DELETE FROM `pending_events` WHERE instance_id = ? AND schedule_event_id != 0", wfi.InstanceID,
+	); err != nil {
+		return fmt.Errorf("removing pending events of previous execution: %w", err)
+	}
+
+	// Activities of the previous execution are discarded, running activities can't complete anymore
+	if _, err := tx.ExecContext(
+		ctx, "DELETE FROM `activities` WHERE instance_id = ? AND execution_id != ?", wfi.InstanceID, wfi.ExecutionID,
+	); err != nil {
+		return fmt.Errorf("removing activities of previous execution: %w", err)
+	}
+
+	if err := replaceMetadata(ctx, tx, wfi.InstanceID, a.Metadata); err != nil {
+		return err
+	}
+
+	// The new execution starts with the search attributes it has been continued with
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `instance_search_attributes` WHERE instance_id = ?", wfi.InstanceID); err != nil {
+		return fmt.Errorf("removing search attributes of previous execution: %w", err)
+	}
+
+	return upsertSearchAttributes(ctx, tx, wfi.InstanceID, a.SearchAttributes)
+}
+
 func (sb *sqliteBackend) CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event) error {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -169,6 +240,253 @@ func (sb *sqliteBackend) CancelWorkflowInstance(ctx context.Context, instance *w
 		return fmt.Errorf("inserting cancellation event: %w", err)
 	}
 
+	if err := startDelayedInstance(ctx, tx, instanceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// startDelayedInstance starts the given instance right away, if its start has been delayed. Used when canceling
+// instances, their workflow observes the cancellation.
+func startDelayedInstance(ctx context.Context, tx *sql.Tx, instanceID string) error {
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE `pending_events` SET visible_at = NULL WHERE instance_id = ? AND event_type = ?",
+		instanceID, history.EventType_WorkflowExecutionStarted,
+	); err != nil {
+		return fmt.Errorf("starting delayed workflow instance: %w", err)
+	}
+
+	return nil
+}
+
+// instanceActive returns ErrInstanceNotFound or ErrInstanceNotActive if the current execution of the given workflow
+// instance is not active
+func instanceActive(ctx context.Context, tx *sql.Tx, instanceID string) error {
+	var completedAt *time.Time
+	if err := tx.QueryRowContext(ctx, "SELECT completed_at FROM `instances` WHERE id = ?", instanceID).Scan(&completedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return fmt.Errorf("reading workflow instance: %w", err)
+	}
+
+	if completedAt != nil {
+		return backend.ErrInstanceNotActive
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, event *history.Event, subWorkflows bool) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Only the current execution of the instance can be terminated
+	var executionID string
+	if err := tx.QueryRowContext(
+		ctx, "SELECT execution_id FROM `instances` WHERE id = ? LIMIT 1", instance.InstanceID,
+	).Scan(&executionID); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return err
+	}
+
+	if executionID != instance.ExecutionID {
+		return backend.ErrInstanceNotActive
+	}
+
+	if subWorkflows {
+		if err := terminateInstanceTree(ctx, tx, instance.InstanceID, event); err != nil {
+			return err
+		}
+	} else if err := terminateInstance(ctx, tx, instance.InstanceID, event); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// terminateInstanceTree terminates the given workflow instance, and all of its running sub-workflow instances
+func terminateInstanceTree(ctx context.Context, tx *sql.Tx, instanceID string, event *history.Event) error {
+	if err := terminateInstance(ctx, tx, instanceID, event); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM `instances` WHERE parent_instance_id = ? AND completed_at IS NULL", instanceID)
+	if err != nil {
+		return fmt.Errorf("finding sub-workflow instances: %w", err)
+	}
+
+	subInstanceIDs := make([]string, 0)
+	for rows.Next() {
+		var subInstanceID string
+		if err := rows.Scan(&subInstanceID); err != nil {
+			rows.Close()
+			return err
+		}
+
+		subInstanceIDs = append(subInstanceIDs, subInstanceID)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	a := event.Attributes.(*history.ExecutionTerminatedAttributes)
+	for _, subInstanceID := range subInstanceIDs {
+		if err := terminateInstanceTree(
+			ctx, tx, subInstanceID, history.NewWorkflowTerminationEvent(event.Timestamp, a.Reason),
+		); err != nil {
+			return fmt.Errorf("terminating sub-workflow instance %s: %w", subInstanceID, err)
+		}
+	}
+
+	return nil
+}
+
+// terminateInstance terminates the current execution of the given workflow instance
+func terminateInstance(ctx context.Context, tx *sql.Tx, instanceID string, event *history.Event) error {
+	// Terminate the current execution of the instance
+	res := tx.QueryRowContext(ctx, "SELECT execution_id, parent_instance_id, parent_schedule_event_id, completed_at FROM `instances` WHERE id = ? LIMIT 1", instanceID)
+
+	var executionID string
+	var parentInstanceID *string
+	var parentEventID *int64
+	var completedAt *time.Time
+	if err := res.Scan(&executionID, &parentInstanceID, &parentEventID, &completedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrInstanceNotFound
+		}
+
+		return err
+	}
+
+	if completedAt != nil {
+		return backend.ErrInstanceNotActive
+	}
+
+	now := time.Now()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE `instances` SET completed_at = ? WHERE id = ?", now, instanceID); err != nil {
+		return fmt.Errorf("completing workflow instance: %w", err)
+	}
+
+	// Add termination event to the end of the history
+	row := tx.QueryRowContext(ctx, "SELECT sequence_id FROM `history` WHERE instance_id = ? AND execution_id = ? ORDER BY rowid DESC LIMIT 1", instanceID, executionID)
+	if err := row.Scan(&event.SequenceID); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("getting most recent sequence id: %w", err)
+	}
+	event.SequenceID++
+
+	if err := insertHistoryEvents(ctx, tx, instanceID, executionID, []*history.Event{event}); err != nil {
+		return fmt.Errorf("inserting termination event: %w", err)
+	}
+
+	// Remove pending events and timers
+	if _, err := tx.ExecContext(ctx, "DELETE FROM `pending_events` WHERE instance_id = ?", instanceID); err != nil {
+		return fmt.Errorf("removing pending events: %w", err)
+	}
+
+	// Remove activities which haven't been picked up by a worker, yet, or are waiting for asynchronous completion.
+	// Running activities will still complete.
+	if _, err := tx.ExecContext(
+		ctx, "DELETE FROM `activities` WHERE instance_id = ? AND (locked_until IS NULL OR locked_until < ? OR completion_pending)", instanceID, now,
+	); err != nil {
+		return fmt.Errorf("removing pending activities: %w", err)
+	}
+
+	// Notify parent, if it's still running
+	if parentInstanceID != nil {
+		a := event.Attributes.(*history.ExecutionTerminatedAttributes)
+
+		res := tx.QueryRowContext(ctx, "SELECT 1 FROM `instances` WHERE id = ? AND completed_at IS NULL", *parentInstanceID)
+		if err := res.Scan(new(int)); err != nil {
+			if err != sql.ErrNoRows {
+				return err
+			}
+		} else {
+			if err := insertPendingEvents(ctx, tx, *parentInstanceID, []*history.Event{
+				history.NewSubWorkflowTerminatedEvent(now, *parentEventID, a),
+			}); err != nil {
+				return fmt.Errorf("notifying parent workflow instance: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// timeoutWorkflowInstances terminates all workflow instances, and their sub-workflow instances, which have exceeded
+// their execution timeout and are terminated on timeout. Instances canceled on timeout are canceled by the worker
+// executing the timeout event.
+func (sb *sqliteBackend) timeoutWorkflowInstances(ctx context.Context, now time.Time) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT pe.instance_id, pe.attributes
+			FROM pending_events pe
+				INNER JOIN instances i ON i.id = pe.instance_id
+			WHERE pe.event_type = ? AND pe.visible_at <= ? AND i.completed_at IS NULL`,
+		history.EventType_WorkflowExecutionTimedOut, now,
+	)
+	if err != nil {
+		return fmt.Errorf("finding timed out workflow instances: %w", err)
+	}
+
+	instanceIDs := make([]string, 0)
+	for rows.Next() {
+		var instanceID string
+		var attributes []byte
+		if err := rows.Scan(&instanceID, &attributes); err != nil {
+			rows.Close()
+			return fmt.Errorf("scanning timed out workflow instance: %w", err)
+		}
+
+		a, err := history.DeserializeAttributes(history.EventType_WorkflowExecutionTimedOut, attributes)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("deserializing attributes: %w", err)
+		}
+
+		if a.(*history.ExecutionTimedOutAttributes).Action == core.ExecutionTimeoutActionTerminate {
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+
+	for _, instanceID := range instanceIDs {
+		if err := terminateInstanceTree(ctx, tx, instanceID, history.NewWorkflowTimeoutTerminationEvent(now)); err != nil {
+			if errors.Is(err, backend.ErrInstanceNotActive) {
+				// Already terminated as sub-workflow of another timed out instance
+				continue
+			}
+
+			return fmt.Errorf("terminating workflow instance %s: %w", instanceID, err)
+		}
+	}
+
 	return tx.Commit()
 }
 
@@ -179,7 +497,7 @@ func (sb *sqliteBackend) GetWorkflowInstanceHistory(ctx context.Context, instanc
 	}
 	defer tx.Rollback()
 
-	h, err := getHistory(ctx, tx, instance.InstanceID, lastSequenceID)
+	h, err := getHistory(ctx, tx, instance.InstanceID, instance.ExecutionID, lastSequenceID)
 	if err != nil {
 		return nil, fmt.Errorf("getting workflow history: %w", err)
 	}
@@ -190,19 +508,20 @@ func (sb *sqliteBackend) GetWorkflowInstanceHistory(ctx context.Context, instanc
 func (s *sqliteBackend) GetWorkflowInstanceState(ctx context.Context, instance *workflow.Instance) (core.WorkflowInstanceState, error) {
 	row := s.db.QueryRowContext(
 		ctx,
-		"SELECT completed_at FROM instances WHERE id = ? AND execution_id = ?",
+		"SELECT execution_id, completed_at FROM instances WHERE id = ?",
 		instance.InstanceID,
-		instance.ExecutionID,
 	)
 
+	var executionID string
 	var completedAt sql.NullTime
-	if err := row.Scan(&completedAt); err != nil {
+	if err := row.Scan(&executionID, &completedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return core.WorkflowInstanceStateActive, backend.ErrInstanceNotFound
 		}
 	}
 
-	if completedAt.Valid {
+	// If the instance has been continued as new, the requested execution is finished
+	if completedAt.Valid || executionID != instance.ExecutionID {
 		return core.WorkflowInstanceStateFinished, nil
 	}
 
@@ -229,39 +548,130 @@ func (sb *sqliteBackend) SignalWorkflow(ctx context.Context, instanceID string,
 	return tx.Commit()
 }
 
-func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, error) {
+func (sb *sqliteBackend) SignalWithStartWorkflow(ctx context.Context, instance *workflow.Instance, startedEvent, signalEvent *history.Event) (*workflow.Instance, error) {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Lock next workflow task by finding an unlocked instance with new events to process
-	// (work around missing LIMIT support in sqlite driver for UPDATE statements by using sub-query)
+	events := []*history.Event{startedEvent, signalEvent}
+	if timeoutEvent := history.NewExecutionTimeoutEvent(startedEvent); timeoutEvent != nil {
+		events = append(events, timeoutEvent)
+	}
+
+	// Running instances are signaled, finished instances are replaced by a new execution if the policy allows it
+	a := startedEvent.Attributes.(*history.ExecutionStartedAttributes)
+	policy := a.IDReusePolicy
+	if policy == core.IDReusePolicyTerminateIfRunning {
+		policy = core.IDReusePolicyAllowDuplicate
+	}
+
+	if err := startExecution(ctx, tx, instance, a, policy); err != nil {
+		if !errors.Is(err, backend.ErrInstanceAlreadyExists) {
+			return nil, err
+		}
+
+		// Signal the existing instance, unless it has finished and can't be replaced
+		res := tx.QueryRowContext(ctx, "SELECT execution_id, parent_instance_id, parent_schedule_event_id, completed_at FROM `instances` WHERE id = ? LIMIT 1", instance.InstanceID)
+
+		var executionID string
+		var parentInstanceID *string
+		var parentEventID *int64
+		var completedAt *time.Time
+		if err := res.Scan(&executionID, &parentInstanceID, &parentEventID, &completedAt); err != nil {
+			return nil, fmt.Errorf("reading existing workflow instance: %w", err)
+		}
+
+		if completedAt != nil {
+			return nil, backend.ErrInstanceNotActive
+		}
+
+		if parentInstanceID != nil {
+			instance = core.NewSubWorkflowInstance(instance.InstanceID, executionID, *parentInstanceID, *parentEventID)
+		} else {
+			instance = core.NewWorkflowInstance(instance.InstanceID, executionID)
+		}
+
+		events = []*history.Event{signalEvent}
+	}
+
+	if err := insertPendingEvents(ctx, tx, instance.InstanceID, events); err != nil {
+		return nil, fmt.Errorf("inserting new events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("signaling workflow instance: %w", err)
+	}
+
+	return instance, nil
+}
+
+func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context, queues []workflow.Queue) (*task.Workflow, error) {
 	now := time.Now()
+
+	// Fail activities which have timed out, this adds new events for their workflow instances
+	if err := sb.timeoutActivities(ctx, now); err != nil {
+		return nil, fmt.Errorf("timing out activities: %w", err)
+	}
+
+	// Terminate workflow instances which have exceeded their execution timeout
+	if err := sb.timeoutWorkflowInstances(ctx, now); err != nil {
+		return nil, fmt.Errorf("timing out workflow instances: %w", err)
+	}
+
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	queuePlaceholders, queueArgs := queuesCondition(queues)
+
+	args := []interface{}{
+		now.Add(sb.options.WorkflowLockTimeout), // new locked_until
+		sb.workerName,
+	}
+	args = append(args, queueArgs...)
+	starvedBefore := now.Add(-sb.options.PriorityStarvationTimeout)
+	args = append(args,
+		now,           // locked_until
+		now,           // sticky_until
+		sb.workerName, // worker
+		now,           // event.visible_at
+		history.EventType_WorkflowExecutionStarted, now, // delayed start
+		starvedBefore, starvedBefore, // priority starvation
+	)
+
+	// Lock next workflow task by finding an unlocked instance on one of the queues with new events to process.
+	// Instances with a higher priority go first, unless an instance has been waiting for longer than the starvation
+	// timeout, then the instance waiting the longest goes first.
+	// (work around missing LIMIT support in sqlite driver for UPDATE statements by using sub-query)
 	row := tx.QueryRowContext(
 		ctx,
 		`UPDATE instances
 			SET locked_until = ?, worker = ?
 			WHERE rowid = (
-				SELECT rowid FROM instances i
+				SELECT i.rowid FROM instances i
+					INNER JOIN pending_events pe ON pe.instance_id = i.id
 					WHERE
-						(locked_until IS NULL OR locked_until < ?)
-						AND (sticky_until IS NULL OR sticky_until < ? OR worker = ?)
-						AND completed_at IS NULL
-						AND EXISTS (
+						i.queue IN (`+queuePlaceholders+`)
+						AND (i.locked_until IS NULL OR i.locked_until < ?)
+						AND (i.sticky_until IS NULL OR i.sticky_until < ? OR i.worker = ?)
+						AND i.completed_at IS NULL
+						AND (pe.visible_at IS NULL OR pe.visible_at <= ?)
+						AND NOT EXISTS (
 							SELECT 1
 								FROM pending_events
-								WHERE instance_id = i.id AND execution_id = i.execution_id AND (visible_at IS NULL OR visible_at <= ?)
+								WHERE instance_id = i.id AND event_type = ? AND visible_at > ?
 						)
+					ORDER BY
+						COALESCE(pe.visible_at, pe.timestamp) <= ? DESC,
+						CASE WHEN COALESCE(pe.visible_at, pe.timestamp) <= ? THEN 0 ELSE i.priority END DESC,
+						COALESCE(pe.visible_at, pe.timestamp)
 					LIMIT 1
 			) RETURNING id, execution_id, parent_instance_id, parent_schedule_event_id, metadata, sticky_until`,
-		now.Add(sb.options.WorkflowLockTimeout), // new locked_until
-		sb.workerName,
-		now,           // locked_until
-		now,           // sticky_until
-		sb.workerName, // worker
-		now,           // event.visible_at
+		args...,
 	)
 
 	var instanceID, executionID string
@@ -314,7 +724,7 @@ func (sb *sqliteBackend) GetWorkflowTask(ctx context.Context) (*task.Workflow, e
 
 	// Get only most recent sequence ID
 	// TODO: Denormalize to instances table
-	row = tx.QueryRowContext(ctx, "SELECT sequence_id FROM `history` WHERE instance_id = ? ORDER BY rowid DESC LIMIT 1", instanceID)
+	row = tx.QueryRowContext(ctx, "SELECT sequence_id FROM `history` WHERE instance_id = ? AND execution_id = ? ORDER BY rowid DESC LIMIT 1", instanceID, executionID)
 	if err := row.Scan(&t.LastSequenceID); err != nil {
 		if err != sql.ErrNoRows {
 			return nil, fmt.Errorf("getting most recent sequence id: %w", err)
@@ -353,7 +763,7 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 	// Unlock instance, but keep it sticky to the current worker
 	if res, err := tx.ExecContext(
 		ctx,
-		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE id = ? AND execution_id = ? AND worker = ?`,
+		`UPDATE instances SET locked_until = NULL, sticky_until = ?, completed_at = ? WHERE id = ? AND execution_id = ? AND worker = ? AND completed_at IS NULL`,
 		time.Now().Add(sb.options.StickyTimeout),
 		completedAt,
 		instance.InstanceID,
@@ -364,6 +774,12 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 	} else if n, err := res.RowsAffected(); err != nil {
 		return fmt.Errorf("checking for unlocked workflow instances: %w", err)
 	} else if n != 1 {
+		// The instance might have been terminated while the task was being executed
+		res := tx.QueryRowContext(ctx, "SELECT 1 FROM instances WHERE id = ? AND execution_id = ? AND completed_at IS NOT NULL", instance.InstanceID, instance.ExecutionID)
+		if err := res.Scan(new(int)); err == nil {
+			return backend.ErrInstanceNotActive
+		}
+
 		return errors.New("could not find workflow instance to unlock")
 	}
 
@@ -385,7 +801,7 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 	}
 
 	// Add events from last execution to history
-	if err := insertHistoryEvents(ctx, tx, instance.InstanceID, executedEvents); err != nil {
+	if err := insertHistoryEvents(ctx, tx, instance.InstanceID, instance.ExecutionID, executedEvents); err != nil {
 		return fmt.Errorf("inserting new history events: %w", err)
 	}
 
@@ -407,33 +823,122 @@ func (sb *sqliteBackend) CompleteWorkflowTask(
 			if err := removeFutureEvent(ctx, tx, instance.InstanceID, event.ScheduleEventID); err != nil {
 				return fmt.Errorf("removing future event: %w", err)
 			}
+
+		case history.EventType_SearchAttributesUpserted:
+			a := event.Attributes.(*history.SearchAttributesUpsertedAttributes)
+			if err := upsertSearchAttributes(ctx, tx, instance.InstanceID, a.SearchAttributes); err != nil {
+				return fmt.Errorf("upserting search attributes: %w", err)
+			}
 		}
 	}
 
 	// Insert new workflow events
 	groupedEvents := history.EventsByWorkflowInstanceID(workflowEvents)
 
+	// Errors delivering signals or cancellations to other workflow instances, by target instance
+	deliveryErrors := make(map[string]error)
+	continued := false
+
 	for targetInstanceID, events := range groupedEvents {
+		var timeoutEvent *history.Event
+		started := false
+
 		for _, m := range events {
 			if m.HistoryEvent.Type == history.EventType_WorkflowExecutionStarted {
 				a := m.HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
-				// Create new instance
-				if err := createInstance(ctx, tx, m.WorkflowInstance, a.Metadata, true); err != nil {
-					return err
+				if targetInstanceID == instance.InstanceID {
+					// Workflow instance continued as new, start new execution
+					if err := continueInstance(ctx, tx, m.WorkflowInstance, a); err != nil {
+						return err
+					}
+
+					continued = true
+				} else {
+					// Create new instance
+					if err := createInstance(ctx, tx, m.WorkflowInstance, a, true); err != nil {
+						return err
+					}
+
+					timeoutEvent = history.NewExecutionTimeoutEvent(m.HistoryEvent)
 				}
 
+				started = true
 				break
 			}
 		}
 
 		// Insert pending events for target instance
 		historyEvents := []*history.Event{}
+		terminations := []*history.Event{}
+		startDelayed := false
 		for _, m := range events {
+			switch m.HistoryEvent.Type {
+			case history.EventType_WorkflowExecutionTerminated:
+				if targetInstanceID != instance.InstanceID {
+					// Sub-workflow instances are terminated according to their parent close policy
+					terminations = append(terminations, m.HistoryEvent)
+					continue
+				}
+
+			case history.EventType_SignalReceived, history.EventType_WorkflowExecutionCanceled:
+				if started || targetInstanceID == instance.InstanceID {
+					break
+				}
+
+				// Signals and cancellations are only delivered to active instances
+				if _, checked := deliveryErrors[targetInstanceID]; !checked {
+					err := instanceActive(ctx, tx, targetInstanceID)
+					if err != nil && !errors.Is(err, backend.ErrInstanceNotFound) && !errors.Is(err, backend.ErrInstanceNotActive) {
+						return err
+					}
+
+					deliveryErrors[targetInstanceID] = err
+				}
+
+				if deliveryErrors[targetInstanceID] != nil {
+					continue
+				}
+
+				startDelayed = startDelayed || m.HistoryEvent.Type == history.EventType_WorkflowExecutionCanceled
+			}
+
 			historyEvents = append(historyEvents, m.HistoryEvent)
 		}
+
+		// Time out new instances once they exceed their execution timeout
+		if timeoutEvent != nil {
+			historyEvents = append(historyEvents, timeoutEvent)
+		}
 		if err := insertPendingEvents(ctx, tx, targetInstanceID, historyEvents); err != nil {
 			return fmt.Errorf("inserting messages: %w", err)
 		}
+
+		if startDelayed {
+			if err := startDelayedInstance(ctx, tx, targetInstanceID); err != nil {
+				return err
+			}
+		}
+
+		for _, event := range terminations {
+			if err := terminateInstanceTree(ctx, tx, targetInstanceID, event); err != nil &&
+				!errors.Is(err, backend.ErrInstanceNotActive) && !errors.Is(err, backend.ErrInstanceNotFound) {
+				return fmt.Errorf("terminating sub-workflow instance: %w", err)
+			}
+		}
+	}
+
+	// Complete requests to signal or cancel other workflow instances, unless this execution has ended
+	if state != core.WorkflowInstanceStateFinished && !continued {
+		results := []*history.Event{}
+		for _, event := range executedEvents {
+			if targetInstanceID, ok := history.ExternalWorkflowRequestTarget(event); ok {
+				results = append(results, history.NewExternalWorkflowResultEvent(time.Now(), event, deliveryErrors[targetInstanceID]))
+			}
+		}
+
+		if err := insertPendingEvents(ctx, tx, instance.InstanceID, results); err != nil {
+			return fmt.Errorf("completing workflow requests: %w", err)
+		}
 	}
 
 	return tx.Commit()
@@ -468,36 +973,65 @@ func (sb *sqliteBackend) ExtendWorkflowTask(ctx context.Context, taskID string,
 	return tx.Commit()
 }
 
-func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, error) {
+func (sb *sqliteBackend) AbandonWorkflowTask(ctx context.Context, task *task.Workflow) error {
+	// Unlock instance, and don't keep it sticky to this worker
+	if _, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE instances SET locked_until = NULL, sticky_until = NULL WHERE id = ? AND execution_id = ? AND worker = ?`,
+		task.WorkflowInstance.InstanceID,
+		task.WorkflowInstance.ExecutionID,
+		sb.workerName,
+	); err != nil {
+		return fmt.Errorf("abandoning workflow task: %w", err)
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) GetActivityTask(ctx context.Context, queues []workflow.Queue) (*task.Activity, error) {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Lock next activity
+	queuePlaceholders, queueArgs := queuesCondition(queues)
+
+	// Lock next activity on one of the queues. Activities with a higher priority go first, unless an activity has
+	// been waiting for longer than the starvation timeout, then the activity waiting the longest goes first.
 	// (work around missing LIMIT support in sqlite driver for UPDATE statements by using sub-query)
 	now := time.Now()
+	starvedBefore := now.Add(-sb.options.PriorityStarvationTimeout)
+	args := []interface{}{now.Add(sb.options.ActivityLockTimeout), sb.workerName, now}
+	args = append(args, queueArgs...)
+	args = append(args, now, starvedBefore, starvedBefore)
+
 	row := tx.QueryRowContext(
 		ctx,
 		`UPDATE activities
-			SET locked_until = ?, worker = ?
+			SET locked_until = ?, worker = ?, started_at = ?, last_heartbeat_at = NULL
 			WHERE rowid = (
-				SELECT rowid FROM activities WHERE locked_until IS NULL OR locked_until < ? LIMIT 1
-			) RETURNING id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at`,
-		now.Add(sb.options.ActivityLockTimeout),
-		sb.workerName,
-		now,
+				SELECT rowid FROM activities
+					WHERE queue IN (`+queuePlaceholders+`) AND (locked_until IS NULL OR locked_until < ?) AND NOT completion_pending
+					ORDER BY
+						COALESCE(visible_at, timestamp) <= ? DESC,
+						CASE WHEN COALESCE(visible_at, timestamp) <= ? THEN 0 ELSE priority END DESC,
+						COALESCE(visible_at, timestamp)
+					LIMIT 1
+			) RETURNING id, instance_id, execution_id, event_type, timestamp, schedule_event_id, attributes, visible_at, heartbeat_details`,
+		args...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	var instanceID, executionID string
-	var attributes []byte
+	var attributes, heartbeatDetails []byte
 	event := &history.Event{}
 
-	if err := row.Scan(&event.ID, &instanceID, &executionID, &event.Type, &event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt); err != nil {
+	if err := row.Scan(
+		&event.ID, &instanceID, &executionID, &event.Type, &event.Timestamp, &event.ScheduleEventID, &attributes, &event.VisibleAt,
+		&heartbeatDetails); err != nil {
 		if err == sql.ErrNoRows {
 			// No rows locked, just return
 			return nil, nil
@@ -511,8 +1045,18 @@ func (sb *sqliteBackend) GetActivityTask(ctx context.Context) (*task.Activity, e
 		return nil, fmt.Errorf("deserializing attributes: %w", err)
 	}
 
+	// A previous worker might have recorded heartbeat details before it gave up the task
+	if heartbeatDetails != nil {
+		a.(*history.ActivityScheduledAttributes).HeartbeatDetails = heartbeatDetails
+	}
+
 	event.Attributes = a
 
+	// The activity has been started, start-to-close and heartbeat timeouts apply from now on
+	if err := updateActivityTimeout(ctx, tx, event.ID, a.(*history.ActivityScheduledAttributes), event.Timestamp, &now, nil); err != nil {
+		return nil, err
+	}
+
 	var metadataJson sql.NullString
 	if err := tx.QueryRowContext(ctx, "SELECT metadata FROM instances WHERE id = ?", instanceID).Scan(&metadataJson); err != nil {
 		return nil, fmt.Errorf("scanning metadata: %w", err)
@@ -544,19 +1088,20 @@ func (sb *sqliteBackend) CompleteActivityTask(ctx context.Context, instance *wor
 	}
 	defer tx.Rollback()
 
-	// Remove activity
+	// Remove activity. Activities can be completed by any process knowing their task token, not only by the worker
+	// executing them, e.g., by a webhook before the activity has returned ErrResultPending.
 	if res, err := tx.ExecContext(
 		ctx,
-		`DELETE FROM activities WHERE instance_id = ? AND id = ? AND worker = ?`,
+		`DELETE FROM activities WHERE instance_id = ? AND execution_id = ? AND id = ?`,
 		instance.InstanceID,
+		instance.ExecutionID,
 		id,
-		sb.workerName,
 	); err != nil {
 		return fmt.Errorf("unlocking instance: %w", err)
 	} else if n, err := res.RowsAffected(); err != nil {
 		return fmt.Errorf("checking for deleted activities: %w", err)
 	} else if n != 1 {
-		return errors.New("could not find activity to delete")
+		return backend.ErrActivityTaskNotFound
 	}
 
 	// Insert new event generated during this workflow execution
@@ -567,29 +1112,111 @@ func (sb *sqliteBackend) CompleteActivityTask(ctx context.Context, instance *wor
 	return tx.Commit()
 }
 
-func (sb *sqliteBackend) ExtendActivityTask(ctx context.Context, activityID string) error {
+func (sb *sqliteBackend) SetActivityTaskPending(ctx context.Context, activityID string) error {
+	res, err := sb.db.ExecContext(
+		ctx,
+		`UPDATE activities SET locked_until = NULL, completion_pending = 1 WHERE id = ? AND worker = ?`,
+		activityID,
+		sb.workerName,
+	)
+	if err != nil {
+		return fmt.Errorf("marking activity as pending: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("checking for pending activity: %w", err)
+	} else if n != 1 {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) ExtendActivityTask(ctx context.Context, activityID string, heartbeatDetails payload.Payload) error {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	until := time.Now().Add(sb.options.ActivityLockTimeout)
-	res, err := tx.ExecContext(
+	now := time.Now()
+	row := tx.QueryRowContext(
 		ctx,
-		`UPDATE activities SET locked_until = ? WHERE id = ? AND worker = ?`,
-		until,
+		`UPDATE activities SET locked_until = ?, last_heartbeat_at = ?, heartbeat_details = COALESCE(?, heartbeat_details)
+			WHERE id = ?
+			RETURNING instance_id, execution_id, schedule_event_id, timestamp, attributes, started_at`,
+		now.Add(sb.options.ActivityLockTimeout),
+		now,
+		[]byte(heartbeatDetails),
 		activityID,
+	)
+
+	var instanceID, executionID string
+	var scheduleEventID int64
+	var scheduledAt time.Time
+	var attributes []byte
+	var startedAt *time.Time
+	if err := row.Scan(&instanceID, &executionID, &scheduleEventID, &scheduledAt, &attributes, &startedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return backend.ErrActivityTaskNotFound
+		}
+
+		return fmt.Errorf("extending activity lock: %w", err)
+	}
+
+	a, err := history.DeserializeAttributes(history.EventType_ActivityScheduled, attributes)
+	if err != nil {
+		return fmt.Errorf("deserializing attributes: %w", err)
+	}
+
+	if err := updateActivityTimeout(ctx, tx, activityID, a.(*history.ActivityScheduledAttributes), scheduledAt, startedAt, &now); err != nil {
+		return err
+	}
+
+	canceled, err := activityCanceled(ctx, tx, instanceID, executionID, scheduleEventID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if canceled {
+		return backend.ErrActivityCanceled
+	}
+
+	return nil
+}
+
+func (sb *sqliteBackend) AbandonActivityTask(ctx context.Context, task *task.Activity) error {
+	tx, err := sb.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE activities SET locked_until = NULL, worker = NULL, started_at = NULL, last_heartbeat_at = NULL
+			WHERE id = ? AND worker = ? AND NOT completion_pending`,
+		task.ID,
 		sb.workerName,
 	)
 	if err != nil {
-		return fmt.Errorf("extending activity lock: %w", err)
+		return fmt.Errorf("abandoning activity task: %w", err)
 	}
 
-	if rowsAffected, err := res.RowsAffected(); err != nil {
-		return fmt.Errorf("determining if activity was extended: %w", err)
-	} else if rowsAffected == 0 {
-		return errors.New("could not extend activity")
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("checking for abandoned activity: %w", err)
+	} else if n != 1 {
+		return backend.ErrActivityTaskNotFound
+	}
+
+	// The activity is not running anymore, only the schedule-to-close timeout applies until it's started again
+	a := task.Event.Attributes.(*history.ActivityScheduledAttributes)
+	if err := updateActivityTimeout(ctx, tx, task.ID, a, task.Event.Timestamp, nil, nil); err != nil {
+		return err
 	}
 
 	return tx.Commit()
@@ -8,6 +8,7 @@ import (
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/backend/test"
 	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/google/uuid"
 )
 
 func Test_SqliteBackend(t *testing.T) {
@@ -34,6 +35,14 @@ func Test_EndToEndSqliteBackend(t *testing.T) {
 
 var _ test.TestBackend = (*sqliteBackend)(nil)
 
+func (sb *sqliteBackend) OtherProcess() backend.Backend {
+	return &sqliteBackend{
+		db:         sb.db,
+		workerName: fmt.Sprintf("worker-%v", uuid.NewString()),
+		options:    sb.options,
+	}
+}
+
 func (sb *sqliteBackend) GetFutureEvents(ctx context.Context) ([]*history.Event, error) {
 	tx, err := sb.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -11,4 +11,7 @@ type TestBackend interface {
 	backend.Backend
 
 	GetFutureEvents(ctx context.Context) ([]*history.Event, error)
+
+	// OtherProcess returns a backend sharing the storage of the backend, like the backend of another process would
+	OtherProcess() backend.Backend
 }
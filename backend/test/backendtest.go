@@ -12,6 +12,8 @@ import (
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/schedule"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
 	"github.com/cschleiden/go-workflows/workflow"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -56,6 +58,130 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
 			},
 		},
+		{
+			name: "CreateWorkflowInstance_AllowDuplicate_StartsNewExecution",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instanceID := uuid.NewString()
+				first := core.NewWorkflowInstance(instanceID, uuid.NewString())
+				createFinishedTestInstance(t, ctx, b, first, nil)
+
+				second := core.NewWorkflowInstance(instanceID, uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, second, newTestStartedEvent(core.IDReusePolicyAllowDuplicate))
+				require.NoError(t, err)
+
+				// The new execution is running, so another one can't be started
+				err = b.CreateWorkflowInstance(ctx, core.NewWorkflowInstance(instanceID, uuid.NewString()), newTestStartedEvent(core.IDReusePolicyAllowDuplicate))
+				require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
+
+				s, err := b.GetWorkflowInstanceState(ctx, second)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateActive, s)
+
+				s, err = b.GetWorkflowInstanceState(ctx, first)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateFinished, s)
+
+				// Past execution is still available
+				executions, err := b.ListWorkflowInstanceExecutions(ctx, instanceID)
+				require.NoError(t, err)
+				require.Len(t, executions, 2)
+				require.Equal(t, second.ExecutionID, executions[0].Instance.ExecutionID)
+				require.Equal(t, core.WorkflowInstanceStateActive, executions[0].State)
+				require.Equal(t, first.ExecutionID, executions[1].Instance.ExecutionID)
+				require.Equal(t, core.WorkflowInstanceStateFinished, executions[1].State)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, first, nil)
+				require.NoError(t, err)
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, h[len(h)-1].Type)
+
+				// The new execution starts with its started event only
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, second, task.WorkflowInstance)
+				require.Len(t, task.NewEvents, 1)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, task.NewEvents[0].Type)
+			},
+		},
+		{
+			name: "CreateWorkflowInstance_AllowDuplicateFailedOnly",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				completed := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				createFinishedTestInstance(t, ctx, b, completed, nil)
+
+				err := b.CreateWorkflowInstance(ctx, core.NewWorkflowInstance(completed.InstanceID, uuid.NewString()), newTestStartedEvent(core.IDReusePolicyAllowDuplicateFailedOnly))
+				require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
+
+				failed := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				createFinishedTestInstance(t, ctx, b, failed, workflowerrors.NewError("Error", "failed"))
+
+				err = b.CreateWorkflowInstance(ctx, core.NewWorkflowInstance(failed.InstanceID, uuid.NewString()), newTestStartedEvent(core.IDReusePolicyAllowDuplicateFailedOnly))
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "CreateWorkflowInstance_TerminateIfRunning",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				first := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				startWorkflow(t, ctx, b, c, first)
+
+				err := b.CreateWorkflowInstance(ctx, core.NewWorkflowInstance(first.InstanceID, uuid.NewString()), newTestStartedEvent(core.IDReusePolicyRejectDuplicate))
+				require.ErrorIs(t, err, backend.ErrInstanceAlreadyExists)
+
+				second := core.NewWorkflowInstance(first.InstanceID, uuid.NewString())
+				err = b.CreateWorkflowInstance(ctx, second, newTestStartedEvent(core.IDReusePolicyTerminateIfRunning))
+				require.NoError(t, err)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, first, nil)
+				require.NoError(t, err)
+				require.Equal(t, history.EventType_WorkflowExecutionTerminated, h[len(h)-1].Type)
+
+				s, err := b.GetWorkflowInstanceState(ctx, second)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateActive, s)
+			},
+		},
+		{
+			name: "CreateWorkflowInstance_TerminateIfRunning_DiscardsActivities",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				first := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, first, newTestStartedEvent(core.IDReusePolicyRejectDuplicate))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				activityScheduledEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name: "activity",
+				}, history.ScheduleEventID(1))
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, first, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{activityScheduledEvent}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+
+				second := core.NewWorkflowInstance(first.InstanceID, uuid.NewString())
+				err = b.CreateWorkflowInstance(ctx, second, newTestStartedEvent(core.IDReusePolicyTerminateIfRunning))
+				require.NoError(t, err)
+
+				// The result of the previous execution's activity is not delivered to the new execution
+				err = b.CompleteActivityTask(ctx, first, activityTask.ID, history.NewPendingEvent(
+					time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)))
+				if err != nil {
+					require.ErrorIs(t, err, backend.ErrActivityTaskNotFound)
+				}
+
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, second, task.WorkflowInstance)
+				require.Len(t, task.NewEvents, 1)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, task.NewEvents[0].Type)
+			},
+		},
 		{
 			name: "CreateWorkflowInstance_Metadata",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
@@ -74,7 +200,7 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				)
 				require.NoError(t, err)
 
-				task, err := b.GetWorkflowTask(ctx)
+				task, err := b.GetWorkflowTask(ctx, nil)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 
@@ -90,7 +216,7 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 
 				time.Sleep(1 * time.Millisecond)
 
-				task, _ := b.GetWorkflowTask(ctx)
+				task, _ := b.GetWorkflowTask(ctx, nil)
 				require.Nil(t, task)
 			},
 		},
@@ -103,7 +229,7 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				)
 				require.NoError(t, err)
 
-				task, err := b.GetWorkflowTask(ctx)
+				task, err := b.GetWorkflowTask(ctx, nil)
 
 				require.NoError(t, err)
 				require.NotNil(t, task)
@@ -120,7 +246,7 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				require.Nil(t, err)
 
 				// Get and lock only task
-				task, err := b.GetWorkflowTask(ctx)
+				task, err := b.GetWorkflowTask(ctx, nil)
 				require.NoError(t, err)
 				require.NotNil(t, task)
 
@@ -128,9 +254,90 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
 				defer cancel()
 
-				task, err = b.GetWorkflowTask(ctx)
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.Nil(t, task)
+				require.True(t, err == nil || errors.Is(err, context.DeadlineExceeded))
+			},
+		},
+		{
+			name: "AbandonWorkflowTask_ReleasesLock",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+				)
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				err = b.AbandonWorkflowTask(ctx, task)
+				require.NoError(t, err)
+
+				// Task is returned again right away, with the same events
+				abandonedTask, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, abandonedTask)
+				require.Equal(t, wfi, abandonedTask.WorkflowInstance)
+				require.Len(t, abandonedTask.NewEvents, len(task.NewEvents))
+
+				err = b.CompleteWorkflowTask(
+					ctx, abandonedTask, wfi, core.WorkflowInstanceStateActive, abandonedTask.NewEvents, []*history.Event{}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "GetWorkflowTask_OnlyReturnsTasksForGivenQueues",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Queue: "other",
+					}),
+				)
+				require.NoError(t, err)
+
+				// Task is not returned for the default queue
+				tctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+				defer cancel()
+
+				task, err := b.GetWorkflowTask(tctx, nil)
 				require.Nil(t, task)
 				require.True(t, err == nil || errors.Is(err, context.DeadlineExceeded))
+
+				task, err = b.GetWorkflowTask(ctx, []workflow.Queue{workflow.QueueDefault, "other"})
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, wfi.InstanceID, task.WorkflowInstance.InstanceID)
+			},
+		},
+		{
+			name: "GetWorkflowTask_ReturnsHigherPriorityFirst",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				low := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, low, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+				)
+				require.NoError(t, err)
+
+				high := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err = b.CreateWorkflowInstance(
+					ctx, high, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Priority: 10,
+					}),
+				)
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, high.InstanceID, task.WorkflowInstance.InstanceID)
+
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, low.InstanceID, task.WorkflowInstance.InstanceID)
 			},
 		},
 		{
@@ -140,7 +347,7 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				err := b.CreateWorkflowInstance(ctx, wfi, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
 				require.NoError(t, err)
 
-				tk, err := b.GetWorkflowTask(ctx)
+				tk, err := b.GetWorkflowTask(ctx, nil)
 				require.NoError(t, err)
 				require.NotNil(t, tk)
 
@@ -163,7 +370,7 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				err := b.CreateWorkflowInstance(ctx, wfi, startedEvent)
 				require.NoError(t, err)
 
-				task, err := b.GetWorkflowTask(ctx)
+				task, err := b.GetWorkflowTask(ctx, nil)
 				require.NoError(t, err)
 
 				taskStartedEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{})
@@ -213,7 +420,7 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				err := b.CreateWorkflowInstance(ctx, wfi, startedEvent)
 				require.NoError(t, err)
 
-				task, err := b.GetWorkflowTask(ctx)
+				task, err := b.GetWorkflowTask(ctx, nil)
 				require.NoError(t, err)
 
 				events := []*history.Event{
@@ -240,6 +447,71 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				require.NotNil(t, s.CompletedAt)
 			},
 		},
+		{
+			name: "CompleteWorkflowTask_ContinueAsNewDiscardsPreviousExecutionEvents",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				activityScheduledEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name: "activity",
+				}, history.ScheduleEventID(1))
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{activityScheduledEvent}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+
+				// Start another workflow task, the activity completes while it is running
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "signal", nil))
+
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				err = b.CompleteActivityTask(ctx, instance, activityTask.ID, history.NewPendingEvent(
+					time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)))
+				require.NoError(t, err)
+
+				// Continue as new
+				continuedInstance := core.NewWorkflowInstance(instance.InstanceID, uuid.NewString())
+				events := append(task.NewEvents, history.NewPendingEvent(
+					time.Now(), history.EventType_WorkflowExecutionContinuedAsNew, &history.ExecutionContinuedAsNewAttributes{
+						ContinuedExecutionID: continuedInstance.ExecutionID,
+					}))
+				sequenceID := int64(1)
+				for i := range events {
+					sequenceID++
+					events[i].SequenceID = sequenceID
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, instance, core.WorkflowInstanceStateFinished, events, []*history.Event{}, []*history.Event{}, []history.WorkflowEvent{
+					{
+						WorkflowInstance: continuedInstance,
+						HistoryEvent: history.NewPendingEvent(
+							time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+					},
+				})
+				require.NoError(t, err)
+
+				// The new execution only sees its own started event, the previous activity result is discarded
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, continuedInstance.ExecutionID, task.WorkflowInstance.ExecutionID)
+				require.Len(t, task.NewEvents, 1)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, task.NewEvents[0].Type)
+			},
+		},
 		{
 			name: "SignalWorkflow_ErrorWhenInstanceDoesNotExist",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
@@ -249,6 +521,94 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				require.Equal(t, backend.ErrInstanceNotFound, err)
 			},
 		},
+		{
+			name: "SignalWithStartWorkflow_CreatesInstance",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+
+				startedEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})
+				signalEvent := history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal"})
+
+				signaled, err := b.SignalWithStartWorkflow(ctx, instance, startedEvent, signalEvent)
+				require.NoError(t, err)
+				require.Equal(t, instance, signaled)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, instance, task.WorkflowInstance)
+				require.Len(t, task.NewEvents, 2)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, task.NewEvents[0].Type)
+				require.Equal(t, history.EventType_SignalReceived, task.NewEvents[1].Type)
+			},
+		},
+		{
+			name: "SignalWithStartWorkflow_SignalsExistingInstance",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				startWorkflow(t, ctx, b, c, instance)
+
+				startedEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{})
+				signalEvent := history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal"})
+
+				signaled, err := b.SignalWithStartWorkflow(ctx, core.NewWorkflowInstance(instance.InstanceID, uuid.NewString()), startedEvent, signalEvent)
+				require.NoError(t, err)
+				require.Equal(t, instance.ExecutionID, signaled.ExecutionID)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, instance.InstanceID, task.WorkflowInstance.InstanceID)
+				require.Len(t, task.NewEvents, 1)
+				require.Equal(t, history.EventType_SignalReceived, task.NewEvents[0].Type)
+			},
+		},
+		{
+			name: "SignalWithStartWorkflow_ErrorWhenInstanceHasFinished",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				createFinishedTestInstance(t, ctx, b, instance, nil)
+
+				startedEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					IDReusePolicy: core.IDReusePolicyRejectDuplicate,
+				})
+				signalEvent := history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal"})
+
+				_, err := b.SignalWithStartWorkflow(ctx, core.NewWorkflowInstance(instance.InstanceID, uuid.NewString()), startedEvent, signalEvent)
+				require.ErrorIs(t, err, backend.ErrInstanceNotActive)
+
+				// The signal is not added to the finished instance
+				ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+				defer cancel()
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.Nil(t, task)
+				require.True(t, err == nil || errors.Is(err, context.DeadlineExceeded))
+			},
+		},
+		{
+			name: "SignalWithStartWorkflow_StartsNewExecutionOfFinishedInstance",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				createFinishedTestInstance(t, ctx, b, instance, nil)
+
+				startedEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					IDReusePolicy: core.IDReusePolicyAllowDuplicate,
+				})
+				signalEvent := history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{Name: "signal"})
+
+				newInstance := core.NewWorkflowInstance(instance.InstanceID, uuid.NewString())
+				signaled, err := b.SignalWithStartWorkflow(ctx, newInstance, startedEvent, signalEvent)
+				require.NoError(t, err)
+				require.Equal(t, newInstance, signaled)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, newInstance, task.WorkflowInstance)
+				require.Len(t, task.NewEvents, 2)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, task.NewEvents[0].Type)
+				require.Equal(t, history.EventType_SignalReceived, task.NewEvents[1].Type)
+			},
+		},
 		{
 			name: "CancelWorkflow_ErrorWhenInstanceDoesNotExist",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
@@ -268,82 +628,1203 @@ func BackendTest(t *testing.T, setup func() TestBackend, teardown func(b TestBac
 				err := c.CancelWorkflowInstance(ctx, instance)
 				require.NoError(t, err)
 
-				task, err := b.GetWorkflowTask(ctx)
+				task, err := b.GetWorkflowTask(ctx, nil)
 				require.NoError(t, err)
 
 				require.Equal(t, history.EventType_WorkflowExecutionCanceled, task.NewEvents[len(task.NewEvents)-1].Type)
 			},
 		},
 		{
-			name: "CompleteWorkflowTask_SendsInstanceEvents",
+			name: "CreateWorkflowInstance_DelayedStart",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
 				c := client.New(b)
 				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
 
-				subInstance1 := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), instance.InstanceID, 1)
-				startWorkflow(t, ctx, b, c, subInstance1)
+				startedEvent := history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionStarted,
+					&history.ExecutionStartedAttributes{}, history.VisibleAt(time.Now().Add(time.Hour)))
+				err := b.CreateWorkflowInstance(ctx, instance, startedEvent)
+				require.NoError(t, err)
 
-				// Create parent instance
-				err := b.CreateWorkflowInstance(ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				task, err := b.GetWorkflowTask(ctx, nil)
 				require.NoError(t, err)
+				require.Nil(t, task)
 
-				// Simulate context and sub-workflow cancellation
-				task, err := b.GetWorkflowTask(ctx)
+				// Signals are kept until the instance has been started
+				err = c.SignalWorkflow(ctx, instance.InstanceID, "signal", "value")
 				require.NoError(t, err)
-				err = b.CompleteWorkflowTask(ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{}, []*history.Event{}, []history.WorkflowEvent{
-					{
-						WorkflowInstance: subInstance1,
-						HistoryEvent: history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionCanceled, &history.SubWorkflowCancellationRequestedAttributes{
-							SubWorkflowInstance: subInstance1,
-						}),
-					},
-				})
+
+				task, err = b.GetWorkflowTask(ctx, nil)
 				require.NoError(t, err)
+				require.Nil(t, task)
 
-				task, err = b.GetWorkflowTask(ctx)
+				// Canceling the instance starts it right away
+				err = c.CancelWorkflowInstance(ctx, instance)
 				require.NoError(t, err)
-				require.Equal(t, subInstance1, task.WorkflowInstance)
-				require.Equal(t, history.EventType_WorkflowExecutionCanceled, task.NewEvents[len(task.NewEvents)-1].Type)
+
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, instance.InstanceID, task.WorkflowInstance.InstanceID)
+
+				eventTypes := make([]history.EventType, 0, len(task.NewEvents))
+				for _, event := range task.NewEvents {
+					eventTypes = append(eventTypes, event.Type)
+				}
+				require.ElementsMatch(t, []history.EventType{
+					history.EventType_WorkflowExecutionStarted,
+					history.EventType_SignalReceived,
+					history.EventType_WorkflowExecutionCanceled,
+				}, eventTypes)
 			},
 		},
 		{
-			name: "GetActivityTask_ReturnsNilWhenTimeout",
+			name: "CreateWorkflowInstance_ExecutionTimeout",
 			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
-				ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
-				defer cancel()
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
 
-				task, _ := b.GetActivityTask(ctx)
-				require.Nil(t, task)
+				// Timeout has already been exceeded
+				startedEvent := history.NewPendingEvent(time.Now().Add(-time.Minute), history.EventType_WorkflowExecutionStarted,
+					&history.ExecutionStartedAttributes{
+						ExecutionTimeout:       time.Second,
+						ExecutionTimeoutAction: core.ExecutionTimeoutActionCancel,
+					})
+				err := b.CreateWorkflowInstance(ctx, instance, startedEvent)
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Len(t, task.NewEvents, 2)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, task.NewEvents[0].Type)
+				require.Equal(t, history.EventType_WorkflowExecutionTimedOut, task.NewEvents[1].Type)
+				require.Equal(t, core.ExecutionTimeoutActionCancel, task.NewEvents[1].Attributes.(*history.ExecutionTimedOutAttributes).Action)
 			},
 		},
-	}
+		{
+			name: "GetWorkflowTask_TerminatesTimedOutInstance",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			b := setup()
-			ctx := context.Background()
+				// Timeout has already been exceeded
+				startedEvent := history.NewPendingEvent(time.Now().Add(-time.Minute), history.EventType_WorkflowExecutionStarted,
+					&history.ExecutionStartedAttributes{
+						ExecutionTimeout: time.Second,
+					})
+				err := b.CreateWorkflowInstance(ctx, instance, startedEvent)
+				require.NoError(t, err)
 
-			t.Cleanup(func() {
-				if teardown != nil {
-					teardown(b)
-				}
-			})
+				// Instance is terminated by the backend, no workflow code runs
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.Nil(t, task)
 
-			tt.f(t, ctx, b)
-		})
-	}
-}
+				state, err := b.GetWorkflowInstanceState(ctx, instance)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateFinished, state)
 
-func startWorkflow(t *testing.T, ctx context.Context, b backend.Backend, c client.Client, instance *core.WorkflowInstance) {
-	err := b.CreateWorkflowInstance(
-		ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
-	require.NoError(t, err)
+				c := client.New(b)
+				_, err = client.GetWorkflowResult[any](ctx, c, instance, time.Second)
+
+				var timeoutErr *workflow.ExecutionTimeoutError
+				require.ErrorAs(t, err, &timeoutErr)
+			},
+		},
+		{
+			name: "TerminateWorkflow_ErrorWhenInstanceDoesNotExist",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				err := c.TerminateWorkflowInstance(ctx, core.NewWorkflowInstance(uuid.NewString(), uuid.NewString()), "reason")
+				require.Error(t, err)
+				require.Equal(t, backend.ErrInstanceNotFound, err)
+			},
+		},
+		{
+			name: "TerminateWorkflow_FinishesInstance",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				startWorkflow(t, ctx, b, c, instance)
+
+				// Add a pending event which should be discarded
+				err := c.SignalWorkflow(ctx, instance.InstanceID, "signal", "value")
+				require.NoError(t, err)
+
+				err = c.TerminateWorkflowInstance(ctx, instance, "reason")
+				require.NoError(t, err)
+
+				s, err := b.GetWorkflowInstanceState(ctx, instance)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateFinished, s)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+				require.NoError(t, err)
+				last := h[len(h)-1]
+				require.Equal(t, history.EventType_WorkflowExecutionTerminated, last.Type)
+				require.Equal(t, h[len(h)-2].SequenceID+1, last.SequenceID)
+				require.Equal(t, "reason", last.Attributes.(*history.ExecutionTerminatedAttributes).Reason)
+
+				// Instance is finished, no task should be returned
+				ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+				defer cancel()
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.Nil(t, task)
+				require.True(t, err == nil || errors.Is(err, context.DeadlineExceeded))
+
+				// Terminating again fails
+				err = c.TerminateWorkflowInstance(ctx, instance, "reason")
+				require.ErrorIs(t, err, backend.ErrInstanceNotActive)
+			},
+		},
+		{
+			name: "TerminateWorkflow_ErrorWhenExecutionIsNotCurrent",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				startWorkflow(t, ctx, b, c, instance)
+
+				err := c.TerminateWorkflowInstance(ctx, core.NewWorkflowInstance(instance.InstanceID, uuid.NewString()), "reason")
+				require.ErrorIs(t, err, backend.ErrInstanceNotActive)
+
+				// The current execution keeps running
+				s, err := b.GetWorkflowInstanceState(ctx, instance)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateActive, s)
+			},
+		},
+		{
+			name: "TerminateWorkflow_NotifiesParent",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				startWorkflow(t, ctx, b, c, instance)
+
+				subInstance := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), instance.InstanceID, 2)
+				startWorkflow(t, ctx, b, c, subInstance)
+
+				err := c.TerminateWorkflowInstance(ctx, subInstance, "reason")
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, instance.InstanceID, task.WorkflowInstance.InstanceID)
+				require.Len(t, task.NewEvents, 1)
+				require.Equal(t, history.EventType_SubWorkflowFailed, task.NewEvents[0].Type)
+				require.Equal(t, int64(2), task.NewEvents[0].ScheduleEventID)
+			},
+		},
+		{
+			name: "TerminateWorkflow_TerminatesSubWorkflows",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				startWorkflow(t, ctx, b, c, instance)
+
+				subInstance := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), instance.InstanceID, 2)
+				startWorkflow(t, ctx, b, c, subInstance)
+
+				subSubInstance := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), subInstance.InstanceID, 3)
+				startWorkflow(t, ctx, b, c, subSubInstance)
+
+				err := c.TerminateWorkflowInstance(ctx, instance, "reason", client.WithSubWorkflows())
+				require.NoError(t, err)
+
+				for _, i := range []*core.WorkflowInstance{instance, subInstance, subSubInstance} {
+					s, err := b.GetWorkflowInstanceState(ctx, i)
+					require.NoError(t, err)
+					require.Equal(t, core.WorkflowInstanceStateFinished, s)
+
+					h, err := b.GetWorkflowInstanceHistory(ctx, i, nil)
+					require.NoError(t, err)
+					require.Equal(t, history.EventType_WorkflowExecutionTerminated, h[len(h)-1].Type)
+				}
+
+				// Parents are terminated along with their sub-workflows, no task should be returned
+				ctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+				defer cancel()
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.Nil(t, task)
+				require.True(t, err == nil || errors.Is(err, context.DeadlineExceeded))
+			},
+		},
+		{
+			name: "CompleteWorkflowTask_SendsInstanceEvents",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+
+				subInstance1 := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), instance.InstanceID, 1)
+				startWorkflow(t, ctx, b, c, subInstance1)
+
+				// Create parent instance
+				err := b.CreateWorkflowInstance(ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				// Simulate context and sub-workflow cancellation
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				err = b.CompleteWorkflowTask(ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{}, []*history.Event{}, []history.WorkflowEvent{
+					{
+						WorkflowInstance: subInstance1,
+						HistoryEvent: history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionCanceled, &history.SubWorkflowCancellationRequestedAttributes{
+							SubWorkflowInstance: subInstance1,
+						}),
+					},
+				})
+				require.NoError(t, err)
+
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, subInstance1, task.WorkflowInstance)
+				require.Equal(t, history.EventType_WorkflowExecutionCanceled, task.NewEvents[len(task.NewEvents)-1].Type)
+			},
+		},
+		{
+			name: "GetActivityTask_ReturnsNilWhenTimeout",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+				defer cancel()
+
+				task, _ := b.GetActivityTask(ctx, nil)
+				require.Nil(t, task)
+			},
+		},
+		{
+			name: "GetActivityTask_ReturnsHigherPriorityFirst",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				lowEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name: "activity",
+				}, history.ScheduleEventID(1))
+				highEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name:     "activity",
+					Priority: 10,
+				}, history.ScheduleEventID(2))
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{lowEvent, highEvent}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+				require.Equal(t, highEvent.ID, activityTask.Event.ID)
+
+				activityTask, err = b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+				require.Equal(t, lowEvent.ID, activityTask.Event.ID)
+			},
+		},
+		{
+			name: "GetActivityTask_OnlyReturnsTasksForGivenQueues",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				activityScheduledEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name:  "activity",
+					Queue: "other",
+				}, history.ScheduleEventID(1))
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{activityScheduledEvent}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				// Task is not returned for the default queue
+				tctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+				defer cancel()
+
+				activityTask, err := b.GetActivityTask(tctx, nil)
+				require.Nil(t, activityTask)
+				require.True(t, err == nil || errors.Is(err, context.DeadlineExceeded))
+
+				activityTask, err = b.GetActivityTask(ctx, []workflow.Queue{"other"})
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+				require.Equal(t, activityScheduledEvent.ID, activityTask.Event.ID)
+
+				err = b.CompleteActivityTask(ctx, instance, activityTask.ID, history.NewPendingEvent(
+					time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)))
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "GetWorkflowTask_TimesOutActivities",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				activityScheduledEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name: "activity",
+					Timeouts: &history.ActivityTimeouts{
+						StartToClose: time.Millisecond * 100,
+					},
+				}, history.ScheduleEventID(1))
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{activityScheduledEvent}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+
+				// Record progress, but don't extend the activity task anymore. Wait for the start-to-close timeout to expire
+				err = b.ExtendActivityTask(ctx, activityTask.ID, payload.Payload(`"progress"`))
+				require.NoError(t, err)
+
+				time.Sleep(time.Millisecond * 300)
+
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, instance.InstanceID, task.WorkflowInstance.InstanceID)
+
+				e := task.NewEvents[len(task.NewEvents)-1]
+				require.Equal(t, history.EventType_ActivityFailed, e.Type)
+				require.Equal(t, int64(1), e.ScheduleEventID)
+				require.Equal(t, workflow.TimeoutTypeStartToClose, e.Attributes.(*history.ActivityFailedAttributes).TimeoutType)
+				require.Equal(t, payload.Payload(`"progress"`), e.Attributes.(*history.ActivityFailedAttributes).HeartbeatDetails)
+
+				// The activity task is gone, the worker cannot extend or complete it anymore
+				err = b.ExtendActivityTask(ctx, activityTask.ID, nil)
+				require.ErrorIs(t, err, backend.ErrActivityTaskNotFound)
+
+				err = b.CompleteActivityTask(ctx, instance, activityTask.ID, history.NewPendingEvent(
+					time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)))
+				require.ErrorIs(t, err, backend.ErrActivityTaskNotFound)
+			},
+		},
+		{
+			name: "ExtendActivityTask_ReturnsErrActivityCanceled",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				activityScheduledEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name: "activity",
+				}, history.ScheduleEventID(1))
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{activityScheduledEvent}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+
+				err = b.ExtendActivityTask(ctx, activityTask.ID, nil)
+				require.NoError(t, err)
+
+				err = c.CancelWorkflowInstance(ctx, instance)
+				require.NoError(t, err)
+
+				err = b.ExtendActivityTask(ctx, activityTask.ID, nil)
+				require.ErrorIs(t, err, backend.ErrActivityCanceled)
+
+				// The activity can still be completed
+				err = b.CompleteActivityTask(ctx, instance, activityTask.ID, history.NewPendingEvent(
+					time.Now(), history.EventType_ActivityFailed, &history.ActivityFailedAttributes{Error: workflow.NewError("", "canceled")}, history.ScheduleEventID(1)))
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "ExtendActivityTask_KeepsActivitiesScheduledAfterCancellation",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				sequenceID := int64(0)
+				executed := func(events ...*history.Event) []*history.Event {
+					for _, event := range events {
+						sequenceID++
+						event.SequenceID = sequenceID
+					}
+
+					return events
+				}
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, instance, core.WorkflowInstanceStateActive, executed(task.NewEvents...), []*history.Event{}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				err = c.CancelWorkflowInstance(ctx, instance)
+				require.NoError(t, err)
+
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, history.EventType_WorkflowExecutionCanceled, task.NewEvents[len(task.NewEvents)-1].Type)
+
+				// Schedule a cleanup activity after the workflow has seen the cancellation
+				activityScheduledEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name: "cleanup",
+				}, history.ScheduleEventID(1))
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, instance, core.WorkflowInstanceStateActive, executed(append(task.NewEvents, activityScheduledEvent)...), []*history.Event{activityScheduledEvent}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+
+				err = b.ExtendActivityTask(ctx, activityTask.ID, nil)
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "SetActivityTaskPending_KeepsActivityUntilCompleted",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				activityScheduledEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name: "activity",
+				}, history.ScheduleEventID(1))
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{activityScheduledEvent}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+
+				err = b.SetActivityTaskPending(ctx, activityTask.ID)
+				require.NoError(t, err)
+
+				// The pending activity task is not handed out again
+				tctx, cancel := context.WithTimeout(ctx, time.Millisecond*100)
+				defer cancel()
+
+				pendingTask, _ := b.GetActivityTask(tctx, nil)
+				require.Nil(t, pendingTask)
+
+				// Heartbeats and the completion can be recorded by other processes
+				err = b.ExtendActivityTask(ctx, activityTask.ID, payload.Payload(`"progress"`))
+				require.NoError(t, err)
+
+				err = b.CompleteActivityTask(ctx, instance, activityTask.ID, history.NewPendingEvent(
+					time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)))
+				require.NoError(t, err)
+
+				task, err = b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				e := task.NewEvents[len(task.NewEvents)-1]
+				require.Equal(t, history.EventType_ActivityCompleted, e.Type)
+				require.Equal(t, int64(1), e.ScheduleEventID)
+
+				// The activity can only be completed once
+				err = b.CompleteActivityTask(ctx, instance, activityTask.ID, history.NewPendingEvent(
+					time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)))
+				require.ErrorIs(t, err, backend.ErrActivityTaskNotFound)
+
+				err = b.SetActivityTaskPending(ctx, activityTask.ID)
+				require.ErrorIs(t, err, backend.ErrActivityTaskNotFound)
+			},
+		},
+		{
+			name: "AbandonActivityTask_ReleasesLock",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(
+					ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				activityScheduledEvent := history.NewPendingEvent(time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+					Name: "activity",
+				}, history.ScheduleEventID(1))
+
+				err = b.CompleteWorkflowTask(
+					ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{activityScheduledEvent}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				activityTask, err := b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, activityTask)
+
+				err = b.ExtendActivityTask(ctx, activityTask.ID, payload.Payload(`"progress"`))
+				require.NoError(t, err)
+
+				err = b.AbandonActivityTask(ctx, activityTask)
+				require.NoError(t, err)
+
+				// Task is returned again right away, with the recorded heartbeat details
+				abandonedTask, err := b.GetActivityTask(ctx, nil)
+				require.NoError(t, err)
+				require.NotNil(t, abandonedTask)
+				require.Equal(t, activityScheduledEvent.ID, abandonedTask.Event.ID)
+				require.Equal(t, payload.Payload(`"progress"`), abandonedTask.Event.Attributes.(*history.ActivityScheduledAttributes).HeartbeatDetails)
+
+				err = b.CompleteActivityTask(ctx, instance, abandonedTask.ID, history.NewPendingEvent(
+					time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)))
+				require.NoError(t, err)
+
+				// Completed tasks can't be abandoned
+				err = b.AbandonActivityTask(ctx, abandonedTask)
+				require.ErrorIs(t, err, backend.ErrActivityTaskNotFound)
+			},
+		},
+		{
+			name: "ListWorkflowInstances_FiltersInstances",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+				start := time.Now().Add(-time.Minute)
+
+				parent := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				createTestInstance(t, ctx, b, parent, "Parent", &core.WorkflowMetadata{"tenant": "a"})
+
+				sub := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), parent.InstanceID, 1)
+				createTestInstance(t, ctx, b, sub, "Child", &core.WorkflowMetadata{"tenant": "a"})
+
+				other := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				createTestInstance(t, ctx, b, other, "Child", &core.WorkflowMetadata{"tenant": "b"})
+
+				err := c.TerminateWorkflowInstance(ctx, other, "reason")
+				require.NoError(t, err)
+
+				list := func(filter *backend.WorkflowInstanceFilter) []string {
+					instances, err := b.ListWorkflowInstances(ctx, filter, "", 10)
+					require.NoError(t, err)
+
+					ids := []string{}
+					for _, i := range instances {
+						ids = append(ids, i.Instance.InstanceID)
+					}
+
+					return ids
+				}
+
+				active := core.WorkflowInstanceStateActive
+				finished := core.WorkflowInstanceStateFinished
+
+				require.ElementsMatch(t, []string{parent.InstanceID, sub.InstanceID, other.InstanceID}, list(nil))
+				require.ElementsMatch(t, []string{sub.InstanceID, other.InstanceID}, list(&backend.WorkflowInstanceFilter{WorkflowName: "Child"}))
+				require.ElementsMatch(t, []string{parent.InstanceID, sub.InstanceID}, list(&backend.WorkflowInstanceFilter{State: &active}))
+				require.ElementsMatch(t, []string{other.InstanceID}, list(&backend.WorkflowInstanceFilter{State: &finished}))
+				require.ElementsMatch(t, []string{sub.InstanceID}, list(&backend.WorkflowInstanceFilter{ParentInstanceID: parent.InstanceID}))
+				require.ElementsMatch(t, []string{parent.InstanceID, sub.InstanceID}, list(&backend.WorkflowInstanceFilter{Metadata: map[string]string{"tenant": "a"}}))
+				require.ElementsMatch(t, []string{sub.InstanceID}, list(&backend.WorkflowInstanceFilter{WorkflowName: "Child", Metadata: map[string]string{"tenant": "a"}}))
+				require.ElementsMatch(t, []string{other.InstanceID}, list(&backend.WorkflowInstanceFilter{CompletedAfter: start}))
+				require.ElementsMatch(t, []string{}, list(&backend.WorkflowInstanceFilter{CompletedBefore: start}))
+				require.ElementsMatch(t, []string{}, list(&backend.WorkflowInstanceFilter{CreatedAfter: time.Now().Add(time.Minute)}))
+				require.ElementsMatch(t, []string{parent.InstanceID, sub.InstanceID, other.InstanceID}, list(&backend.WorkflowInstanceFilter{
+					CreatedAfter:  start,
+					CreatedBefore: time.Now().Add(time.Minute),
+				}))
+
+				instances, err := b.ListWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{ParentInstanceID: parent.InstanceID}, "", 10)
+				require.NoError(t, err)
+				require.Len(t, instances, 1)
+				require.Equal(t, sub, instances[0].Instance)
+				require.Equal(t, "Child", instances[0].WorkflowName)
+				require.Equal(t, active, instances[0].State)
+				require.Equal(t, "a", instances[0].Metadata.Get("tenant"))
+				require.Nil(t, instances[0].CompletedAt)
+
+				count, err := b.CountWorkflowInstances(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, int64(3), count)
+
+				count, err = b.CountWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{WorkflowName: "Child"})
+				require.NoError(t, err)
+				require.Equal(t, int64(2), count)
+
+				count, err = b.CountWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{WorkflowName: "Child", State: &finished})
+				require.NoError(t, err)
+				require.Equal(t, int64(1), count)
+			},
+		},
+		{
+			name: "ListWorkflowInstances_FiltersBySearchAttributes",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				createdAt := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+				startedEvent := history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name: "Workflow",
+					SearchAttributes: core.SearchAttributes{
+						"customer": core.StringSearchAttribute("c1"),
+						"amount":   core.IntSearchAttribute(42),
+						"tags":     core.KeywordsSearchAttribute("a", "b"),
+					},
+				})
+
+				wfi := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, wfi, startedEvent)
+				require.NoError(t, err)
+
+				other := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err = b.CreateWorkflowInstance(ctx, other, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name: "Workflow",
+					SearchAttributes: core.SearchAttributes{
+						"customer": core.StringSearchAttribute("c2"),
+						"amount":   core.StringSearchAttribute("42"),
+					},
+				}))
+				require.NoError(t, err)
+
+				list := func(attributes core.SearchAttributes) []string {
+					instances, err := b.ListWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{SearchAttributes: attributes}, "", 10)
+					require.NoError(t, err)
+
+					ids := []string{}
+					for _, i := range instances {
+						ids = append(ids, i.Instance.InstanceID)
+					}
+
+					return ids
+				}
+
+				require.ElementsMatch(t, []string{wfi.InstanceID}, list(core.SearchAttributes{"customer": core.StringSearchAttribute("c1")}))
+				require.ElementsMatch(t, []string{wfi.InstanceID}, list(core.SearchAttributes{"amount": core.IntSearchAttribute(42)}))
+				require.ElementsMatch(t, []string{other.InstanceID}, list(core.SearchAttributes{"amount": core.StringSearchAttribute("42")}))
+				require.ElementsMatch(t, []string{wfi.InstanceID}, list(core.SearchAttributes{"tags": core.KeywordsSearchAttribute("b")}))
+				require.ElementsMatch(t, []string{}, list(core.SearchAttributes{"tags": core.KeywordsSearchAttribute("b", "c")}))
+
+				// Upsert attributes from the workflow
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+				require.Equal(t, wfi.InstanceID, task.WorkflowInstance.InstanceID)
+
+				events := []*history.Event{
+					history.NewHistoryEvent(-1, time.Now(), history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{}),
+					startedEvent,
+					history.NewHistoryEvent(-1, time.Now(), history.EventType_SearchAttributesUpserted, &history.SearchAttributesUpsertedAttributes{
+						SearchAttributes: core.SearchAttributes{
+							"customer":  core.StringSearchAttribute("c3"),
+							"shipped":   core.BoolSearchAttribute(true),
+							"shippedAt": core.TimeSearchAttribute(createdAt),
+						},
+					}),
+				}
+
+				for i := range events {
+					events[i].SequenceID = int64(i + 1)
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, wfi, core.WorkflowInstanceStateActive, events, []*history.Event{}, []*history.Event{}, []history.WorkflowEvent{})
+				require.NoError(t, err)
+
+				require.ElementsMatch(t, []string{}, list(core.SearchAttributes{"customer": core.StringSearchAttribute("c1")}))
+				require.ElementsMatch(t, []string{wfi.InstanceID}, list(core.SearchAttributes{"customer": core.StringSearchAttribute("c3")}))
+				require.ElementsMatch(t, []string{wfi.InstanceID}, list(core.SearchAttributes{
+					"shipped":   core.BoolSearchAttribute(true),
+					"shippedAt": core.TimeSearchAttribute(createdAt),
+					"amount":    core.IntSearchAttribute(42),
+				}))
+
+				instances, err := b.ListWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{
+					SearchAttributes: core.SearchAttributes{"customer": core.StringSearchAttribute("c3")},
+				}, "", 10)
+				require.NoError(t, err)
+				require.Len(t, instances, 1)
+				require.Equal(t, core.SearchAttributes{
+					"customer":  core.StringSearchAttribute("c3"),
+					"amount":    core.IntSearchAttribute(42),
+					"tags":      core.KeywordsSearchAttribute("a", "b"),
+					"shipped":   core.BoolSearchAttribute(true),
+					"shippedAt": core.TimeSearchAttribute(createdAt),
+				}, instances[0].SearchAttributes)
+
+				count, err := b.CountWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{
+					SearchAttributes: core.SearchAttributes{"tags": core.KeywordsSearchAttribute("a")},
+				})
+				require.NoError(t, err)
+				require.Equal(t, int64(1), count)
+			},
+		},
+		{
+			name: "CompleteWorkflowTask_ContinueAsNewReplacesSearchAttributes",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				err := b.CreateWorkflowInstance(ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+					Name: "Workflow",
+					SearchAttributes: core.SearchAttributes{
+						"customer": core.StringSearchAttribute("c1"),
+						"tags":     core.KeywordsSearchAttribute("a"),
+					},
+				}))
+				require.NoError(t, err)
+
+				task, err := b.GetWorkflowTask(ctx, nil)
+				require.NoError(t, err)
+
+				// Continue as new with different search attributes
+				continuedInstance := core.NewWorkflowInstance(instance.InstanceID, uuid.NewString())
+				events := append(task.NewEvents, history.NewPendingEvent(
+					time.Now(), history.EventType_WorkflowExecutionContinuedAsNew, &history.ExecutionContinuedAsNewAttributes{
+						ContinuedExecutionID: continuedInstance.ExecutionID,
+					}))
+				for i := range events {
+					events[i].SequenceID = int64(i + 1)
+				}
+
+				err = b.CompleteWorkflowTask(ctx, task, instance, core.WorkflowInstanceStateFinished, events, []*history.Event{}, []*history.Event{}, []history.WorkflowEvent{
+					{
+						WorkflowInstance: continuedInstance,
+						HistoryEvent: history.NewPendingEvent(
+							time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+								Name: "Workflow",
+								SearchAttributes: core.SearchAttributes{
+									"customer": core.StringSearchAttribute("c2"),
+								},
+							}),
+					},
+				})
+				require.NoError(t, err)
+
+				list := func(attributes core.SearchAttributes) []*backend.WorkflowInstanceInfo {
+					instances, err := b.ListWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{SearchAttributes: attributes}, "", 10)
+					require.NoError(t, err)
+
+					return instances
+				}
+
+				require.Empty(t, list(core.SearchAttributes{"customer": core.StringSearchAttribute("c1")}))
+				require.Empty(t, list(core.SearchAttributes{"tags": core.KeywordsSearchAttribute("a")}))
+
+				instances := list(core.SearchAttributes{"customer": core.StringSearchAttribute("c2")})
+				require.Len(t, instances, 1)
+				require.Equal(t, continuedInstance.ExecutionID, instances[0].Instance.ExecutionID)
+				require.Equal(t, core.SearchAttributes{
+					"customer": core.StringSearchAttribute("c2"),
+				}, instances[0].SearchAttributes)
+			},
+		},
+		{
+			name: "ListWorkflowInstances_Paginates",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				c := client.New(b)
+
+				instanceIDs := []string{}
+				for i := 0; i < 5; i++ {
+					instance := core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+					createTestInstance(t, ctx, b, instance, "Workflow", nil)
+					instanceIDs = append(instanceIDs, instance.InstanceID)
+				}
+
+				listed := []string{}
+				pages := 0
+				cursor := ""
+				var lastCreatedAt time.Time
+				for {
+					page, err := c.ListWorkflowInstances(ctx, nil, client.WithPageSize(2), client.WithCursor(cursor))
+					require.NoError(t, err)
+					pages++
+
+					for _, i := range page.Instances {
+						// Newest instances are returned first
+						if !lastCreatedAt.IsZero() {
+							require.False(t, i.CreatedAt.After(lastCreatedAt))
+						}
+
+						lastCreatedAt = i.CreatedAt
+						listed = append(listed, i.Instance.InstanceID)
+					}
+
+					if page.NextCursor == "" {
+						break
+					}
+
+					cursor = page.NextCursor
+				}
+
+				require.Equal(t, 3, pages)
+				require.Len(t, listed, 5)
+				require.ElementsMatch(t, instanceIDs, listed)
+			},
+		},
+		{
+			name: "CreateSchedule_SameIDErrors",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				s := newTestSchedule(uuid.NewString(), time.Now().Add(time.Hour))
+
+				err := b.CreateSchedule(ctx, s)
+				require.NoError(t, err)
+
+				err = b.CreateSchedule(ctx, s)
+				require.ErrorIs(t, err, backend.ErrScheduleAlreadyExists)
+			},
+		},
+		{
+			name: "GetSchedule_ReturnsSchedule",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				s := newTestSchedule(uuid.NewString(), time.Now().Add(time.Hour))
+
+				err := b.CreateSchedule(ctx, s)
+				require.NoError(t, err)
+
+				stored, err := b.GetSchedule(ctx, s.ID)
+				require.NoError(t, err)
+				require.Equal(t, s.ID, stored.ID)
+				require.Equal(t, s.Spec, stored.Spec)
+				require.Equal(t, s.WorkflowName, stored.WorkflowName)
+				require.Equal(t, s.Inputs, stored.Inputs)
+				require.False(t, stored.Paused)
+				require.True(t, s.NextRunAt.Equal(stored.NextRunAt))
+				require.Nil(t, stored.BufferedRunAt)
+				require.Nil(t, stored.LastInstance)
+			},
+		},
+		{
+			name: "Schedule_ErrorWhenScheduleDoesNotExist",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				_, err := b.GetSchedule(ctx, "does-not-exist")
+				require.ErrorIs(t, err, backend.ErrScheduleNotFound)
+
+				err = b.PauseSchedule(ctx, "does-not-exist")
+				require.ErrorIs(t, err, backend.ErrScheduleNotFound)
+
+				err = b.ResumeSchedule(ctx, "does-not-exist", time.Now())
+				require.ErrorIs(t, err, backend.ErrScheduleNotFound)
+
+				err = b.DeleteSchedule(ctx, "does-not-exist")
+				require.ErrorIs(t, err, backend.ErrScheduleNotFound)
+			},
+		},
+		{
+			name: "GetScheduleTask_ReturnsNilWhenNotDue",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				err := b.CreateSchedule(ctx, newTestSchedule(uuid.NewString(), time.Now().Add(time.Hour)))
+				require.NoError(t, err)
+
+				s, err := b.GetScheduleTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, s)
+			},
+		},
+		{
+			name: "GetScheduleTask_LocksSchedule",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				s := newTestSchedule(uuid.NewString(), time.Now().Add(-time.Minute))
+
+				err := b.CreateSchedule(ctx, s)
+				require.NoError(t, err)
+
+				task, err := b.GetScheduleTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.Equal(t, s.ID, task.ID)
+
+				task, err = b.GetScheduleTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, task)
+			},
+		},
+		{
+			name: "GetScheduleTask_SkipsPausedSchedules",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				s := newTestSchedule(uuid.NewString(), time.Now().Add(-time.Minute))
+
+				err := b.CreateSchedule(ctx, s)
+				require.NoError(t, err)
+
+				err = b.PauseSchedule(ctx, s.ID)
+				require.NoError(t, err)
+
+				task, err := b.GetScheduleTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, task)
+
+				stored, err := b.GetSchedule(ctx, s.ID)
+				require.NoError(t, err)
+				require.True(t, stored.Paused)
+
+				err = b.ResumeSchedule(ctx, s.ID, time.Now().Add(-time.Minute))
+				require.NoError(t, err)
+
+				task, err = b.GetScheduleTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+				require.False(t, task.Paused)
+			},
+		},
+		{
+			name: "CompleteScheduleTask_StoresStateAndUnlocks",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				s := newTestSchedule(uuid.NewString(), time.Now().Add(-time.Minute))
+
+				err := b.CreateSchedule(ctx, s)
+				require.NoError(t, err)
+
+				task, err := b.GetScheduleTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				bufferedRunAt := s.NextRunAt
+				task.BufferedRunAt = &bufferedRunAt
+				task.LastInstance = core.NewWorkflowInstance(uuid.NewString(), uuid.NewString())
+				task.NextRunAt = time.Now().Add(time.Hour).Truncate(time.Second)
+				task.DueAt = time.Now().Add(-time.Second).Truncate(time.Second)
+
+				err = b.CompleteScheduleTask(ctx, task)
+				require.NoError(t, err)
+
+				stored, err := b.GetSchedule(ctx, s.ID)
+				require.NoError(t, err)
+				require.True(t, task.NextRunAt.Equal(stored.NextRunAt))
+				require.NotNil(t, stored.BufferedRunAt)
+				require.True(t, bufferedRunAt.Equal(*stored.BufferedRunAt))
+				require.Equal(t, task.LastInstance, stored.LastInstance)
+
+				// Schedule is due again
+				task, err = b.GetScheduleTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+			},
+		},
+		{
+			name: "CompleteScheduleTask_RequiresLock",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				s := newTestSchedule(uuid.NewString(), time.Now().Add(-time.Minute))
+
+				err := b.CreateSchedule(ctx, s)
+				require.NoError(t, err)
+
+				err = b.CompleteScheduleTask(ctx, s)
+				require.ErrorIs(t, err, backend.ErrScheduleNotFound)
+
+				task, err := b.GetScheduleTask(ctx)
+				require.NoError(t, err)
+				require.NotNil(t, task)
+
+				err = b.CompleteScheduleTask(ctx, task)
+				require.NoError(t, err)
+
+				// The lock has been released
+				err = b.CompleteScheduleTask(ctx, task)
+				require.ErrorIs(t, err, backend.ErrScheduleNotFound)
+			},
+		},
+		{
+			name: "DeleteSchedule_RemovesSchedule",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				s := newTestSchedule(uuid.NewString(), time.Now().Add(-time.Minute))
+
+				err := b.CreateSchedule(ctx, s)
+				require.NoError(t, err)
+
+				err = b.DeleteSchedule(ctx, s.ID)
+				require.NoError(t, err)
+
+				_, err = b.GetSchedule(ctx, s.ID)
+				require.ErrorIs(t, err, backend.ErrScheduleNotFound)
+
+				task, err := b.GetScheduleTask(ctx)
+				require.NoError(t, err)
+				require.Nil(t, task)
+			},
+		},
+		{
+			name: "AcquireActivitySlot_LimitsHolders",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				limiter, ok := b.(backend.ActivityLimiter)
+				if !ok {
+					t.Skip("backend does not support activity limits")
+				}
+
+				name := uuid.NewString()
+
+				acquired, err := limiter.AcquireActivitySlot(ctx, name, "a", 2, time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+
+				acquired, err = limiter.AcquireActivitySlot(ctx, name, "b", 2, time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+
+				acquired, err = limiter.AcquireActivitySlot(ctx, name, "c", 2, time.Minute)
+				require.NoError(t, err)
+				require.False(t, acquired)
+
+				// Held slots can be extended
+				acquired, err = limiter.AcquireActivitySlot(ctx, name, "a", 2, time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+
+				// Other activities are not affected
+				acquired, err = limiter.AcquireActivitySlot(ctx, uuid.NewString(), "c", 2, time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+
+				err = limiter.ReleaseActivitySlot(ctx, name, "a")
+				require.NoError(t, err)
+
+				acquired, err = limiter.AcquireActivitySlot(ctx, name, "c", 2, time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+			},
+		},
+		{
+			name: "AcquireActivitySlot_ReleasesExpiredSlots",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				limiter, ok := b.(backend.ActivityLimiter)
+				if !ok {
+					t.Skip("backend does not support activity limits")
+				}
+
+				name := uuid.NewString()
+
+				acquired, err := limiter.AcquireActivitySlot(ctx, name, "a", 1, 100*time.Millisecond)
+				require.NoError(t, err)
+				require.True(t, acquired)
+
+				acquired, err = limiter.AcquireActivitySlot(ctx, name, "b", 1, time.Minute)
+				require.NoError(t, err)
+				require.False(t, acquired)
+
+				time.Sleep(200 * time.Millisecond)
+
+				acquired, err = limiter.AcquireActivitySlot(ctx, name, "b", 1, time.Minute)
+				require.NoError(t, err)
+				require.True(t, acquired)
+			},
+		},
+		{
+			name: "TakeActivityToken_LimitsRate",
+			f: func(t *testing.T, ctx context.Context, b backend.Backend) {
+				limiter, ok := b.(backend.ActivityLimiter)
+				if !ok {
+					t.Skip("backend does not support activity limits")
+				}
+
+				name := uuid.NewString()
+
+				for i := 0; i < 2; i++ {
+					wait, err := limiter.TakeActivityToken(ctx, name, 1, 2)
+					require.NoError(t, err)
+					require.Zero(t, wait)
+				}
+
+				wait, err := limiter.TakeActivityToken(ctx, name, 1, 2)
+				require.NoError(t, err)
+				require.Greater(t, wait, time.Duration(0))
+				require.LessOrEqual(t, wait, time.Second)
+
+				time.Sleep(wait)
+
+				wait, err = limiter.TakeActivityToken(ctx, name, 1, 2)
+				require.NoError(t, err)
+				require.Zero(t, wait)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := setup()
+			ctx := context.Background()
+
+			t.Cleanup(func() {
+				if teardown != nil {
+					teardown(b)
+				}
+			})
+
+			tt.f(t, ctx, b)
+		})
+	}
+}
+
+func startWorkflow(t *testing.T, ctx context.Context, b backend.Backend, c client.Client, instance *core.WorkflowInstance) {
+	err := b.CreateWorkflowInstance(
+		ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}))
+	require.NoError(t, err)
 
 	// Get task to clear initial event
-	task, err := b.GetWorkflowTask(ctx)
+	task, err := b.GetWorkflowTask(ctx, nil)
 	require.NoError(t, err)
 
 	err = b.CompleteWorkflowTask(
 		ctx, task, instance, core.WorkflowInstanceStateActive, task.NewEvents, []*history.Event{}, []*history.Event{}, []history.WorkflowEvent{})
 	require.NoError(t, err)
 }
+
+func newTestStartedEvent(policy core.IDReusePolicy) *history.Event {
+	return history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+		IDReusePolicy: policy,
+	})
+}
+
+// createFinishedTestInstance creates a workflow instance and finishes it with the given error
+func createFinishedTestInstance(t *testing.T, ctx context.Context, b backend.Backend, instance *core.WorkflowInstance, wfErr *workflowerrors.Error) {
+	err := b.CreateWorkflowInstance(ctx, instance, newTestStartedEvent(core.IDReusePolicyRejectDuplicate))
+	require.NoError(t, err)
+
+	task, err := b.GetWorkflowTask(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, instance, task.WorkflowInstance)
+
+	events := append(task.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{
+		Error: wfErr,
+	}))
+
+	for i := range events {
+		events[i].SequenceID = int64(i + 1)
+	}
+
+	err = b.CompleteWorkflowTask(
+		ctx, task, instance, core.WorkflowInstanceStateFinished, events, []*history.Event{}, []*history.Event{}, []history.WorkflowEvent{})
+	require.NoError(t, err)
+}
+
+func createTestInstance(t *testing.T, ctx context.Context, b backend.Backend, instance *core.WorkflowInstance, workflowName string, metadata *core.WorkflowMetadata) {
+	err := b.CreateWorkflowInstance(
+		ctx, instance, history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+			Name:     workflowName,
+			Metadata: metadata,
+		}))
+	require.NoError(t, err)
+}
+
+func newTestSchedule(id string, nextRunAt time.Time) *schedule.Schedule {
+	// Some backends store timestamps with second precision
+	nextRunAt = nextRunAt.Truncate(time.Second)
+
+	s := schedule.NewSchedule(id, schedule.Spec{Interval: time.Hour}, "Workflow", []payload.Payload{[]byte("42")}, time.Now().Truncate(time.Second))
+	s.NextRunAt = nextRunAt
+	s.DueAt = nextRunAt
+
+	return s
+}
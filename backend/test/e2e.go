@@ -2,14 +2,19 @@ package test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/cschleiden/go-workflows/activity"
 	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/client"
+	"github.com/cschleiden/go-workflows/diag"
 	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/fn"
 	"github.com/cschleiden/go-workflows/internal/history"
 	internalwf "github.com/cschleiden/go-workflows/internal/workflow"
 	"github.com/cschleiden/go-workflows/worker"
@@ -128,6 +133,87 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.ErrorContains(t, err, "mismatched argument count: expected 2, got 1")
 			},
 		},
+		{
+			name: "Activity_StructuredError",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(context.Context) error {
+					return workflow.NewError("InvalidInput", "invalid input").WithDetails("details")
+				}
+				wf := func(ctx workflow.Context) (string, error) {
+					_, err := workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts: 1,
+						},
+					}, a).Get(ctx)
+
+					var activityErr *workflow.Error
+					if !errors.As(err, &activityErr) {
+						return "", errors.New("expected workflow.Error")
+					}
+
+					var details string
+					if err := activityErr.Details(&details); err != nil {
+						return "", err
+					}
+
+					return "", fmt.Errorf("activity failed with %s: %w", details, err)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				_, err := runWorkflowWithResult[string](t, ctx, c, wf)
+
+				var werr *workflow.Error
+				require.ErrorAs(t, err, &werr)
+				require.Equal(t, "activity failed with details: invalid input", werr.Message)
+				require.ErrorAs(t, werr.Cause, &werr)
+				require.Equal(t, "InvalidInput", werr.Type)
+			},
+		},
+		{
+			name: "Activity_PermanentError",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var attempts int32
+				a := func(context.Context) error {
+					atomic.AddInt32(&attempts, 1)
+					return workflow.NewPermanentError(errors.New("invalid input"))
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts: 3,
+						},
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				_, err := runWorkflowWithResult[int](t, ctx, c, wf)
+				require.EqualError(t, err, "invalid input")
+				require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+			},
+		},
+		{
+			name: "Activity_NonRetryableErrorTypes",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var attempts int32
+				a := func(context.Context) error {
+					atomic.AddInt32(&attempts, 1)
+					return workflow.NewError("InvalidInput", "invalid input")
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts:            3,
+							NonRetryableErrorTypes: []string{"InvalidInput"},
+						},
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				_, err := runWorkflowWithResult[int](t, ctx, c, wf)
+				require.EqualError(t, err, "invalid input")
+				require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+			},
+		},
 		{
 			name: "SideEffect_Simple",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
@@ -157,6 +243,37 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.Equal(t, 7, r)
 			},
 		},
+		{
+			name: "GetVersion",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) (int, error) {
+					v := workflow.GetVersion(ctx, "change", workflow.DefaultVersion, 2)
+
+					// Force the workflow to be replayed
+					workflow.Sleep(ctx, time.Millisecond*1)
+
+					return v + workflow.GetVersion(ctx, "change", workflow.DefaultVersion, 2), nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*5)
+				require.NoError(t, err)
+				require.Equal(t, 4, r)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+				require.NoError(t, err)
+
+				markers := 0
+				for _, event := range h {
+					if event.Type == history.EventType_VersionMarker {
+						markers++
+					}
+				}
+				require.Equal(t, 1, markers)
+			},
+		},
 		{
 			name: "Signal_after_completion",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
@@ -181,6 +298,289 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.NoError(t, err)
 			},
 		},
+		{
+			name: "SignalWithStart",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context, start int) (int, error) {
+					sc := workflow.NewSignalChannel[int](ctx, "signal")
+
+					sum := start
+					for i := 0; i < 2; i++ {
+						v, _ := sc.Receive(ctx)
+						sum += v
+					}
+
+					return sum, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				options := client.WorkflowInstanceOptions{
+					InstanceID: uuid.NewString(),
+				}
+
+				instance, err := c.SignalWithStartWorkflow(ctx, options, "signal", 1, wf, 10)
+				require.NoError(t, err)
+
+				// The second call signals the existing instance
+				instance2, err := c.SignalWithStartWorkflow(ctx, options, "signal", 2, wf, 20)
+				require.NoError(t, err)
+				require.Equal(t, instance.ExecutionID, instance2.ExecutionID)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 13, r)
+			},
+		},
+		{
+			name: "StartDelay",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) (string, error) {
+					v, _ := workflow.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+					return v, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				start := time.Now()
+
+				instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+					InstanceID: uuid.NewString(),
+					StartDelay: time.Millisecond * 500,
+				}, wf)
+				require.NoError(t, err)
+
+				// Signal the instance before it starts
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "signal", "hello"))
+
+				r, err := client.GetWorkflowResult[string](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, "hello", r)
+				require.GreaterOrEqual(t, time.Since(start), time.Millisecond*500)
+			},
+		},
+		{
+			name: "StartDelay_CancelBeforeStart",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) error {
+					return workflow.Sleep(ctx, time.Hour)
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+					InstanceID: uuid.NewString(),
+					StartAt:    time.Now().Add(time.Hour),
+				}, wf)
+				require.NoError(t, err)
+
+				require.NoError(t, c.CancelWorkflowInstance(ctx, instance))
+
+				_, err = client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+				require.EqualError(t, err, workflow.Canceled.Error())
+			},
+		},
+		{
+			name: "ExecutionTimeout",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) error {
+					workflow.NewSignalChannel[string](ctx, "never").Receive(ctx)
+
+					return nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+					InstanceID:       uuid.NewString(),
+					ExecutionTimeout: time.Millisecond * 100,
+				}, wf)
+				require.NoError(t, err)
+
+				_, err = client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+
+				var timeoutErr *workflow.ExecutionTimeoutError
+				require.ErrorAs(t, err, &timeoutErr)
+				require.Nil(t, timeoutErr.Cause)
+			},
+		},
+		{
+			name: "ExecutionTimeout_TerminatesSubWorkflows",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swf := func(ctx workflow.Context) error {
+					workflow.NewSignalChannel[string](ctx, "never").Receive(ctx)
+
+					return nil
+				}
+				wf := func(ctx workflow.Context, subInstanceID string) error {
+					_, err := workflow.CreateSubWorkflowInstance[any](ctx, workflow.SubWorkflowOptions{
+						InstanceID: subInstanceID,
+					}, swf).Get(ctx)
+
+					return err
+				}
+				register(t, ctx, w, []interface{}{wf, swf}, nil)
+
+				subInstanceID := uuid.NewString()
+				instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+					InstanceID:       uuid.NewString(),
+					ExecutionTimeout: time.Millisecond * 500,
+				}, wf, subInstanceID)
+				require.NoError(t, err)
+
+				_, err = client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+
+				var timeoutErr *workflow.ExecutionTimeoutError
+				require.ErrorAs(t, err, &timeoutErr)
+
+				// Sub-workflow instance is terminated with its parent
+				executions, err := c.ListWorkflowInstanceExecutions(ctx, subInstanceID)
+				require.NoError(t, err)
+				require.Len(t, executions, 1)
+				require.Equal(t, core.WorkflowInstanceStateFinished, executions[0].State)
+
+				_, err = client.GetWorkflowResult[any](ctx, c, executions[0].Instance, time.Second*10)
+				require.ErrorIs(t, err, client.ErrWorkflowTerminated)
+			},
+		},
+		{
+			name: "ExecutionTimeout_Cancel",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) error {
+					return workflow.Sleep(ctx, time.Hour)
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+					InstanceID:             uuid.NewString(),
+					ExecutionTimeout:       time.Millisecond * 100,
+					ExecutionTimeoutAction: client.ExecutionTimeoutActionCancel,
+				}, wf)
+				require.NoError(t, err)
+
+				_, err = client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+
+				var timeoutErr *workflow.ExecutionTimeoutError
+				require.ErrorAs(t, err, &timeoutErr)
+				require.EqualError(t, timeoutErr.Cause, workflow.Canceled.Error())
+			},
+		},
+		{
+			name: "ExecutionTimeout_SubWorkflow",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swf := func(ctx workflow.Context) error {
+					workflow.NewSignalChannel[string](ctx, "never").Receive(ctx)
+
+					return nil
+				}
+				wf := func(ctx workflow.Context) (bool, error) {
+					_, err := workflow.CreateSubWorkflowInstance[any](ctx, workflow.SubWorkflowOptions{
+						ExecutionTimeout: time.Millisecond * 100,
+					}, swf).Get(ctx)
+
+					var timeoutErr *workflow.ExecutionTimeoutError
+
+					return errors.As(err, &timeoutErr), nil
+				}
+				register(t, ctx, w, []interface{}{wf, swf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				timedOut, err := client.GetWorkflowResult[bool](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.True(t, timedOut)
+			},
+		},
+		{
+			name: "UpdateWorkflow",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				act := func(ctx context.Context, approver string) (string, error) {
+					return "approved by " + approver, nil
+				}
+
+				wf := func(ctx workflow.Context) (string, error) {
+					var approval string
+
+					if err := workflow.SetUpdateHandler(ctx, "approve",
+						func(approver string) error {
+							if approver == "" {
+								return errors.New("approver is required")
+							}
+
+							return nil
+						},
+						func(ctx workflow.Context, approver string) (string, error) {
+							r, err := workflow.ExecuteActivity[string](ctx, workflow.DefaultActivityOptions, act, approver).Get(ctx)
+							if err != nil {
+								return "", err
+							}
+
+							approval = r
+
+							return r, nil
+						}); err != nil {
+						return "", err
+					}
+
+					workflow.NewSignalChannel[any](ctx, "finish").Receive(ctx)
+
+					return approval, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{act})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				uctx, cancel := context.WithTimeout(ctx, time.Second*10)
+				defer cancel()
+
+				_, err := client.UpdateWorkflow[string](uctx, c, instance, "approve", "")
+				require.EqualError(t, err, "approver is required")
+
+				r, err := client.UpdateWorkflow[string](uctx, c, instance, "approve", "alice")
+				require.NoError(t, err)
+				require.Equal(t, "approved by alice", r)
+
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "finish", nil))
+
+				r, err = client.GetWorkflowResult[string](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, "approved by alice", r)
+			},
+		},
+		{
+			name: "IDReusePolicy_AllowDuplicate",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context, i int) (int, error) {
+					return i * 2, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				options := client.WorkflowInstanceOptions{
+					InstanceID:    uuid.NewString(),
+					IDReusePolicy: client.IDReusePolicyAllowDuplicate,
+				}
+
+				first, err := c.CreateWorkflowInstance(ctx, options, wf, 1)
+				require.NoError(t, err)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, first, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 2, r)
+
+				second, err := c.CreateWorkflowInstance(ctx, options, wf, 2)
+				require.NoError(t, err)
+				require.NotEqual(t, first.ExecutionID, second.ExecutionID)
+
+				r, err = client.GetWorkflowResult[int](ctx, c, second, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 4, r)
+
+				// The result of the first execution is still available
+				r, err = client.GetWorkflowResult[int](ctx, c, first, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 2, r)
+
+				executions, err := c.ListWorkflowInstanceExecutions(ctx, options.InstanceID)
+				require.NoError(t, err)
+				require.Len(t, executions, 2)
+			},
+		},
 		{
 			name: "SubWorkflow_Simple",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
@@ -204,6 +604,34 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 				require.Equal(t, 2, r)
 			},
 		},
+		{
+			name: "SubWorkflow_StructuredError",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swf := func(ctx workflow.Context) error {
+					return workflow.NewError("InvalidInput", "invalid input").WithDetails(42)
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					_, err := workflow.CreateSubWorkflowInstance[int](ctx, workflow.DefaultSubWorkflowOptions, swf).Get(ctx)
+
+					var swfErr *workflow.Error
+					if !errors.As(err, &swfErr) || swfErr.Type != "InvalidInput" {
+						return 0, errors.New("expected workflow.Error")
+					}
+
+					var details int
+					if err := swfErr.Details(&details); err != nil {
+						return 0, err
+					}
+
+					return details, nil
+				}
+				register(t, ctx, w, []interface{}{wf, swf}, nil)
+
+				r, err := runWorkflowWithResult[int](t, ctx, c, wf)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+			},
+		},
 		{
 			name: "SubWorkflow_PropagateCancellation",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
@@ -373,30 +801,104 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 			},
 		},
 		{
-			name: "Timer_CancelWorkflowInstance",
+			name: "SignalWorkflow_Finished",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
-				a := func(ctx context.Context) error {
-					return nil
+				target := func(ctx workflow.Context) (int, error) {
+					return 42, nil
 				}
-				wf := func(ctx workflow.Context) error {
-					_, err := workflow.ScheduleTimer(ctx, time.Second*10).Get(ctx)
-					if err != nil && err != workflow.Canceled {
-						return err
-					}
-
-					return nil
+				wf := func(ctx workflow.Context, id string) error {
+					_, err := workflow.SignalWorkflow(ctx, id, "signal", "hello").Get(ctx)
+					return err
 				}
-				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+				register(t, ctx, w, []interface{}{wf, target}, nil)
 
-				instance := runWorkflow(t, ctx, c, wf)
+				targetInstance := runWorkflow(t, ctx, c, target)
+				_, err := client.GetWorkflowResult[int](ctx, c, targetInstance, time.Second*20)
+				require.NoError(t, err)
 
-				// Allow some time for the timer to get scheduled
-				time.Sleep(time.Millisecond * 200)
+				instance := runWorkflow(t, ctx, c, wf, targetInstance.InstanceID)
 
-				require.NoError(t, c.CancelWorkflowInstance(ctx, instance))
+				_, err = client.GetWorkflowResult[any](ctx, c, instance, time.Second*20)
+				require.ErrorContains(t, err, backend.ErrInstanceNotActive.Error())
 
-				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*5)
-				require.NoError(t, err)
+				historyContains(ctx, t, b, instance,
+					history.EventType_SignalWorkflowRequested,
+					history.EventType_SignalWorkflowFailed,
+				)
+			},
+		},
+		{
+			name: "CancelWorkflow",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				target := func(ctx workflow.Context) (string, error) {
+					if _, err := workflow.ScheduleTimer(ctx, time.Hour).Get(ctx); err != workflow.Canceled {
+						return "", err
+					}
+
+					return "canceled", nil
+				}
+				wf := func(ctx workflow.Context, id string) error {
+					_, err := workflow.CancelWorkflow(ctx, id).Get(ctx)
+					return err
+				}
+				register(t, ctx, w, []interface{}{wf, target}, nil)
+
+				targetInstance := runWorkflow(t, ctx, c, target)
+				instance := runWorkflow(t, ctx, c, wf, targetInstance.InstanceID)
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*20)
+				require.NoError(t, err)
+
+				r, err := client.GetWorkflowResult[string](ctx, c, targetInstance, time.Second*20)
+				require.NoError(t, err)
+				require.Equal(t, "canceled", r)
+
+				historyContains(ctx, t, b, instance,
+					history.EventType_CancelWorkflowRequested,
+					history.EventType_CancelWorkflowDelivered,
+				)
+			},
+		},
+		{
+			name: "CancelWorkflow_NotFound",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) error {
+					_, err := workflow.CancelWorkflow(ctx, "does-not-exist").Get(ctx)
+					return err
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*20)
+				require.ErrorContains(t, err, backend.ErrInstanceNotFound.Error())
+			},
+		},
+		{
+			name: "Timer_CancelWorkflowInstance",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) error {
+					return nil
+				}
+				wf := func(ctx workflow.Context) error {
+					_, err := workflow.ScheduleTimer(ctx, time.Second*10).Get(ctx)
+					if err != nil && err != workflow.Canceled {
+						return err
+					}
+
+					return nil
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				// Allow some time for the timer to get scheduled
+				time.Sleep(time.Millisecond * 200)
+
+				require.NoError(t, c.CancelWorkflowInstance(ctx, instance))
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*5)
+				require.NoError(t, err)
 			},
 		},
 		{
@@ -524,99 +1026,1211 @@ func EndToEndBackendTest(t *testing.T, setup func() TestBackend, teardown func(b
 			},
 		},
 		{
-			name:         "NonDeterminism",
-			withoutCache: true,
+			name: "Terminate_Workflow",
 			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
-				i := 0
-				wf := func(ctx workflow.Context) (int, error) {
-					var r int
+				// Workflow will be executed multiple times, but the test will wait only once. Create buffered channel
+				ch := make(chan struct{}, 10)
 
-					i++
-					if i%2 == 0 {
-						r, _ = workflow.SideEffect(ctx, func(ctx workflow.Context) int {
-							return 1
-						}).Get(ctx)
-					} else {
-						workflow.Sleep(ctx, time.Millisecond*1)
-					}
+				wf := func(ctx workflow.Context) error {
+					f := workflow.ScheduleTimer(ctx, time.Second*10)
 
-					// Do something to force the task to end
-					workflow.Sleep(ctx, time.Millisecond*1)
+					ch <- struct{}{}
 
-					return r, nil
+					_, err := f.Get(ctx)
+					return err
 				}
 				register(t, ctx, w, []interface{}{wf}, nil)
 
 				instance := runWorkflow(t, ctx, c, wf)
 
-				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*5)
+				// Wait for the workflow to start running
+				<-ch
+
+				// Allow some time for the timer to get scheduled
+				time.Sleep(time.Millisecond * 200)
+
+				require.NoError(t, c.TerminateWorkflowInstance(ctx, instance, "no longer needed"))
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*5)
+				require.ErrorIs(t, err, client.ErrWorkflowTerminated)
+
+				futureEvents, err := b.GetFutureEvents(ctx)
 				require.NoError(t, err)
-				require.Equal(t, 0, r)
+				require.Len(t, futureEvents, 0, "no future events should be scheduled")
 			},
 		},
-	}
+		{
+			name: "Terminate_SubWorkflows",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swInstanceID := uuid.NewString()
 
-	run := func(suffix string, workerOptions *worker.Options) {
-		for _, tt := range tests {
-			if tt.withoutCache && workerOptions.WorkflowExecutorCache != nil {
-				// Skip test
-				continue
-			}
+				swf := func(ctx workflow.Context) error {
+					return workflow.Sleep(ctx, time.Second*10)
+				}
 
-			t.Run(tt.name+suffix, func(t *testing.T) {
-				b := setup()
-				ctx := context.Background()
-				ctx, cancel := context.WithCancel(ctx)
+				// Workflow will be executed multiple times, but the test will wait only once. Create buffered channel
+				ch := make(chan struct{}, 10)
 
-				c := client.New(b)
-				w := worker.New(b, workerOptions)
+				wf := func(ctx workflow.Context) error {
+					f := workflow.CreateSubWorkflowInstance[any](ctx, workflow.SubWorkflowOptions{
+						InstanceID: swInstanceID,
+					}, swf)
 
-				t.Cleanup(func() {
-					cancel()
+					ch <- struct{}{}
 
-					// Wait for in-progress executions to finish
-					if err := w.WaitForCompletion(); err != nil {
-						log.Println("Worker did not stop in time")
-						t.FailNow()
+					_, err := f.Get(ctx)
+					return err
+				}
+				register(t, ctx, w, []interface{}{wf, swf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				// Wait for the workflow to start running
+				<-ch
+
+				// Allow some time for the sub-workflow to get started
+				time.Sleep(time.Millisecond * 500)
+
+				require.NoError(t, c.TerminateWorkflowInstance(ctx, instance, "no longer needed", client.WithSubWorkflows()))
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*5)
+				require.ErrorIs(t, err, client.ErrWorkflowTerminated)
+
+				db := b.(diag.Backend)
+				ref, err := db.GetWorkflowInstance(ctx, swInstanceID)
+				require.NoError(t, err)
+				require.Equal(t, core.WorkflowInstanceStateFinished, ref.State)
+
+				historyContains(ctx, t, b, ref.Instance, history.EventType_WorkflowExecutionTerminated)
+			},
+		},
+		{
+			name: "ParentClosePolicy_Terminate",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swInstanceID := uuid.NewString()
+				sswInstanceID := uuid.NewString()
+
+				sswf := func(ctx workflow.Context) error {
+					return workflow.Sleep(ctx, time.Second*10)
+				}
+				swf := func(ctx workflow.Context) error {
+					_, err := workflow.CreateSubWorkflowInstance[any](ctx, workflow.SubWorkflowOptions{
+						InstanceID: sswInstanceID,
+					}, sswf).Get(ctx)
+					return err
+				}
+				wf := func(ctx workflow.Context) error {
+					workflow.CreateSubWorkflowInstance[any](ctx, workflow.SubWorkflowOptions{
+						InstanceID:        swInstanceID,
+						ParentClosePolicy: workflow.ParentClosePolicyTerminate,
+					}, swf)
+
+					// Give the sub-workflow time to start its own sub-workflow, then fail without waiting for it
+					if err := workflow.Sleep(ctx, time.Millisecond*500); err != nil {
+						return err
 					}
 
-					if teardown != nil {
-						teardown(b)
+					return errors.New("failed early")
+				}
+				register(t, ctx, w, []interface{}{wf, swf, sswf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*5)
+				require.EqualError(t, err, "failed early")
+
+				db := b.(diag.Backend)
+				for _, id := range []string{swInstanceID, sswInstanceID} {
+					ref, err := db.GetWorkflowInstance(ctx, id)
+					require.NoError(t, err)
+					require.Equal(t, core.WorkflowInstanceStateFinished, ref.State)
+
+					historyContains(ctx, t, b, ref.Instance, history.EventType_WorkflowExecutionTerminated)
+				}
+			},
+		},
+		{
+			name: "ParentClosePolicy_RequestCancel",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swInstanceID := uuid.NewString()
+
+				swf := func(ctx workflow.Context) error {
+					return workflow.Sleep(ctx, time.Second*10)
+				}
+				wf := func(ctx workflow.Context) error {
+					workflow.CreateSubWorkflowInstance[any](ctx, workflow.SubWorkflowOptions{
+						InstanceID:        swInstanceID,
+						ParentClosePolicy: workflow.ParentClosePolicyRequestCancel,
+					}, swf)
+
+					return nil
+				}
+				register(t, ctx, w, []interface{}{wf, swf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*5)
+				require.NoError(t, err)
+
+				db := b.(diag.Backend)
+				ref, err := db.GetWorkflowInstance(ctx, swInstanceID)
+				require.NoError(t, err)
+
+				_, err = client.GetWorkflowResult[any](ctx, c, ref.Instance, time.Second*5)
+				require.EqualError(t, err, workflow.Canceled.Error())
+			},
+		},
+		{
+			name: "ParentClosePolicy_Abandon",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swInstanceID := uuid.NewString()
+
+				swf := func(ctx workflow.Context) (int, error) {
+					if err := workflow.Sleep(ctx, time.Millisecond*500); err != nil {
+						return 0, err
 					}
-				})
 
-				tt.f(t, ctx, c, w, b)
-			})
-		}
-	}
+					return 42, nil
+				}
+				wf := func(ctx workflow.Context) error {
+					workflow.CreateSubWorkflowInstance[int](ctx, workflow.SubWorkflowOptions{
+						InstanceID:        swInstanceID,
+						ParentClosePolicy: workflow.ParentClosePolicyAbandon,
+					}, swf)
 
-	options := worker.DefaultWorkerOptions
+					return nil
+				}
+				register(t, ctx, w, []interface{}{wf, swf}, nil)
 
-	// Run with cache
-	run("", &options)
+				instance := runWorkflow(t, ctx, c, wf)
 
-	// Disable cache for this execution
-	options.WorkflowExecutorCache = &noopWorkflowExecutorCache{}
-	run("_without_cache", &options)
-}
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*5)
+				require.NoError(t, err)
 
-type noopWorkflowExecutorCache struct {
-}
+				db := b.(diag.Backend)
+				ref, err := db.GetWorkflowInstance(ctx, swInstanceID)
+				require.NoError(t, err)
 
-var _ internalwf.ExecutorCache = (*noopWorkflowExecutorCache)(nil)
+				r, err := client.GetWorkflowResult[int](ctx, c, ref.Instance, time.Second*5)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+			},
+		},
+		{
+			name: "ContinueAsNew",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context, iteration int) (int, error) {
+					return iteration + 1, nil
+				}
 
-// Get implements workflow.ExecutorCache
-func (*noopWorkflowExecutorCache) Get(ctx context.Context, instance *core.WorkflowInstance) (internalwf.WorkflowExecutor, bool, error) {
-	return nil, false, nil
-}
+				wf := func(ctx workflow.Context, iteration int) (int, error) {
+					r, err := workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, a, iteration).Get(ctx)
+					if err != nil {
+						return 0, err
+					}
 
-// StartEviction implements workflow.ExecutorCache
-func (*noopWorkflowExecutorCache) StartEviction(ctx context.Context) {
-}
+					if r < 3 {
+						return 0, workflow.ContinueAsNew(ctx, r)
+					}
 
-// Store implements workflow.ExecutorCache
-func (*noopWorkflowExecutorCache) Store(ctx context.Context, instance *core.WorkflowInstance, workflow internalwf.WorkflowExecutor) error {
-	return nil
+					return r, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf, 0)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 3, r)
+
+				// First execution ends with the continued event
+				historyContains(ctx, t, b, instance, history.EventType_WorkflowExecutionStarted, history.EventType_WorkflowExecutionContinuedAsNew)
+
+				// Latest execution has a fresh history
+				db := b.(diag.Backend)
+				ref, err := db.GetWorkflowInstance(ctx, instance.InstanceID)
+				require.NoError(t, err)
+				require.Equal(t, instance.InstanceID, ref.Instance.InstanceID)
+				require.NotEqual(t, instance.ExecutionID, ref.Instance.ExecutionID)
+				require.Equal(t, core.WorkflowInstanceStateFinished, ref.State)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, ref.Instance, nil)
+				require.NoError(t, err)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, h[1].Type)
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, h[len(h)-1].Type)
+			},
+		},
+		{
+			name: "ContinueAsNew_Signal",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context, iteration int) (int, error) {
+					if iteration == 0 {
+						return 0, workflow.ContinueAsNew(ctx, iteration+1)
+					}
+
+					v, _ := workflow.NewSignalChannel[int](ctx, "signal").Receive(ctx)
+
+					return v, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf, 0)
+
+				// Wait for the first execution to be continued
+				require.NoError(t, c.WaitForWorkflowInstance(ctx, instance, time.Second*5))
+
+				// Signals are delivered to the new execution
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "signal", 42))
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+			},
+		},
+		{
+			name: "ContinueAsNew_SubWorkflow",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				swf := func(ctx workflow.Context, iteration int) (int, error) {
+					if iteration < 2 {
+						return 0, workflow.ContinueAsNew(ctx, iteration+1)
+					}
+
+					return iteration * 10, nil
+				}
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.CreateSubWorkflowInstance[int](ctx, workflow.DefaultSubWorkflowOptions, swf, 0).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf, swf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 20, r)
+			},
+		},
+		{
+			name: "Query",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				wf := func(ctx workflow.Context) (string, error) {
+					step := "waiting"
+					if err := workflow.SetQueryHandler(ctx, "step", func() (string, error) {
+						return step, nil
+					}); err != nil {
+						return "", err
+					}
+
+					workflow.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+					step = "signaled"
+
+					return step, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+				require.NoError(t, c.RegisterWorkflow(wf))
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				// Wait for the workflow to start
+				require.Eventually(t, func() bool {
+					h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+					require.NoError(t, err)
+					return len(h) > 0
+				}, time.Second*10, time.Millisecond*100)
+
+				h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+				require.NoError(t, err)
+
+				step, err := client.QueryWorkflow[string](ctx, c, instance, "step")
+				require.NoError(t, err)
+				require.Equal(t, "waiting", step)
+
+				queries, err := c.ListWorkflowQueries(ctx, instance)
+				require.NoError(t, err)
+				require.Equal(t, []string{"step"}, queries)
+
+				_, err = client.QueryWorkflow[string](ctx, c, instance, "unknown")
+				require.ErrorIs(t, err, client.ErrUnknownQuery)
+
+				// Queries don't add any events
+				h2, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+				require.NoError(t, err)
+				require.Len(t, h2, len(h))
+
+				require.NoError(t, c.SignalWorkflow(ctx, instance.InstanceID, "signal", ""))
+
+				_, err = client.GetWorkflowResult[string](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+
+				step, err = client.QueryWorkflow[string](ctx, c, instance, "step")
+				require.NoError(t, err)
+				require.Equal(t, "signaled", step)
+			},
+		},
+		{
+			name: "Activity_StartToCloseTimeout",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var attempts int32
+				a := func(ctx context.Context) error {
+					atomic.AddInt32(&attempts, 1)
+
+					<-ctx.Done()
+					return ctx.Err()
+				}
+
+				wf := func(ctx workflow.Context) (workflow.TimeoutType, error) {
+					_, err := workflow.ExecuteActivity[any](ctx, workflow.ActivityOptions{
+						StartToCloseTimeout: time.Millisecond * 200,
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts:        2,
+							FirstRetryInterval: time.Millisecond,
+						},
+					}, a).Get(ctx)
+
+					var timeoutErr *workflow.ActivityTimeoutError
+					if !errors.As(err, &timeoutErr) {
+						return "", err
+					}
+
+					return timeoutErr.TimeoutType, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				output, err := runWorkflowWithResult[workflow.TimeoutType](t, ctx, c, wf)
+				require.NoError(t, err)
+				require.Equal(t, workflow.TimeoutTypeStartToClose, output)
+				require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+			},
+		},
+		{
+			name: "Activity_ScheduleToCloseTimeout",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+
+				wf := func(ctx workflow.Context) (workflow.TimeoutType, error) {
+					_, err := workflow.ExecuteActivity[any](ctx, workflow.ActivityOptions{
+						ScheduleToCloseTimeout: time.Millisecond * 500,
+						StartToCloseTimeout:    time.Millisecond * 200,
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts:        10,
+							FirstRetryInterval: time.Millisecond,
+						},
+					}, a).Get(ctx)
+
+					var timeoutErr *workflow.ActivityTimeoutError
+					if !errors.As(err, &timeoutErr) {
+						return "", err
+					}
+
+					return timeoutErr.TimeoutType, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				output, err := runWorkflowWithResult[workflow.TimeoutType](t, ctx, c, wf)
+				require.NoError(t, err)
+				require.Equal(t, workflow.TimeoutTypeScheduleToClose, output)
+			},
+		},
+		{
+			name: "Activity_HeartbeatTimeout",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var attempts int32
+
+				// The activity blocks without reporting its liveness
+				a := func(ctx context.Context) error {
+					atomic.AddInt32(&attempts, 1)
+
+					<-ctx.Done()
+					return ctx.Err()
+				}
+
+				wf := func(ctx workflow.Context) (workflow.TimeoutType, error) {
+					_, err := workflow.ExecuteActivity[any](ctx, workflow.ActivityOptions{
+						HeartbeatTimeout: time.Millisecond * 200,
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts: 1,
+						},
+					}, a).Get(ctx)
+
+					var timeoutErr *workflow.ActivityTimeoutError
+					if !errors.As(err, &timeoutErr) {
+						return "", err
+					}
+
+					return timeoutErr.TimeoutType, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				output, err := runWorkflowWithResult[workflow.TimeoutType](t, ctx, c, wf)
+				require.NoError(t, err)
+				require.Equal(t, workflow.TimeoutTypeHeartbeat, output)
+				require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+			},
+		},
+		{
+			name: "Activity_HeartbeatDetails",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) (int, error) {
+					processed := 0
+					if err := activity.GetHeartbeatDetails(ctx, &processed); err != nil {
+						return 0, err
+					}
+
+					if processed == 0 {
+						// Make some progress in the first attempt, then fail
+						activity.RecordHeartbeat(ctx, 5)
+						return 0, errors.New("failed after 5 items")
+					}
+
+					return processed, nil
+				}
+
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts:        2,
+							FirstRetryInterval: time.Millisecond,
+						},
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				output, err := runWorkflowWithResult[int](t, ctx, c, wf)
+				require.NoError(t, err)
+				require.Equal(t, 5, output)
+			},
+		},
+		{
+			name: "Activity_CanceledOnHeartbeat",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				started := make(chan struct{})
+				var canceled int32
+
+				a := func(ctx context.Context) error {
+					close(started)
+
+					for {
+						activity.RecordHeartbeat(ctx, nil)
+
+						select {
+						case <-ctx.Done():
+							atomic.StoreInt32(&canceled, 1)
+							return ctx.Err()
+						case <-time.After(time.Millisecond * 100):
+						}
+					}
+				}
+
+				wf := func(ctx workflow.Context) error {
+					_, err := workflow.ExecuteActivity[any](ctx, workflow.ActivityOptions{
+						HeartbeatTimeout: time.Second,
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts: 1,
+						},
+					}, a).Get(ctx)
+
+					return err
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				<-started
+
+				require.NoError(t, c.CancelWorkflowInstance(ctx, instance))
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+				require.ErrorContains(t, err, "context canceled")
+				require.Equal(t, int32(1), atomic.LoadInt32(&canceled))
+			},
+		},
+		{
+			name: "Activity_CompletedAsynchronously",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				tokens := make(chan string, 1)
+
+				a := func(ctx context.Context) (int, error) {
+					tokens <- activity.GetTaskToken(ctx)
+
+					return 0, activity.ErrResultPending
+				}
+
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				token := <-tokens
+
+				require.NoError(t, c.HeartbeatActivity(ctx, token, "progress"))
+				require.NoError(t, c.CompleteActivity(ctx, token, 42, nil))
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+
+				// The activity has been completed, later results are rejected
+				require.ErrorIs(t, c.CompleteActivity(ctx, token, 23, nil), backend.ErrActivityTaskNotFound)
+			},
+		},
+		{
+			name: "Activity_CompletedByOtherProcessBeforeReturning",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				tokens := make(chan string, 1)
+				completed := make(chan struct{})
+				defer close(completed)
+
+				a := func(ctx context.Context) (int, error) {
+					tokens <- activity.GetTaskToken(ctx)
+
+					// The result is reported by another process while the activity is still running
+					<-completed
+
+					return 0, activity.ErrResultPending
+				}
+
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				// Client of another process, the activity is locked by the worker's backend
+				oc := client.New(b.OtherProcess())
+
+				token := <-tokens
+
+				require.NoError(t, oc.HeartbeatActivity(ctx, token, "progress"))
+				require.NoError(t, oc.CompleteActivity(ctx, token, 42, nil))
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+			},
+		},
+		{
+			name: "Activity_FailedAsynchronously",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				tokens := make(chan string, 1)
+
+				a := func(ctx context.Context) error {
+					tokens <- activity.GetTaskToken(ctx)
+
+					return activity.ErrResultPending
+				}
+
+				wf := func(ctx workflow.Context) error {
+					_, err := workflow.ExecuteActivity[any](ctx, workflow.ActivityOptions{
+						RetryOptions: workflow.RetryOptions{
+							MaxAttempts: 1,
+						},
+					}, a).Get(ctx)
+
+					return err
+				}
+				register(t, ctx, w, []interface{}{wf}, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				require.NoError(t, c.CompleteActivity(ctx, <-tokens, nil, errors.New("webhook failed")))
+
+				_, err := client.GetWorkflowResult[any](ctx, c, instance, time.Second*10)
+				require.ErrorContains(t, err, "webhook failed")
+			},
+		},
+		{
+			name: "LocalActivity",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var executions int32
+
+				a := func(ctx context.Context, msg string) (string, error) {
+					atomic.AddInt32(&executions, 1)
+
+					return msg + " world", nil
+				}
+
+				wf := func(ctx workflow.Context) (string, error) {
+					r, err := workflow.ExecuteLocalActivity[string](ctx, workflow.DefaultLocalActivityOptions, a, "hello").Get(ctx)
+					if err != nil {
+						return "", err
+					}
+
+					// Force another workflow task, which replays the local activity result without the cache
+					workflow.Sleep(ctx, time.Millisecond)
+
+					return r, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+				output, err := client.GetWorkflowResult[string](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, "hello world", output)
+				require.Equal(t, int32(1), atomic.LoadInt32(&executions))
+
+				historyContains(ctx, t, b, instance, history.EventType_LocalActivityResult, history.EventType_TimerFired)
+			},
+		},
+		{
+			name: "LocalActivity_DeferredPastWorkflowTaskTimeout",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var attempts, running int32
+
+				a := func(ctx context.Context) (int, error) {
+					atomic.AddInt32(&running, 1)
+					defer atomic.AddInt32(&running, -1)
+
+					if atomic.AddInt32(&attempts, 1) > 1 {
+						// The attempt which didn't complete in time has been stopped
+						return int(atomic.LoadInt32(&running)), nil
+					}
+
+					// Block the local attempt until the workflow task runs out of time
+					<-ctx.Done()
+					return 0, ctx.Err()
+				}
+
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteLocalActivity[int](ctx, workflow.DefaultLocalActivityOptions, a).Get(ctx)
+				}
+
+				options := worker.DefaultWorkerOptions
+				options.Queues = []workflow.Queue{"local"}
+				options.WorkflowTaskTimeout = 100 * time.Millisecond
+
+				wctx, cancel := context.WithCancel(ctx)
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					cancel()
+					require.NoError(t, w2.WaitForCompletion())
+				})
+				register(t, wctx, w2, []interface{}{wf}, nil)
+
+				instance, err := c.CreateWorkflowInstance(ctx, client.WorkflowInstanceOptions{
+					InstanceID: uuid.NewString(),
+					Queue:      "local",
+				}, wf)
+				require.NoError(t, err)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 1, r)
+				require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+				// The local activity is executed again in a later workflow task, after a timer
+				historyContains(ctx, t, b, instance,
+					history.EventType_LocalActivityResult, history.EventType_TimerFired, history.EventType_LocalActivityResult)
+			},
+		},
+		{
+			name: "ActivityQueue",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				a := func(ctx context.Context) (string, error) {
+					return "heavy", nil
+				}
+
+				wf := func(ctx workflow.Context) (string, error) {
+					return workflow.ExecuteActivity[string](ctx, workflow.ActivityOptions{
+						Queue: "heavy",
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				// Only the second worker processes tasks from the heavy queue and knows the activity
+				options := worker.DefaultWorkerOptions
+				options.Queues = []workflow.Queue{"heavy"}
+
+				wctx, cancel := context.WithCancel(ctx)
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					cancel()
+					require.NoError(t, w2.WaitForCompletion())
+				})
+				register(t, wctx, w2, nil, []interface{}{a})
+
+				output, err := runWorkflowWithResult[string](t, ctx, c, wf)
+				require.NoError(t, err)
+				require.Equal(t, "heavy", output)
+			},
+		},
+		{
+			name: "ActivityLimits_MaxParallel",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				testActivityMaxParallel(t, ctx, c, w, b, false)
+			},
+		},
+		{
+			name: "ActivityLimits_GlobalMaxParallel",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				if _, ok := b.(backend.ActivityLimiter); !ok {
+					t.Skip("backend does not support activity limits")
+				}
+
+				testActivityMaxParallel(t, ctx, c, w, b, true)
+			},
+		},
+		{
+			name: "ActivityLimits_DoNotDelayOtherActivities",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				unlimitedDone := make(chan struct{})
+
+				limited := func(ctx context.Context) (int, error) {
+					select {
+					case <-unlimitedDone:
+						return 1, nil
+					case <-time.After(5 * time.Second):
+						return 0, errors.New("unlimited activity was delayed by limited activity")
+					}
+				}
+
+				unlimited := func(ctx context.Context) (int, error) {
+					close(unlimitedDone)
+					return 1, nil
+				}
+
+				wf := func(ctx workflow.Context) (int, error) {
+					options := workflow.ActivityOptions{
+						Queue: "limited",
+					}
+
+					// More tasks than can wait for the limit of the activity
+					fs := make([]workflow.Future[int], 0, 4)
+					for i := 0; i < 4; i++ {
+						fs = append(fs, workflow.ExecuteActivity[int](ctx, options, limited))
+					}
+
+					// Give the worker time to retrieve the limited tasks
+					if err := workflow.Sleep(ctx, 200*time.Millisecond); err != nil {
+						return 0, err
+					}
+
+					sum, err := workflow.ExecuteActivity[int](ctx, options, unlimited).Get(ctx)
+					if err != nil {
+						return 0, err
+					}
+
+					for _, f := range fs {
+						r, err := f.Get(ctx)
+						if err != nil {
+							return 0, err
+						}
+
+						sum += r
+					}
+
+					return sum, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				options := worker.DefaultWorkerOptions
+				options.Queues = []workflow.Queue{"limited"}
+				options.ActivityLimits = map[string]worker.ActivityLimit{
+					fn.Name(limited): {MaxParallel: 1},
+				}
+
+				wctx, cancel := context.WithCancel(ctx)
+				w2 := worker.New(b, &options)
+				t.Cleanup(func() {
+					cancel()
+					require.NoError(t, w2.WaitForCompletion())
+				})
+				register(t, wctx, w2, nil, []interface{}{limited, unlimited})
+
+				output, err := runWorkflowWithResult[int](t, ctx, c, wf)
+				require.NoError(t, err)
+				require.Equal(t, 5, output)
+			},
+		},
+		{
+			name: "Shutdown_WaitsForTasks",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				started := make(chan struct{}, 1)
+
+				a := func(ctx context.Context) (int, error) {
+					started <- struct{}{}
+					time.Sleep(100 * time.Millisecond)
+
+					return 42, nil
+				}
+
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						Queue: "shutdown",
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				options := worker.DefaultWorkerOptions
+				options.Queues = []workflow.Queue{"shutdown"}
+
+				w2 := worker.New(b, &options)
+				register(t, ctx, w2, nil, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				select {
+				case <-started:
+				case <-time.After(5 * time.Second):
+					require.FailNow(t, "activity not started")
+				}
+
+				sctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				defer cancel()
+
+				require.NoError(t, w2.Shutdown(sctx))
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+			},
+		},
+		{
+			name: "Shutdown_AbandonsTasks",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var attempts int32
+				started := make(chan struct{}, 1)
+				canceled := make(chan struct{}, 1)
+
+				a := func(ctx context.Context) (int, error) {
+					if atomic.AddInt32(&attempts, 1) > 1 {
+						return 42, nil
+					}
+
+					// Block the first attempt until the worker gives up on it
+					started <- struct{}{}
+					<-ctx.Done()
+					canceled <- struct{}{}
+
+					return 0, ctx.Err()
+				}
+
+				wf := func(ctx workflow.Context) (int, error) {
+					return workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+						Queue: "shutdown",
+					}, a).Get(ctx)
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				options := worker.DefaultWorkerOptions
+				options.Queues = []workflow.Queue{"shutdown"}
+
+				w2 := worker.New(b, &options)
+				register(t, ctx, w2, nil, []interface{}{a})
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				select {
+				case <-started:
+				case <-time.After(5 * time.Second):
+					require.FailNow(t, "activity not started")
+				}
+
+				sctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+				defer cancel()
+
+				require.ErrorIs(t, w2.Shutdown(sctx), context.DeadlineExceeded)
+
+				select {
+				case <-canceled:
+				case <-time.After(5 * time.Second):
+					require.FailNow(t, "activity context not canceled")
+				}
+
+				// The abandoned task is picked up by another worker right away, without waiting for its lock to expire
+				wctx, wcancel := context.WithCancel(ctx)
+				w3 := worker.New(b, &options)
+				t.Cleanup(func() {
+					wcancel()
+					require.NoError(t, w3.WaitForCompletion())
+				})
+				register(t, wctx, w3, nil, []interface{}{a})
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*10)
+				require.NoError(t, err)
+				require.Equal(t, 42, r)
+				require.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+			},
+		},
+		{
+			name:         "NonDeterminism",
+			withoutCache: true,
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				i := 0
+				wf := func(ctx workflow.Context) (int, error) {
+					var r int
+
+					i++
+					if i%2 == 0 {
+						r, _ = workflow.SideEffect(ctx, func(ctx workflow.Context) int {
+							return 1
+						}).Get(ctx)
+					} else {
+						workflow.Sleep(ctx, time.Millisecond*1)
+					}
+
+					// Do something to force the task to end
+					workflow.Sleep(ctx, time.Millisecond*1)
+
+					return r, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				instance := runWorkflow(t, ctx, c, wf)
+
+				r, err := client.GetWorkflowResult[int](ctx, c, instance, time.Second*5)
+				require.NoError(t, err)
+				require.Equal(t, 0, r)
+			},
+		},
+		{
+			name: "Schedule_StartsInstances",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var runs int32
+
+				wf := func(ctx workflow.Context, msg string) (string, error) {
+					atomic.AddInt32(&runs, 1)
+					return msg, nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				id := uuid.NewString()
+				err := c.CreateSchedule(ctx, id, client.ScheduleSpec{
+					Interval:      time.Second,
+					OverlapPolicy: client.OverlapPolicyAllowAll,
+				}, wf, "hello")
+				require.NoError(t, err)
+
+				err = c.CreateSchedule(ctx, id, client.ScheduleSpec{Interval: time.Second}, wf, "hello")
+				require.ErrorIs(t, err, client.ErrScheduleAlreadyExists)
+
+				require.Eventually(t, func() bool {
+					return atomic.LoadInt32(&runs) >= 2
+				}, time.Second*10, time.Millisecond*100)
+
+				s, err := c.GetSchedule(ctx, id)
+				require.NoError(t, err)
+				require.NotNil(t, s.LastInstance)
+				require.True(t, s.NextRunAt.After(time.Now().Add(-time.Second)))
+
+				require.NoError(t, c.DeleteSchedule(ctx, id))
+
+				_, err = c.GetSchedule(ctx, id)
+				require.ErrorIs(t, err, client.ErrScheduleNotFound)
+			},
+		},
+		{
+			name: "Schedule_SkipsWhileRunning",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var runs int32
+
+				wf := func(ctx workflow.Context) error {
+					atomic.AddInt32(&runs, 1)
+
+					// Block until signaled
+					workflow.NewSignalChannel[string](ctx, "done").Receive(ctx)
+
+					return nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				id := uuid.NewString()
+				err := c.CreateSchedule(ctx, id, client.ScheduleSpec{Interval: time.Second}, wf)
+				require.NoError(t, err)
+
+				require.Eventually(t, func() bool {
+					return atomic.LoadInt32(&runs) == 1
+				}, time.Second*10, time.Millisecond*100)
+
+				time.Sleep(time.Second * 3)
+				require.Equal(t, int32(1), atomic.LoadInt32(&runs))
+
+				s, err := c.GetSchedule(ctx, id)
+				require.NoError(t, err)
+				require.Nil(t, s.BufferedRunAt)
+
+				require.NoError(t, c.DeleteSchedule(ctx, id))
+			},
+		},
+		{
+			name: "Schedule_PauseAndBackfill",
+			f: func(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend) {
+				var runs int32
+
+				wf := func(ctx workflow.Context) error {
+					atomic.AddInt32(&runs, 1)
+					return nil
+				}
+				register(t, ctx, w, []interface{}{wf}, nil)
+
+				id := uuid.NewString()
+				err := c.CreateSchedule(ctx, id, client.ScheduleSpec{Interval: time.Hour}, wf)
+				require.NoError(t, err)
+
+				require.NoError(t, c.PauseSchedule(ctx, id))
+
+				s, err := c.GetSchedule(ctx, id)
+				require.NoError(t, err)
+				require.True(t, s.Paused)
+
+				end := time.Now().Truncate(time.Hour)
+				instances, err := c.BackfillSchedule(ctx, id, end.Add(-3*time.Hour), end)
+				require.NoError(t, err)
+				require.Len(t, instances, 3)
+
+				for _, instance := range instances {
+					require.NoError(t, c.WaitForWorkflowInstance(ctx, instance, time.Second*10))
+				}
+
+				require.Equal(t, int32(3), atomic.LoadInt32(&runs))
+
+				// Runs which have already been started are skipped
+				instances, err = c.BackfillSchedule(ctx, id, end.Add(-3*time.Hour), end)
+				require.NoError(t, err)
+				require.Len(t, instances, 0)
+
+				require.NoError(t, c.ResumeSchedule(ctx, id))
+
+				s, err = c.GetSchedule(ctx, id)
+				require.NoError(t, err)
+				require.False(t, s.Paused)
+				require.True(t, s.NextRunAt.After(time.Now()))
+			},
+		},
+	}
+
+	run := func(suffix string, workerOptions *worker.Options) {
+		for _, tt := range tests {
+			if tt.withoutCache && workerOptions.WorkflowExecutorCache != nil {
+				// Skip test
+				continue
+			}
+
+			t.Run(tt.name+suffix, func(t *testing.T) {
+				b := setup()
+				ctx := context.Background()
+				ctx, cancel := context.WithCancel(ctx)
+
+				c := client.New(b)
+				w := worker.New(b, workerOptions)
+
+				t.Cleanup(func() {
+					cancel()
+
+					// Wait for in-progress executions to finish
+					if err := w.WaitForCompletion(); err != nil {
+						log.Println("Worker did not stop in time")
+						t.FailNow()
+					}
+
+					if teardown != nil {
+						teardown(b)
+					}
+				})
+
+				tt.f(t, ctx, c, w, b)
+			})
+		}
+	}
+
+	options := worker.DefaultWorkerOptions
+
+	// Run with cache
+	run("", &options)
+
+	// Disable cache for this execution
+	options.WorkflowExecutorCache = &noopWorkflowExecutorCache{}
+	run("_without_cache", &options)
+}
+
+type noopWorkflowExecutorCache struct {
+}
+
+var _ internalwf.ExecutorCache = (*noopWorkflowExecutorCache)(nil)
+
+// Get implements workflow.ExecutorCache
+func (*noopWorkflowExecutorCache) Get(ctx context.Context, instance *core.WorkflowInstance) (internalwf.WorkflowExecutor, bool, error) {
+	return nil, false, nil
+}
+
+// Evict implements workflow.ExecutorCache
+func (*noopWorkflowExecutorCache) Evict(ctx context.Context, instance *core.WorkflowInstance) error {
+	return nil
+}
+
+// StartEviction implements workflow.ExecutorCache
+func (*noopWorkflowExecutorCache) StartEviction(ctx context.Context) {
+}
+
+// Store implements workflow.ExecutorCache
+func (*noopWorkflowExecutorCache) Store(ctx context.Context, instance *core.WorkflowInstance, workflow internalwf.WorkflowExecutor) error {
+	return nil
+}
+
+// testActivityMaxParallel executes activities concurrently on a worker which limits them to one at a time
+func testActivityMaxParallel(t *testing.T, ctx context.Context, c client.Client, w worker.Worker, b TestBackend, global bool) {
+	var running, maxRunning int32
+
+	a := func(ctx context.Context) (int, error) {
+		r := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if r <= m || atomic.CompareAndSwapInt32(&maxRunning, m, r) {
+				break
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		return 1, nil
+	}
+
+	wf := func(ctx workflow.Context) (int, error) {
+		fs := make([]workflow.Future[int], 0, 3)
+		for i := 0; i < 3; i++ {
+			fs = append(fs, workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
+				Queue: "limited",
+			}, a))
+		}
+
+		sum := 0
+		for _, f := range fs {
+			r, err := f.Get(ctx)
+			if err != nil {
+				return 0, err
+			}
+
+			sum += r
+		}
+
+		return sum, nil
+	}
+	register(t, ctx, w, []interface{}{wf}, nil)
+
+	options := worker.DefaultWorkerOptions
+	options.Queues = []workflow.Queue{"limited"}
+	options.ActivityLimits = map[string]worker.ActivityLimit{
+		fn.Name(a): {MaxParallel: 1, Global: global},
+	}
+
+	wctx, cancel := context.WithCancel(ctx)
+	w2 := worker.New(b, &options)
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, w2.WaitForCompletion())
+	})
+	register(t, wctx, w2, nil, []interface{}{a})
+
+	output, err := runWorkflowWithResult[int](t, ctx, c, wf)
+	require.NoError(t, err)
+	require.Equal(t, 3, output)
+	require.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
 }
 
 func register(t *testing.T, ctx context.Context, w worker.Worker, workflows []interface{}, activities []interface{}) {
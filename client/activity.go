@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/activity"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+)
+
+func (c *client) CompleteActivity(ctx context.Context, token string, result interface{}, err error) error {
+	t, terr := activity.DecodeTaskToken(token)
+	if terr != nil {
+		return terr
+	}
+
+	var event *history.Event
+
+	if err != nil {
+		event = history.NewPendingEvent(
+			c.clock.Now(),
+			history.EventType_ActivityFailed,
+			&history.ActivityFailedAttributes{
+				Error: workflowerrors.FromError(c.backend.Converter(), err),
+			},
+			history.ScheduleEventID(t.ScheduleEventID),
+		)
+	} else {
+		r, cerr := c.backend.Converter().To(result)
+		if cerr != nil {
+			return fmt.Errorf("converting activity result: %w", cerr)
+		}
+
+		event = history.NewPendingEvent(
+			c.clock.Now(),
+			history.EventType_ActivityCompleted,
+			&history.ActivityCompletedAttributes{
+				Result: r,
+			},
+			history.ScheduleEventID(t.ScheduleEventID),
+		)
+	}
+
+	if err := c.backend.CompleteActivityTask(ctx, t.Instance, t.ActivityID, event); err != nil {
+		return err
+	}
+
+	c.backend.Logger().Debug("Completed activity", "instance_id", t.Instance.InstanceID, "task_id", t.ActivityID)
+
+	return nil
+}
+
+func (c *client) HeartbeatActivity(ctx context.Context, token string, details interface{}) error {
+	t, err := activity.DecodeTaskToken(token)
+	if err != nil {
+		return err
+	}
+
+	var p payload.Payload
+	if details != nil {
+		p, err = c.backend.Converter().To(details)
+		if err != nil {
+			return fmt.Errorf("converting heartbeat details: %w", err)
+		}
+	}
+
+	return c.backend.ExtendActivityTask(ctx, t.ActivityID, p)
+}
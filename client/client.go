@@ -15,6 +15,9 @@ import (
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
 	"github.com/cschleiden/go-workflows/internal/tracing"
+	internalwf "github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/metrics"
 	"github.com/cschleiden/go-workflows/workflow"
 	"github.com/google/uuid"
@@ -25,32 +28,148 @@ import (
 var ErrWorkflowCanceled = errors.New("workflow canceled")
 var ErrWorkflowTerminated = errors.New("workflow terminated")
 
+// ErrUnknownQuery is returned when a workflow instance has not registered a handler for the requested query.
+var ErrUnknownQuery = workflowstate.ErrUnknownQuery
+
+type IDReusePolicy = core.IDReusePolicy
+
+const (
+	IDReusePolicyRejectDuplicate          = core.IDReusePolicyRejectDuplicate
+	IDReusePolicyAllowDuplicate           = core.IDReusePolicyAllowDuplicate
+	IDReusePolicyAllowDuplicateFailedOnly = core.IDReusePolicyAllowDuplicateFailedOnly
+	IDReusePolicyTerminateIfRunning       = core.IDReusePolicyTerminateIfRunning
+)
+
+type ExecutionTimeoutAction = core.ExecutionTimeoutAction
+
+const (
+	ExecutionTimeoutActionTerminate = core.ExecutionTimeoutActionTerminate
+	ExecutionTimeoutActionCancel    = core.ExecutionTimeoutActionCancel
+)
+
 type WorkflowInstanceOptions struct {
 	InstanceID string
 
+	// IDReusePolicy determines whether a new execution is started if an instance with the same InstanceID exists.
+	// By default, ErrInstanceAlreadyExists is returned. Past executions can be retrieved using
+	// ListWorkflowInstanceExecutions.
+	IDReusePolicy IDReusePolicy
+
+	// SearchAttributes are indexed attributes of the workflow instance which can be used to find it with
+	// ListWorkflowInstances. Workflows can update them using workflow.UpsertSearchAttributes.
+	SearchAttributes workflow.SearchAttributes
+
+	// StartDelay delays the start of the workflow instance by the given duration. StartAt starts the workflow instance
+	// at the given time instead, only one of them can be set. The instance exists immediately, it can be signaled or
+	// canceled before it starts. Signals are delivered once it starts, canceling it starts it right away with a
+	// canceled context.
+	StartDelay time.Duration
+	StartAt    time.Time
+
+	// ExecutionTimeout bounds the time the workflow instance can run, measured from its start and including any
+	// executions it continues as. When it's exceeded, the instance is terminated or canceled depending on
+	// ExecutionTimeoutAction, and fails with a workflow.ExecutionTimeoutError. By default, instances can run forever.
+	ExecutionTimeout       time.Duration
+	ExecutionTimeoutAction ExecutionTimeoutAction
+
+	// Queue is the queue workflow tasks of the instance are routed to, only workers subscribed to it process the
+	// instance. Defaults to workflow.QueueDefault.
+	Queue workflow.Queue
+
+	// Priority of workflow tasks of the instance, tasks with a higher priority are handed out to workers first. Tasks
+	// waiting for longer than the backend's priority starvation timeout are handed out first regardless of their
+	// priority. Sub-workflows inherit the priority of their parent. Must be between workflow.MinPriority and
+	// workflow.MaxPriority, defaults to 0.
+	Priority int
+
 	// FUTURE: Expose this to callers of the API. Use it only internally for now.
 	// Metadata *core.WorkflowInstanceMetadata
 }
 
+type terminateOptions struct {
+	subWorkflows bool
+}
+
+type TerminateOption func(o *terminateOptions)
+
+// WithSubWorkflows also terminates all running sub-workflows of the given workflow instance, recursively. The
+// instance and its sub-workflows are terminated in a single transaction.
+func WithSubWorkflows() TerminateOption {
+	return func(o *terminateOptions) {
+		o.subWorkflows = true
+	}
+}
+
 type Client interface {
 	CreateWorkflowInstance(ctx context.Context, options WorkflowInstanceOptions, wf workflow.Workflow, args ...interface{}) (*workflow.Instance, error)
 
 	CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance) error
 
+	TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, reason string, opts ...TerminateOption) error
+
 	WaitForWorkflowInstance(ctx context.Context, instance *workflow.Instance, timeout time.Duration) error
 
 	SignalWorkflow(ctx context.Context, instanceID string, name string, arg interface{}) error
+
+	// SignalWithStartWorkflow signals the workflow instance with the given options, creating it if it doesn't exist.
+	SignalWithStartWorkflow(ctx context.Context, options WorkflowInstanceOptions, signalName string, signalArg interface{}, wf workflow.Workflow, args ...interface{}) (*workflow.Instance, error)
+
+	// ListWorkflowInstances returns a page of workflow instances matching the given filter, newest first.
+	ListWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter, opts ...ListOption) (*WorkflowInstancePage, error)
+
+	// CountWorkflowInstances returns the number of workflow instances matching the given filter.
+	CountWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter) (int64, error)
+
+	// ListWorkflowInstanceExecutions returns all executions of the given workflow instance, the current execution first.
+	ListWorkflowInstanceExecutions(ctx context.Context, instanceID string) ([]*WorkflowInstanceInfo, error)
+
+	// RegisterWorkflow registers a workflow with the client. Queries replay the history of a workflow instance in the
+	// calling process, so workflows need to be registered with the client in order to be queried.
+	RegisterWorkflow(wf workflow.Workflow) error
+
+	// ListWorkflowQueries returns the names of the queries the given workflow instance has registered handlers for.
+	ListWorkflowQueries(ctx context.Context, instance *workflow.Instance) ([]string, error)
+
+	// CreateSchedule creates a schedule which starts instances of the given workflow according to spec.
+	CreateSchedule(ctx context.Context, id string, spec ScheduleSpec, wf workflow.Workflow, args ...interface{}) error
+
+	GetSchedule(ctx context.Context, id string) (*Schedule, error)
+
+	PauseSchedule(ctx context.Context, id string) error
+
+	ResumeSchedule(ctx context.Context, id string) error
+
+	DeleteSchedule(ctx context.Context, id string) error
+
+	// BackfillSchedule starts instances for all runs of the given schedule in [start, end).
+	BackfillSchedule(ctx context.Context, id string, start, end time.Time) ([]*workflow.Instance, error)
+
+	// CompleteActivity completes the activity identified by the given task token, see activity.GetTaskToken. If err
+	// is nil, the activity completes with the given result, otherwise it fails with err. Activities can be completed
+	// once they have returned activity.ErrResultPending.
+	//
+	// If the activity has already been completed or has timed out, backend.ErrActivityTaskNotFound is returned.
+	CompleteActivity(ctx context.Context, token string, result interface{}, err error) error
+
+	// HeartbeatActivity records a heartbeat for the activity identified by the given task token, see
+	// activity.GetTaskToken. If details are given, they replace the heartbeat details stored with the activity.
+	//
+	// If the activity has already been completed or has timed out, backend.ErrActivityTaskNotFound is returned. If
+	// the workflow instance which scheduled the activity has been canceled, backend.ErrActivityCanceled is returned.
+	HeartbeatActivity(ctx context.Context, token string, details interface{}) error
 }
 
 type client struct {
-	backend backend.Backend
-	clock   clock.Clock
+	backend  backend.Backend
+	clock    clock.Clock
+	registry *internalwf.Registry
 }
 
 func New(backend backend.Backend) Client {
 	return &client{
-		backend: backend,
-		clock:   clock.New(),
+		backend:  backend,
+		clock:    clock.New(),
+		registry: internalwf.NewRegistry(),
 	}
 }
 
@@ -60,14 +179,7 @@ func (c *client) CreateWorkflowInstance(ctx context.Context, options WorkflowIns
 		return nil, err
 	}
 
-	inputs, err := a.ArgsToInputs(c.backend.Converter(), args...)
-	if err != nil {
-		return nil, fmt.Errorf("converting arguments: %w", err)
-	}
-
 	wfi := core.NewWorkflowInstance(options.InstanceID, uuid.NewString())
-	metadata := &workflow.Metadata{}
-
 	workflowName := fn.Name(wf)
 
 	// Start new span and add to metadata
@@ -77,16 +189,10 @@ func (c *client) CreateWorkflowInstance(ctx context.Context, options WorkflowIns
 	))
 	defer span.End()
 
-	tracing.MarshalSpan(sctx, metadata)
-
-	startedEvent := history.NewPendingEvent(
-		c.clock.Now(),
-		history.EventType_WorkflowExecutionStarted,
-		&history.ExecutionStartedAttributes{
-			Metadata: metadata,
-			Name:     workflowName,
-			Inputs:   inputs,
-		})
+	startedEvent, err := c.newStartedEvent(sctx, options, wf, args...)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := c.backend.CreateWorkflowInstance(ctx, wfi, startedEvent); err != nil {
 		return nil, fmt.Errorf("creating workflow instance: %w", err)
@@ -99,11 +205,149 @@ func (c *client) CreateWorkflowInstance(ctx context.Context, options WorkflowIns
 	return wfi, nil
 }
 
+// SignalWithStartWorkflow signals the workflow instance with the given options, creating it if it doesn't exist,
+// yet. Creating the instance and signaling it happens atomically, a new instance receives the signal with its first
+// workflow task. It returns the instance which has been signaled.
+//
+// If the instance has finished, a new execution is started if the IDReusePolicy of the options allows it, otherwise
+// backend.ErrInstanceNotActive is returned.
+func (c *client) SignalWithStartWorkflow(ctx context.Context, options WorkflowInstanceOptions, signalName string, signalArg interface{}, wf workflow.Workflow, args ...interface{}) (*workflow.Instance, error) {
+	// Check arguments
+	if err := a.ParamsMatch(wf, args...); err != nil {
+		return nil, err
+	}
+
+	wfi := core.NewWorkflowInstance(options.InstanceID, uuid.NewString())
+	workflowName := fn.Name(wf)
+
+	sctx, span := c.backend.Tracer().Start(ctx, fmt.Sprintf("SignalWithStartWorkflow: %s", workflowName), trace.WithAttributes(
+		attribute.String(tracing.WorkflowInstanceID, wfi.InstanceID),
+		attribute.String(tracing.WorkflowName, workflowName),
+		attribute.String("signal.name", signalName),
+	))
+	defer span.End()
+
+	startedEvent, err := c.newStartedEvent(sctx, options, wf, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := c.backend.Converter().To(signalArg)
+	if err != nil {
+		return nil, fmt.Errorf("converting signal argument: %w", err)
+	}
+
+	signalEvent := history.NewPendingEvent(
+		c.clock.Now(),
+		history.EventType_SignalReceived,
+		&history.SignalReceivedAttributes{
+			Name: signalName,
+			Arg:  input,
+		},
+	)
+
+	instance, err := c.backend.SignalWithStartWorkflow(ctx, wfi, startedEvent, signalEvent)
+	if err != nil {
+		return nil, fmt.Errorf("signaling workflow instance: %w", err)
+	}
+
+	if instance.ExecutionID == wfi.ExecutionID {
+		c.backend.Logger().Debug("Created workflow instance", "instance_id", instance.InstanceID, "execution_id", instance.ExecutionID)
+
+		c.backend.Metrics().Counter(metrickeys.WorkflowInstanceCreated, metrics.Tags{}, 1)
+	}
+
+	c.backend.Logger().Debug("Signaled workflow instance", "instance_id", instance.InstanceID)
+
+	return instance, nil
+}
+
+// newStartedEvent returns the event starting a new execution of the given workflow. Arguments need to be checked
+// by the caller.
+func (c *client) newStartedEvent(ctx context.Context, options WorkflowInstanceOptions, wf workflow.Workflow, args ...interface{}) (*history.Event, error) {
+	inputs, err := a.ArgsToInputs(c.backend.Converter(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("converting arguments: %w", err)
+	}
+
+	for name := range options.SearchAttributes {
+		if name == "" {
+			return nil, errors.New("search attribute name is required")
+		}
+	}
+
+	if options.StartDelay < 0 {
+		return nil, errors.New("start delay must not be negative")
+	}
+
+	if options.StartDelay > 0 && !options.StartAt.IsZero() {
+		return nil, errors.New("only one of StartDelay and StartAt can be set")
+	}
+
+	if options.ExecutionTimeout < 0 {
+		return nil, errors.New("execution timeout must not be negative")
+	}
+
+	if err := core.ValidatePriority(options.Priority); err != nil {
+		return nil, err
+	}
+
+	metadata := &workflow.Metadata{}
+	tracing.MarshalSpan(ctx, metadata)
+
+	now := c.clock.Now()
+
+	// Delayed executions are started by making the started event visible at the start time
+	var opts []history.HistoryEventOption
+	if options.StartDelay > 0 {
+		opts = append(opts, history.VisibleAt(now.Add(options.StartDelay)))
+	} else if options.StartAt.After(now) {
+		opts = append(opts, history.VisibleAt(options.StartAt))
+	}
+
+	return history.NewPendingEvent(
+		now,
+		history.EventType_WorkflowExecutionStarted,
+		&history.ExecutionStartedAttributes{
+			Metadata:         metadata,
+			Name:             fn.Name(wf),
+			Inputs:           inputs,
+			SearchAttributes: options.SearchAttributes,
+			IDReusePolicy:    options.IDReusePolicy,
+
+			ExecutionTimeout:       options.ExecutionTimeout,
+			ExecutionTimeoutAction: options.ExecutionTimeoutAction,
+
+			Queue:    options.Queue.OrDefault(),
+			Priority: options.Priority,
+		},
+		opts...,
+	), nil
+}
+
 func (c *client) CancelWorkflowInstance(ctx context.Context, instance *workflow.Instance) error {
 	cancellationEvent := history.NewWorkflowCancellationEvent(time.Now())
 	return c.backend.CancelWorkflowInstance(ctx, instance, cancellationEvent)
 }
 
+// TerminateWorkflowInstance terminates the given workflow instance. No more workflow code is executed, the instance
+// is marked as finished, and any pending activities or timers are discarded.
+func (c *client) TerminateWorkflowInstance(ctx context.Context, instance *workflow.Instance, reason string, opts ...TerminateOption) error {
+	options := &terminateOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	terminationEvent := history.NewWorkflowTerminationEvent(c.clock.Now(), reason)
+	if err := c.backend.TerminateWorkflowInstance(ctx, instance, terminationEvent, options.subWorkflows); err != nil {
+		return err
+	}
+
+	c.backend.Logger().Debug("Terminated workflow instance", "instance_id", instance.InstanceID, "reason", reason)
+
+	return nil
+}
+
 func (c *client) SignalWorkflow(ctx context.Context, instanceID string, name string, arg interface{}) error {
 	input, err := c.backend.Converter().To(arg)
 	if err != nil {
@@ -162,45 +406,124 @@ func (c *client) WaitForWorkflowInstance(ctx context.Context, instance *workflow
 	return errors.New("workflow did not finish in specified timeout")
 }
 
-// GetWorkflowResult gets the workflow result for the given workflow result. It first waits for the workflow to finish or until
-// the given timeout has expired.
-func GetWorkflowResult[T any](ctx context.Context, c Client, instance *workflow.Instance, timeout time.Duration) (T, error) {
-	if err := c.WaitForWorkflowInstance(ctx, instance, timeout); err != nil {
-		return *new(T), fmt.Errorf("workflow did not finish in time: %w", err)
+func (c *client) RegisterWorkflow(wf workflow.Workflow) error {
+	return c.registry.RegisterWorkflow(wf)
+}
+
+func (c *client) ListWorkflowQueries(ctx context.Context, instance *workflow.Instance) ([]string, error) {
+	e, err := c.queryExecutor(ctx, instance)
+	if err != nil {
+		return nil, err
 	}
+	defer e.Close()
 
+	return e.QueryNames(ctx, instance)
+}
+
+// queryExecutor returns a new executor for running queries against the given workflow instance.
+func (c *client) queryExecutor(ctx context.Context, instance *workflow.Instance) (internalwf.WorkflowExecutor, error) {
+	// Ensure the instance exists
+	if _, err := c.backend.GetWorkflowInstanceState(ctx, instance); err != nil {
+		return nil, err
+	}
+
+	return internalwf.NewExecutor(
+		c.backend.Logger(), c.backend.Tracer(), c.registry, c.backend.Converter(), c.backend, instance, c.clock,
+		internalwf.NonDeterminismPolicyFail), nil
+}
+
+// QueryWorkflow runs the query with the given name against the given workflow instance and returns its result. The
+// history of the workflow instance is replayed in the calling process, the workflow has to be registered with the
+// client. Running a query does not change the workflow instance or its history.
+func QueryWorkflow[T any](ctx context.Context, c Client, instance *workflow.Instance, query string, args ...interface{}) (T, error) {
 	ic := c.(*client)
 	b := ic.backend
 
-	h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+	inputs, err := a.ArgsToInputs(b.Converter(), args...)
 	if err != nil {
-		return *new(T), fmt.Errorf("getting workflow history: %w", err)
+		return *new(T), fmt.Errorf("converting arguments: %w", err)
 	}
 
-	// Iterate over history backwards
-	for i := len(h) - 1; i >= 0; i-- {
-		event := h[i]
-		switch event.Type {
-		case history.EventType_WorkflowExecutionFinished:
-			a := event.Attributes.(*history.ExecutionCompletedAttributes)
-			if a.Error != "" {
-				return *new(T), errors.New(a.Error)
-			}
+	e, err := ic.queryExecutor(ctx, instance)
+	if err != nil {
+		return *new(T), err
+	}
+	defer e.Close()
 
-			var r T
-			if err := b.Converter().From(a.Result, &r); err != nil {
-				return *new(T), fmt.Errorf("converting result: %w", err)
-			}
+	result, err := e.Query(ctx, instance, query, inputs)
+	if err != nil {
+		return *new(T), err
+	}
+
+	var r T
+	if err := b.Converter().From(result, &r); err != nil {
+		return *new(T), fmt.Errorf("converting query result: %w", err)
+	}
 
-			return r, nil
+	return r, nil
+}
 
-		case history.EventType_WorkflowExecutionCanceled:
-			return *new(T), ErrWorkflowCanceled
+// GetWorkflowResult gets the workflow result for the given workflow result. It first waits for the workflow to finish or until
+// the given timeout has expired. If the workflow instance has been continued as new, the result of the latest execution is
+// returned. The timeout applies to each execution in the chain.
+func GetWorkflowResult[T any](ctx context.Context, c Client, instance *workflow.Instance, timeout time.Duration) (T, error) {
+	ic := c.(*client)
+	b := ic.backend
 
-		case history.EventType_WorkflowExecutionTerminated:
-			return *new(T), ErrWorkflowTerminated
+	for {
+		if err := c.WaitForWorkflowInstance(ctx, instance, timeout); err != nil {
+			return *new(T), fmt.Errorf("workflow did not finish in time: %w", err)
 		}
-	}
 
-	return *new(T), errors.New("workflow finished, but could not find result event")
+		h, err := b.GetWorkflowInstanceHistory(ctx, instance, nil)
+		if err != nil {
+			return *new(T), fmt.Errorf("getting workflow history: %w", err)
+		}
+
+		continued := false
+
+		// Iterate over history backwards
+	events:
+		for i := len(h) - 1; i >= 0; i-- {
+			event := h[i]
+			switch event.Type {
+			case history.EventType_WorkflowExecutionFinished:
+				a := event.Attributes.(*history.ExecutionCompletedAttributes)
+				if a.Error != nil {
+					return *new(T), workflowerrors.RecordedError(b.Converter(), a.Error)
+				}
+
+				var r T
+				if err := b.Converter().From(a.Result, &r); err != nil {
+					return *new(T), fmt.Errorf("converting result: %w", err)
+				}
+
+				return r, nil
+
+			case history.EventType_WorkflowExecutionCanceled:
+				return *new(T), ErrWorkflowCanceled
+
+			case history.EventType_WorkflowExecutionTerminated:
+				a := event.Attributes.(*history.ExecutionTerminatedAttributes)
+				if a.Error != nil {
+					// Terminated by the backend, e.g., because the execution timeout was exceeded
+					return *new(T), workflowerrors.RecordedError(b.Converter(), a.Error)
+				}
+
+				return *new(T), ErrWorkflowTerminated
+
+			case history.EventType_WorkflowExecutionContinuedAsNew:
+				// Follow the chain to the new execution
+				a := event.Attributes.(*history.ExecutionContinuedAsNewAttributes)
+				instance = core.NewSubWorkflowInstance(instance.InstanceID, a.ContinuedExecutionID, instance.ParentInstanceID, instance.ParentEventID)
+				continued = true
+
+				break events
+			}
+		}
+
+		if !continued {
+			return *new(T), errors.New("workflow finished, but could not find result event")
+		}
+	}
 }
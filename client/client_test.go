@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/logger"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
 	"github.com/cschleiden/go-workflows/workflow"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
@@ -39,6 +41,67 @@ func Test_Client_CreateWorkflowInstance_ParamMismatch(t *testing.T) {
 	b.AssertExpectations(t)
 }
 
+func Test_Client_StartedEvent_StartDelay(t *testing.T) {
+	wf := func(workflow.Context) error {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	mockClock := clock.NewMock()
+
+	b := &backend.MockBackend{}
+	b.On("Converter").Return(converter.DefaultConverter)
+
+	c := &client{
+		backend: b,
+		clock:   mockClock,
+	}
+
+	event, err := c.newStartedEvent(ctx, WorkflowInstanceOptions{StartDelay: time.Minute}, wf)
+	require.NoError(t, err)
+	require.NotNil(t, event.VisibleAt)
+	require.Equal(t, mockClock.Now().Add(time.Minute), *event.VisibleAt)
+
+	event, err = c.newStartedEvent(ctx, WorkflowInstanceOptions{StartAt: mockClock.Now().Add(time.Hour)}, wf)
+	require.NoError(t, err)
+	require.Equal(t, mockClock.Now().Add(time.Hour), *event.VisibleAt)
+
+	// Start times in the past start the instance right away
+	event, err = c.newStartedEvent(ctx, WorkflowInstanceOptions{StartAt: mockClock.Now().Add(-time.Hour)}, wf)
+	require.NoError(t, err)
+	require.Nil(t, event.VisibleAt)
+
+	_, err = c.newStartedEvent(ctx, WorkflowInstanceOptions{StartDelay: time.Minute, StartAt: mockClock.Now()}, wf)
+	require.EqualError(t, err, "only one of StartDelay and StartAt can be set")
+}
+
+func Test_Client_StartedEvent_Priority(t *testing.T) {
+	wf := func(workflow.Context) error {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	b := &backend.MockBackend{}
+	b.On("Converter").Return(converter.DefaultConverter)
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	event, err := c.newStartedEvent(ctx, WorkflowInstanceOptions{Priority: workflow.MaxPriority}, wf)
+	require.NoError(t, err)
+	require.Equal(t, workflow.MaxPriority, event.Attributes.(*history.ExecutionStartedAttributes).Priority)
+
+	_, err = c.newStartedEvent(ctx, WorkflowInstanceOptions{Priority: workflow.MaxPriority + 1}, wf)
+	require.EqualError(t, err, "priority 11 is out of range, must be between -10 and 10")
+
+	_, err = c.newStartedEvent(ctx, WorkflowInstanceOptions{Priority: workflow.MinPriority - 1}, wf)
+	require.EqualError(t, err, "priority -11 is out of range, must be between -10 and 10")
+}
+
 func Test_Client_GetWorkflowResultTimeout(t *testing.T) {
 	instance := core.NewWorkflowInstance(uuid.NewString(), "test")
 
@@ -77,7 +140,6 @@ func Test_Client_GetWorkflowResultSuccess(t *testing.T) {
 		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
 		history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{
 			Result: r,
-			Error:  "",
 		}),
 	}, nil)
 	b.On("Converter").Return(converter.DefaultConverter)
@@ -93,6 +155,42 @@ func Test_Client_GetWorkflowResultSuccess(t *testing.T) {
 	b.AssertExpectations(t)
 }
 
+func Test_Client_GetWorkflowResultError(t *testing.T) {
+	instance := core.NewWorkflowInstance(uuid.NewString(), "test")
+
+	ctx := context.Background()
+
+	werr := workflow.NewError("InvalidInput", "invalid input").WithDetails(42)
+
+	b := &backend.MockBackend{}
+	b.On("GetWorkflowInstanceState", mock.Anything, instance).Return(core.WorkflowInstanceStateFinished, nil)
+	b.On("GetWorkflowInstanceHistory", mock.Anything, instance, (*int64)(nil)).Return([]*history.Event{
+		history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{}),
+		history.NewHistoryEvent(2, time.Now(), history.EventType_WorkflowExecutionFinished, &history.ExecutionCompletedAttributes{
+			Error: workflowerrors.FromError(converter.DefaultConverter, werr),
+		}),
+	}, nil)
+	b.On("Converter").Return(converter.DefaultConverter)
+
+	c := &client{
+		backend: b,
+		clock:   clock.NewMock(),
+	}
+
+	result, err := GetWorkflowResult[int](ctx, c, instance, 0)
+	require.Zero(t, result)
+
+	var resultErr *workflow.Error
+	require.True(t, errors.As(err, &resultErr))
+	require.Equal(t, "InvalidInput", resultErr.Type)
+	require.Equal(t, "invalid input", resultErr.Message)
+
+	var details int
+	require.NoError(t, resultErr.Details(&details))
+	require.Equal(t, 42, details)
+	b.AssertExpectations(t)
+}
+
 func Test_Client_SignalWorkflow(t *testing.T) {
 	instanceID := uuid.NewString()
 
@@ -145,3 +243,35 @@ func Test_Client_SignalWorkflow_WithArgs(t *testing.T) {
 	require.Nil(t, err)
 	b.AssertExpectations(t)
 }
+
+func Test_Client_ListWorkflowInstances_ReturnsCursor(t *testing.T) {
+	ctx := context.Background()
+
+	filter := &WorkflowInstanceFilter{WorkflowName: "wf"}
+	instances := []*WorkflowInstanceInfo{
+		{Instance: core.NewWorkflowInstance("a", "")},
+		{Instance: core.NewWorkflowInstance("b", "")},
+		{Instance: core.NewWorkflowInstance("c", "")},
+	}
+
+	b := &backend.MockBackend{}
+	b.On("ListWorkflowInstances", mock.Anything, filter, "", 3).Return(instances, nil)
+	b.On("ListWorkflowInstances", mock.Anything, filter, "b", 3).Return(instances[2:], nil)
+
+	c := &client{
+		backend: b,
+		clock:   clock.New(),
+	}
+
+	page, err := c.ListWorkflowInstances(ctx, filter, WithPageSize(2))
+	require.NoError(t, err)
+	require.Equal(t, instances[:2], page.Instances)
+	require.Equal(t, "b", page.NextCursor)
+
+	page, err = c.ListWorkflowInstances(ctx, filter, WithPageSize(2), WithCursor(page.NextCursor))
+	require.NoError(t, err)
+	require.Equal(t, instances[2:], page.Instances)
+	require.Empty(t, page.NextCursor)
+
+	b.AssertExpectations(t)
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+type WorkflowInstanceFilter = backend.WorkflowInstanceFilter
+
+type WorkflowInstanceInfo = backend.WorkflowInstanceInfo
+
+type WorkflowInstanceState = core.WorkflowInstanceState
+
+const (
+	WorkflowInstanceStateActive   = core.WorkflowInstanceStateActive
+	WorkflowInstanceStateFinished = core.WorkflowInstanceStateFinished
+)
+
+const defaultPageSize = 100
+
+type WorkflowInstancePage struct {
+	Instances []*WorkflowInstanceInfo
+
+	// NextCursor can be passed to WithCursor to retrieve the next page. It is empty if there are no more instances.
+	NextCursor string
+}
+
+type listOptions struct {
+	cursor   string
+	pageSize int
+}
+
+type ListOption func(o *listOptions)
+
+// WithCursor continues listing after the page the given cursor was returned with.
+func WithCursor(cursor string) ListOption {
+	return func(o *listOptions) {
+		o.cursor = cursor
+	}
+}
+
+// WithPageSize sets the maximum number of instances returned per page. Defaults to 100.
+func WithPageSize(pageSize int) ListOption {
+	return func(o *listOptions) {
+		o.pageSize = pageSize
+	}
+}
+
+// ListWorkflowInstances returns a page of workflow instances matching the given filter, ordered by creation time,
+// newest first. A nil filter matches all instances.
+func (c *client) ListWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter, opts ...ListOption) (*WorkflowInstancePage, error) {
+	o := listOptions{
+		pageSize: defaultPageSize,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.pageSize <= 0 {
+		return nil, fmt.Errorf("invalid page size %v", o.pageSize)
+	}
+
+	// Request one more instance to determine whether there is a next page
+	instances, err := c.backend.ListWorkflowInstances(ctx, filter, o.cursor, o.pageSize+1)
+	if err != nil {
+		return nil, fmt.Errorf("listing workflow instances: %w", err)
+	}
+
+	page := &WorkflowInstancePage{
+		Instances: instances,
+	}
+
+	if len(instances) > o.pageSize {
+		page.Instances = instances[:o.pageSize]
+		page.NextCursor = page.Instances[o.pageSize-1].Instance.InstanceID
+	}
+
+	return page, nil
+}
+
+// CountWorkflowInstances returns the number of workflow instances matching the given filter. A nil filter matches
+// all instances.
+func (c *client) CountWorkflowInstances(ctx context.Context, filter *WorkflowInstanceFilter) (int64, error) {
+	count, err := c.backend.CountWorkflowInstances(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("counting workflow instances: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListWorkflowInstanceExecutions returns all executions of the given workflow instance, the current execution first,
+// followed by past executions which have been continued as new or replaced according to an IDReusePolicy.
+func (c *client) ListWorkflowInstanceExecutions(ctx context.Context, instanceID string) ([]*WorkflowInstanceInfo, error) {
+	executions, err := c.backend.ListWorkflowInstanceExecutions(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("listing workflow instance executions: %w", err)
+	}
+
+	return executions, nil
+}
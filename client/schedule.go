@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/backend"
+	a "github.com/cschleiden/go-workflows/internal/args"
+	"github.com/cschleiden/go-workflows/internal/fn"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/schedule"
+	"github.com/cschleiden/go-workflows/internal/tracing"
+	"github.com/cschleiden/go-workflows/metrics"
+	"github.com/cschleiden/go-workflows/workflow"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrScheduleNotFound = backend.ErrScheduleNotFound
+var ErrScheduleAlreadyExists = backend.ErrScheduleAlreadyExists
+
+type Schedule = schedule.Schedule
+
+type ScheduleSpec = schedule.Spec
+
+type OverlapPolicy = schedule.OverlapPolicy
+
+const (
+	// OverlapPolicySkip skips runs while the previous instance is still running
+	OverlapPolicySkip = schedule.OverlapPolicySkip
+
+	// OverlapPolicyBufferOne buffers one run while the previous instance is still running, and starts it once
+	// the previous instance has finished
+	OverlapPolicyBufferOne = schedule.OverlapPolicyBufferOne
+
+	// OverlapPolicyAllowAll starts every run, regardless of any running instances
+	OverlapPolicyAllowAll = schedule.OverlapPolicyAllowAll
+)
+
+// CreateSchedule creates a schedule which starts instances of the given workflow according to spec. Workers
+// start instances for due runs, runs missed while no worker was running are handled according to the overlap
+// policy of the schedule.
+func (c *client) CreateSchedule(ctx context.Context, id string, spec ScheduleSpec, wf workflow.Workflow, args ...interface{}) error {
+	if id == "" {
+		return errors.New("schedule id is required")
+	}
+
+	if err := spec.Validate(); err != nil {
+		return fmt.Errorf("invalid schedule spec: %w", err)
+	}
+
+	// Check arguments
+	if err := a.ParamsMatch(wf, args...); err != nil {
+		return err
+	}
+
+	inputs, err := a.ArgsToInputs(c.backend.Converter(), args...)
+	if err != nil {
+		return fmt.Errorf("converting arguments: %w", err)
+	}
+
+	s := schedule.NewSchedule(id, spec, fn.Name(wf), inputs, c.clock.Now())
+
+	if err := c.backend.CreateSchedule(ctx, s); err != nil {
+		return fmt.Errorf("creating schedule: %w", err)
+	}
+
+	c.backend.Logger().Debug("Created schedule", "schedule_id", id, "next_run_at", s.NextRunAt)
+
+	return nil
+}
+
+// GetSchedule returns the schedule with the given id.
+func (c *client) GetSchedule(ctx context.Context, id string) (*Schedule, error) {
+	return c.backend.GetSchedule(ctx, id)
+}
+
+// PauseSchedule pauses the given schedule. No instances are started while a schedule is paused.
+func (c *client) PauseSchedule(ctx context.Context, id string) error {
+	if err := c.backend.PauseSchedule(ctx, id); err != nil {
+		return err
+	}
+
+	c.backend.Logger().Debug("Paused schedule", "schedule_id", id)
+
+	return nil
+}
+
+// ResumeSchedule resumes the given schedule. Runs missed while the schedule was paused are not started, use
+// BackfillSchedule to start them.
+func (c *client) ResumeSchedule(ctx context.Context, id string) error {
+	s, err := c.backend.GetSchedule(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := c.backend.ResumeSchedule(ctx, id, s.Spec.Next(c.clock.Now())); err != nil {
+		return err
+	}
+
+	c.backend.Logger().Debug("Resumed schedule", "schedule_id", id)
+
+	return nil
+}
+
+// DeleteSchedule deletes the given schedule. Instances started by the schedule are not affected.
+func (c *client) DeleteSchedule(ctx context.Context, id string) error {
+	if err := c.backend.DeleteSchedule(ctx, id); err != nil {
+		return err
+	}
+
+	c.backend.Logger().Debug("Deleted schedule", "schedule_id", id)
+
+	return nil
+}
+
+// BackfillSchedule starts instances for all runs of the given schedule in [start, end), regardless of the overlap
+// policy of the schedule. Runs which have already been started are skipped. The started instances are returned.
+func (c *client) BackfillSchedule(ctx context.Context, id string, start, end time.Time) ([]*workflow.Instance, error) {
+	s, err := c.backend.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]*workflow.Instance, 0)
+
+	for _, at := range s.Spec.Runs(start, end) {
+		metadata := &workflow.Metadata{}
+
+		instanceID := schedule.RunInstanceID(s.ID, at)
+
+		sctx, span := c.backend.Tracer().Start(ctx, fmt.Sprintf("BackfillWorkflowInstance: %s", s.WorkflowName), trace.WithAttributes(
+			attribute.String(tracing.WorkflowInstanceID, instanceID),
+			attribute.String(tracing.WorkflowName, s.WorkflowName),
+		))
+
+		tracing.MarshalSpan(sctx, metadata)
+		span.End()
+
+		wfi, startedEvent := s.NewRun(at, c.clock.Now(), metadata)
+
+		if err := c.backend.CreateWorkflowInstance(ctx, wfi, startedEvent); err != nil {
+			if errors.Is(err, backend.ErrInstanceAlreadyExists) {
+				continue
+			}
+
+			return instances, fmt.Errorf("starting run at %v: %w", at, err)
+		}
+
+		c.backend.Metrics().Counter(metrickeys.WorkflowInstanceCreated, metrics.Tags{}, 1)
+
+		instances = append(instances, wfi)
+	}
+
+	c.backend.Logger().Debug("Backfilled schedule", "schedule_id", id, "instances", len(instances))
+
+	return instances, nil
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+	"github.com/cschleiden/go-workflows/workflow"
+	"github.com/google/uuid"
+)
+
+// ErrUpdateNotCompleted is returned by UpdateWorkflow when the workflow execution ends before it has handled the
+// update.
+var ErrUpdateNotCompleted = errors.New("workflow execution ended before completing the update")
+
+// UpdateWorkflow sends the update with the given name to the workflow instance and waits until the update handler
+// registered with workflow.SetUpdateHandler has returned. It returns the result of the handler, or the error with
+// which the validator rejected the update. Use the context to limit how long to wait for the result.
+func UpdateWorkflow[T any](ctx context.Context, c Client, instance *workflow.Instance, name string, arg interface{}) (T, error) {
+	ic := c.(*client)
+	b := ic.backend
+
+	state, err := b.GetWorkflowInstanceState(ctx, instance)
+	if err != nil {
+		return *new(T), err
+	}
+
+	if state == core.WorkflowInstanceStateFinished {
+		return *new(T), backend.ErrInstanceNotActive
+	}
+
+	input, err := b.Converter().To(arg)
+	if err != nil {
+		return *new(T), fmt.Errorf("converting update argument: %w", err)
+	}
+
+	updateID := uuid.NewString()
+
+	updateEvent := history.NewPendingEvent(
+		ic.clock.Now(),
+		history.EventType_UpdateRequested,
+		&history.UpdateRequestedAttributes{
+			UpdateID: updateID,
+			Name:     name,
+			Arg:      input,
+		},
+	)
+
+	if err := b.SignalWorkflow(ctx, instance.InstanceID, updateEvent); err != nil {
+		return *new(T), fmt.Errorf("requesting update: %w", err)
+	}
+
+	b.Logger().Debug("Requested workflow update", "instance_id", instance.InstanceID, "update_id", updateID, "name", name)
+
+	result, err := ic.waitForUpdate(ctx, instance, updateID)
+	if err != nil {
+		return *new(T), err
+	}
+
+	var r T
+	if err := b.Converter().From(result, &r); err != nil {
+		return *new(T), fmt.Errorf("converting update result: %w", err)
+	}
+
+	return r, nil
+}
+
+// waitForUpdate polls the history of the given workflow instance until the outcome of the update with the given id
+// has been recorded. If the instance continues as new before receiving the update, the new execution is followed.
+func (c *client) waitForUpdate(ctx context.Context, instance *workflow.Instance, updateID string) (payload.Payload, error) {
+	b := backoff.ExponentialBackOff{
+		InitialInterval:     time.Millisecond * 1,
+		MaxInterval:         time.Second * 1,
+		Multiplier:          1.5,
+		RandomizationFactor: 0.5,
+		Stop:                backoff.Stop,
+		Clock:               c.clock,
+	}
+	b.Reset()
+
+	ticker := backoff.NewTicker(backoff.WithContext(&b, ctx))
+	defer ticker.Stop()
+
+	var lastSequenceID int64
+	requested := false
+
+	for range ticker.C {
+		h, err := c.backend.GetWorkflowInstanceHistory(ctx, instance, &lastSequenceID)
+		if err != nil {
+			return nil, fmt.Errorf("getting workflow history: %w", err)
+		}
+
+	events:
+		for _, event := range h {
+			lastSequenceID = event.SequenceID
+
+			switch event.Type {
+			case history.EventType_UpdateRequested:
+				requested = requested || event.Attributes.(*history.UpdateRequestedAttributes).UpdateID == updateID
+
+			case history.EventType_UpdateRejected:
+				a := event.Attributes.(*history.UpdateRejectedAttributes)
+				if a.UpdateID == updateID {
+					return nil, workflowerrors.WithConverter(c.backend.Converter(), a.Error)
+				}
+
+			case history.EventType_UpdateCompleted:
+				a := event.Attributes.(*history.UpdateCompletedAttributes)
+				if a.UpdateID == updateID {
+					if a.Error != nil {
+						return nil, workflowerrors.WithConverter(c.backend.Converter(), a.Error)
+					}
+
+					return a.Result, nil
+				}
+
+			case history.EventType_WorkflowExecutionContinuedAsNew:
+				if requested {
+					return nil, ErrUpdateNotCompleted
+				}
+
+				// The update is delivered to the new execution
+				a := event.Attributes.(*history.ExecutionContinuedAsNewAttributes)
+				instance = core.NewSubWorkflowInstance(instance.InstanceID, a.ContinuedExecutionID, instance.ParentInstanceID, instance.ParentEventID)
+				lastSequenceID = 0
+
+				break events
+
+			case history.EventType_WorkflowExecutionFinished, history.EventType_WorkflowExecutionTerminated:
+				return nil, ErrUpdateNotCompleted
+			}
+		}
+	}
+
+	return nil, ctx.Err()
+}
@@ -1,6 +1,7 @@
 import React from "react";
 import { Badge } from "react-bootstrap";
 import { Color } from "react-bootstrap/esm/types";
+import { SearchAttributes as SearchAttributesMap } from "./client";
 
 export function decodePayload(payload: string): string {
   try {
@@ -53,6 +54,30 @@ export const WorkflowInstanceState: React.FC<{ state: number }> = ({
   }
 };
 
+export const SearchAttributes: React.FC<{
+  attributes?: SearchAttributesMap;
+}> = ({ attributes }) => {
+  if (!attributes || Object.keys(attributes).length === 0) {
+    return <i>none</i>;
+  }
+
+  return (
+    <>
+      {Object.keys(attributes)
+        .sort()
+        .map((name) => {
+          const { value } = attributes[name];
+
+          return (
+            <Badge bg="light" text="dark" className="me-1" key={name}>
+              {name}: {Array.isArray(value) ? value.join(", ") : `${value}`}
+            </Badge>
+          );
+        })}
+    </>
+  );
+};
+
 export const EventType: React.FC<{ type: string }> = ({ type }) => {
   const [textColor, bgColor] = eventColor(type);
 
@@ -105,6 +130,7 @@ function eventColor(event: string): [Color, string] {
     case "ActivityScheduled":
     case "ActivityCompleted":
     case "ActivityFailed":
+    case "LocalActivityResult":
       return ["dark", "warning"];
 
     case "TimerScheduled":
@@ -113,9 +139,21 @@ function eventColor(event: string): [Color, string] {
       return ["light", "primary"];
 
     case "SignalReceived":
+    case "SignalWorkflowRequested":
+    case "SignalWorkflowDelivered":
+    case "SignalWorkflowFailed":
+    case "CancelWorkflowRequested":
+    case "CancelWorkflowDelivered":
+    case "CancelWorkflowFailed":
+    case "UpdateRequested":
+    case "UpdateAccepted":
+    case "UpdateRejected":
+    case "UpdateCompleted":
       return ["light", "dark"];
 
     case "SideEffectResult":
+    case "VersionMarker":
+    case "SearchAttributesUpserted":
       return ["dark", "secondary"];
 
     case "WorkflowTaskStarted":
@@ -1,10 +1,16 @@
-import { Pagination, Table } from "react-bootstrap";
-import { Link, useLocation } from "react-router-dom";
+import { Button, Form, Pagination, Table } from "react-bootstrap";
+import { Link, useLocation, useNavigate } from "react-router-dom";
 
 import React from "react";
 import useFetch from "react-fetch-hook";
 import { LinkContainer } from "react-router-bootstrap";
-import { WorkflowInstanceRef } from "./client";
+import {
+  SearchAttributeType,
+  SearchAttributeValue,
+  WorkflowInstanceFilter,
+  WorkflowInstanceRef,
+} from "./client";
+import { SearchAttributes } from "./Components";
 
 function useQuery() {
   const { search } = useLocation();
@@ -12,17 +18,136 @@ function useQuery() {
   return React.useMemo(() => new URLSearchParams(search), [search]);
 }
 
+function searchAttributeValue(
+  type: SearchAttributeType,
+  value: string
+): SearchAttributeValue {
+  switch (type) {
+    case "int":
+      return { type, value: parseInt(value, 10) };
+
+    case "bool":
+      return { type, value: value === "true" };
+
+    case "keywords":
+      return {
+        type,
+        value: value
+          .split(",")
+          .map((v) => v.trim())
+          .filter((v) => !!v),
+      };
+
+    default:
+      return { type, value };
+  }
+}
+
+const Filter: React.FC<{ filter: string | null }> = ({ filter }) => {
+  const navigate = useNavigate();
+
+  const current: WorkflowInstanceFilter = filter ? JSON.parse(filter) : {};
+  const [currentName] = Object.keys(current.search_attributes || {});
+  const currentAttribute =
+    currentName !== undefined
+      ? current.search_attributes![currentName]
+      : undefined;
+
+  const [workflowName, setWorkflowName] = React.useState(
+    current.workflow_name || ""
+  );
+  const [name, setName] = React.useState(currentName || "");
+  const [type, setType] = React.useState<SearchAttributeType>(
+    currentAttribute?.type || "string"
+  );
+  const [value, setValue] = React.useState(
+    currentAttribute
+      ? Array.isArray(currentAttribute.value)
+        ? currentAttribute.value.join(", ")
+        : `${currentAttribute.value}`
+      : ""
+  );
+
+  const onSubmit = (e: React.FormEvent) => {
+    e.preventDefault();
+
+    const f: WorkflowInstanceFilter = {};
+    if (workflowName) {
+      f.workflow_name = workflowName;
+    }
+
+    if (name) {
+      f.search_attributes = { [name]: searchAttributeValue(type, value) };
+    }
+
+    navigate(
+      Object.keys(f).length > 0
+        ? `/?filter=${encodeURIComponent(JSON.stringify(f))}`
+        : "/"
+    );
+  };
+
+  return (
+    <Form className="d-flex align-items-end mb-3" onSubmit={onSubmit}>
+      <Form.Group className="me-2">
+        <Form.Label>Workflow</Form.Label>
+        <Form.Control
+          size="sm"
+          value={workflowName}
+          onChange={(e) => setWorkflowName(e.target.value)}
+        />
+      </Form.Group>
+      <Form.Group className="me-2">
+        <Form.Label>Search attribute</Form.Label>
+        <Form.Control
+          size="sm"
+          value={name}
+          onChange={(e) => setName(e.target.value)}
+        />
+      </Form.Group>
+      <Form.Group className="me-2">
+        <Form.Label>Type</Form.Label>
+        <Form.Select
+          size="sm"
+          value={type}
+          onChange={(e) => setType(e.target.value as SearchAttributeType)}
+        >
+          <option value="string">string</option>
+          <option value="int">int</option>
+          <option value="time">time</option>
+          <option value="bool">bool</option>
+          <option value="keywords">keywords</option>
+        </Form.Select>
+      </Form.Group>
+      <Form.Group className="me-2">
+        <Form.Label>Value</Form.Label>
+        <Form.Control
+          size="sm"
+          value={value}
+          onChange={(e) => setValue(e.target.value)}
+        />
+      </Form.Group>
+      <Button size="sm" type="submit">
+        Filter
+      </Button>
+    </Form>
+  );
+};
+
 function Home() {
   const count = 20;
 
   const query = useQuery();
   const afterId = query.get("after");
   const page = +(query.get("page") || 1);
+  const filter = query.get("filter");
+  const filterParam = filter ? `&filter=${encodeURIComponent(filter)}` : "";
 
   const { isLoading, data, error } = useFetch<WorkflowInstanceRef[]>(
     document.location.pathname +
       `api/?count=${count}` +
-      (afterId ? `&after=${afterId}` : "")
+      (afterId ? `&after=${afterId}` : "") +
+      filterParam
   );
 
   return (
@@ -31,6 +156,8 @@ function Home() {
         <h2>Instances</h2>
       </header>
 
+      <Filter filter={filter} key={filter || ""} />
+
       {isLoading && <div>Loading...</div>}
 
       {!isLoading && (
@@ -42,6 +169,7 @@ function Home() {
                 <th>Execution ID</th>
                 <th>Parent Instance ID</th>
                 <th>Created At</th>
+                <th>Search Attributes</th>
               </tr>
             </thead>
             <tbody>
@@ -63,6 +191,9 @@ function Home() {
                   <td>
                     <code>{i.created_at}</code>
                   </td>
+                  <td>
+                    <SearchAttributes attributes={i.search_attributes} />
+                  </td>
                 </tr>
               ))}
             </tbody>
@@ -70,14 +201,17 @@ function Home() {
 
           <div className="d-flex justify-content-center">
             <Pagination>
-              <LinkContainer to="/?">
+              <LinkContainer to={`/?${filterParam.substring(1)}`}>
                 <Pagination.First disabled={!afterId} />
               </LinkContainer>
               <Pagination.Item active>{page}</Pagination.Item>
               <LinkContainer
                 to={`/?after=${
-                  (data && data[data.length - 1].instance.instance_id) || ""
-                }&page=${page + 1}`}
+                  (data &&
+                    data.length > 0 &&
+                    data[data.length - 1].instance.instance_id) ||
+                  ""
+                }&page=${page + 1}${filterParam}`}
               >
                 <Pagination.Next disabled={!data || data.length < count} />
               </LinkContainer>
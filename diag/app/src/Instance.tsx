@@ -12,11 +12,13 @@ import {
   EventType,
   Payload,
   ScheduleEventID,
+  SearchAttributes,
   WorkflowInstanceState,
 } from "./Components";
 
 import useFetch from "react-fetch-hook";
 import { InstanceTree } from "./InstanceTree";
+import { Queries } from "./Queries";
 
 function Instance() {
   let params = useParams();
@@ -104,6 +106,11 @@ function Instance() {
         <dd className="col-sm-8">
           {!instance.completed_at ? <i>pending</i> : instance.completed_at}
         </dd>
+
+        <dt className="col-sm-4">Search attributes</dt>
+        <dd className="col-sm-8">
+          <SearchAttributes attributes={instance.search_attributes} />
+        </dd>
       </dl>
 
       <Card>
@@ -123,6 +130,9 @@ function Instance() {
         </Card.Body>
       </Card>
 
+      <h2 className="mt-4">Queries</h2>
+      <Queries instanceId={instance.instance.instance_id} />
+
       <h2 className="mt-4">Workflow Graph</h2>
       <InstanceTree instanceId={instance.instance.instance_id} />
 
@@ -0,0 +1,101 @@
+import { useState } from "react";
+import { Alert, Button, Form, InputGroup, ListGroup } from "react-bootstrap";
+import useFetch from "react-fetch-hook";
+import { Payload } from "./Components";
+
+const Query: React.FC<{ instanceId: string; name: string }> = ({
+  instanceId,
+  name,
+}) => {
+  const [args, setArgs] = useState("[]");
+  const [result, setResult] = useState<string | undefined>();
+  const [error, setError] = useState<string | undefined>();
+
+  const run = async () => {
+    setResult(undefined);
+    setError(undefined);
+
+    const response = await fetch(
+      document.location.pathname +
+        "api/" +
+        instanceId +
+        "/queries/" +
+        encodeURIComponent(name),
+      {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: args,
+      }
+    );
+
+    if (!response.ok) {
+      setError((await response.text()) || response.statusText);
+      return;
+    }
+
+    setResult(JSON.stringify(await response.json(), undefined, 2));
+  };
+
+  return (
+    <ListGroup.Item>
+      <InputGroup>
+        <InputGroup.Text>
+          <code>{name}</code>
+        </InputGroup.Text>
+        <Form.Control
+          placeholder="Arguments as JSON array"
+          value={args}
+          onChange={(e) => setArgs(e.target.value)}
+        />
+        <Button variant="outline-primary" onClick={run}>
+          Run
+        </Button>
+      </InputGroup>
+
+      {result && (
+        <div className="mt-2">
+          <Payload payloads={[result]} />
+        </div>
+      )}
+      {error && (
+        <Alert className="mt-2" variant="danger">
+          {error}
+        </Alert>
+      )}
+    </ListGroup.Item>
+  );
+};
+
+export const Queries: React.FC<{ instanceId: string }> = ({ instanceId }) => {
+  const {
+    isLoading,
+    data: queries,
+    error,
+  } = useFetch<string[]>(
+    document.location.pathname + "api/" + instanceId + "/queries"
+  );
+
+  if (isLoading) {
+    return <div>Loading...</div>;
+  }
+
+  if (error || !queries) {
+    return (
+      <Alert variant="secondary">
+        Queries are not available for this workflow instance
+      </Alert>
+    );
+  }
+
+  if (queries.length === 0) {
+    return <i>No queries registered</i>;
+  }
+
+  return (
+    <ListGroup>
+      {queries.map((name) => (
+        <Query key={name} instanceId={instanceId} name={name} />
+      ))}
+    </ListGroup>
+  );
+};
@@ -4,6 +4,20 @@ export interface WorkflowInstance {
   execution_id: string;
 }
 
+export type SearchAttributeType =
+  | "string"
+  | "int"
+  | "time"
+  | "bool"
+  | "keywords";
+
+export interface SearchAttributeValue {
+  type: SearchAttributeType;
+  value: string | number | boolean | string[];
+}
+
+export type SearchAttributes = { [name: string]: SearchAttributeValue };
+
 export interface WorkflowInstanceRef {
   instance: WorkflowInstance;
 
@@ -11,6 +25,13 @@ export interface WorkflowInstanceRef {
   completed_at?: string;
 
   state: number;
+
+  search_attributes?: SearchAttributes;
+}
+
+export interface WorkflowInstanceFilter {
+  workflow_name?: string;
+  search_attributes?: SearchAttributes;
 }
 
 export type WorkflowInstanceInfo = WorkflowInstanceRef & {
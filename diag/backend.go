@@ -11,10 +11,17 @@ import (
 // json: serialization in this file needs to be kept in sync with client.ts in the web app
 
 type WorkflowInstanceRef struct {
-	Instance    *core.WorkflowInstance     `json:"instance,omitempty"`
-	CreatedAt   time.Time                  `json:"created_at,omitempty"`
-	CompletedAt *time.Time                 `json:"completed_at,omitempty"`
-	State       core.WorkflowInstanceState `json:"state"`
+	Instance         *core.WorkflowInstance     `json:"instance,omitempty"`
+	CreatedAt        time.Time                  `json:"created_at,omitempty"`
+	CompletedAt      *time.Time                 `json:"completed_at,omitempty"`
+	State            core.WorkflowInstanceState `json:"state"`
+	SearchAttributes core.SearchAttributes      `json:"search_attributes,omitempty"`
+}
+
+// WorkflowInstanceFilter is passed as JSON in the filter query parameter of the instance list API
+type WorkflowInstanceFilter struct {
+	WorkflowName     string                `json:"workflow_name,omitempty"`
+	SearchAttributes core.SearchAttributes `json:"search_attributes,omitempty"`
 }
 
 type Event struct {
@@ -1,41 +1,79 @@
 package diag
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"io/fs"
 	"net/http"
 	"strconv"
 	"strings"
+
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/client"
 )
 
 //go:embed app/build
 var embeddedFiles embed.FS
 
+type options struct {
+	client client.Client
+}
+
+type Option func(o *options)
+
+// WithClient enables listing and running workflow queries from the diagnostics web app. Queries are executed by the
+// given client, so workflows need to be registered with it.
+func WithClient(c client.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
 // NewServeMux returns an *http.ServeMux that serves the diagnostics web app at / and the diagnostics API at /api which is
 // used by the web app.
-func NewServeMux(backend Backend) *http.ServeMux {
+func NewServeMux(backend Backend, opts ...Option) *http.ServeMux {
+	options := &options{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	mux := http.NewServeMux()
 
 	// API
 	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
-		// Only support GET requests
+		relativeURL := strings.TrimPrefix(r.URL.Path, "/api/")
+		segments := strings.Split(relativeURL, "/")
+
+		// /api/{instanceID}/queries/{query}
+		if len(segments) == 3 && segments[1] == "queries" {
+			// Queries are run with POST, arguments are passed in the request body
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			runQuery(w, r, backend, options.client, segments[0], segments[2])
+			return
+		}
+
+		// Only support GET requests for everything else
 		if r.Method != http.MethodGet {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		relativeURL := strings.TrimPrefix(r.URL.Path, "/api/")
-
 		// /api/
 		if relativeURL == "" {
 			// Index
 			query := r.URL.Query()
 
+			var err error
+
 			count := 25
 			countStr := query.Get("count")
 			if countStr != "" {
-				var err error
 				count, err = strconv.Atoi(countStr)
 				if err != nil {
 					w.WriteHeader(http.StatusBadRequest)
@@ -43,7 +81,18 @@ func NewServeMux(backend Backend) *http.ServeMux {
 				}
 			}
 
-			instances, err := backend.GetWorkflowInstances(r.Context(), query.Get("after"), count)
+			var instances []*WorkflowInstanceRef
+			if filterStr := query.Get("filter"); filterStr != "" {
+				var filter WorkflowInstanceFilter
+				if err := json.Unmarshal([]byte(filterStr), &filter); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				instances, err = listWorkflowInstances(r.Context(), backend, &filter, query.Get("after"), count)
+			} else {
+				instances, err = backend.GetWorkflowInstances(r.Context(), query.Get("after"), count)
+			}
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				return
@@ -58,8 +107,6 @@ func NewServeMux(backend Backend) *http.ServeMux {
 			return
 		}
 
-		segments := strings.Split(relativeURL, "/")
-
 		// /api/{instanceID}
 		if len(segments) == 1 {
 			instanceID := segments[0]
@@ -108,6 +155,12 @@ func NewServeMux(backend Backend) *http.ServeMux {
 			return
 		}
 
+		// /api/{instanceID}/queries
+		if len(segments) == 2 && segments[1] == "queries" {
+			listQueries(w, r, backend, options.client, segments[0])
+			return
+		}
+
 		// /api/{instanceID}/tree
 		if len(segments) == 2 {
 			instanceID := segments[0]
@@ -144,6 +197,93 @@ func NewServeMux(backend Backend) *http.ServeMux {
 	return mux
 }
 
+func listWorkflowInstances(ctx context.Context, b Backend, filter *WorkflowInstanceFilter, afterInstanceID string, count int) ([]*WorkflowInstanceRef, error) {
+	instances, err := b.ListWorkflowInstances(ctx, &backend.WorkflowInstanceFilter{
+		WorkflowName:     filter.WorkflowName,
+		SearchAttributes: filter.SearchAttributes,
+	}, afterInstanceID, count)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]*WorkflowInstanceRef, 0, len(instances))
+	for _, i := range instances {
+		refs = append(refs, &WorkflowInstanceRef{
+			Instance:         i.Instance,
+			CreatedAt:        i.CreatedAt,
+			CompletedAt:      i.CompletedAt,
+			State:            i.State,
+			SearchAttributes: i.SearchAttributes,
+		})
+	}
+
+	return refs, nil
+}
+
+func listQueries(w http.ResponseWriter, r *http.Request, backend Backend, c client.Client, instanceID string) {
+	if c == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	instance, err := backend.GetWorkflowInstance(r.Context(), instanceID)
+	if err != nil || instance == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	queries, err := c.ListWorkflowQueries(r.Context(), instance.Instance)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(queries); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+func runQuery(w http.ResponseWriter, r *http.Request, backend Backend, c client.Client, instanceID, query string) {
+	if c == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	instance, err := backend.GetWorkflowInstance(r.Context(), instanceID)
+	if err != nil || instance == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// Arguments are passed as a JSON array in the request body
+	var args []interface{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := client.QueryWorkflow[interface{}](r.Context(), c, instance.Instance, query, args...)
+	if err != nil {
+		if errors.Is(err, client.ErrUnknownQuery) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
 func getFileSystem() http.FileSystem {
 	// Get the build subdirectory as the
 	// root directory so that it can be passed
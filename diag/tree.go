@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/cschleiden/go-workflows/backend"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 )
@@ -85,27 +86,47 @@ func (itb *instanceTreeBuilder) getRoot(ctx context.Context, instance *WorkflowI
 	return instance, nil
 }
 
+// getNameAndChildren returns the workflow name of the current execution of the given instance, and the sub-workflow
+// instances started by any of its executions. Executions which continued as new are followed, oldest first.
 func (itb *instanceTreeBuilder) getNameAndChildren(ctx context.Context, instance *core.WorkflowInstance) (string, []*WorkflowInstanceRef, error) {
-	h, err := itb.b.GetWorkflowInstanceHistory(ctx, instance, nil)
+	executions, err := itb.b.ListWorkflowInstanceExecutions(ctx, instance.InstanceID)
 	if err != nil {
-		return "", nil, fmt.Errorf("getting instance history: %w", err)
+		return "", nil, fmt.Errorf("listing instance executions: %w", err)
+	}
+
+	if len(executions) == 0 {
+		executions = []*backend.WorkflowInstanceInfo{{Instance: instance}}
 	}
 
 	workflowName := ""
 
 	var children []*WorkflowInstanceRef
-	for _, event := range h {
-		switch event.Type {
-		case history.EventType_SubWorkflowScheduled:
-			childInstance, err := itb.getInstance(ctx, event.Attributes.(*history.SubWorkflowScheduledAttributes).SubWorkflowInstance.InstanceID)
-			if err != nil {
-				return "", nil, fmt.Errorf("getting child instance: %w", err)
-			}
+	seen := map[string]bool{}
+	for i := len(executions) - 1; i >= 0; i-- {
+		h, err := itb.b.GetWorkflowInstanceHistory(ctx, executions[i].Instance, nil)
+		if err != nil {
+			return "", nil, fmt.Errorf("getting instance history: %w", err)
+		}
+
+		for _, event := range h {
+			switch event.Type {
+			case history.EventType_SubWorkflowScheduled:
+				childInstanceID := event.Attributes.(*history.SubWorkflowScheduledAttributes).SubWorkflowInstance.InstanceID
+				if seen[childInstanceID] {
+					continue
+				}
 
-			children = append(children, childInstance)
+				childInstance, err := itb.getInstance(ctx, childInstanceID)
+				if err != nil {
+					return "", nil, fmt.Errorf("getting child instance: %w", err)
+				}
 
-		case history.EventType_WorkflowExecutionStarted:
-			workflowName = event.Attributes.(*history.ExecutionStartedAttributes).Name
+				seen[childInstanceID] = true
+				children = append(children, childInstance)
+
+			case history.EventType_WorkflowExecutionStarted:
+				workflowName = event.Attributes.(*history.ExecutionStartedAttributes).Name
+			}
 		}
 	}
 
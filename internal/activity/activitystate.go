@@ -3,25 +3,35 @@ package activity
 import (
 	"context"
 
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/log"
-	"github.com/cschleiden/go-workflows/workflow"
 )
 
 type ActivityState struct {
 	ActivityID string
-	Instance   *workflow.Instance
+	Instance   *core.WorkflowInstance
 	Logger     log.Logger
+
+	Converter converter.Converter
+	Heartbeat *Heartbeat
+
+	// TaskToken identifies the activity task for asynchronous completion
+	TaskToken *TaskToken
 }
 
-func NewActivityState(activityID string, instance *workflow.Instance, logger log.Logger) *ActivityState {
+func NewActivityState(activityID string, instance *core.WorkflowInstance, logger log.Logger) *ActivityState {
 	return &ActivityState{
-		activityID,
-		instance,
-		logger.With(
+		ActivityID: activityID,
+		Instance:   instance,
+		Logger: logger.With(
 			"activity_id", activityID,
 			"instance_id", instance.InstanceID,
 			"execution_id", instance.ExecutionID,
-		)}
+		),
+		Converter: converter.DefaultConverter,
+		Heartbeat: NewHeartbeat(nil),
+	}
 }
 
 type key int
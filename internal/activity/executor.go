@@ -12,20 +12,24 @@ import (
 	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/internal/tracing"
-	"github.com/cschleiden/go-workflows/internal/workflow"
 	"github.com/cschleiden/go-workflows/log"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// Registry provides the activities registered with a worker
+type Registry interface {
+	GetActivity(name string) (interface{}, error)
+}
+
 type Executor struct {
 	logger    log.Logger
 	tracer    trace.Tracer
 	converter converter.Converter
-	r         *workflow.Registry
+	r         Registry
 }
 
-func NewExecutor(logger log.Logger, tracer trace.Tracer, converter converter.Converter, r *workflow.Registry) Executor {
+func NewExecutor(logger log.Logger, tracer trace.Tracer, converter converter.Converter, r Registry) Executor {
 	return Executor{
 		logger:    logger,
 		tracer:    tracer,
@@ -34,7 +38,9 @@ func NewExecutor(logger log.Logger, tracer trace.Tracer, converter converter.Con
 	}
 }
 
-func (e *Executor) ExecuteActivity(ctx context.Context, task *task.Activity) (payload.Payload, error) {
+// ExecuteActivity executes the given activity task. Heartbeat details recorded by the activity are stored in
+// heartbeat, if it's nil, a new heartbeat initialized with the details of the previous attempt is used.
+func (e *Executor) ExecuteActivity(ctx context.Context, task *task.Activity, heartbeat *Heartbeat) (payload.Payload, error) {
 	a := task.Event.Attributes.(*history.ActivityScheduledAttributes)
 
 	activity, err := e.r.GetActivity(a.Name)
@@ -42,14 +48,9 @@ func (e *Executor) ExecuteActivity(ctx context.Context, task *task.Activity) (pa
 		return nil, err
 	}
 
-	activityFn := reflect.ValueOf(activity)
-	if activityFn.Type().Kind() != reflect.Func {
-		return nil, errors.New("activity not a function")
-	}
-
-	args, addContext, err := args.InputsToArgs(e.converter, activityFn, a.Inputs)
+	call, err := prepareActivity(e.converter, activity, a.Inputs)
 	if err != nil {
-		return nil, fmt.Errorf("converting activity inputs: %w", err)
+		return nil, err
 	}
 
 	// Add activity state to context
@@ -57,6 +58,18 @@ func (e *Executor) ExecuteActivity(ctx context.Context, task *task.Activity) (pa
 		task.Event.ID,
 		task.WorkflowInstance,
 		e.logger)
+	as.Converter = e.converter
+	if heartbeat != nil {
+		as.Heartbeat = heartbeat
+	} else {
+		as.Heartbeat = NewHeartbeat(a.HeartbeatDetails)
+	}
+	as.TaskToken = &TaskToken{
+		Instance:        task.WorkflowInstance,
+		ActivityID:      task.ID,
+		ScheduleEventID: task.Event.ScheduleEventID,
+	}
+
 	activityCtx := WithActivityState(ctx, as)
 
 	activityCtx = tracing.UnmarshalSpan(activityCtx, task.Metadata)
@@ -67,35 +80,66 @@ func (e *Executor) ExecuteActivity(ctx context.Context, task *task.Activity) (pa
 	))
 	defer span.End()
 
-	// Execute activity
-	if addContext {
-		args[0] = reflect.ValueOf(activityCtx)
-	}
-	r := activityFn.Call(args)
+	return call(activityCtx)
+}
 
-	if len(r) < 1 || len(r) > 2 {
-		return nil, errors.New("activity has to return either (error) or (<result>, error)")
+// Invoke calls the given activity function in the calling process, with the given activity state added to its
+// context. Arguments are converted from inputs, and the result is converted, using the converter of the state.
+func Invoke(ctx context.Context, as *ActivityState, activity interface{}, inputs []payload.Payload) (payload.Payload, error) {
+	call, err := prepareActivity(as.Converter, activity, inputs)
+	if err != nil {
+		return nil, err
 	}
 
-	var result payload.Payload
+	return call(WithActivityState(ctx, as))
+}
 
-	if len(r) > 1 {
-		var err error
-		result, err = e.converter.To(r[0].Interface())
-		if err != nil {
-			return nil, fmt.Errorf("converting activity result: %w", err)
-		}
+// prepareActivity converts the inputs to the arguments of the given activity function, and returns a function
+// calling the activity with them.
+func prepareActivity(
+	cv converter.Converter, activity interface{}, inputs []payload.Payload,
+) (func(ctx context.Context) (payload.Payload, error), error) {
+	activityFn := reflect.ValueOf(activity)
+	if activityFn.Type().Kind() != reflect.Func {
+		return nil, errors.New("activity not a function")
 	}
 
-	errResult := r[len(r)-1]
-	if errResult.IsNil() {
-		return result, nil
+	args, addContext, err := args.InputsToArgs(cv, activityFn, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("converting activity inputs: %w", err)
 	}
 
-	errInterface, ok := errResult.Interface().(error)
-	if !ok {
-		return nil, fmt.Errorf("activity error result does not satisfy error interface (%T): %v", errResult, errResult)
-	}
+	return func(ctx context.Context) (payload.Payload, error) {
+		// Execute activity
+		if addContext {
+			args[0] = reflect.ValueOf(ctx)
+		}
+		r := activityFn.Call(args)
+
+		if len(r) < 1 || len(r) > 2 {
+			return nil, errors.New("activity has to return either (error) or (<result>, error)")
+		}
+
+		var result payload.Payload
+
+		if len(r) > 1 {
+			var err error
+			result, err = cv.To(r[0].Interface())
+			if err != nil {
+				return nil, fmt.Errorf("converting activity result: %w", err)
+			}
+		}
+
+		errResult := r[len(r)-1]
+		if errResult.IsNil() {
+			return result, nil
+		}
+
+		errInterface, ok := errResult.Interface().(error)
+		if !ok {
+			return nil, fmt.Errorf("activity error result does not satisfy error interface (%T): %v", errResult, errResult)
+		}
 
-	return result, errInterface
+		return result, errInterface
+	}, nil
 }
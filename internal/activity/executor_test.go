@@ -65,7 +65,7 @@ func TestExecutor_ExecuteActivity(t *testing.T) {
 				ID:               uuid.NewString(),
 				WorkflowInstance: core.NewWorkflowInstance("instanceID", "executionID"),
 				Event:            history.NewHistoryEvent(1, time.Now(), history.EventType_ActivityScheduled, attr),
-			})
+			}, nil)
 			tt.result(t, got, err)
 		})
 	}
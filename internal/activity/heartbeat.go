@@ -0,0 +1,55 @@
+package activity
+
+import (
+	"sync"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// Heartbeat keeps track of the details an activity reports via heartbeats
+type Heartbeat struct {
+	mu sync.Mutex
+
+	details payload.Payload
+
+	// recorded is set when new details have been recorded since they were last sent
+	recorded bool
+}
+
+// NewHeartbeat creates a new heartbeat. details are the details recorded by a previous attempt of the activity.
+func NewHeartbeat(details payload.Payload) *Heartbeat {
+	return &Heartbeat{
+		details: details,
+	}
+}
+
+// Record stores the latest details reported by the activity
+func (h *Heartbeat) Record(details payload.Payload) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.details = details
+	h.recorded = true
+}
+
+// Details returns the latest details, either recorded by the current or by a previous attempt
+func (h *Heartbeat) Details() payload.Payload {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.details
+}
+
+// Pending returns the details if a heartbeat has been recorded since the last call, and whether one has been
+// recorded
+func (h *Heartbeat) Pending() (payload.Payload, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.recorded {
+		return nil, false
+	}
+
+	h.recorded = false
+	return h.details, true
+}
@@ -0,0 +1,30 @@
+package activity
+
+import (
+	"testing"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeat(t *testing.T) {
+	h := NewHeartbeat(payload.Payload("previous"))
+
+	// Details of a previous attempt are available, but don't need to be sent again
+	require.Equal(t, payload.Payload("previous"), h.Details())
+	details, recorded := h.Pending()
+	require.False(t, recorded)
+	require.Nil(t, details)
+
+	h.Record(payload.Payload("current"))
+	require.Equal(t, payload.Payload("current"), h.Details())
+	details, recorded = h.Pending()
+	require.True(t, recorded)
+	require.Equal(t, payload.Payload("current"), details)
+
+	// Details are only sent once
+	details, recorded = h.Pending()
+	require.False(t, recorded)
+	require.Nil(t, details)
+	require.Equal(t, payload.Payload("current"), h.Details())
+}
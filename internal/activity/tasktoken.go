@@ -0,0 +1,54 @@
+package activity
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+)
+
+// ErrResultPending is returned by activities which are completed asynchronously, by another process using the
+// activity's task token.
+var ErrResultPending = errors.New("activity result pending")
+
+// TaskToken identifies an activity task for completing it asynchronously
+type TaskToken struct {
+	Instance *core.WorkflowInstance `json:"instance"`
+
+	// ActivityID is the ID of the activity task in the backend
+	ActivityID string `json:"activity_id"`
+
+	// ScheduleEventID is the id of the event which scheduled the activity
+	ScheduleEventID int64 `json:"schedule_event_id"`
+}
+
+// Encode returns an opaque string representation of the token
+func (t *TaskToken) Encode() (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("marshaling task token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeTaskToken parses a token created using TaskToken.Encode
+func DecodeTaskToken(token string) (*TaskToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid task token: %w", err)
+	}
+
+	var t TaskToken
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("invalid task token: %w", err)
+	}
+
+	if t.Instance == nil || t.ActivityID == "" {
+		return nil, errors.New("invalid task token")
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,29 @@
+package activity
+
+import (
+	"testing"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskToken(t *testing.T) {
+	token := &TaskToken{
+		Instance:        core.NewWorkflowInstance("instance", "execution"),
+		ActivityID:      "activity",
+		ScheduleEventID: 3,
+	}
+
+	s, err := token.Encode()
+	require.NoError(t, err)
+
+	decoded, err := DecodeTaskToken(s)
+	require.NoError(t, err)
+	require.Equal(t, token, decoded)
+
+	_, err = DecodeTaskToken("invalid")
+	require.Error(t, err)
+
+	_, err = DecodeTaskToken("")
+	require.Error(t, err)
+}
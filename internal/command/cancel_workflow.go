@@ -0,0 +1,58 @@
+package command
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+type CancelWorkflowCommand struct {
+	command
+
+	Instance *core.WorkflowInstance
+}
+
+var _ Command = (*CancelWorkflowCommand)(nil)
+
+func NewCancelWorkflowCommand(id int64, instanceID string) *CancelWorkflowCommand {
+	return &CancelWorkflowCommand{
+		command: command{
+			id:    id,
+			name:  "CancelWorkflow",
+			state: CommandState_Pending,
+		},
+
+		Instance: core.NewWorkflowInstance(instanceID, ""),
+	}
+}
+
+func (c *CancelWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Committed
+
+		return &CommandResult{
+			// Record the cancellation request for the source workflow instance
+			Events: []*history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_CancelWorkflowRequested,
+					&history.CancelWorkflowRequestedAttributes{
+						InstanceID: c.Instance.InstanceID,
+					},
+					history.ScheduleEventID(c.id),
+				),
+			},
+
+			// Send cancellation event to the target workflow instance
+			WorkflowEvents: []history.WorkflowEvent{
+				{
+					WorkflowInstance: c.Instance,
+					HistoryEvent:     history.NewWorkflowCancellationEvent(clock.Now()),
+				},
+			},
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelWorkflowCommand_StateTransitions(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(t *testing.T, c *CancelWorkflowCommand, clock clock.Clock)
+	}{
+		{"Execute requests cancellation", func(t *testing.T, c *CancelWorkflowCommand, clock clock.Clock) {
+			r := assertExecuteWithEvent(t, c, CommandState_Committed, history.EventType_CancelWorkflowRequested)
+
+			require.Equal(t, "target", r.Events[0].Attributes.(*history.CancelWorkflowRequestedAttributes).InstanceID)
+
+			require.Len(t, r.WorkflowEvents, 1)
+			require.Equal(t, "target", r.WorkflowEvents[0].WorkflowInstance.InstanceID)
+			require.Equal(t, history.EventType_WorkflowExecutionCanceled, r.WorkflowEvents[0].HistoryEvent.Type)
+		}},
+		{"Commit", func(t *testing.T, c *CancelWorkflowCommand, _ clock.Clock) {
+			require.Equal(t, CommandState_Pending, c.State())
+
+			c.Commit()
+			require.Equal(t, CommandState_Committed, c.State())
+
+			assertExecuteNoEvent(t, c, CommandState_Committed)
+		}},
+		{"Done_after_commit", func(t *testing.T, c *CancelWorkflowCommand, clock clock.Clock) {
+			c.Commit()
+
+			c.Done()
+			require.Equal(t, CommandState_Done, c.State())
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clock.NewMock()
+			cmd := NewCancelWorkflowCommand(1, "target")
+
+			tt.f(t, cmd, clock)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package command
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/google/uuid"
+)
+
+type ContinueAsNewCommand struct {
+	command
+
+	Instance *core.WorkflowInstance
+	Metadata *core.WorkflowMetadata
+
+	Name   string
+	Inputs []payload.Payload
+
+	// Queue is the queue workflow tasks of the new execution are routed to
+	Queue core.Queue
+
+	// Priority is the priority of workflow tasks of the new execution
+	Priority int
+
+	// SearchAttributes are the search attributes of the new execution
+	SearchAttributes core.SearchAttributes
+
+	// ContinuedInstance is the instance of the new execution
+	ContinuedInstance *core.WorkflowInstance
+}
+
+var _ Command = (*ContinueAsNewCommand)(nil)
+
+func NewContinueAsNewCommand(
+	id int64, instance *core.WorkflowInstance, name string, inputs []payload.Payload, metadata *core.WorkflowMetadata,
+) *ContinueAsNewCommand {
+	// Same instance id and parent, but a new execution
+	continuedInstance := core.NewSubWorkflowInstance(instance.InstanceID, uuid.NewString(), instance.ParentInstanceID, instance.ParentEventID)
+
+	return &ContinueAsNewCommand{
+		command: command{
+			id:    id,
+			name:  "ContinueAsNew",
+			state: CommandState_Pending,
+		},
+		Instance:          instance,
+		Metadata:          metadata,
+		Name:              name,
+		Inputs:            inputs,
+		ContinuedInstance: continuedInstance,
+	}
+}
+
+func (c *ContinueAsNewCommand) Commit() {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
+
+func (c *ContinueAsNewCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Done
+
+		return &CommandResult{
+			Completed: true,
+			// Record that the current execution has been continued
+			Events: []*history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_WorkflowExecutionContinuedAsNew,
+					&history.ExecutionContinuedAsNewAttributes{
+						ContinuedExecutionID: c.ContinuedInstance.ExecutionID,
+						Inputs:               c.Inputs,
+					},
+					history.ScheduleEventID(0),
+				),
+			},
+			// Start the new execution. The parent is not notified, it will be notified when the last
+			// execution in the chain completes.
+			WorkflowEvents: []history.WorkflowEvent{
+				{
+					WorkflowInstance: c.ContinuedInstance,
+					HistoryEvent: history.NewPendingEvent(
+						clock.Now(),
+						history.EventType_WorkflowExecutionStarted,
+						&history.ExecutionStartedAttributes{
+							Name:     c.Name,
+							Inputs:   c.Inputs,
+							Metadata: c.Metadata,
+							Queue:    c.Queue,
+							Priority: c.Priority,
+
+							SearchAttributes: c.SearchAttributes,
+						},
+						history.ScheduleEventID(0),
+					),
+				},
+			},
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContinueAsNewCommand_StateTransitions(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(t *testing.T, c *ContinueAsNewCommand, clock clock.Clock)
+	}{
+		{"Execute records continued event", func(t *testing.T, c *ContinueAsNewCommand, clock clock.Clock) {
+			r := assertExecuteWithEvent(t, c, CommandState_Done, history.EventType_WorkflowExecutionContinuedAsNew)
+			require.True(t, r.Completed)
+
+			a := r.Events[0].Attributes.(*history.ExecutionContinuedAsNewAttributes)
+			require.Equal(t, c.ContinuedInstance.ExecutionID, a.ContinuedExecutionID)
+
+			require.Len(t, r.WorkflowEvents, 1)
+			require.Equal(t, c.ContinuedInstance, r.WorkflowEvents[0].WorkflowInstance)
+			require.Equal(t, history.EventType_WorkflowExecutionStarted, r.WorkflowEvents[0].HistoryEvent.Type)
+		}},
+		{"Continued instance keeps instance and parent", func(t *testing.T, c *ContinueAsNewCommand, clock clock.Clock) {
+			require.Equal(t, c.Instance.InstanceID, c.ContinuedInstance.InstanceID)
+			require.NotEqual(t, c.Instance.ExecutionID, c.ContinuedInstance.ExecutionID)
+			require.Equal(t, c.Instance.ParentInstanceID, c.ContinuedInstance.ParentInstanceID)
+			require.Equal(t, c.Instance.ParentEventID, c.ContinuedInstance.ParentEventID)
+		}},
+		{"Commit", func(t *testing.T, c *ContinueAsNewCommand, _ clock.Clock) {
+			require.Equal(t, CommandState_Pending, c.State())
+
+			c.Commit()
+			require.Equal(t, CommandState_Done, c.State())
+
+			assertExecuteNoEvent(t, c, CommandState_Done)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clock.NewMock()
+			instance := core.NewSubWorkflowInstance(uuid.NewString(), uuid.NewString(), uuid.NewString(), 2)
+			cmd := NewContinueAsNewCommand(1, instance, "Workflow", []payload.Payload{}, &core.WorkflowMetadata{})
+
+			tt.f(t, cmd, clock)
+		})
+	}
+}
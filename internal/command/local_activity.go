@@ -0,0 +1,101 @@
+package command
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+)
+
+type LocalActivityCommand struct {
+	command
+
+	Name   string
+	Inputs []payload.Payload
+
+	result   payload.Payload
+	err      *workflowerrors.Error
+	attempts int
+	deferred bool
+}
+
+var _ Command = (*LocalActivityCommand)(nil)
+
+func NewLocalActivityCommand(id int64, name string, inputs []payload.Payload) *LocalActivityCommand {
+	return &LocalActivityCommand{
+		command: command{
+			id:    id,
+			name:  "LocalActivity",
+			state: CommandState_Pending,
+		},
+		Name:   name,
+		Inputs: inputs,
+	}
+}
+
+// SetResult sets the result of the local activity, either after executing it or when replaying its result. Deferred
+// local activities didn't complete within the workflow task, and are executed again in a later workflow task.
+func (c *LocalActivityCommand) SetResult(result payload.Payload, err *workflowerrors.Error, attempts int, deferred bool) {
+	c.result = result
+	c.err = err
+	c.attempts = attempts
+	c.deferred = deferred
+}
+
+// Attempts returns the number of attempts executed before the result was recorded
+func (c *LocalActivityCommand) Attempts() int {
+	return c.attempts
+}
+
+// Deferred returns true if the local activity has to be executed again in a later workflow task
+func (c *LocalActivityCommand) Deferred() bool {
+	return c.deferred
+}
+
+func (c *LocalActivityCommand) Commit() {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
+
+func (c *LocalActivityCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		// Local activities have already been executed, only their result is added to the history
+		c.state = CommandState_Done
+
+		return &CommandResult{
+			Events: []*history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_LocalActivityResult,
+					&history.LocalActivityResultAttributes{
+						Name:     c.Name,
+						Inputs:   c.Inputs,
+						Result:   c.result,
+						Error:    c.err,
+						Attempts: c.attempts,
+						Deferred: c.deferred,
+					},
+					history.ScheduleEventID(c.id),
+				),
+			},
+		}
+	}
+
+	return nil
+}
+
+func (c *LocalActivityCommand) Done() {
+	switch c.state {
+	case CommandState_Pending, CommandState_Committed:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalActivityCommand_StateTransitions(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(t *testing.T, c *LocalActivityCommand, clock clock.Clock)
+	}{
+		{"Execute records local activity result", func(t *testing.T, c *LocalActivityCommand, clock clock.Clock) {
+			c.SetResult(nil, workflowerrors.NewError("", "failed"), 2, false)
+
+			r := assertExecuteWithEvent(t, c, CommandState_Done, history.EventType_LocalActivityResult)
+
+			a := r.Events[0].Attributes.(*history.LocalActivityResultAttributes)
+			require.Equal(t, "foo", a.Name)
+			require.Equal(t, []payload.Payload{[]byte("input")}, a.Inputs)
+			require.Equal(t, "failed", a.Error.Message)
+			require.Equal(t, 2, a.Attempts)
+			require.Equal(t, int64(1), r.Events[0].ScheduleEventID)
+		}},
+		{"Execute records deferred local activity", func(t *testing.T, c *LocalActivityCommand, clock clock.Clock) {
+			c.SetResult(nil, nil, 1, true)
+			require.True(t, c.Deferred())
+
+			r := assertExecuteWithEvent(t, c, CommandState_Done, history.EventType_LocalActivityResult)
+
+			a := r.Events[0].Attributes.(*history.LocalActivityResultAttributes)
+			require.True(t, a.Deferred)
+			require.Equal(t, 1, a.Attempts)
+		}},
+		{"Commit", func(t *testing.T, c *LocalActivityCommand, _ clock.Clock) {
+			require.Equal(t, CommandState_Pending, c.State())
+
+			c.Commit()
+			require.Equal(t, CommandState_Done, c.State())
+
+			assertExecuteNoEvent(t, c, CommandState_Done)
+		}},
+		{"Done_after_commit", func(t *testing.T, c *LocalActivityCommand, clock clock.Clock) {
+			c.Commit()
+
+			require.PanicsWithError(t, "invalid state transition for command LocalActivity: Done -> Done", func() {
+				c.Done()
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clock.NewMock()
+			cmd := NewLocalActivityCommand(1, "foo", []payload.Payload{[]byte("input")})
+
+			tt.f(t, cmd, clock)
+		})
+	}
+}
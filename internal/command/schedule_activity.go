@@ -2,6 +2,7 @@ package command
 
 import (
 	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/payload"
 )
@@ -9,21 +10,33 @@ import (
 type ScheduleActivityCommand struct {
 	command
 
-	Name   string
-	Inputs []payload.Payload
+	Name             string
+	Inputs           []payload.Payload
+	Timeouts         *history.ActivityTimeouts
+	HeartbeatDetails payload.Payload
+
+	// Queue is the queue the activity task is routed to
+	Queue core.Queue
+
+	// Priority is the priority of the activity task
+	Priority int
 }
 
 var _ Command = (*ScheduleActivityCommand)(nil)
 
-func NewScheduleActivityCommand(id int64, name string, inputs []payload.Payload) *ScheduleActivityCommand {
+func NewScheduleActivityCommand(
+	id int64, name string, inputs []payload.Payload, timeouts *history.ActivityTimeouts, heartbeatDetails payload.Payload,
+) *ScheduleActivityCommand {
 	return &ScheduleActivityCommand{
 		command: command{
 			id:    id,
 			name:  "ScheduleActivity",
 			state: CommandState_Pending,
 		},
-		Name:   name,
-		Inputs: inputs,
+		Name:             name,
+		Inputs:           inputs,
+		Timeouts:         timeouts,
+		HeartbeatDetails: heartbeatDetails,
 	}
 }
 
@@ -36,8 +49,12 @@ func (c *ScheduleActivityCommand) Execute(clock clock.Clock) *CommandResult {
 			clock.Now(),
 			history.EventType_ActivityScheduled,
 			&history.ActivityScheduledAttributes{
-				Name:   c.Name,
-				Inputs: c.Inputs,
+				Name:             c.Name,
+				Inputs:           c.Inputs,
+				Timeouts:         c.Timeouts,
+				Queue:            c.Queue,
+				Priority:         c.Priority,
+				HeartbeatDetails: c.HeartbeatDetails,
 			},
 			history.ScheduleEventID(c.id))
 
@@ -2,8 +2,10 @@ package command
 
 import (
 	"testing"
+	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/stretchr/testify/require"
@@ -17,6 +19,33 @@ func TestScheduleActivityCommand_StateTransitions(t *testing.T) {
 		{"Execute schedules activity", func(t *testing.T, c *ScheduleActivityCommand, clock clock.Clock) {
 			assertExecuteWithEvent(t, c, CommandState_Committed, history.EventType_ActivityScheduled)
 		}},
+		{"Execute records timeouts", func(t *testing.T, c *ScheduleActivityCommand, clock clock.Clock) {
+			c.Timeouts = &history.ActivityTimeouts{StartToClose: time.Second}
+
+			r := c.Execute(clock)
+			require.Len(t, r.ActivityEvents, 1)
+
+			a := r.ActivityEvents[0].Attributes.(*history.ActivityScheduledAttributes)
+			require.Equal(t, time.Second, a.Timeouts.StartToClose)
+		}},
+		{"Execute records queue", func(t *testing.T, c *ScheduleActivityCommand, clock clock.Clock) {
+			c.Queue = "heavy"
+
+			r := c.Execute(clock)
+			require.Len(t, r.ActivityEvents, 1)
+
+			a := r.ActivityEvents[0].Attributes.(*history.ActivityScheduledAttributes)
+			require.Equal(t, core.Queue("heavy"), a.Queue)
+		}},
+		{"Execute records priority", func(t *testing.T, c *ScheduleActivityCommand, clock clock.Clock) {
+			c.Priority = 10
+
+			r := c.Execute(clock)
+			require.Len(t, r.ActivityEvents, 1)
+
+			a := r.ActivityEvents[0].Attributes.(*history.ActivityScheduledAttributes)
+			require.Equal(t, 10, a.Priority)
+		}},
 		{"Commit", func(t *testing.T, c *ScheduleActivityCommand, _ clock.Clock) {
 			require.Equal(t, CommandState_Pending, c.State())
 
@@ -36,7 +65,7 @@ func TestScheduleActivityCommand_StateTransitions(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			clock := clock.NewMock()
-			cmd := NewScheduleActivityCommand(1, "activity", []payload.Payload{})
+			cmd := NewScheduleActivityCommand(1, "activity", []payload.Payload{}, nil, nil)
 
 			tt.f(t, cmd, clock)
 		})
@@ -1,6 +1,8 @@
 package command
 
 import (
+	"time"
+
 	"github.com/benbjohnson/clock"
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/history"
@@ -8,6 +10,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// parentClosedReason is the reason recorded for sub-workflow instances terminated because their parent completed
+const parentClosedReason = "parent workflow instance closed"
+
 type ScheduleSubWorkflowCommand struct {
 	cancelableCommand
 
@@ -16,6 +21,17 @@ type ScheduleSubWorkflowCommand struct {
 
 	Name   string
 	Inputs []payload.Payload
+
+	ExecutionTimeout       time.Duration
+	ExecutionTimeoutAction core.ExecutionTimeoutAction
+
+	ParentClosePolicy core.ParentClosePolicy
+
+	// Queue is the queue workflow tasks of the sub-workflow instance are routed to
+	Queue core.Queue
+
+	// Priority is the priority of workflow tasks of the sub-workflow instance
+	Priority int
 }
 
 var _ CancelableCommand = (*ScheduleSubWorkflowCommand)(nil)
@@ -59,6 +75,7 @@ func (c *ScheduleSubWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 						Metadata:            c.Metadata,
 						Name:                c.Name,
 						Inputs:              c.Inputs,
+						ParentClosePolicy:   c.ParentClosePolicy,
 					},
 					history.ScheduleEventID(c.id),
 				),
@@ -74,6 +91,12 @@ func (c *ScheduleSubWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 							Name:     c.Name,
 							Inputs:   c.Inputs,
 							Metadata: c.Metadata,
+
+							ExecutionTimeout:       c.ExecutionTimeout,
+							ExecutionTimeoutAction: c.ExecutionTimeoutAction,
+
+							Queue:    c.Queue,
+							Priority: c.Priority,
 						},
 						history.ScheduleEventID(0),
 					),
@@ -108,3 +131,26 @@ func (c *ScheduleSubWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
 
 	return nil
 }
+
+// ParentClosed returns the event applying the ParentClosePolicy to the sub-workflow instance, if it's still running
+// when the parent workflow instance completes.
+func (c *ScheduleSubWorkflowCommand) ParentClosed(clock clock.Clock) *history.WorkflowEvent {
+	if c.state != CommandState_Committed {
+		return nil
+	}
+
+	var event *history.Event
+	switch c.ParentClosePolicy {
+	case core.ParentClosePolicyTerminate:
+		event = history.NewWorkflowTerminationEvent(clock.Now(), parentClosedReason)
+	case core.ParentClosePolicyRequestCancel:
+		event = history.NewWorkflowCancellationEvent(clock.Now())
+	default:
+		return nil
+	}
+
+	return &history.WorkflowEvent{
+		WorkflowInstance: c.Instance,
+		HistoryEvent:     event,
+	}
+}
@@ -75,6 +75,34 @@ func TestScheduleSubWorkflowCommand_StateTransitions(t *testing.T) {
 			c.Done()
 			require.Equal(t, CommandState_Done, c.State())
 		}},
+		{"ParentClosed abandons running subworkflow by default", func(t *testing.T, c *ScheduleSubWorkflowCommand, clock clock.Clock) {
+			c.Commit()
+
+			require.Nil(t, c.ParentClosed(clock))
+		}},
+		{"ParentClosed terminates running subworkflow", func(t *testing.T, c *ScheduleSubWorkflowCommand, clock clock.Clock) {
+			c.ParentClosePolicy = core.ParentClosePolicyTerminate
+			require.Nil(t, c.ParentClosed(clock))
+
+			c.Commit()
+
+			e := c.ParentClosed(clock)
+			require.Equal(t, c.Instance, e.WorkflowInstance)
+			require.Equal(t, history.EventType_WorkflowExecutionTerminated, e.HistoryEvent.Type)
+
+			c.Done()
+			require.Nil(t, c.ParentClosed(clock))
+		}},
+		{"ParentClosed cancels running subworkflow", func(t *testing.T, c *ScheduleSubWorkflowCommand, clock clock.Clock) {
+			c.ParentClosePolicy = core.ParentClosePolicyRequestCancel
+			c.Commit()
+
+			e := c.ParentClosed(clock)
+			require.Equal(t, history.EventType_WorkflowExecutionCanceled, e.HistoryEvent.Type)
+
+			c.ParentClosePolicy = core.ParentClosePolicyAbandon
+			require.Nil(t, c.ParentClosed(clock))
+		}},
 		{"Invalid_HandleCancel", func(t *testing.T, c *ScheduleSubWorkflowCommand, clock clock.Clock) {
 			c.Commit()
 
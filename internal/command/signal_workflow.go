@@ -0,0 +1,74 @@
+package command
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+type SignalWorkflowCommand struct {
+	command
+
+	Instance *core.WorkflowInstance
+
+	Name string
+	Arg  payload.Payload
+}
+
+var _ Command = (*SignalWorkflowCommand)(nil)
+
+func NewSignalWorkflowCommand(id int64, instanceID, name string, arg payload.Payload) *SignalWorkflowCommand {
+	return &SignalWorkflowCommand{
+		command: command{
+			id:    id,
+			name:  "SignalWorkflow",
+			state: CommandState_Pending,
+		},
+
+		Instance: core.NewWorkflowInstance(instanceID, ""),
+
+		Name: name,
+		Arg:  arg,
+	}
+}
+
+func (c *SignalWorkflowCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Committed
+
+		return &CommandResult{
+			// Record the signal request for the source workflow instance
+			Events: []*history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_SignalWorkflowRequested,
+					&history.SignalWorkflowRequestedAttributes{
+						InstanceID: c.Instance.InstanceID,
+						Name:       c.Name,
+						Arg:        c.Arg,
+					},
+					history.ScheduleEventID(c.id),
+				),
+			},
+
+			// Send signal to the target workflow instance
+			WorkflowEvents: []history.WorkflowEvent{
+				{
+					WorkflowInstance: c.Instance,
+					HistoryEvent: history.NewPendingEvent(
+						clock.Now(),
+						history.EventType_SignalReceived,
+						&history.SignalReceivedAttributes{
+							Name: c.Name,
+							Arg:  c.Arg,
+						},
+					),
+				},
+			},
+		}
+	}
+
+	return nil
+}
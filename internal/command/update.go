@@ -0,0 +1,101 @@
+package command
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+)
+
+// UpdateCommand records the outcome of an update request in the history: whether it has been accepted or
+// rejected, and the result of an accepted update once its handler has returned.
+type UpdateCommand struct {
+	command
+
+	UpdateID string
+
+	eventType  history.EventType
+	attributes interface{}
+}
+
+var _ Command = (*UpdateCommand)(nil)
+
+func NewAcceptUpdateCommand(id int64, updateID string) *UpdateCommand {
+	return newUpdateCommand(id, "AcceptUpdate", updateID, history.EventType_UpdateAccepted, &history.UpdateAcceptedAttributes{
+		UpdateID: updateID,
+	})
+}
+
+func NewRejectUpdateCommand(id int64, updateID string, err *workflowerrors.Error) *UpdateCommand {
+	return newUpdateCommand(id, "RejectUpdate", updateID, history.EventType_UpdateRejected, &history.UpdateRejectedAttributes{
+		UpdateID: updateID,
+		Error:    err,
+	})
+}
+
+func NewCompleteUpdateCommand(id int64, updateID string, result payload.Payload, err *workflowerrors.Error) *UpdateCommand {
+	return newUpdateCommand(id, "CompleteUpdate", updateID, history.EventType_UpdateCompleted, &history.UpdateCompletedAttributes{
+		UpdateID: updateID,
+		Result:   result,
+		Error:    err,
+	})
+}
+
+func newUpdateCommand(id int64, name, updateID string, eventType history.EventType, attributes interface{}) *UpdateCommand {
+	return &UpdateCommand{
+		command: command{
+			id:    id,
+			name:  name,
+			state: CommandState_Pending,
+		},
+		UpdateID:   updateID,
+		eventType:  eventType,
+		attributes: attributes,
+	}
+}
+
+// EventType returns the type of the event recorded by this command
+func (c *UpdateCommand) EventType() history.EventType {
+	return c.eventType
+}
+
+func (c *UpdateCommand) Commit() {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
+
+func (c *UpdateCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		// Update outcomes are only added to the history, callers read them from there
+		c.state = CommandState_Done
+
+		return &CommandResult{
+			Events: []*history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					c.eventType,
+					c.attributes,
+					history.ScheduleEventID(c.id),
+				),
+			},
+		}
+	}
+
+	return nil
+}
+
+func (c *UpdateCommand) Done() {
+	switch c.state {
+	case CommandState_Pending, CommandState_Committed:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateCommand_StateTransitions(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  func() *UpdateCommand
+		f    func(t *testing.T, c *UpdateCommand, clock clock.Clock)
+	}{
+		{"Execute records accepted update", func() *UpdateCommand {
+			return NewAcceptUpdateCommand(1, "update-1")
+		}, func(t *testing.T, c *UpdateCommand, clock clock.Clock) {
+			assertExecuteWithEvent(t, c, CommandState_Done, history.EventType_UpdateAccepted)
+		}},
+		{"Execute records rejected update", func() *UpdateCommand {
+			return NewRejectUpdateCommand(1, "update-1", workflowerrors.NewError("", "invalid"))
+		}, func(t *testing.T, c *UpdateCommand, clock clock.Clock) {
+			assertExecuteWithEvent(t, c, CommandState_Done, history.EventType_UpdateRejected)
+		}},
+		{"Execute records completed update", func() *UpdateCommand {
+			return NewCompleteUpdateCommand(1, "update-1", nil, nil)
+		}, func(t *testing.T, c *UpdateCommand, clock clock.Clock) {
+			assertExecuteWithEvent(t, c, CommandState_Done, history.EventType_UpdateCompleted)
+		}},
+		{"Commit", func() *UpdateCommand {
+			return NewAcceptUpdateCommand(1, "update-1")
+		}, func(t *testing.T, c *UpdateCommand, _ clock.Clock) {
+			require.Equal(t, CommandState_Pending, c.State())
+
+			c.Commit()
+			require.Equal(t, CommandState_Done, c.State())
+
+			assertExecuteNoEvent(t, c, CommandState_Done)
+		}},
+		{"Done_after_commit", func() *UpdateCommand {
+			return NewCompleteUpdateCommand(1, "update-1", nil, nil)
+		}, func(t *testing.T, c *UpdateCommand, clock clock.Clock) {
+			c.Commit()
+
+			require.PanicsWithError(t, "invalid state transition for command CompleteUpdate: Done -> Done", func() {
+				c.Done()
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clock.NewMock()
+
+			tt.f(t, tt.cmd(), clock)
+		})
+	}
+}
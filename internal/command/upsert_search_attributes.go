@@ -0,0 +1,74 @@
+package command
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+type UpsertSearchAttributesCommand struct {
+	command
+
+	attributes core.SearchAttributes
+}
+
+var _ Command = (*UpsertSearchAttributesCommand)(nil)
+
+func NewUpsertSearchAttributesCommand(id int64, attributes core.SearchAttributes) *UpsertSearchAttributesCommand {
+	return &UpsertSearchAttributesCommand{
+		command: command{
+			id:    id,
+			name:  "UpsertSearchAttributes",
+			state: CommandState_Pending,
+		},
+		attributes: attributes,
+	}
+}
+
+// Attributes returns the search attributes upserted by the command
+func (c *UpsertSearchAttributesCommand) Attributes() core.SearchAttributes {
+	return c.attributes
+}
+
+func (c *UpsertSearchAttributesCommand) Commit() {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
+
+func (c *UpsertSearchAttributesCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		// Upserted search attributes are only added to the history, backends apply them when checkpointing
+		c.state = CommandState_Done
+
+		return &CommandResult{
+			Events: []*history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_SearchAttributesUpserted,
+					&history.SearchAttributesUpsertedAttributes{
+						SearchAttributes: c.attributes,
+					},
+					history.ScheduleEventID(c.id),
+				),
+			},
+		}
+	}
+
+	return nil
+}
+
+func (c *UpsertSearchAttributesCommand) Done() {
+	switch c.state {
+	case CommandState_Pending, CommandState_Committed:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
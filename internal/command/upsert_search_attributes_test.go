@@ -0,0 +1,44 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertSearchAttributesCommand_StateTransitions(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(t *testing.T, c *UpsertSearchAttributesCommand, clock clock.Clock)
+	}{
+		{"Execute records upserted search attributes", func(t *testing.T, c *UpsertSearchAttributesCommand, clock clock.Clock) {
+			assertExecuteWithEvent(t, c, CommandState_Done, history.EventType_SearchAttributesUpserted)
+		}},
+		{"Commit", func(t *testing.T, c *UpsertSearchAttributesCommand, _ clock.Clock) {
+			require.Equal(t, CommandState_Pending, c.State())
+
+			c.Commit()
+			require.Equal(t, CommandState_Done, c.State())
+
+			assertExecuteNoEvent(t, c, CommandState_Done)
+		}},
+		{"Done_after_commit", func(t *testing.T, c *UpsertSearchAttributesCommand, clock clock.Clock) {
+			c.Commit()
+
+			require.PanicsWithError(t, "invalid state transition for command UpsertSearchAttributes: Done -> Done", func() {
+				c.Done()
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := clock.NewMock()
+			cmd := NewUpsertSearchAttributesCommand(1, core.SearchAttributes{"customer": core.StringSearchAttribute("c1")})
+
+			tt.f(t, cmd, clock)
+		})
+	}
+}
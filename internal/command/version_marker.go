@@ -0,0 +1,76 @@
+package command
+
+import (
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/internal/history"
+)
+
+type VersionMarkerCommand struct {
+	command
+
+	changeID string
+	version  int
+}
+
+var _ Command = (*VersionMarkerCommand)(nil)
+
+func NewVersionMarkerCommand(id int64, changeID string, version int) *VersionMarkerCommand {
+	return &VersionMarkerCommand{
+		command: command{
+			id:    id,
+			name:  "VersionMarker",
+			state: CommandState_Pending,
+		},
+		changeID: changeID,
+		version:  version,
+	}
+}
+
+// ChangeID returns the id of the change the version is recorded for
+func (c *VersionMarkerCommand) ChangeID() string {
+	return c.changeID
+}
+
+func (c *VersionMarkerCommand) Commit() {
+	switch c.state {
+	case CommandState_Pending:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
+
+func (c *VersionMarkerCommand) Execute(clock clock.Clock) *CommandResult {
+	switch c.state {
+	case CommandState_Pending:
+		// Version markers are only added to the history, transition to Done
+		c.state = CommandState_Done
+
+		return &CommandResult{
+			Events: []*history.Event{
+				history.NewPendingEvent(
+					clock.Now(),
+					history.EventType_VersionMarker,
+					&history.VersionMarkerAttributes{
+						ChangeID: c.changeID,
+						Version:  c.version,
+					},
+					history.ScheduleEventID(c.id),
+				),
+			},
+		}
+	}
+
+	return nil
+}
+
+func (c *VersionMarkerCommand) Done() {
+	switch c.state {
+	case CommandState_Pending, CommandState_Committed:
+		c.state = CommandState_Done
+
+	default:
+		c.invalidStateTransition(CommandState_Done)
+	}
+}
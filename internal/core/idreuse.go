@@ -0,0 +1,44 @@
+package core
+
+// IDReusePolicy determines whether a workflow instance can be created with the ID of an existing instance
+type IDReusePolicy int
+
+const (
+	// IDReusePolicyRejectDuplicate rejects creating an instance if an instance with the same ID exists. This is the
+	// default policy.
+	IDReusePolicyRejectDuplicate IDReusePolicy = iota
+
+	// IDReusePolicyAllowDuplicate starts a new execution of an existing instance if it has finished
+	IDReusePolicyAllowDuplicate
+
+	// IDReusePolicyAllowDuplicateFailedOnly starts a new execution of an existing instance if it has failed, or has
+	// been canceled or terminated
+	IDReusePolicyAllowDuplicateFailedOnly
+
+	// IDReusePolicyTerminateIfRunning terminates an existing instance if it's still running, and starts a new
+	// execution
+	IDReusePolicyTerminateIfRunning
+)
+
+// AllowsReuse returns whether a new execution can be started for an existing instance in the given state. failed
+// reports whether the finished execution of the instance failed, it's only called when needed. For
+// IDReusePolicyTerminateIfRunning, active instances need to be terminated before starting a new execution.
+func (p IDReusePolicy) AllowsReuse(state WorkflowInstanceState, failed func() (bool, error)) (bool, error) {
+	switch p {
+	case IDReusePolicyAllowDuplicate:
+		return state == WorkflowInstanceStateFinished, nil
+
+	case IDReusePolicyAllowDuplicateFailedOnly:
+		if state != WorkflowInstanceStateFinished {
+			return false, nil
+		}
+
+		return failed()
+
+	case IDReusePolicyTerminateIfRunning:
+		return true, nil
+
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,16 @@
+package core
+
+// ParentClosePolicy determines what happens to a running sub-workflow instance when its parent workflow instance
+// completes, fails, or continues as new
+type ParentClosePolicy int
+
+const (
+	// ParentClosePolicyAbandon keeps the sub-workflow instance running. This is the default policy.
+	ParentClosePolicyAbandon ParentClosePolicy = iota
+
+	// ParentClosePolicyTerminate terminates the sub-workflow instance, and all of its running sub-workflow instances
+	ParentClosePolicyTerminate
+
+	// ParentClosePolicyRequestCancel cancels the sub-workflow instance, which can still clean up before it returns
+	ParentClosePolicyRequestCancel
+)
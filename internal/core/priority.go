@@ -0,0 +1,19 @@
+package core
+
+import "fmt"
+
+// MinPriority and MaxPriority bound the priorities of workflow and activity tasks. Backends may keep tasks of each
+// priority apart, the fixed range keeps the number of priorities small.
+const (
+	MinPriority = -10
+	MaxPriority = 10
+)
+
+// ValidatePriority returns an error if the given priority is outside of the supported range
+func ValidatePriority(priority int) error {
+	if priority < MinPriority || priority > MaxPriority {
+		return fmt.Errorf("priority %d is out of range, must be between %d and %d", priority, MinPriority, MaxPriority)
+	}
+
+	return nil
+}
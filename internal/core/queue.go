@@ -0,0 +1,17 @@
+package core
+
+// Queue is the name of a queue workflow and activity tasks are routed to. Workers only process tasks from the queues
+// they subscribe to.
+type Queue string
+
+// QueueDefault is the queue of workflow instances and activities which don't specify a queue
+const QueueDefault Queue = "default"
+
+// OrDefault returns the queue, or QueueDefault if no queue is given
+func (q Queue) OrDefault() Queue {
+	if q == "" {
+		return QueueDefault
+	}
+
+	return q
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type SearchAttributeType int
+
+const (
+	SearchAttributeTypeString SearchAttributeType = iota
+	SearchAttributeTypeInt
+	SearchAttributeTypeTime
+	SearchAttributeTypeBool
+	SearchAttributeTypeKeywords
+)
+
+func (t SearchAttributeType) String() string {
+	switch t {
+	case SearchAttributeTypeString:
+		return "string"
+	case SearchAttributeTypeInt:
+		return "int"
+	case SearchAttributeTypeTime:
+		return "time"
+	case SearchAttributeTypeBool:
+		return "bool"
+	case SearchAttributeTypeKeywords:
+		return "keywords"
+	default:
+		return "unknown"
+	}
+}
+
+func parseSearchAttributeType(s string) (SearchAttributeType, error) {
+	for t := SearchAttributeTypeString; t <= SearchAttributeTypeKeywords; t++ {
+		if t.String() == s {
+			return t, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown search attribute type %q", s)
+}
+
+// SearchAttributes are typed attributes of a workflow instance which can be used to find instances
+type SearchAttributes map[string]SearchAttributeValue
+
+// Upsert returns a copy of the search attributes, with the given attributes added or replaced
+func (sa SearchAttributes) Upsert(attributes SearchAttributes) SearchAttributes {
+	r := make(SearchAttributes, len(sa)+len(attributes))
+
+	for k, v := range sa {
+		r[k] = v
+	}
+
+	for k, v := range attributes {
+		r[k] = v
+	}
+
+	return r
+}
+
+// Matches returns whether the search attributes match all of the given attributes. See SearchAttributeValue.Matches.
+func (sa SearchAttributes) Matches(attributes SearchAttributes) bool {
+	for k, v := range attributes {
+		stored, ok := sa[k]
+		if !ok || !stored.Matches(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SearchAttributeValue is a typed search attribute value. Create values using StringSearchAttribute,
+// IntSearchAttribute, TimeSearchAttribute, BoolSearchAttribute, or KeywordsSearchAttribute.
+type SearchAttributeValue struct {
+	t SearchAttributeType
+
+	s  string
+	i  int64
+	tm time.Time
+	b  bool
+	kw []string
+}
+
+func StringSearchAttribute(v string) SearchAttributeValue {
+	return SearchAttributeValue{t: SearchAttributeTypeString, s: v}
+}
+
+func IntSearchAttribute(v int64) SearchAttributeValue {
+	return SearchAttributeValue{t: SearchAttributeTypeInt, i: v}
+}
+
+func TimeSearchAttribute(v time.Time) SearchAttributeValue {
+	return SearchAttributeValue{t: SearchAttributeTypeTime, tm: v.Round(0).UTC()}
+}
+
+func BoolSearchAttribute(v bool) SearchAttributeValue {
+	return SearchAttributeValue{t: SearchAttributeTypeBool, b: v}
+}
+
+// KeywordsSearchAttribute returns a keyword list. Instances match a keyword list filter if they have all of its
+// keywords.
+func KeywordsSearchAttribute(v ...string) SearchAttributeValue {
+	return SearchAttributeValue{t: SearchAttributeTypeKeywords, kw: append([]string{}, v...)}
+}
+
+func (v SearchAttributeValue) Type() SearchAttributeType {
+	return v.t
+}
+
+// Value returns the value as string, int64, time.Time, bool, or []string, depending on its type
+func (v SearchAttributeValue) Value() interface{} {
+	switch v.t {
+	case SearchAttributeTypeInt:
+		return v.i
+	case SearchAttributeTypeTime:
+		return v.tm
+	case SearchAttributeTypeBool:
+		return v.b
+	case SearchAttributeTypeKeywords:
+		return append([]string{}, v.kw...)
+	default:
+		return v.s
+	}
+}
+
+// IndexValues returns the canonical string representations of the value used by backends to index it. Keyword
+// lists are indexed by each of their keywords.
+func (v SearchAttributeValue) IndexValues() []string {
+	switch v.t {
+	case SearchAttributeTypeInt:
+		return []string{strconv.FormatInt(v.i, 10)}
+	case SearchAttributeTypeTime:
+		return []string{v.tm.Format(time.RFC3339Nano)}
+	case SearchAttributeTypeBool:
+		return []string{strconv.FormatBool(v.b)}
+	case SearchAttributeTypeKeywords:
+		return append([]string{}, v.kw...)
+	default:
+		return []string{v.s}
+	}
+}
+
+// Matches returns whether the value matches the given filter value. Values match if they have the same type and
+// value, a keyword list matches if it contains all keywords of the filter.
+func (v SearchAttributeValue) Matches(filter SearchAttributeValue) bool {
+	if v.t != filter.t {
+		return false
+	}
+
+	values := v.IndexValues()
+
+	for _, fv := range filter.IndexValues() {
+		found := false
+		for _, v := range values {
+			if v == fv {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+type searchAttributeValueJSON struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (v SearchAttributeValue) MarshalJSON() ([]byte, error) {
+	value, err := json.Marshal(v.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&searchAttributeValueJSON{
+		Type:  v.t.String(),
+		Value: value,
+	})
+}
+
+func (v *SearchAttributeValue) UnmarshalJSON(data []byte) error {
+	var j searchAttributeValueJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	t, err := parseSearchAttributeType(j.Type)
+	if err != nil {
+		return err
+	}
+
+	*v = SearchAttributeValue{t: t}
+
+	switch t {
+	case SearchAttributeTypeInt:
+		err = json.Unmarshal(j.Value, &v.i)
+	case SearchAttributeTypeTime:
+		err = json.Unmarshal(j.Value, &v.tm)
+		v.tm = v.tm.UTC()
+	case SearchAttributeTypeBool:
+		err = json.Unmarshal(j.Value, &v.b)
+	case SearchAttributeTypeKeywords:
+		err = json.Unmarshal(j.Value, &v.kw)
+	default:
+		err = json.Unmarshal(j.Value, &v.s)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unmarshaling %v search attribute: %w", t, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package core
+
+// ExecutionTimeoutAction determines what happens to a workflow instance which exceeds its execution timeout
+type ExecutionTimeoutAction int
+
+const (
+	// ExecutionTimeoutActionTerminate completes the instance with an ExecutionTimeoutError without running any more
+	// workflow code. This is the default action.
+	ExecutionTimeoutActionTerminate ExecutionTimeoutAction = iota
+
+	// ExecutionTimeoutActionCancel cancels the context of the workflow, which can still clean up before it returns.
+	// Errors returned by the workflow are wrapped in an ExecutionTimeoutError.
+	ExecutionTimeoutActionCancel
+)
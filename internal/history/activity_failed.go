@@ -1,5 +1,16 @@
 package history
 
+import (
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+)
+
 type ActivityFailedAttributes struct {
-	Reason string `json:"reason,omitempty"`
+	Error *workflowerrors.Error `json:"error,omitempty"`
+
+	// TimeoutType is set if the activity failed because it exceeded one of its timeouts
+	TimeoutType workflowerrors.TimeoutType `json:"timeout_type,omitempty"`
+
+	// HeartbeatDetails are the last details the activity recorded before it failed
+	HeartbeatDetails payload.Payload `json:"heartbeat_details,omitempty"`
 }
@@ -1,8 +1,11 @@
 package history
 
 import (
+	"time"
+
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
 )
 
 type ActivityScheduledAttributes struct {
@@ -11,4 +14,62 @@ type ActivityScheduledAttributes struct {
 	Inputs []payload.Payload `json:"inputs,omitempty"`
 
 	Metadata core.WorkflowMetadata `json:"metadata,omitempty"`
+
+	Timeouts *ActivityTimeouts `json:"timeouts,omitempty"`
+
+	// Queue is the queue the activity task is routed to
+	Queue core.Queue `json:"queue,omitempty"`
+
+	// Priority of the activity task, tasks with a higher priority are handed out first
+	Priority int `json:"priority,omitempty"`
+
+	// HeartbeatDetails are the last details recorded by a previous attempt of this activity
+	HeartbeatDetails payload.Payload `json:"heartbeat_details,omitempty"`
+}
+
+// ActivityTimeouts are the timeouts for a single scheduled activity. A zero value disables the respective timeout.
+type ActivityTimeouts struct {
+	ScheduleToClose time.Duration `json:"schedule_to_close,omitempty"`
+	StartToClose    time.Duration `json:"start_to_close,omitempty"`
+	Heartbeat       time.Duration `json:"heartbeat,omitempty"`
+}
+
+// Deadline returns the earliest point in time at which the activity times out, together with the type of that
+// timeout. startedAt is nil if no worker has started executing the activity, yet. lastHeartbeatAt is nil if the
+// running activity has not sent a heartbeat, yet. If none of the timeouts apply, a zero time is returned.
+func (t *ActivityTimeouts) Deadline(scheduledAt time.Time, startedAt, lastHeartbeatAt *time.Time) (time.Time, workflowerrors.TimeoutType) {
+	var deadline time.Time
+	var timeoutType workflowerrors.TimeoutType
+
+	if t == nil {
+		return deadline, timeoutType
+	}
+
+	consider := func(d time.Time, tt workflowerrors.TimeoutType) {
+		if deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+			timeoutType = tt
+		}
+	}
+
+	if t.ScheduleToClose > 0 {
+		consider(scheduledAt.Add(t.ScheduleToClose), workflowerrors.TimeoutTypeScheduleToClose)
+	}
+
+	if startedAt != nil {
+		if t.StartToClose > 0 {
+			consider(startedAt.Add(t.StartToClose), workflowerrors.TimeoutTypeStartToClose)
+		}
+
+		if t.Heartbeat > 0 {
+			lastHeartbeat := *startedAt
+			if lastHeartbeatAt != nil && lastHeartbeatAt.After(lastHeartbeat) {
+				lastHeartbeat = *lastHeartbeatAt
+			}
+
+			consider(lastHeartbeat.Add(t.Heartbeat), workflowerrors.TimeoutTypeHeartbeat)
+		}
+	}
+
+	return deadline, timeoutType
 }
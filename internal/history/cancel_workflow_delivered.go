@@ -0,0 +1,4 @@
+package history
+
+type CancelWorkflowDeliveredAttributes struct {
+}
@@ -0,0 +1,7 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/workflowerrors"
+
+type CancelWorkflowFailedAttributes struct {
+	Error *workflowerrors.Error `json:"error,omitempty"`
+}
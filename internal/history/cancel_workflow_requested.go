@@ -0,0 +1,5 @@
+package history
+
+type CancelWorkflowRequestedAttributes struct {
+	InstanceID string `json:"instance_id,omitempty"`
+}
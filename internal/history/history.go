@@ -1,9 +1,12 @@
 package history
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
 	"github.com/google/uuid"
 )
 
@@ -16,7 +19,7 @@ const (
 	EventType_WorkflowExecutionStarted
 	// Workflow has finished
 	EventType_WorkflowExecutionFinished
-	// Workflow has been terminated (not yet used)
+	// Workflow has been terminated
 	EventType_WorkflowExecutionTerminated
 	// Workflow has been canceled
 	EventType_WorkflowExecutionCanceled
@@ -53,6 +56,44 @@ const (
 
 	// Recorded result of a side-efect
 	EventType_SideEffectResult
+
+	// Workflow has completed and continued as a new execution
+	EventType_WorkflowExecutionContinuedAsNew
+
+	// Recorded version of a change in workflow code
+	EventType_VersionMarker
+
+	// Search attributes of the workflow instance have been added or updated
+	EventType_SearchAttributesUpserted
+
+	// Workflow has received an update request
+	EventType_UpdateRequested
+	// Update has passed validation and is being handled
+	EventType_UpdateAccepted
+	// Update has been rejected by its validator, or no handler was registered for it
+	EventType_UpdateRejected
+	// Update handler has returned
+	EventType_UpdateCompleted
+
+	// Workflow has exceeded its execution timeout
+	EventType_WorkflowExecutionTimedOut
+
+	// Signal to another workflow instance has been requested
+	EventType_SignalWorkflowRequested
+	// Signal has been delivered to the other workflow instance
+	EventType_SignalWorkflowDelivered
+	// Signal could not be delivered to the other workflow instance
+	EventType_SignalWorkflowFailed
+
+	// Cancellation of another workflow instance has been requested
+	EventType_CancelWorkflowRequested
+	// Cancellation has been delivered to the other workflow instance
+	EventType_CancelWorkflowDelivered
+	// Cancellation could not be delivered to the other workflow instance
+	EventType_CancelWorkflowFailed
+
+	// Local activity has been executed by the workflow worker, records its result
+	EventType_LocalActivityResult
 )
 
 func (et EventType) String() string {
@@ -65,6 +106,8 @@ func (et EventType) String() string {
 		return "WorkflowExecutionTerminated"
 	case EventType_WorkflowExecutionCanceled:
 		return "WorkflowExecutionCanceled"
+	case EventType_WorkflowExecutionTimedOut:
+		return "WorkflowExecutionTimedOut"
 
 	case EventType_WorkflowTaskStarted:
 		return "WorkflowTaskStarted"
@@ -95,9 +138,44 @@ func (et EventType) String() string {
 	case EventType_SignalReceived:
 		return "SignalReceived"
 
+	case EventType_SignalWorkflowRequested:
+		return "SignalWorkflowRequested"
+	case EventType_SignalWorkflowDelivered:
+		return "SignalWorkflowDelivered"
+	case EventType_SignalWorkflowFailed:
+		return "SignalWorkflowFailed"
+
+	case EventType_CancelWorkflowRequested:
+		return "CancelWorkflowRequested"
+	case EventType_CancelWorkflowDelivered:
+		return "CancelWorkflowDelivered"
+	case EventType_CancelWorkflowFailed:
+		return "CancelWorkflowFailed"
+
+	case EventType_LocalActivityResult:
+		return "LocalActivityResult"
+
 	case EventType_SideEffectResult:
 		return "SideEffectResult"
 
+	case EventType_WorkflowExecutionContinuedAsNew:
+		return "WorkflowExecutionContinuedAsNew"
+
+	case EventType_VersionMarker:
+		return "VersionMarker"
+
+	case EventType_SearchAttributesUpserted:
+		return "SearchAttributesUpserted"
+
+	case EventType_UpdateRequested:
+		return "UpdateRequested"
+	case EventType_UpdateAccepted:
+		return "UpdateAccepted"
+	case EventType_UpdateRejected:
+		return "UpdateRejected"
+	case EventType_UpdateCompleted:
+		return "UpdateCompleted"
+
 	default:
 		return "Unknown"
 	}
@@ -167,3 +245,123 @@ func NewPendingEvent(timestamp time.Time, eventType EventType, attributes interf
 func NewWorkflowCancellationEvent(timestamp time.Time) *Event {
 	return NewPendingEvent(timestamp, EventType_WorkflowExecutionCanceled, &ExecutionCanceledAttributes{})
 }
+
+func NewWorkflowTerminationEvent(timestamp time.Time, reason string) *Event {
+	return NewPendingEvent(timestamp, EventType_WorkflowExecutionTerminated, &ExecutionTerminatedAttributes{
+		Reason: reason,
+	})
+}
+
+// NewWorkflowTimeoutTerminationEvent creates the event terminating a workflow instance which exceeded its execution
+// timeout. The instance fails with an ExecutionTimeoutError.
+func NewWorkflowTimeoutTerminationEvent(timestamp time.Time) *Event {
+	return NewPendingEvent(timestamp, EventType_WorkflowExecutionTerminated, &ExecutionTerminatedAttributes{
+		Reason: "execution timeout exceeded",
+		Error:  workflowerrors.FromError(nil, workflowerrors.NewExecutionTimeoutError(nil)),
+	})
+}
+
+// NewExecutionTimeoutEvent creates the pending event timing out the workflow instance started by the given event, once
+// its execution timeout is exceeded. The timeout starts when the instance is started, for instances with a delayed
+// start when the start becomes visible. If no execution timeout is configured, nil is returned.
+func NewExecutionTimeoutEvent(startedEvent *Event) *Event {
+	a := startedEvent.Attributes.(*ExecutionStartedAttributes)
+	if a.ExecutionTimeout <= 0 {
+		return nil
+	}
+
+	start := startedEvent.Timestamp
+	if startedEvent.VisibleAt != nil {
+		start = *startedEvent.VisibleAt
+	}
+
+	return NewPendingEvent(startedEvent.Timestamp, EventType_WorkflowExecutionTimedOut, &ExecutionTimedOutAttributes{
+		Action: a.ExecutionTimeoutAction,
+	}, VisibleAt(start.Add(a.ExecutionTimeout)))
+}
+
+// ExecutionFailed returns whether an execution whose history ends with the given event has failed, has been canceled,
+// or has been terminated.
+func ExecutionFailed(lastEvent *Event) bool {
+	switch lastEvent.Type {
+	case EventType_WorkflowExecutionTerminated:
+		return true
+
+	case EventType_WorkflowExecutionFinished:
+		return lastEvent.Attributes.(*ExecutionCompletedAttributes).Error != nil
+	}
+
+	return false
+}
+
+// NewSubWorkflowTerminatedEvent creates the event notifying a parent workflow instance that one of its sub-workflow
+// instances, created by the event with the given parentEventID, has been terminated by the event with the given
+// attributes. The sub-workflow fails with the error recorded for the termination, if any.
+func NewSubWorkflowTerminatedEvent(timestamp time.Time, parentEventID int64, a *ExecutionTerminatedAttributes) *Event {
+	err := a.Error
+	if err == nil {
+		err = workflowerrors.NewError("SubWorkflowTerminated", fmt.Sprintf("sub-workflow terminated: %s", a.Reason))
+	}
+
+	return NewPendingEvent(timestamp, EventType_SubWorkflowFailed, &SubWorkflowFailedAttributes{
+		Error: err,
+	}, ScheduleEventID(parentEventID))
+}
+
+// NewActivityTimedOutEvent creates the event failing the activity scheduled by the event with the given
+// scheduleEventID, because it exceeded the timeout of the given type. heartbeatDetails are the last details
+// recorded by the activity, if any.
+func NewActivityTimedOutEvent(
+	timestamp time.Time, scheduleEventID int64, timeoutType workflowerrors.TimeoutType, heartbeatDetails payload.Payload,
+) *Event {
+	return NewPendingEvent(timestamp, EventType_ActivityFailed, &ActivityFailedAttributes{
+		Error:            workflowerrors.FromError(nil, workflowerrors.NewActivityTimeoutError(timeoutType)),
+		TimeoutType:      timeoutType,
+		HeartbeatDetails: heartbeatDetails,
+	}, ScheduleEventID(scheduleEventID))
+}
+
+// ExternalWorkflowRequestTarget returns the ID of the workflow instance targeted by the given request to signal or
+// cancel another workflow instance. If the event is not such a request, false is returned.
+func ExternalWorkflowRequestTarget(event *Event) (string, bool) {
+	switch a := event.Attributes.(type) {
+	case *SignalWorkflowRequestedAttributes:
+		return a.InstanceID, true
+	case *CancelWorkflowRequestedAttributes:
+		return a.InstanceID, true
+	}
+
+	return "", false
+}
+
+// NewExternalWorkflowResultEvent creates the event completing the given request to signal or cancel another workflow
+// instance. If err is nil, the request has been delivered, otherwise it has failed with err.
+func NewExternalWorkflowResultEvent(timestamp time.Time, requestEvent *Event, err error) *Event {
+	var eventType EventType
+	var attributes interface{}
+
+	switch requestEvent.Type {
+	case EventType_SignalWorkflowRequested:
+		if err == nil {
+			eventType, attributes = EventType_SignalWorkflowDelivered, &SignalWorkflowDeliveredAttributes{}
+		} else {
+			eventType, attributes = EventType_SignalWorkflowFailed, &SignalWorkflowFailedAttributes{
+				Error: workflowerrors.NewPermanentError(err),
+			}
+		}
+
+	case EventType_CancelWorkflowRequested:
+		if err == nil {
+			eventType, attributes = EventType_CancelWorkflowDelivered, &CancelWorkflowDeliveredAttributes{}
+		} else {
+			eventType, attributes = EventType_CancelWorkflowFailed, &CancelWorkflowFailedAttributes{
+				Error: workflowerrors.NewPermanentError(err),
+			}
+		}
+
+	default:
+		panic(fmt.Sprintf("unexpected external workflow request event %v", requestEvent.Type))
+	}
+
+	return NewPendingEvent(timestamp, eventType, attributes, ScheduleEventID(requestEvent.ScheduleEventID))
+}
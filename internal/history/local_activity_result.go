@@ -0,0 +1,24 @@
+package history
+
+import (
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+)
+
+type LocalActivityResultAttributes struct {
+	Name string `json:"name,omitempty"`
+
+	Inputs []payload.Payload `json:"inputs,omitempty"`
+
+	Result payload.Payload `json:"result,omitempty"`
+
+	// Error is set if the last attempt of the local activity failed
+	Error *workflowerrors.Error `json:"error,omitempty"`
+
+	// Attempts is the number of attempts executed before the result was recorded
+	Attempts int `json:"attempts,omitempty"`
+
+	// Deferred is set if the workflow task ran out of time before the local activity completed. The local activity is
+	// executed again in a later workflow task, after a timer.
+	Deferred bool `json:"deferred,omitempty"`
+}
@@ -38,8 +38,14 @@ func DeserializeAttributes(eventType EventType, attributes []byte) (attr interfa
 		attr = &ExecutionStartedAttributes{}
 	case EventType_WorkflowExecutionFinished:
 		attr = &ExecutionCompletedAttributes{}
+	case EventType_WorkflowExecutionTerminated:
+		attr = &ExecutionTerminatedAttributes{}
 	case EventType_WorkflowExecutionCanceled:
 		attr = &ExecutionCanceledAttributes{}
+	case EventType_WorkflowExecutionTimedOut:
+		attr = &ExecutionTimedOutAttributes{}
+	case EventType_WorkflowExecutionContinuedAsNew:
+		attr = &ExecutionContinuedAsNewAttributes{}
 
 	case EventType_WorkflowTaskStarted:
 		attr = &WorkflowTaskStartedAttributes{}
@@ -54,9 +60,41 @@ func DeserializeAttributes(eventType EventType, attributes []byte) (attr interfa
 	case EventType_SignalReceived:
 		attr = &SignalReceivedAttributes{}
 
+	case EventType_SignalWorkflowRequested:
+		attr = &SignalWorkflowRequestedAttributes{}
+	case EventType_SignalWorkflowDelivered:
+		attr = &SignalWorkflowDeliveredAttributes{}
+	case EventType_SignalWorkflowFailed:
+		attr = &SignalWorkflowFailedAttributes{}
+
+	case EventType_CancelWorkflowRequested:
+		attr = &CancelWorkflowRequestedAttributes{}
+	case EventType_CancelWorkflowDelivered:
+		attr = &CancelWorkflowDeliveredAttributes{}
+	case EventType_CancelWorkflowFailed:
+		attr = &CancelWorkflowFailedAttributes{}
+
+	case EventType_LocalActivityResult:
+		attr = &LocalActivityResultAttributes{}
+
 	case EventType_SideEffectResult:
 		attr = &SideEffectResultAttributes{}
 
+	case EventType_VersionMarker:
+		attr = &VersionMarkerAttributes{}
+
+	case EventType_SearchAttributesUpserted:
+		attr = &SearchAttributesUpsertedAttributes{}
+
+	case EventType_UpdateRequested:
+		attr = &UpdateRequestedAttributes{}
+	case EventType_UpdateAccepted:
+		attr = &UpdateAcceptedAttributes{}
+	case EventType_UpdateRejected:
+		attr = &UpdateRejectedAttributes{}
+	case EventType_UpdateCompleted:
+		attr = &UpdateCompletedAttributes{}
+
 	case EventType_TimerScheduled:
 		attr = &TimerScheduledAttributes{}
 	case EventType_TimerFired:
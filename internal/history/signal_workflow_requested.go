@@ -0,0 +1,9 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+type SignalWorkflowRequestedAttributes struct {
+	InstanceID string          `json:"instance_id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Arg        payload.Payload `json:"arg,omitempty"`
+}
@@ -13,4 +13,7 @@ type SubWorkflowScheduledAttributes struct {
 	Inputs []payload.Payload `json:"inputs,omitempty"`
 
 	Metadata *core.WorkflowMetadata `json:"metadata,omitempty"`
+
+	// ParentClosePolicy determines what happens to the sub-workflow instance when the workflow completes
+	ParentClosePolicy core.ParentClosePolicy `json:"parent_close_policy,omitempty"`
 }
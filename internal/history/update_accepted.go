@@ -0,0 +1,5 @@
+package history
+
+type UpdateAcceptedAttributes struct {
+	UpdateID string `json:"update_id,omitempty"`
+}
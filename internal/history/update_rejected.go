@@ -0,0 +1,8 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/workflowerrors"
+
+type UpdateRejectedAttributes struct {
+	UpdateID string                `json:"update_id,omitempty"`
+	Error    *workflowerrors.Error `json:"error,omitempty"`
+}
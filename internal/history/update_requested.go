@@ -0,0 +1,9 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+type UpdateRequestedAttributes struct {
+	UpdateID string          `json:"update_id,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Arg      payload.Payload `json:"arg,omitempty"`
+}
@@ -0,0 +1,6 @@
+package history
+
+type VersionMarkerAttributes struct {
+	ChangeID string `json:"change_id,omitempty"`
+	Version  int    `json:"version"`
+}
@@ -1,8 +1,11 @@
 package history
 
-import "github.com/cschleiden/go-workflows/internal/payload"
+import (
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+)
 
 type ExecutionCompletedAttributes struct {
-	Result payload.Payload `json:"result,omitempty"`
-	Error  string          `json:"error,omitempty"`
+	Result payload.Payload       `json:"result,omitempty"`
+	Error  *workflowerrors.Error `json:"error,omitempty"`
 }
@@ -0,0 +1,10 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/payload"
+
+type ExecutionContinuedAsNewAttributes struct {
+	// ContinuedExecutionID is the execution id of the new execution
+	ContinuedExecutionID string `json:"continued_execution_id,omitempty"`
+
+	Inputs []payload.Payload `json:"inputs,omitempty"`
+}
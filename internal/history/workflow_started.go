@@ -1,6 +1,8 @@
 package history
 
 import (
+	"time"
+
 	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/payload"
 )
@@ -10,5 +12,22 @@ type ExecutionStartedAttributes struct {
 
 	Metadata *core.WorkflowMetadata `json:"metadata,omitempty"`
 
+	SearchAttributes core.SearchAttributes `json:"search_attributes,omitempty"`
+
 	Inputs []payload.Payload `json:"inputs,omitempty"`
+
+	// IDReusePolicy determines whether the instance can be created if an instance with the same ID exists
+	IDReusePolicy core.IDReusePolicy `json:"id_reuse_policy,omitempty"`
+
+	// ExecutionTimeout bounds the time the instance can run, including executions it has been continued as
+	ExecutionTimeout time.Duration `json:"execution_timeout,omitempty"`
+
+	// ExecutionTimeoutAction determines what happens when the execution timeout is exceeded
+	ExecutionTimeoutAction core.ExecutionTimeoutAction `json:"execution_timeout_action,omitempty"`
+
+	// Queue is the queue workflow tasks of the instance are routed to
+	Queue core.Queue `json:"queue,omitempty"`
+
+	// Priority of workflow tasks of the instance, tasks with a higher priority are handed out first
+	Priority int `json:"priority,omitempty"`
 }
@@ -0,0 +1,11 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/workflowerrors"
+
+type ExecutionTerminatedAttributes struct {
+	Reason string `json:"reason,omitempty"`
+
+	// Error is the error the instance fails with, if it has been terminated by the backend, e.g., because it exceeded
+	// its execution timeout
+	Error *workflowerrors.Error `json:"error,omitempty"`
+}
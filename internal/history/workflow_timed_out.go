@@ -0,0 +1,7 @@
+package history
+
+import "github.com/cschleiden/go-workflows/internal/core"
+
+type ExecutionTimedOutAttributes struct {
+	Action core.ExecutionTimeoutAction `json:"action,omitempty"`
+}
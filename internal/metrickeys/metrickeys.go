@@ -18,6 +18,9 @@ const (
 	ActivityTaskScheduled = Prefix + "activity.task.scheduled"
 	ActivityTaskProcessed = Prefix + "activity.task.processed"
 	ActivityTaskDelay     = Prefix + "activity.task.time_in_queue"
+
+	// Schedules
+	ScheduleRunStarted = Prefix + "schedule.run.started"
 )
 
 // Tag names
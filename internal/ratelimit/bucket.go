@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Bucket is a token bucket which is refilled continuously with a fixed rate of tokens per second
+type Bucket struct {
+	// Tokens is the number of tokens in the bucket at UpdatedAt
+	Tokens float64
+
+	// UpdatedAt is the time the number of tokens was last updated
+	UpdatedAt time.Time
+}
+
+// NewBucket returns a full bucket
+func NewBucket(burst int, now time.Time) Bucket {
+	return Bucket{
+		Tokens:    float64(burst),
+		UpdatedAt: now,
+	}
+}
+
+// Take refills the bucket with rate tokens per second up to burst tokens, and takes a token. If no token is
+// available, the bucket is not modified and the time until the next token is available is returned.
+func (b *Bucket) Take(now time.Time, rate float64, burst int) time.Duration {
+	if burst < 1 {
+		burst = 1
+	}
+
+	tokens := b.Tokens
+	if elapsed := now.Sub(b.UpdatedAt); elapsed > 0 {
+		tokens += elapsed.Seconds() * rate
+	}
+
+	if tokens > float64(burst) {
+		tokens = float64(burst)
+	}
+
+	if tokens < 1 {
+		wait := time.Duration(math.Ceil((1 - tokens) / rate * float64(time.Second)))
+		if wait <= 0 {
+			wait = time.Millisecond
+		}
+
+		return wait
+	}
+
+	b.Tokens = tokens - 1
+	if now.After(b.UpdatedAt) {
+		b.UpdatedAt = now
+	}
+
+	return 0
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Take(t *testing.T) {
+	now := time.Now()
+
+	b := NewBucket(2, now)
+
+	// Burst
+	require.Zero(t, b.Take(now, 1, 2))
+	require.Zero(t, b.Take(now, 1, 2))
+
+	wait := b.Take(now, 1, 2)
+	require.Equal(t, time.Second, wait)
+
+	// Refilled after waiting
+	require.Zero(t, b.Take(now.Add(wait), 1, 2))
+
+	// Refills up to burst
+	now = now.Add(time.Hour)
+	require.Zero(t, b.Take(now, 1, 2))
+	require.Zero(t, b.Take(now, 1, 2))
+	require.Equal(t, time.Second, b.Take(now, 1, 2))
+}
+
+func TestBucket_TakeFractionalRate(t *testing.T) {
+	now := time.Now()
+
+	b := NewBucket(1, now)
+	require.Zero(t, b.Take(now, 0.5, 1))
+	require.Equal(t, 2*time.Second, b.Take(now, 0.5, 1))
+	require.Equal(t, time.Second, b.Take(now.Add(time.Second), 0.5, 1))
+	require.Zero(t, b.Take(now.Add(2*time.Second), 0.5, 1))
+}
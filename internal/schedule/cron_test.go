@@ -0,0 +1,80 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseCron_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@every",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := parseCron(expr)
+			require.Error(t, err)
+		})
+	}
+}
+
+func Test_cronExpression_next(t *testing.T) {
+	start := time.Date(2022, 6, 15, 10, 30, 0, 0, time.UTC) // Wednesday
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2022, 6, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2022, 6, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2022, 6, 16, 0, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2022, 6, 16, 10, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2022, 6, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 8,12 * * *", time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * MON", time.Date(2022, 6, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2022, 6, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * JAN *", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Day of month and day of week are combined with OR if both are restricted
+		{"0 0 20 * 5", time.Date(2022, 6, 17, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2022, 6, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2022, 6, 19, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := parseCron(tt.expr)
+			require.NoError(t, err)
+
+			got, err := c.next(start)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_cronExpression_next_NoMatch(t *testing.T) {
+	c, err := parseCron("0 0 30 2 *")
+	require.NoError(t, err)
+
+	_, err = c.next(time.Now())
+	require.ErrorIs(t, err, errNoCronTime)
+}
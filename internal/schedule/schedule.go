@@ -0,0 +1,247 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/google/uuid"
+)
+
+// OverlapPolicy determines what happens when a schedule is due while the workflow instance started for a
+// previous run is still running.
+type OverlapPolicy int
+
+const (
+	// OverlapPolicySkip skips runs while the previous instance is still running
+	OverlapPolicySkip OverlapPolicy = iota
+
+	// OverlapPolicyBufferOne buffers one run while the previous instance is still running, and starts it once
+	// the previous instance has finished. Any further runs are skipped.
+	OverlapPolicyBufferOne
+
+	// OverlapPolicyAllowAll starts every run, regardless of any running instances
+	OverlapPolicyAllowAll
+)
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapPolicySkip:
+		return "Skip"
+	case OverlapPolicyBufferOne:
+		return "BufferOne"
+	case OverlapPolicyAllowAll:
+		return "AllowAll"
+	default:
+		return "Unknown"
+	}
+}
+
+// Spec determines when a schedule starts workflow instances. Exactly one of Cron and Interval has to be set.
+type Spec struct {
+	// Cron is a cron expression with the five fields minute, hour, day of month, month, and day of week, or one of
+	// the descriptors @yearly, @monthly, @weekly, @daily, and @hourly. Expressions are evaluated in UTC.
+	Cron string `json:"cron,omitempty"`
+
+	// Interval starts a workflow instance every interval. Runs are aligned to the Unix epoch, so an interval of
+	// one hour starts an instance at the beginning of every hour.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// OverlapPolicy determines what happens if a run is due while a previous instance is still running
+	OverlapPolicy OverlapPolicy `json:"overlap_policy,omitempty"`
+}
+
+// Validate returns an error if the spec is not valid.
+func (s Spec) Validate() error {
+	if s.Cron == "" && s.Interval == 0 {
+		return errors.New("either cron or interval is required")
+	}
+
+	if s.Cron != "" && s.Interval != 0 {
+		return errors.New("only one of cron and interval can be set")
+	}
+
+	if s.Cron != "" {
+		c, err := parseCron(s.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression %q: %w", s.Cron, err)
+		}
+
+		if _, err := c.next(time.Now()); err != nil {
+			return fmt.Errorf("invalid cron expression %q: %w", s.Cron, err)
+		}
+	}
+
+	if s.Interval != 0 && s.Interval < time.Second {
+		return errors.New("interval must be at least one second")
+	}
+
+	switch s.OverlapPolicy {
+	case OverlapPolicySkip, OverlapPolicyBufferOne, OverlapPolicyAllowAll:
+	default:
+		return fmt.Errorf("unknown overlap policy %d", s.OverlapPolicy)
+	}
+
+	return nil
+}
+
+var unixEpoch = time.Unix(0, 0).UTC()
+
+// Next returns the first run of the spec after t. For an invalid spec, the zero time is returned. Runs of intervals are
+// aligned to the Unix epoch, e.g., an interval of one week runs on Thursdays at midnight UTC.
+func (s Spec) Next(t time.Time) time.Time {
+	if s.Interval > 0 {
+		offset := t.Sub(unixEpoch) % s.Interval
+		if offset < 0 {
+			offset += s.Interval
+		}
+
+		return t.UTC().Add(s.Interval - offset)
+	}
+
+	c, err := parseCron(s.Cron)
+	if err != nil {
+		return time.Time{}
+	}
+
+	n, err := c.next(t)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return n
+}
+
+// Runs returns all runs of the spec in [start, end).
+func (s Spec) Runs(start, end time.Time) []time.Time {
+	var runs []time.Time
+
+	for at := s.Next(start.Add(-time.Nanosecond)); !at.IsZero() && at.Before(end); at = s.Next(at) {
+		runs = append(runs, at)
+	}
+
+	return runs
+}
+
+// Schedule starts instances of a workflow according to its spec.
+type Schedule struct {
+	ID string `json:"id,omitempty"`
+
+	Spec Spec `json:"spec,omitempty"`
+
+	WorkflowName string `json:"workflow_name,omitempty"`
+
+	Inputs []payload.Payload `json:"inputs,omitempty"`
+
+	// Paused schedules do not start any workflow instances
+	Paused bool `json:"paused,omitempty"`
+
+	CreatedAt time.Time `json:"created_at,omitempty"`
+
+	// NextRunAt is the time of the next run of the schedule
+	NextRunAt time.Time `json:"next_run_at,omitempty"`
+
+	// BufferedRunAt is the time of a run buffered by OverlapPolicyBufferOne, if any
+	BufferedRunAt *time.Time `json:"buffered_run_at,omitempty"`
+
+	// LastInstance is the workflow instance started most recently by the schedule
+	LastInstance *core.WorkflowInstance `json:"last_instance,omitempty"`
+
+	// DueAt is the time at which the schedule needs to be processed next. This is NextRunAt, unless a buffered run
+	// is waiting for the previous instance to finish.
+	DueAt time.Time `json:"due_at,omitempty"`
+}
+
+func NewSchedule(id string, spec Spec, workflowName string, inputs []payload.Payload, now time.Time) *Schedule {
+	nextRunAt := spec.Next(now)
+
+	return &Schedule{
+		ID:           id,
+		Spec:         spec,
+		WorkflowName: workflowName,
+		Inputs:       inputs,
+		CreatedAt:    now,
+		NextRunAt:    nextRunAt,
+		DueAt:        nextRunAt,
+	}
+}
+
+// maxRunsPerTick limits the number of runs processed at once, e.g., after a long downtime of all workers. Any
+// remaining runs are processed by subsequent ticks.
+const maxRunsPerTick = 100
+
+// Tick processes all runs of the schedule due at now, and returns the runs for which a workflow instance
+// should be started. running indicates whether the last instance started by the schedule is still running.
+//
+// NextRunAt, BufferedRunAt, and DueAt are updated accordingly. If a run is buffered, the schedule is due again
+// after recheck, to start the buffered run once the previous instance has finished.
+func (s *Schedule) Tick(now time.Time, running bool, recheck time.Duration) []time.Time {
+	var runs []time.Time
+
+	if s.BufferedRunAt != nil && !running {
+		runs = append(runs, *s.BufferedRunAt)
+		s.BufferedRunAt = nil
+		running = true
+	}
+
+	at := s.NextRunAt
+	for i := 0; i < maxRunsPerTick && !at.IsZero() && !at.After(now); i++ {
+		switch s.Spec.OverlapPolicy {
+		case OverlapPolicyAllowAll:
+			runs = append(runs, at)
+
+		case OverlapPolicyBufferOne:
+			if !running {
+				runs = append(runs, at)
+				running = true
+			} else if s.BufferedRunAt == nil {
+				bufferedAt := at
+				s.BufferedRunAt = &bufferedAt
+			}
+
+		default:
+			if !running {
+				runs = append(runs, at)
+				running = true
+			}
+		}
+
+		at = s.Spec.Next(at)
+	}
+
+	s.NextRunAt = at
+	s.DueAt = at
+
+	if s.BufferedRunAt != nil {
+		if checkAt := now.Add(recheck); checkAt.Before(s.DueAt) {
+			s.DueAt = checkAt
+		}
+	}
+
+	return runs
+}
+
+// RunInstanceID returns the id of the workflow instance started for the run of a schedule at the given time. Ids
+// are deterministic, so that a run is never started more than once.
+func RunInstanceID(scheduleID string, at time.Time) string {
+	return fmt.Sprintf("%s-%s", scheduleID, at.UTC().Format(time.RFC3339))
+}
+
+// NewRun returns the workflow instance and its start event for the run of the schedule at the given time.
+func (s *Schedule) NewRun(at, now time.Time, metadata *core.WorkflowMetadata) (*core.WorkflowInstance, *history.Event) {
+	wfi := core.NewWorkflowInstance(RunInstanceID(s.ID, at), uuid.NewString())
+
+	startedEvent := history.NewPendingEvent(
+		now,
+		history.EventType_WorkflowExecutionStarted,
+		&history.ExecutionStartedAttributes{
+			Metadata: metadata,
+			Name:     s.WorkflowName,
+			Inputs:   s.Inputs,
+		})
+
+	return wfi, startedEvent
+}
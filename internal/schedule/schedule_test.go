@@ -0,0 +1,185 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Spec_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    Spec
+		wantErr bool
+	}{
+		{"cron", Spec{Cron: "0 * * * *"}, false},
+		{"interval", Spec{Interval: time.Minute, OverlapPolicy: OverlapPolicyAllowAll}, false},
+		{"empty", Spec{}, true},
+		{"cron and interval", Spec{Cron: "0 * * * *", Interval: time.Minute}, true},
+		{"invalid cron", Spec{Cron: "0 * *"}, true},
+		{"cron without match", Spec{Cron: "0 0 31 2 *"}, true},
+		{"short interval", Spec{Interval: time.Millisecond}, true},
+		{"unknown overlap policy", Spec{Interval: time.Minute, OverlapPolicy: 42}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Spec_Next_Interval(t *testing.T) {
+	s := Spec{Interval: time.Hour}
+
+	require.Equal(t,
+		time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC),
+		s.Next(time.Date(2022, 6, 15, 10, 30, 0, 0, time.UTC)))
+
+	require.Equal(t,
+		time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC),
+		s.Next(time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC)))
+}
+
+func Test_Spec_Next_Interval_AlignedToUnixEpoch(t *testing.T) {
+	// 2022-06-16 is a Thursday, like the Unix epoch
+	s := Spec{Interval: 7 * 24 * time.Hour}
+
+	require.Equal(t,
+		time.Date(2022, 6, 16, 0, 0, 0, 0, time.UTC),
+		s.Next(time.Date(2022, 6, 13, 10, 30, 0, 0, time.UTC)))
+
+	s = Spec{Interval: 90 * time.Minute}
+
+	require.Equal(t,
+		time.Date(1970, 1, 1, 1, 30, 0, 0, time.UTC),
+		s.Next(time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	require.Equal(t,
+		time.Date(1969, 12, 31, 22, 30, 0, 0, time.UTC),
+		s.Next(time.Date(1969, 12, 31, 22, 0, 0, 0, time.UTC)))
+}
+
+func Test_Spec_Runs(t *testing.T) {
+	s := Spec{Cron: "0 * * * *"}
+
+	runs := s.Runs(
+		time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC),
+		time.Date(2022, 6, 15, 13, 0, 0, 0, time.UTC))
+
+	require.Equal(t, []time.Time{
+		time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC),
+		time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC),
+		time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC),
+	}, runs)
+}
+
+func Test_Schedule_Tick(t *testing.T) {
+	start := time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time {
+		return start.Add(time.Duration(h) * time.Hour)
+	}
+
+	tests := []struct {
+		name         string
+		policy       OverlapPolicy
+		buffered     *time.Time
+		running      bool
+		wantRuns     []time.Time
+		wantBuffered *time.Time
+	}{
+		{
+			name:     "skip",
+			policy:   OverlapPolicySkip,
+			wantRuns: []time.Time{at(0)},
+		},
+		{
+			name:    "skip while running",
+			policy:  OverlapPolicySkip,
+			running: true,
+		},
+		{
+			name:         "buffer one",
+			policy:       OverlapPolicyBufferOne,
+			wantRuns:     []time.Time{at(0)},
+			wantBuffered: timePtr(at(1)),
+		},
+		{
+			name:         "buffer one while running",
+			policy:       OverlapPolicyBufferOne,
+			running:      true,
+			wantBuffered: timePtr(at(0)),
+		},
+		{
+			name:         "buffered run is kept while running",
+			policy:       OverlapPolicyBufferOne,
+			buffered:     timePtr(at(-1)),
+			running:      true,
+			wantBuffered: timePtr(at(-1)),
+		},
+		{
+			name:         "buffered run starts after previous instance finished",
+			policy:       OverlapPolicyBufferOne,
+			buffered:     timePtr(at(-1)),
+			wantRuns:     []time.Time{at(-1)},
+			wantBuffered: timePtr(at(0)),
+		},
+		{
+			name:     "allow all",
+			policy:   OverlapPolicyAllowAll,
+			running:  true,
+			wantRuns: []time.Time{at(0), at(1), at(2)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Schedule{
+				ID:            "s",
+				Spec:          Spec{Interval: time.Hour, OverlapPolicy: tt.policy},
+				NextRunAt:     start,
+				BufferedRunAt: tt.buffered,
+			}
+
+			now := at(2).Add(time.Minute)
+			runs := s.Tick(now, tt.running, time.Second)
+
+			require.Equal(t, tt.wantRuns, runs)
+			require.Equal(t, tt.wantBuffered, s.BufferedRunAt)
+			require.Equal(t, at(3), s.NextRunAt)
+
+			if tt.wantBuffered != nil {
+				require.Equal(t, now.Add(time.Second), s.DueAt)
+			} else {
+				require.Equal(t, at(3), s.DueAt)
+			}
+		})
+	}
+}
+
+func Test_Schedule_Tick_LimitsRuns(t *testing.T) {
+	start := time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC)
+
+	s := &Schedule{
+		Spec:      Spec{Interval: time.Minute, OverlapPolicy: OverlapPolicyAllowAll},
+		NextRunAt: start,
+	}
+
+	runs := s.Tick(start.Add(24*time.Hour), false, time.Second)
+	require.Len(t, runs, maxRunsPerTick)
+	require.Equal(t, start.Add(maxRunsPerTick*time.Minute), s.NextRunAt)
+}
+
+func Test_RunInstanceID(t *testing.T) {
+	require.Equal(t, "nightly-2022-06-15T10:00:00Z", RunInstanceID("nightly", time.Date(2022, 6, 15, 10, 0, 0, 0, time.UTC)))
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -12,6 +12,8 @@ type Activities struct {
 	Signaler Signaler
 }
 
+// DeliverWorkflowSignal delivers a signal to another workflow instance. Signals are sent natively by workflows now, the
+// activity is kept for workflow instances which scheduled it before, and for replaying their histories.
 func (a *Activities) DeliverWorkflowSignal(ctx context.Context, instanceID, signalName string, arg interface{}) error {
 	return a.Signaler.SignalWorkflow(ctx, instanceID, signalName, arg)
 }
@@ -3,8 +3,10 @@ package worker
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -14,6 +16,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/metrickeys"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
 	"github.com/cschleiden/go-workflows/metrics"
 )
 
@@ -25,6 +28,13 @@ type ActivityWorker struct {
 	activityTaskQueue    chan *task.Activity
 	activityTaskExecutor activity.Executor
 
+	limiters map[string]*activityLimiter
+
+	inflight *inflightTasks
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
 	wg        sync.WaitGroup
 	pollersWg sync.WaitGroup
 
@@ -40,11 +50,22 @@ func NewActivityWorker(backend backend.Backend, registry *workflow.Registry, clo
 		activityTaskQueue:    make(chan *task.Activity),
 		activityTaskExecutor: activity.NewExecutor(backend.Logger(), backend.Tracer(), backend.Converter(), registry),
 
+		inflight: newInflightTasks(backend.Logger()),
+
+		stop: make(chan struct{}),
+
 		clock: clock,
 	}
 }
 
 func (aw *ActivityWorker) Start(ctx context.Context) error {
+	limiters, err := newActivityLimiters(aw.backend, aw.clock, aw.options.ActivityLimits)
+	if err != nil {
+		return err
+	}
+
+	aw.limiters = limiters
+
 	aw.pollersWg.Add(aw.options.ActivityPollers)
 
 	for i := 0; i < aw.options.ActivityPollers; i++ {
@@ -67,6 +88,27 @@ func (aw *ActivityWorker) WaitForCompletion() error {
 	return nil
 }
 
+// Stop stops polling for new activity tasks. Polls in progress are finished, tasks they return are released.
+func (aw *ActivityWorker) Stop() {
+	aw.stopOnce.Do(func() {
+		close(aw.stop)
+	})
+}
+
+// AbandonTasks cancels the contexts of the activity tasks which are still being processed, and releases their locks
+// in the backend so that other workers can pick them up.
+func (aw *ActivityWorker) AbandonTasks(ctx context.Context) {
+	aw.inflight.abandonAll(ctx)
+}
+
+func (aw *ActivityWorker) abandonTask(ctx context.Context, task *task.Activity) error {
+	if err := aw.backend.AbandonActivityTask(ctx, task); err != nil && !errors.Is(err, backend.ErrActivityTaskNotFound) {
+		return fmt.Errorf("abandoning activity task: %w", err)
+	}
+
+	return nil
+}
+
 func (aw *ActivityWorker) runPoll(ctx context.Context) {
 	defer aw.pollersWg.Done()
 
@@ -74,6 +116,8 @@ func (aw *ActivityWorker) runPoll(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
+		case <-aw.stop:
+			return
 		default:
 			task, err := aw.poll(ctx, 30*time.Second)
 			if err != nil {
@@ -82,12 +126,30 @@ func (aw *ActivityWorker) runPoll(ctx context.Context) {
 			}
 
 			if task != nil {
-				aw.activityTaskQueue <- task
+				aw.wg.Add(1)
+
+				select {
+				case aw.activityTaskQueue <- task:
+				case <-ctx.Done():
+					aw.abandonPolledTask(task)
+				case <-aw.stop:
+					aw.abandonPolledTask(task)
+				}
 			}
 		}
 	}
 }
 
+// abandonPolledTask releases a task which has been polled while the worker is stopping, so that another worker can
+// pick it up right away
+func (aw *ActivityWorker) abandonPolledTask(task *task.Activity) {
+	defer aw.wg.Done()
+
+	if err := aw.abandonTask(context.Background(), task); err != nil {
+		aw.backend.Logger().Error("could not abandon activity task", "error", err)
+	}
+}
+
 func (aw *ActivityWorker) runDispatcher(ctx context.Context) {
 	var sem chan struct{}
 	if aw.options.MaxParallelActivityTasks > 0 {
@@ -95,28 +157,138 @@ func (aw *ActivityWorker) runDispatcher(ctx context.Context) {
 	}
 
 	for task := range aw.activityTaskQueue {
-		if sem != nil {
+		task := task
+
+		// Create new context to allow activities to complete when root context is canceled
+		taskCtx, cancel := context.WithCancel(context.Background())
+		inflight := aw.inflight.add(cancel, func(ctx context.Context) error {
+			return aw.abandonTask(ctx, task)
+		})
+
+		a := task.Event.Attributes.(*history.ActivityScheduledAttributes)
+		limiter := aw.limiters[a.Name]
+
+		if limiter != nil {
+			// Tasks waiting for the limits of their activity don't take up a slot, to not block other activities.
+			// While too many tasks are waiting, new ones are released, without blocking the dispatcher.
+			select {
+			case limiter.waiting <- struct{}{}:
+			default:
+				go aw.releaseLimitedTask(taskCtx, inflight, task)
+				continue
+			}
+		} else if sem != nil {
 			sem <- struct{}{}
 		}
 
-		task := task
-
-		aw.wg.Add(1)
 		go func() {
 			defer aw.wg.Done()
+			defer aw.inflight.remove(inflight)
+			defer cancel()
+
+			if limiter != nil {
+				release, ok := aw.waitForLimits(taskCtx, limiter, task)
+				if ok && sem != nil {
+					sem <- struct{}{}
+				}
+
+				<-limiter.waiting
 
-			// Create new context to allow activities to complete when root context is canceled
-			taskCtx := context.Background()
-			aw.handleTask(taskCtx, task)
+				if !ok {
+					return
+				}
+
+				defer release()
+			}
 
 			if sem != nil {
-				<-sem
+				defer func() { <-sem }()
 			}
+
+			// Don't start tasks which have been abandoned in the meantime
+			if inflight.abandoned() {
+				return
+			}
+
+			aw.handleTask(taskCtx, inflight, task)
 		}()
 	}
 }
 
-func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
+// releaseLimitedTask releases a task which can't wait for the limits of its activity on this worker. The task is
+// held for a short time before its lock is released, so it's not retrieved again right away.
+func (aw *ActivityWorker) releaseLimitedTask(ctx context.Context, inflight *inflightTask, task *task.Activity) {
+	defer aw.wg.Done()
+	defer aw.inflight.remove(inflight)
+	defer inflight.cancel()
+
+	t := aw.clock.Timer(activityLimitRetryInterval)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+
+	// Tasks abandoned in the meantime have been released already
+	if !inflight.complete() {
+		return
+	}
+
+	if err := aw.abandonTask(context.Background(), task); err != nil {
+		aw.backend.Logger().Error("could not release activity task", "error", err)
+	}
+}
+
+// waitForLimits waits until the given task can be started within the limits of its activity, while extending the
+// lock of the task. Returns false if the task has been canceled or timed out while waiting.
+func (aw *ActivityWorker) waitForLimits(ctx context.Context, limiter *activityLimiter, task *task.Activity) (func(), bool) {
+	a := task.Event.Attributes.(*history.ActivityScheduledAttributes)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if heartbeatInterval := activityHeartbeatInterval(aw.options.ActivityHeartbeatInterval, a.Timeouts); heartbeatInterval > 0 {
+		heartbeatCtx, cancelHeartbeat := context.WithCancel(ctx)
+		defer cancelHeartbeat()
+
+		go func(ctx context.Context) {
+			t := time.NewTicker(heartbeatInterval)
+			defer t.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					if err := aw.backend.ExtendActivityTask(ctx, task.ID, nil); err != nil {
+						if errors.Is(err, backend.ErrActivityTaskNotFound) || errors.Is(err, backend.ErrActivityCanceled) {
+							// Activity has timed out or its workflow instance has been canceled, stop waiting
+							cancel()
+							return
+						}
+
+						if ctx.Err() != nil {
+							return
+						}
+
+						aw.backend.Logger().Panic("extending activity task", "error", err)
+					}
+				}
+			}
+		}(heartbeatCtx)
+	}
+
+	release, err := limiter.acquire(ctx, task.ID)
+	if err != nil {
+		aw.backend.Logger().Debug("activity task canceled or timed out while waiting for limits", "activity", a.Name, "task_id", task.ID)
+		return nil, false
+	}
+
+	return release, true
+}
+
+func (aw *ActivityWorker) handleTask(ctx context.Context, inflight *inflightTask, task *task.Activity) {
 	a := task.Event.Attributes.(*history.ActivityScheduledAttributes)
 	ametrics := aw.backend.Metrics().WithTags(metrics.Tags{metrickeys.ActivityName: a.Name})
 
@@ -125,21 +297,65 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 	timeInQueue := time.Since(scheduledAt)
 	ametrics.Distribution(metrickeys.ActivityTaskDelay, metrics.Tags{}, float64(timeInQueue/time.Millisecond))
 
-	// Start heartbeat while activity is running
-	if aw.options.ActivityHeartbeatInterval > 0 {
+	// Enforce start-to-close and schedule-to-close timeouts locally
+	ctx, cancel, timeoutType := withActivityDeadline(ctx, scheduledAt, a.Timeouts)
+	defer cancel()
+
+	// Details recorded by the activity, initialized with the ones from the previous attempt
+	heartbeat := activity.NewHeartbeat(a.HeartbeatDetails)
+
+	// Start heartbeat while activity is running. Activities with a heartbeat timeout report their liveness using
+	// RecordHeartbeat, only their heartbeats extend the lock. The lock of other activities is extended as long as
+	// the worker is executing them.
+	var heartbeatTimeout time.Duration
+	if a.Timeouts != nil {
+		heartbeatTimeout = a.Timeouts.Heartbeat
+	}
+
+	var heartbeatTimedOut int32
+
+	if heartbeatInterval := activityHeartbeatInterval(aw.options.ActivityHeartbeatInterval, a.Timeouts); heartbeatInterval > 0 {
 		heartbeatCtx, cancelHeartbeat := context.WithCancel(ctx)
 		defer cancelHeartbeat()
 
 		go func(ctx context.Context) {
-			t := time.NewTicker(aw.options.ActivityHeartbeatInterval)
+			t := time.NewTicker(heartbeatInterval)
 			defer t.Stop()
 
+			lastHeartbeat := time.Now()
+
 			for {
 				select {
 				case <-ctx.Done():
 					return
 				case <-t.C:
-					if err := aw.backend.ExtendActivityTask(ctx, task.ID); err != nil {
+					details, recorded := heartbeat.Pending()
+					if heartbeatTimeout > 0 {
+						if !recorded {
+							if time.Since(lastHeartbeat) >= heartbeatTimeout {
+								// The activity has not reported its liveness in time, stop executing it
+								atomic.StoreInt32(&heartbeatTimedOut, 1)
+								cancel()
+								return
+							}
+
+							continue
+						}
+
+						lastHeartbeat = time.Now()
+					}
+
+					if err := aw.backend.ExtendActivityTask(ctx, task.ID, details); err != nil {
+						if errors.Is(err, backend.ErrActivityTaskNotFound) || errors.Is(err, backend.ErrActivityCanceled) {
+							// Activity has timed out or its workflow instance has been canceled, stop executing it
+							cancel()
+							return
+						}
+
+						if ctx.Err() != nil {
+							return
+						}
+
 						aw.backend.Logger().Panic("extending activity task", "error", err)
 					}
 				}
@@ -150,19 +366,47 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 	timer := metrics.Timer(ametrics, metrickeys.ActivityTaskProcessed, metrics.Tags{})
 	defer timer.Stop()
 
-	result, err := aw.activityTaskExecutor.ExecuteActivity(ctx, task)
+	result, err := aw.activityTaskExecutor.ExecuteActivity(ctx, task, heartbeat)
+
+	if !inflight.complete() {
+		// The worker is shutting down and has released the task, another worker will execute it
+		aw.backend.Logger().Debug("activity task abandoned, discarding result", "activity", a.Name, "task_id", task.ID)
+		return
+	}
+
+	if errors.Is(err, activity.ErrResultPending) {
+		// The activity will be completed asynchronously using its task token, keep the task until then
+		if err := aw.backend.SetActivityTaskPending(context.Background(), task.ID); err != nil {
+			if errors.Is(err, backend.ErrActivityTaskNotFound) {
+				aw.backend.Logger().Debug("activity task already completed or timed out", "activity", a.Name, "task_id", task.ID)
+				return
+			}
+
+			aw.backend.Logger().Panic("marking activity task as pending", "error", err)
+		}
+
+		return
+	}
 
 	var event *history.Event
 
-	if err != nil {
-		event = history.NewPendingEvent(
-			aw.clock.Now(),
-			history.EventType_ActivityFailed,
-			&history.ActivityFailedAttributes{
-				Reason: err.Error(),
-			},
-			history.ScheduleEventID(task.Event.ScheduleEventID),
-		)
+	if atomic.LoadInt32(&heartbeatTimedOut) == 1 {
+		event = history.NewActivityTimedOutEvent(
+			aw.clock.Now(), task.Event.ScheduleEventID, workflowerrors.TimeoutTypeHeartbeat, heartbeat.Details())
+	} else if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			event = history.NewActivityTimedOutEvent(aw.clock.Now(), task.Event.ScheduleEventID, timeoutType, heartbeat.Details())
+		} else {
+			event = history.NewPendingEvent(
+				aw.clock.Now(),
+				history.EventType_ActivityFailed,
+				&history.ActivityFailedAttributes{
+					Error:            workflowerrors.FromError(aw.backend.Converter(), err),
+					HeartbeatDetails: heartbeat.Details(),
+				},
+				history.ScheduleEventID(task.Event.ScheduleEventID),
+			)
+		}
 	} else {
 		event = history.NewPendingEvent(
 			aw.clock.Now(),
@@ -173,11 +417,64 @@ func (aw *ActivityWorker) handleTask(ctx context.Context, task *task.Activity) {
 			history.ScheduleEventID(task.Event.ScheduleEventID))
 	}
 
-	if err := aw.backend.CompleteActivityTask(ctx, task.WorkflowInstance, task.ID, event); err != nil {
+	// Use a fresh context, the activity context might have been canceled
+	if err := aw.backend.CompleteActivityTask(context.Background(), task.WorkflowInstance, task.ID, event); err != nil {
+		if errors.Is(err, backend.ErrActivityTaskNotFound) {
+			aw.backend.Logger().Warn("activity task already completed or timed out, discarding result", "activity", a.Name, "task_id", task.ID)
+			return
+		}
+
 		aw.backend.Logger().Panic("completing activity task", "error", err)
 	}
 }
 
+// withActivityDeadline returns a context that is canceled when the start-to-close or schedule-to-close timeout of
+// an activity expires, along with the type of the timeout that expires first.
+func withActivityDeadline(
+	ctx context.Context, scheduledAt time.Time, timeouts *history.ActivityTimeouts,
+) (context.Context, context.CancelFunc, workflowerrors.TimeoutType) {
+	if timeouts == nil {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, ""
+	}
+
+	var deadline time.Time
+	var timeoutType workflowerrors.TimeoutType
+
+	if timeouts.StartToClose > 0 {
+		deadline = time.Now().Add(timeouts.StartToClose)
+		timeoutType = workflowerrors.TimeoutTypeStartToClose
+	}
+
+	if timeouts.ScheduleToClose > 0 {
+		d := scheduledAt.Add(timeouts.ScheduleToClose)
+		if deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+			timeoutType = workflowerrors.TimeoutTypeScheduleToClose
+		}
+	}
+
+	if deadline.IsZero() {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, ""
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	return ctx, cancel, timeoutType
+}
+
+// activityHeartbeatInterval returns the interval in which the lock of an activity task is extended. If the activity
+// has a heartbeat timeout, heartbeats are sent at least twice per timeout period.
+func activityHeartbeatInterval(interval time.Duration, timeouts *history.ActivityTimeouts) time.Duration {
+	if timeouts != nil && timeouts.Heartbeat > 0 {
+		if interval <= 0 || timeouts.Heartbeat/2 < interval {
+			return timeouts.Heartbeat / 2
+		}
+	}
+
+	return interval
+}
+
 func (aw *ActivityWorker) poll(ctx context.Context, timeout time.Duration) (*task.Activity, error) {
 	if timeout == 0 {
 		timeout = 30 * time.Second
@@ -186,7 +483,7 @@ func (aw *ActivityWorker) poll(ctx context.Context, timeout time.Duration) (*tas
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	task, err := aw.backend.GetActivityTask(ctx)
+	task, err := aw.backend.GetActivityTask(ctx, aw.options.Queues)
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			return nil, nil
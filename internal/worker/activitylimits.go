@@ -0,0 +1,213 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/ratelimit"
+	"github.com/cschleiden/go-workflows/log"
+)
+
+// activitySlotLease is the time a global execution slot is held without being extended, e.g., after a worker crashed
+const activitySlotLease = time.Minute
+
+// activityLimitRetryInterval is the interval between attempts to acquire a global execution slot
+const activityLimitRetryInterval = 500 * time.Millisecond
+
+type activityLimiter struct {
+	name  string
+	limit ActivityLimit
+
+	// waiting bounds the number of tasks waiting for the limits of the activity
+	waiting chan struct{}
+
+	// slots and bucket enforce the limits locally
+	slots    chan struct{}
+	bucketMu sync.Mutex
+	bucket   ratelimit.Bucket
+
+	// limiter enforces the limits globally, if configured
+	limiter backend.ActivityLimiter
+
+	logger log.Logger
+	clock  clock.Clock
+}
+
+func newActivityLimiters(b backend.Backend, clock clock.Clock, limits map[string]ActivityLimit) (map[string]*activityLimiter, error) {
+	limiters := make(map[string]*activityLimiter)
+
+	for name, limit := range limits {
+		if limit.MaxParallel <= 0 && limit.RateLimit <= 0 {
+			continue
+		}
+
+		if limit.Burst < 1 {
+			limit.Burst = 1
+		}
+
+		waiting := limit.Burst
+		if limit.MaxParallel > waiting {
+			waiting = limit.MaxParallel
+		}
+
+		l := &activityLimiter{
+			name:    name,
+			limit:   limit,
+			waiting: make(chan struct{}, waiting),
+			logger:  b.Logger(),
+			clock:   clock,
+		}
+
+		if limit.Global {
+			limiter, ok := b.(backend.ActivityLimiter)
+			if !ok {
+				return nil, fmt.Errorf("backend does not support global limits for activity %q", name)
+			}
+
+			l.limiter = limiter
+		} else {
+			if limit.MaxParallel > 0 {
+				l.slots = make(chan struct{}, limit.MaxParallel)
+			}
+
+			l.bucket = ratelimit.NewBucket(limit.Burst, clock.Now())
+		}
+
+		limiters[name] = l
+	}
+
+	return limiters, nil
+}
+
+// acquire waits until an execution of the activity can be started for the given holder. The returned function
+// has to be called once the execution has finished.
+func (l *activityLimiter) acquire(ctx context.Context, holder string) (func(), error) {
+	release := func() {}
+
+	// Acquire a slot before taking a token, tokens are taken when an execution actually starts
+	if l.limit.MaxParallel > 0 {
+		var err error
+		if release, err = l.acquireSlot(ctx, holder); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.limit.RateLimit > 0 {
+		if err := l.takeToken(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+func (l *activityLimiter) acquireSlot(ctx context.Context, holder string) (func(), error) {
+	if l.limiter == nil {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case l.slots <- struct{}{}:
+			return func() { <-l.slots }, nil
+		}
+	}
+
+	for {
+		acquired, err := l.limiter.AcquireActivitySlot(ctx, l.name, holder, l.limit.MaxParallel, activitySlotLease)
+		if err != nil && ctx.Err() == nil {
+			l.logger.Error("acquiring activity slot", "activity", l.name, "error", err)
+		}
+
+		if acquired {
+			break
+		}
+
+		if err := l.sleep(ctx, activityLimitRetryInterval); err != nil {
+			return nil, err
+		}
+	}
+
+	// Extend the slot while the activity is executing
+	extendCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		t := l.clock.Ticker(activitySlotLease / 3)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-extendCtx.Done():
+				return
+			case <-t.C:
+				acquired, err := l.limiter.AcquireActivitySlot(extendCtx, l.name, holder, l.limit.MaxParallel, activitySlotLease)
+				if extendCtx.Err() != nil {
+					return
+				}
+
+				if err != nil {
+					l.logger.Error("extending activity slot", "activity", l.name, "error", err)
+				} else if !acquired {
+					l.logger.Warn("activity slot expired while executing", "activity", l.name)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+
+		if err := l.limiter.ReleaseActivitySlot(context.Background(), l.name, holder); err != nil {
+			l.logger.Error("releasing activity slot", "activity", l.name, "error", err)
+		}
+	}, nil
+}
+
+func (l *activityLimiter) takeToken(ctx context.Context) error {
+	for {
+		var wait time.Duration
+
+		if l.limiter == nil {
+			l.bucketMu.Lock()
+			wait = l.bucket.Take(l.clock.Now(), l.limit.RateLimit, l.limit.Burst)
+			l.bucketMu.Unlock()
+		} else {
+			var err error
+			wait, err = l.limiter.TakeActivityToken(ctx, l.name, l.limit.RateLimit, l.limit.Burst)
+			if err != nil {
+				if ctx.Err() == nil {
+					l.logger.Error("taking activity token", "activity", l.name, "error", err)
+				}
+
+				wait = activityLimitRetryInterval
+			}
+		}
+
+		if wait == 0 {
+			return nil
+		}
+
+		if err := l.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *activityLimiter) sleep(ctx context.Context, d time.Duration) error {
+	t := l.clock.Timer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cschleiden/go-workflows/log"
+)
+
+const (
+	taskRunning int32 = iota
+	taskCompleting
+	taskAbandoned
+)
+
+type inflightTask struct {
+	state int32
+
+	// cancel cancels the context the task is processed with
+	cancel context.CancelFunc
+
+	// abandon releases the lock of the task in the backend
+	abandon func(ctx context.Context) error
+}
+
+// complete marks the task as being completed. Returns false if the task has been abandoned and must not be completed.
+func (t *inflightTask) complete() bool {
+	return atomic.CompareAndSwapInt32(&t.state, taskRunning, taskCompleting)
+}
+
+// abandoned returns true if the task has been abandoned
+func (t *inflightTask) abandoned() bool {
+	return atomic.LoadInt32(&t.state) == taskAbandoned
+}
+
+// inflightTasks tracks the tasks a worker is processing, so that they can be abandoned on shutdown
+type inflightTasks struct {
+	mu    sync.Mutex
+	tasks map[*inflightTask]struct{}
+
+	// abandoning is set once all tasks have been abandoned, tasks added afterwards are abandoned right away
+	abandoning bool
+
+	logger log.Logger
+}
+
+func newInflightTasks(logger log.Logger) *inflightTasks {
+	return &inflightTasks{
+		tasks:  make(map[*inflightTask]struct{}),
+		logger: logger,
+	}
+}
+
+func (it *inflightTasks) add(cancel context.CancelFunc, abandon func(ctx context.Context) error) *inflightTask {
+	t := &inflightTask{
+		cancel:  cancel,
+		abandon: abandon,
+	}
+
+	it.mu.Lock()
+	abandoning := it.abandoning
+	if !abandoning {
+		it.tasks[t] = struct{}{}
+	}
+	it.mu.Unlock()
+
+	if abandoning {
+		it.abandon(context.Background(), t)
+	}
+
+	return t
+}
+
+func (it *inflightTasks) remove(t *inflightTask) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	delete(it.tasks, t)
+}
+
+// abandonAll abandons all tasks which are not being completed: their contexts are canceled, and their locks are
+// released in the backend.
+func (it *inflightTasks) abandonAll(ctx context.Context) {
+	it.mu.Lock()
+	it.abandoning = true
+	tasks := make([]*inflightTask, 0, len(it.tasks))
+	for t := range it.tasks {
+		tasks = append(tasks, t)
+	}
+	it.mu.Unlock()
+
+	for _, t := range tasks {
+		it.abandon(ctx, t)
+	}
+}
+
+func (it *inflightTasks) abandon(ctx context.Context, t *inflightTask) {
+	if !atomic.CompareAndSwapInt32(&t.state, taskRunning, taskAbandoned) {
+		return
+	}
+
+	t.cancel()
+
+	if err := t.abandon(ctx); err != nil {
+		it.logger.Error("could not abandon task", "error", err)
+	}
+}
@@ -3,10 +3,14 @@ package worker
 import (
 	"time"
 
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/workflow"
 )
 
 type Options struct {
+	// Queues are the queues the worker processes workflow and activity tasks from. Defaults to the default queue.
+	Queues []core.Queue
+
 	// WorkflowsPollers is the number of pollers to start. Defaults to 2.
 	WorkflowPollers int
 
@@ -21,6 +25,10 @@ type Options struct {
 	// by the worker. The default is 0 which is no limit.
 	MaxParallelActivityTasks int
 
+	// ActivityLimits limits the concurrency and rate of executions per activity name. Tasks of activities without
+	// limits are not affected.
+	ActivityLimits map[string]ActivityLimit
+
 	// ActivityHeartbeatInterval is the interval between heartbeat attempts for activity tasks. Defaults
 	// to 25 seconds
 	ActivityHeartbeatInterval time.Duration
@@ -33,6 +41,11 @@ type Options struct {
 	// WorkflowHeartbeatInterval is the interval between heartbeat attempts on workflow tasks, when enabled.
 	WorkflowHeartbeatInterval time.Duration
 
+	// WorkflowTaskTimeout is the maximum time a workflow task waits for local activities. Local activity attempts
+	// still executing when it expires are canceled, and retried in a later workflow task. Should be lower than the workflow
+	// lock timeout of the backend, unless HeartbeatWorkflowTasks is enabled. Defaults to 30 seconds.
+	WorkflowTaskTimeout time.Duration
+
 	// WorkflowExecutorCache is the max size of the workflow executor cache. Defaults to 128
 	WorkflowExecutorCacheSize int
 
@@ -42,17 +55,51 @@ type Options struct {
 	// WorkflowExecutorCache is the cache to use for workflow executors. If nil, a default cache implementation
 	// will be used.
 	WorkflowExecutorCache workflow.ExecutorCache
+
+	// NonDeterminismPolicy determines how non-determinism detected while replaying a workflow instance is handled.
+	// By default, the workflow instance fails. With NonDeterminismPolicyBlock, the workflow task is not completed
+	// and retried once its lock expires, until a worker with fixed workflow code processes it.
+	NonDeterminismPolicy workflow.NonDeterminismPolicy
+
+	// SchedulePollingInterval is the interval between checks for due schedules. Defaults to 1 second.
+	SchedulePollingInterval time.Duration
+}
+
+// ActivityLimit limits the executions of an activity. Tasks which can't be started right away wait in the worker,
+// their locks are extended while they are waiting. While too many tasks are waiting, further tasks of the activity
+// are released again. The time spent waiting counts toward the start-to-close timeout of the activity.
+type ActivityLimit struct {
+	// MaxParallel is the maximum number of concurrent executions of the activity. The default is 0 which is no limit.
+	MaxParallel int
+
+	// RateLimit is the maximum number of executions of the activity started per second. The default is 0 which is
+	// no limit.
+	RateLimit float64
+
+	// Burst is the number of executions which can be started at once when RateLimit is set. Defaults to 1.
+	Burst int
+
+	// Global enforces the limits across all workers using the backend, instead of for this worker only. Requires a
+	// backend implementing backend.ActivityLimiter.
+	Global bool
 }
 
 var DefaultOptions = Options{
+	Queues: []core.Queue{core.QueueDefault},
+
 	WorkflowPollers:           2,
 	ActivityPollers:           2,
 	MaxParallelWorkflowTasks:  0,
 	MaxParallelActivityTasks:  0,
 	ActivityHeartbeatInterval: 25 * time.Second,
 	WorkflowHeartbeatInterval: 25 * time.Second,
+	WorkflowTaskTimeout:       30 * time.Second,
 
 	WorkflowExecutorCacheSize: 128,
 	WorkflowExecutorCacheTTL:  time.Second * 10,
 	WorkflowExecutorCache:     nil,
+
+	NonDeterminismPolicy: workflow.NonDeterminismPolicyFail,
+
+	SchedulePollingInterval: time.Second,
 }
@@ -0,0 +1,179 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cschleiden/go-workflows/backend"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/metrickeys"
+	"github.com/cschleiden/go-workflows/internal/schedule"
+	"github.com/cschleiden/go-workflows/internal/tracing"
+	"github.com/cschleiden/go-workflows/log"
+	"github.com/cschleiden/go-workflows/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScheduleWorker starts workflow instances for due schedules
+type ScheduleWorker struct {
+	backend backend.Backend
+
+	options *Options
+
+	clock clock.Clock
+
+	logger log.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	wg sync.WaitGroup
+}
+
+func NewScheduleWorker(backend backend.Backend, clock clock.Clock, options *Options) *ScheduleWorker {
+	return &ScheduleWorker{
+		backend: backend,
+
+		options: options,
+
+		clock: clock,
+
+		logger: backend.Logger(),
+
+		stop: make(chan struct{}),
+	}
+}
+
+func (sw *ScheduleWorker) Start(ctx context.Context) error {
+	sw.wg.Add(1)
+
+	go sw.run(ctx)
+
+	return nil
+}
+
+func (sw *ScheduleWorker) WaitForCompletion() error {
+	sw.wg.Wait()
+
+	return nil
+}
+
+// Stop stops checking for due schedules, after the current check has finished
+func (sw *ScheduleWorker) Stop() {
+	sw.stopOnce.Do(func() {
+		close(sw.stop)
+	})
+}
+
+func (sw *ScheduleWorker) run(ctx context.Context) {
+	defer sw.wg.Done()
+
+	ticker := sw.clock.Ticker(sw.options.SchedulePollingInterval)
+	defer ticker.Stop()
+
+	for {
+		sw.processSchedules(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-sw.stop:
+			return
+
+		case <-ticker.C:
+		}
+	}
+}
+
+// processSchedules processes schedules until no schedule is due anymore
+func (sw *ScheduleWorker) processSchedules(ctx context.Context) {
+	for ctx.Err() == nil {
+		s, err := sw.backend.GetScheduleTask(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				sw.logger.Error("error while polling for schedule", "error", err)
+			}
+
+			return
+		}
+
+		if s == nil {
+			return
+		}
+
+		if err := sw.handle(ctx, s); err != nil {
+			// The schedule is not completed, and processed again once its lock expires. Already started runs
+			// are not started again, since their instance ids are deterministic.
+			sw.logger.Error("error while processing schedule", "schedule_id", s.ID, "error", err)
+		}
+	}
+}
+
+func (sw *ScheduleWorker) handle(ctx context.Context, s *schedule.Schedule) error {
+	running := false
+	if s.LastInstance != nil {
+		state, err := sw.backend.GetWorkflowInstanceState(ctx, s.LastInstance)
+		if err != nil && !errors.Is(err, backend.ErrInstanceNotFound) {
+			return fmt.Errorf("getting state of last instance: %w", err)
+		}
+
+		running = err == nil && state == core.WorkflowInstanceStateActive
+	}
+
+	now := sw.clock.Now()
+	runs := s.Tick(now, running, sw.options.SchedulePollingInterval)
+
+	for _, at := range runs {
+		instance, err := sw.startRun(ctx, s, at, now)
+		if err != nil {
+			return fmt.Errorf("starting run at %v: %w", at, err)
+		}
+
+		s.LastInstance = instance
+	}
+
+	if err := sw.backend.CompleteScheduleTask(ctx, s); err != nil {
+		return fmt.Errorf("completing schedule task: %w", err)
+	}
+
+	return nil
+}
+
+func (sw *ScheduleWorker) startRun(ctx context.Context, s *schedule.Schedule, at, now time.Time) (*core.WorkflowInstance, error) {
+	metadata := &core.WorkflowMetadata{}
+
+	instanceID := schedule.RunInstanceID(s.ID, at)
+
+	sctx, span := sw.backend.Tracer().Start(ctx, fmt.Sprintf("ScheduleWorkflowInstance: %s", s.WorkflowName), trace.WithAttributes(
+		attribute.String(tracing.WorkflowInstanceID, instanceID),
+		attribute.String(tracing.WorkflowName, s.WorkflowName),
+	))
+	defer span.End()
+
+	tracing.MarshalSpan(sctx, metadata)
+
+	instance, startedEvent := s.NewRun(at, now, metadata)
+
+	if err := sw.backend.CreateWorkflowInstance(ctx, instance, startedEvent); err != nil {
+		if errors.Is(err, backend.ErrInstanceAlreadyExists) {
+			// Run has already been started by a previous attempt, or a backfill
+			sw.logger.Debug("Run already started", "schedule_id", s.ID, "instance_id", instanceID)
+			return s.LastInstance, nil
+		}
+
+		return nil, err
+	}
+
+	sw.logger.Debug("Started scheduled workflow instance", "schedule_id", s.ID, "instance_id", instance.InstanceID)
+
+	sw.backend.Metrics().Counter(metrickeys.WorkflowInstanceCreated, metrics.Tags{}, 1)
+	sw.backend.Metrics().Counter(metrickeys.ScheduleRunStarted, metrics.Tags{}, 1)
+
+	return instance, nil
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/internal/workflow"
 	"github.com/cschleiden/go-workflows/internal/workflow/cache"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/cschleiden/go-workflows/metrics"
 )
@@ -31,6 +32,11 @@ type WorkflowWorker struct {
 
 	logger log.Logger
 
+	inflight *inflightTasks
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
 	pollersWg sync.WaitGroup
 	wg        sync.WaitGroup
 }
@@ -54,6 +60,10 @@ func NewWorkflowWorker(backend backend.Backend, registry *workflow.Registry, opt
 		cache: c,
 
 		logger: backend.Logger(),
+
+		inflight: newInflightTasks(backend.Logger()),
+
+		stop: make(chan struct{}),
 	}
 }
 
@@ -80,6 +90,19 @@ func (ww *WorkflowWorker) WaitForCompletion() error {
 	return nil
 }
 
+// Stop stops polling for new workflow tasks. Polls in progress are finished, tasks they return are released.
+func (ww *WorkflowWorker) Stop() {
+	ww.stopOnce.Do(func() {
+		close(ww.stop)
+	})
+}
+
+// AbandonTasks releases the locks of the workflow tasks which are still being processed in the backend, so that
+// other workers can pick them up.
+func (ww *WorkflowWorker) AbandonTasks(ctx context.Context) {
+	ww.inflight.abandonAll(ctx)
+}
+
 func (ww *WorkflowWorker) runPoll(ctx context.Context) {
 	defer ww.pollersWg.Done()
 
@@ -88,6 +111,9 @@ func (ww *WorkflowWorker) runPoll(ctx context.Context) {
 		case <-ctx.Done():
 			return
 
+		case <-ww.stop:
+			return
+
 		default:
 			task, err := ww.poll(ctx, 30*time.Second)
 			if err != nil {
@@ -97,12 +123,29 @@ func (ww *WorkflowWorker) runPoll(ctx context.Context) {
 
 			if task != nil {
 				ww.wg.Add(1)
-				ww.workflowTaskQueue <- task
+
+				select {
+				case ww.workflowTaskQueue <- task:
+				case <-ctx.Done():
+					ww.abandonPolledTask(task)
+				case <-ww.stop:
+					ww.abandonPolledTask(task)
+				}
 			}
 		}
 	}
 }
 
+// abandonPolledTask releases a task which has been polled while the worker is stopping, so that another worker can
+// pick it up right away
+func (ww *WorkflowWorker) abandonPolledTask(task *task.Workflow) {
+	defer ww.wg.Done()
+
+	if err := ww.backend.AbandonWorkflowTask(context.Background(), task); err != nil {
+		ww.logger.Error("could not abandon workflow task", "error", err)
+	}
+}
+
 func (ww *WorkflowWorker) runDispatcher() {
 	var sem chan (struct{})
 
@@ -111,27 +154,39 @@ func (ww *WorkflowWorker) runDispatcher() {
 	}
 
 	for t := range ww.workflowTaskQueue {
+		t := t
+
+		// Create new context to allow workflows to complete when root context is canceled. Workflow code can't be
+		// interrupted, abandoned tasks only cancel their local activities and discard their result.
+		taskCtx, cancel := context.WithCancel(context.Background())
+		inflight := ww.inflight.add(cancel, func(ctx context.Context) error {
+			return ww.backend.AbandonWorkflowTask(ctx, t)
+		})
+
 		if sem != nil {
 			sem <- struct{}{}
 		}
 
-		t := t
-
 		go func() {
 			defer ww.wg.Done()
-
-			// Create new context to allow workflows to complete when root context is canceled
-			taskCtx := context.Background()
-			ww.handle(taskCtx, t)
+			defer ww.inflight.remove(inflight)
+			defer cancel()
 
 			if sem != nil {
-				<-sem
+				defer func() { <-sem }()
 			}
+
+			// Don't start tasks which have been abandoned in the meantime
+			if inflight.abandoned() {
+				return
+			}
+
+			ww.handle(taskCtx, inflight, t)
 		}()
 	}
 }
 
-func (ww *WorkflowWorker) handle(ctx context.Context, t *task.Workflow) {
+func (ww *WorkflowWorker) handle(ctx context.Context, inflight *inflightTask, t *task.Workflow) {
 	// Record how long this task was in the queue
 	scheduledAt := t.NewEvents[0].Timestamp // Use the timestamp of the first event as the schedule time
 	timeInQueue := time.Since(scheduledAt)
@@ -139,14 +194,53 @@ func (ww *WorkflowWorker) handle(ctx context.Context, t *task.Workflow) {
 
 	timer := metrics.Timer(ww.backend.Metrics(), metrickeys.WorkflowTaskProcessed, metrics.Tags{})
 
-	result, err := ww.handleTask(ctx, t)
+	result, err := ww.handleTask(ctx, inflight, t)
 	if err != nil {
+		var ndErr *workflowerrors.NonDeterminismError
+		if errors.As(err, &ndErr) {
+			// The workflow code does not match the history, leave the task to be retried after its lock expired
+			ww.logger.Error("non-determinism detected, abandoning workflow task",
+				"instance_id", t.WorkflowInstance.InstanceID, "task_id", t.ID, "error", err)
+
+			// The state of the executor is not usable anymore
+			if err := ww.cache.Evict(ctx, t.WorkflowInstance); err != nil {
+				ww.logger.Error("could not evict workflow task executor", "error", err)
+			}
+
+			return
+		}
+
+		if inflight.abandoned() {
+			// The task has been abandoned while its history was being fetched
+			ww.logger.Debug("workflow task abandoned while executing",
+				"instance_id", t.WorkflowInstance.InstanceID, "task_id", t.ID, "error", err)
+
+			if err := ww.cache.Evict(ctx, t.WorkflowInstance); err != nil {
+				ww.logger.Error("could not evict workflow task executor", "error", err)
+			}
+
+			return
+		}
+
 		ww.logger.Panic("could not handle workflow task", "error", err)
 	}
 
 	// Only record the time spent in the workflow code
 	timer.Stop()
 
+	if !inflight.complete() {
+		// The worker is shutting down and has released the task, another worker will execute it
+		ww.logger.Debug("workflow task abandoned, discarding result",
+			"instance_id", t.WorkflowInstance.InstanceID, "task_id", t.ID)
+
+		// The state of the executor doesn't match the history in the backend anymore
+		if err := ww.cache.Evict(ctx, t.WorkflowInstance); err != nil {
+			ww.logger.Error("could not evict workflow task executor", "error", err)
+		}
+
+		return
+	}
+
 	state := core.WorkflowInstanceStateActive
 	if result.Completed {
 		state = core.WorkflowInstanceStateFinished
@@ -163,12 +257,20 @@ func (ww *WorkflowWorker) handle(ctx context.Context, t *task.Workflow) {
 
 	if err := ww.backend.CompleteWorkflowTask(
 		ctx, t, t.WorkflowInstance, state, result.Executed, result.ActivityEvents, result.TimerEvents, result.WorkflowEvents); err != nil {
+		if errors.Is(err, backend.ErrInstanceNotActive) {
+			// Instance has been terminated while the task was being executed, discard the result
+			ww.logger.Warn("workflow instance is not active anymore, discarding workflow task result",
+				"instance_id", t.WorkflowInstance.InstanceID, "task_id", t.ID)
+			return
+		}
+
 		ww.logger.Panic("could not complete workflow task", "error", err)
 	}
 }
 
 func (ww *WorkflowWorker) handleTask(
 	ctx context.Context,
+	inflight *inflightTask,
 	t *task.Workflow,
 ) (*workflow.ExecutionResult, error) {
 	executor, err := ww.getExecutor(ctx, t)
@@ -180,10 +282,14 @@ func (ww *WorkflowWorker) handleTask(
 		// Start heartbeat while processing workflow task
 		heartbeatCtx, cancelHeartbeat := context.WithCancel(ctx)
 		defer cancelHeartbeat()
-		go ww.heartbeatTask(heartbeatCtx, t)
+		go ww.heartbeatTask(heartbeatCtx, inflight, t)
 	}
 
-	result, err := executor.ExecuteTask(ctx, t)
+	// Local activities executed as part of the task are bounded by the workflow task timeout
+	executeCtx, cancel := context.WithTimeout(ctx, ww.options.WorkflowTaskTimeout)
+	defer cancel()
+
+	result, err := executor.ExecuteTask(executeCtx, t)
 	if err != nil {
 		return nil, fmt.Errorf("executing workflow task: %w", err)
 	}
@@ -200,7 +306,8 @@ func (ww *WorkflowWorker) getExecutor(ctx context.Context, t *task.Workflow) (wo
 
 	if !ok {
 		executor = workflow.NewExecutor(
-			ww.backend.Logger(), ww.backend.Tracer(), ww.registry, ww.backend.Converter(), ww.backend, t.WorkflowInstance, clock.New())
+			ww.backend.Logger(), ww.backend.Tracer(), ww.registry, ww.backend.Converter(), ww.backend, t.WorkflowInstance, clock.New(),
+			ww.options.NonDeterminismPolicy)
 	}
 
 	// Cache executor instance for future continuation tasks, or refresh last access time
@@ -211,7 +318,7 @@ func (ww *WorkflowWorker) getExecutor(ctx context.Context, t *task.Workflow) (wo
 	return executor, nil
 }
 
-func (ww *WorkflowWorker) heartbeatTask(ctx context.Context, task *task.Workflow) {
+func (ww *WorkflowWorker) heartbeatTask(ctx context.Context, inflight *inflightTask, task *task.Workflow) {
 	t := time.NewTicker(ww.options.WorkflowHeartbeatInterval)
 	defer t.Stop()
 
@@ -220,8 +327,22 @@ func (ww *WorkflowWorker) heartbeatTask(ctx context.Context, task *task.Workflow
 		case <-ctx.Done():
 			return
 		case <-t.C:
+			// Don't lock the task again after it has been released
+			if inflight.abandoned() {
+				return
+			}
+
 			if err := ww.backend.ExtendWorkflowTask(ctx, task.ID, task.WorkflowInstance); err != nil {
-				ww.logger.Panic("could not heartbeat workflow task", "error", err)
+				// The task has been abandoned while its lock was being extended, or the worker is stopping
+				if inflight.abandoned() || ctx.Err() != nil {
+					return
+				}
+
+				// The lock has been lost, e.g., because it expired and another worker picked up the task. Completing
+				// the task fails in the backend, and its result is discarded.
+				ww.logger.Error("could not heartbeat workflow task, stopping heartbeat",
+					"instance_id", task.WorkflowInstance.InstanceID, "task_id", task.ID, "error", err)
+				return
 			}
 		}
 	}
@@ -235,7 +356,7 @@ func (ww *WorkflowWorker) poll(ctx context.Context, timeout time.Duration) (*tas
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	task, err := ww.backend.GetWorkflowTask(ctx)
+	task, err := ww.backend.GetWorkflowTask(ctx, ww.options.Queues)
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			return nil, nil
@@ -27,11 +27,11 @@ func Test_Cache_StoreAndGet(t *testing.T) {
 
 	i := core.NewWorkflowInstance("instanceID", "executionID")
 	e := wf.NewExecutor(
-		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, converter.DefaultConverter, &testHistoryProvider{}, i, clock.New())
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, converter.DefaultConverter, &testHistoryProvider{}, i, clock.New(), wf.NonDeterminismPolicyFail)
 
 	i2 := core.NewWorkflowInstance("instanceID2", "executionID2")
 	e2 := wf.NewExecutor(
-		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, converter.DefaultConverter, &testHistoryProvider{}, i, clock.New())
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, converter.DefaultConverter, &testHistoryProvider{}, i, clock.New(), wf.NonDeterminismPolicyFail)
 
 	err := c.Store(context.Background(), i, e)
 	require.NoError(t, err)
@@ -61,7 +61,7 @@ func Test_Cache_Evict(t *testing.T) {
 	r := wf.NewRegistry()
 	r.RegisterWorkflow(workflowWithActivity)
 	e := wf.NewExecutor(
-		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, converter.DefaultConverter, &testHistoryProvider{}, i, clock.New())
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, converter.DefaultConverter, &testHistoryProvider{}, i, clock.New(), wf.NonDeterminismPolicyFail)
 
 	err := c.Store(context.Background(), i, e)
 	require.NoError(t, err)
@@ -76,6 +76,27 @@ func Test_Cache_Evict(t *testing.T) {
 	require.Nil(t, e2)
 }
 
+func Test_Cache_EvictInstance(t *testing.T) {
+	c := NewWorkflowExecutorLRUCache(metrics.NewNoopMetricsClient(), 128, time.Second*10)
+
+	i := core.NewWorkflowInstance("instanceID", "executionID")
+	r := wf.NewRegistry()
+	r.RegisterWorkflow(workflowWithActivity)
+	e := wf.NewExecutor(
+		logger.NewDefaultLogger(), trace.NewNoopTracerProvider().Tracer(backend.TracerName), r, converter.DefaultConverter, &testHistoryProvider{}, i, clock.New(), wf.NonDeterminismPolicyFail)
+
+	err := c.Store(context.Background(), i, e)
+	require.NoError(t, err)
+
+	err = c.Evict(context.Background(), i)
+	require.NoError(t, err)
+
+	e2, ok, err := c.Get(context.Background(), i)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Nil(t, e2)
+}
+
 func workflowWithActivity(ctx workflow.Context) (int, error) {
 	r, err := workflow.ExecuteActivity[int](ctx, workflow.ActivityOptions{
 		RetryOptions: workflow.RetryOptions{
@@ -5,16 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/benbjohnson/clock"
 	"github.com/cschleiden/go-workflows/internal/command"
 	"github.com/cschleiden/go-workflows/internal/converter"
 	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/fn"
 	"github.com/cschleiden/go-workflows/internal/history"
 	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/signals"
 	"github.com/cschleiden/go-workflows/internal/sync"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/internal/tracing"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
 	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/internal/workflowtracer"
 	"github.com/cschleiden/go-workflows/log"
@@ -37,24 +41,39 @@ type WorkflowHistoryProvider interface {
 type WorkflowExecutor interface {
 	ExecuteTask(ctx context.Context, t *task.Workflow) (*ExecutionResult, error)
 
+	// Query brings the executor up to date with the history of the given workflow instance and invokes the query
+	// handler with the given name. No events are added to the history.
+	Query(ctx context.Context, instance *core.WorkflowInstance, query string, args []payload.Payload) (payload.Payload, error)
+
+	// QueryNames brings the executor up to date with the history of the given workflow instance and returns the
+	// names of all registered query handlers.
+	QueryNames(ctx context.Context, instance *core.WorkflowInstance) ([]string, error)
+
 	Close()
 }
 
 type executor struct {
-	registry          *Registry
-	historyProvider   WorkflowHistoryProvider
-	workflow          *workflow
-	workflowTracer    *workflowtracer.WorkflowTracer
-	workflowState     *workflowstate.WfState
-	workflowCtx       sync.Context
-	workflowCtxCancel sync.CancelFunc
-	clock             clock.Clock
-	logger            log.Logger
-	tracer            trace.Tracer
-	lastSequenceID    int64
-}
-
-func NewExecutor(logger log.Logger, tracer trace.Tracer, registry *Registry, cv converter.Converter, historyProvider WorkflowHistoryProvider, instance *core.WorkflowInstance, clock clock.Clock) WorkflowExecutor {
+	registry             *Registry
+	historyProvider      WorkflowHistoryProvider
+	workflow             *workflow
+	workflowStarted      *history.ExecutionStartedAttributes
+	workflowTracer       *workflowtracer.WorkflowTracer
+	workflowState        *workflowstate.WfState
+	workflowCtx          sync.Context
+	workflowCtxCancel    sync.CancelFunc
+	clock                clock.Clock
+	converter            converter.Converter
+	nonDeterminismPolicy NonDeterminismPolicy
+	logger               log.Logger
+	tracer               trace.Tracer
+	lastSequenceID       int64
+	timedOut             bool
+}
+
+func NewExecutor(
+	logger log.Logger, tracer trace.Tracer, registry *Registry, cv converter.Converter, historyProvider WorkflowHistoryProvider,
+	instance *core.WorkflowInstance, clock clock.Clock, nonDeterminismPolicy NonDeterminismPolicy,
+) WorkflowExecutor {
 	s := workflowstate.NewWorkflowState(instance, logger, clock)
 
 	wfTracer := workflowtracer.New(tracer)
@@ -66,15 +85,17 @@ func NewExecutor(logger log.Logger, tracer trace.Tracer, registry *Registry, cv
 	wfCtx, cancel := sync.WithCancel(wfCtx)
 
 	return &executor{
-		registry:          registry,
-		historyProvider:   historyProvider,
-		workflowTracer:    wfTracer,
-		workflowState:     s,
-		workflowCtx:       wfCtx,
-		workflowCtxCancel: cancel,
-		clock:             clock,
-		logger:            logger,
-		tracer:            tracer,
+		registry:             registry,
+		historyProvider:      historyProvider,
+		workflowTracer:       wfTracer,
+		workflowState:        s,
+		workflowCtx:          wfCtx,
+		workflowCtxCancel:    cancel,
+		clock:                clock,
+		converter:            cv,
+		nonDeterminismPolicy: nonDeterminismPolicy,
+		logger:               logger,
+		tracer:               tracer,
 	}
 }
 
@@ -94,6 +115,10 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 
 	logger := e.logger.With("task_id", t.ID, "instance_id", t.WorkflowInstance.InstanceID)
 
+	// Local activities are executed with the context of the task, which bounds the time they may take
+	e.workflowState.SetTaskContext(ctx)
+	defer e.workflowState.SetTaskContext(nil)
+
 	logger.Debug("Executing workflow task", "task_last_sequence_id", t.LastSequenceID)
 
 	if t.WorkflowInstanceState == core.WorkflowInstanceStateFinished {
@@ -123,6 +148,12 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 		if err := e.replayHistory(h); err != nil {
 			logger.Error("Error while replaying history", "error", err)
 
+			var ndErr *workflowerrors.NonDeterminismError
+			if errors.As(err, &ndErr) && e.nonDeterminismPolicy == NonDeterminismPolicyBlock {
+				// Leave the task for a worker with fixed workflow code
+				return nil, fmt.Errorf("replaying history: %w", err)
+			}
+
 			// Fail workflow with an error. Skip executing new events, but still go through the commands
 			e.workflowCompleted(nil, err)
 			skipNewEvents = true
@@ -142,7 +173,14 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 	toExecute := []*history.Event{e.createNewEvent(history.EventType_WorkflowTaskStarted, &history.WorkflowTaskStartedAttributes{})}
 	executedEvents := toExecute
 
-	toExecute = append(toExecute, t.NewEvents...)
+	// A continued workflow instance might have received events, e.g., signals, before its new execution has been
+	// started. Ensure the workflow is started before any other event is applied.
+	newEvents := t.NewEvents
+	sort.SliceStable(newEvents, func(i, j int) bool {
+		return newEvents[i].Type == history.EventType_WorkflowExecutionStarted && newEvents[j].Type != history.EventType_WorkflowExecutionStarted
+	})
+
+	toExecute = append(toExecute, newEvents...)
 
 	// Execute new events received from the backend
 	if !skipNewEvents {
@@ -167,6 +205,12 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 			continue
 		}
 
+		if _, ok := c.(*command.UpdateCommand); ok && completed {
+			// Nothing is recorded after the workflow has finished, callers of updates still running are told that
+			// their updates have not been completed
+			continue
+		}
+
 		r := c.Execute(e.clock)
 		if r == nil {
 			continue
@@ -179,6 +223,17 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 		workflowEvents = append(workflowEvents, r.WorkflowEvents...)
 	}
 
+	// Apply the parent close policies of sub-workflows which are still running
+	if completed {
+		for _, c := range e.workflowState.Commands() {
+			if sc, ok := c.(*command.ScheduleSubWorkflowCommand); ok {
+				if event := sc.ParentClosed(e.clock); event != nil {
+					workflowEvents = append(workflowEvents, *event)
+				}
+			}
+		}
+	}
+
 	// Events from commands don't have to be executed again, add them to the executed events.
 	executedEvents = append(executedEvents, newCommandEvents...)
 
@@ -202,8 +257,50 @@ func (e *executor) ExecuteTask(ctx context.Context, t *task.Workflow) (*Executio
 	}, nil
 }
 
+func (e *executor) Query(ctx context.Context, instance *core.WorkflowInstance, query string, args []payload.Payload) (payload.Payload, error) {
+	if err := e.replayForQuery(ctx, instance); err != nil {
+		return nil, err
+	}
+
+	return e.workflowState.HandleQuery(query, args)
+}
+
+func (e *executor) QueryNames(ctx context.Context, instance *core.WorkflowInstance) ([]string, error) {
+	if err := e.replayForQuery(ctx, instance); err != nil {
+		return nil, err
+	}
+
+	return e.workflowState.QueryNames(), nil
+}
+
+func (e *executor) replayForQuery(ctx context.Context, instance *core.WorkflowInstance) error {
+	h, err := e.historyProvider.GetWorkflowInstanceHistory(ctx, instance, &e.lastSequenceID)
+	if err != nil {
+		return fmt.Errorf("getting workflow history: %w", err)
+	}
+
+	if err := e.replayHistory(h); err != nil {
+		return fmt.Errorf("replaying workflow history: %w", err)
+	}
+
+	if e.workflow == nil {
+		return errors.New("workflow has not been started")
+	}
+
+	return nil
+}
+
 func (e *executor) replayHistory(h []*history.Event) error {
 	e.workflowState.SetReplaying(true)
+
+	// Versions have to be known when GetVersion is called, before the marker events are replayed
+	for _, event := range h {
+		if event.Type == history.EventType_VersionMarker {
+			a := event.Attributes.(*history.VersionMarkerAttributes)
+			e.workflowState.AddRecordedVersion(a.ChangeID, a.Version)
+		}
+	}
+
 	for _, event := range h {
 		if event.SequenceID < e.lastSequenceID {
 			e.logger.Panic("history has older events than current state")
@@ -226,9 +323,31 @@ func (e *executor) executeNewEvents(newEvents []*history.Event) ([]*history.Even
 		if err := e.executeEvent(event); err != nil {
 			return newEvents[:i], err
 		}
+
+		if event.Type == history.EventType_WorkflowExecutionTimedOut && e.timedOut &&
+			event.Attributes.(*history.ExecutionTimedOutAttributes).Action == core.ExecutionTimeoutActionTerminate {
+			// Stop the workflow without executing any more of its code
+			e.workflowCompleted(nil, workflowerrors.NewExecutionTimeoutError(nil))
+
+			return newEvents[:i+1], nil
+		}
+	}
+
+	// Wait for local activities started while executing the events, and continue the workflow with their results
+	for e.workflowState.CompleteLocalActivity() {
+		if err := e.workflow.Continue(); err != nil {
+			return newEvents, err
+		}
 	}
 
 	if e.workflow.Completed() {
+		// Sub-workflows the workflow did not wait for are handled according to their parent close policy
+		for _, c := range e.workflowState.Commands() {
+			if _, ok := c.(*command.ScheduleSubWorkflowCommand); ok {
+				e.workflowState.RemoveFuture(c.ID())
+			}
+		}
+
 		// TODO: Is this too early? We haven't committed some of the commands
 		if e.workflowState.HasPendingFutures() {
 			e.logger.Panic("workflow completed, but there are still pending futures")
@@ -268,9 +387,18 @@ func (e *executor) executeEvent(event *history.Event) error {
 	case history.EventType_WorkflowExecutionFinished:
 	// Ignore
 
+	case history.EventType_WorkflowExecutionTerminated:
+	// Ignore
+
+	case history.EventType_WorkflowExecutionContinuedAsNew:
+	// Ignore
+
 	case history.EventType_WorkflowExecutionCanceled:
 		err = e.handleWorkflowCanceled()
 
+	case history.EventType_WorkflowExecutionTimedOut:
+		err = e.handleWorkflowTimedOut(event.Attributes.(*history.ExecutionTimedOutAttributes))
+
 	case history.EventType_WorkflowTaskStarted:
 		err = e.handleWorkflowTaskStarted(event, event.Attributes.(*history.WorkflowTaskStartedAttributes))
 
@@ -298,6 +426,24 @@ func (e *executor) executeEvent(event *history.Event) error {
 	case history.EventType_SideEffectResult:
 		err = e.handleSideEffectResult(event, event.Attributes.(*history.SideEffectResultAttributes))
 
+	case history.EventType_LocalActivityResult:
+		err = e.handleLocalActivityResult(event, event.Attributes.(*history.LocalActivityResultAttributes))
+
+	case history.EventType_VersionMarker:
+		err = e.handleVersionMarker(event, event.Attributes.(*history.VersionMarkerAttributes))
+
+	case history.EventType_SearchAttributesUpserted:
+		err = e.handleSearchAttributesUpserted(event)
+
+	case history.EventType_UpdateRequested:
+		err = e.handleUpdateRequested(event, event.Attributes.(*history.UpdateRequestedAttributes))
+	case history.EventType_UpdateAccepted:
+		err = e.handleUpdateRecorded(event, event.Attributes.(*history.UpdateAcceptedAttributes).UpdateID)
+	case history.EventType_UpdateRejected:
+		err = e.handleUpdateRecorded(event, event.Attributes.(*history.UpdateRejectedAttributes).UpdateID)
+	case history.EventType_UpdateCompleted:
+		err = e.handleUpdateRecorded(event, event.Attributes.(*history.UpdateCompletedAttributes).UpdateID)
+
 	case history.EventType_SubWorkflowScheduled:
 		err = e.handleSubWorkflowScheduled(event, event.Attributes.(*history.SubWorkflowScheduledAttributes))
 	case history.EventType_SubWorkflowCancellationRequested:
@@ -307,6 +453,20 @@ func (e *executor) executeEvent(event *history.Event) error {
 	case history.EventType_SubWorkflowCompleted:
 		err = e.handleSubWorkflowCompleted(event, event.Attributes.(*history.SubWorkflowCompletedAttributes))
 
+	case history.EventType_SignalWorkflowRequested:
+		err = e.handleSignalWorkflowRequested(event, event.Attributes.(*history.SignalWorkflowRequestedAttributes))
+	case history.EventType_SignalWorkflowDelivered:
+		err = e.handleExternalWorkflowResult(event, nil)
+	case history.EventType_SignalWorkflowFailed:
+		err = e.handleExternalWorkflowResult(event, event.Attributes.(*history.SignalWorkflowFailedAttributes).Error)
+
+	case history.EventType_CancelWorkflowRequested:
+		err = e.handleCancelWorkflowRequested(event, event.Attributes.(*history.CancelWorkflowRequestedAttributes))
+	case history.EventType_CancelWorkflowDelivered:
+		err = e.handleExternalWorkflowResult(event, nil)
+	case history.EventType_CancelWorkflowFailed:
+		err = e.handleExternalWorkflowResult(event, event.Attributes.(*history.CancelWorkflowFailedAttributes).Error)
+
 	default:
 		return fmt.Errorf("unknown event type: %v", event.Type)
 	}
@@ -321,6 +481,9 @@ func (e *executor) handleWorkflowExecutionStarted(a *history.ExecutionStartedAtt
 	}
 
 	e.workflow = NewWorkflow(reflect.ValueOf(wfFn))
+	e.workflowStarted = a
+	e.workflowState.SetQueue(a.Queue)
+	e.workflowState.SetPriority(a.Priority)
 
 	return e.workflow.Execute(e.workflowCtx, a.Inputs)
 }
@@ -331,6 +494,26 @@ func (e *executor) handleWorkflowCanceled() error {
 	return e.workflow.Continue()
 }
 
+func (e *executor) handleWorkflowTimedOut(a *history.ExecutionTimedOutAttributes) error {
+	if e.workflow == nil || e.workflow.Completed() {
+		// Workflow has finished before the timeout was delivered
+		return nil
+	}
+
+	e.timedOut = true
+
+	if a.Action == core.ExecutionTimeoutActionCancel {
+		e.workflowCtxCancel()
+
+		return e.workflow.Continue()
+	}
+
+	// Backends terminate instances which are terminated on timeout, together with their sub-workflows and
+	// activities. If the timeout event has been delivered anyway, e.g., because the task was started before the
+	// instance was terminated, the workflow is completed by executeNewEvents without running any more workflow code.
+	return nil
+}
+
 func (e *executor) handleWorkflowTaskStarted(event *history.Event, a *history.WorkflowTaskStartedAttributes) error {
 	e.workflowState.SetTime(event.Timestamp)
 
@@ -339,18 +522,17 @@ func (e *executor) handleWorkflowTaskStarted(event *history.Event, a *history.Wo
 
 func (e *executor) handleActivityScheduled(event *history.Event, a *history.ActivityScheduledAttributes) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
-	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled an activity which could not be found")
-	}
 
-	sac, ok := c.(*command.ScheduleActivityCommand)
-	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled an activity, not: %v", c.Type())
+	if e.isLegacySignal(c, a) {
+		c.Commit()
+		return nil
 	}
 
 	// Ensure the same activity was scheduled again
-	if a.Name != sac.Name {
-		return fmt.Errorf("previous workflow execution scheduled different type of activity: %s, %s", a.Name, sac.Name)
+	sac, ok := c.(*command.ScheduleActivityCommand)
+	if !ok || sac.Name != a.Name || inputsHash(sac.Inputs) != inputsHash(a.Inputs) {
+		return workflowerrors.NewNonDeterminismError(
+			event.ScheduleEventID, describeScheduled("activity", a.Name, a.Inputs), describeCommand(c))
 	}
 
 	c.Commit()
@@ -361,6 +543,11 @@ func (e *executor) handleActivityScheduled(event *history.Event, a *history.Acti
 func (e *executor) handleActivityCompleted(event *history.Event, a *history.ActivityCompletedAttributes) error {
 	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
 	if !ok {
+		if e.activityDone(event.ScheduleEventID) {
+			// Activity has already timed out, ignore the late result
+			return nil
+		}
+
 		return fmt.Errorf("could not find pending future for activity completion")
 	}
 
@@ -376,23 +563,40 @@ func (e *executor) handleActivityCompleted(event *history.Event, a *history.Acti
 		return fmt.Errorf("previous workflow execution scheduled an activity which could not be found")
 	}
 
-	sac, ok := c.(*command.ScheduleActivityCommand)
-	if !ok {
+	switch c.(type) {
+	case *command.ScheduleActivityCommand, *command.SignalWorkflowCommand:
+		c.Done()
+	default:
 		return fmt.Errorf("previous workflow execution scheduled an activity, not: %v", c.Type())
 	}
 
-	sac.Done()
-
 	return e.workflow.Continue()
 }
 
 func (e *executor) handleActivityFailed(event *history.Event, a *history.ActivityFailedAttributes) error {
 	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
 	if !ok {
+		if e.activityDone(event.ScheduleEventID) {
+			// Activity has already timed out, ignore the late result
+			return nil
+		}
+
 		return errors.New("no pending future for activity failed event")
 	}
 
-	if err := f(nil, errors.New(a.Reason)); err != nil {
+	var activityErr error
+	if a.TimeoutType != "" {
+		activityErr = workflowerrors.NewActivityTimeoutError(a.TimeoutType)
+	} else {
+		activityErr = failureError(e.converter, a.Error)
+	}
+
+	// Keep heartbeat details around, so that they can be passed to the next attempt of the activity
+	if a.HeartbeatDetails != nil {
+		activityErr = workflowerrors.WithHeartbeatDetails(activityErr, a.HeartbeatDetails)
+	}
+
+	if err := f(nil, activityErr); err != nil {
 		return fmt.Errorf("setting activity failed result: %w", err)
 	}
 
@@ -403,24 +607,59 @@ func (e *executor) handleActivityFailed(event *history.Event, a *history.Activit
 		return fmt.Errorf("previous workflow execution scheduled an activity which could not be found")
 	}
 
-	sac, ok := c.(*command.ScheduleActivityCommand)
-	if !ok {
+	switch c.(type) {
+	case *command.ScheduleActivityCommand, *command.SignalWorkflowCommand:
+		c.Done()
+	default:
 		return fmt.Errorf("previous workflow execution scheduled an activity, not: %v", c.Type())
 	}
 
-	sac.Done()
-
 	return e.workflow.Continue()
 }
 
-func (e *executor) handleTimerScheduled(event *history.Event, a *history.TimerScheduledAttributes) error {
-	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+// legacySignalActivity is the name of the activity workflows used to signal other workflow instances, before signals
+// were sent natively
+var legacySignalActivity = fn.Name((*signals.Activities)(nil).DeliverWorkflowSignal)
+
+// isLegacySignal returns true if the given activity has been scheduled to deliver the signal of the given command.
+// Histories recorded before signals were sent natively contain the activity instead of a SignalWorkflowRequested
+// event, its result resolves the signal.
+func (e *executor) isLegacySignal(c command.Command, a *history.ActivityScheduledAttributes) bool {
+	swc, ok := c.(*command.SignalWorkflowCommand)
+	if !ok || a.Name != legacySignalActivity {
+		return false
+	}
+
+	instanceID, err := e.converter.To(swc.Instance.InstanceID)
+	if err != nil {
+		return false
+	}
+
+	name, err := e.converter.To(swc.Name)
+	if err != nil {
+		return false
+	}
+
+	return inputsHash(a.Inputs) == inputsHash([]payload.Payload{instanceID, name, swc.Arg})
+}
+
+// activityDone returns true if the activity scheduled with the given id has already been resolved. A backend
+// might record an activity timeout while the activity is still running, its result arrives afterwards.
+func (e *executor) activityDone(scheduleEventID int64) bool {
+	c := e.workflowState.CommandByScheduleEventID(scheduleEventID)
 	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled a timer")
+		return false
 	}
 
+	_, ok := c.(*command.ScheduleActivityCommand)
+	return ok && c.State() == command.CommandState_Done
+}
+
+func (e *executor) handleTimerScheduled(event *history.Event, a *history.TimerScheduledAttributes) error {
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+
 	if _, ok := c.(*command.ScheduleTimerCommand); !ok {
-		return fmt.Errorf("previous workflow execution scheduled a timer, not: %v", c.Type())
+		return workflowerrors.NewNonDeterminismError(event.ScheduleEventID, "timer", describeCommand(c))
 	}
 
 	c.Commit()
@@ -486,17 +725,12 @@ func (e *executor) handleTimerCanceled(event *history.Event, a *history.TimerCan
 
 func (e *executor) handleSubWorkflowScheduled(event *history.Event, a *history.SubWorkflowScheduledAttributes) error {
 	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
-	if c == nil {
-		return fmt.Errorf("previous workflow execution scheduled a sub workflow")
-	}
 
+	// Ensure the same sub-workflow was scheduled again
 	sswc, ok := c.(*command.ScheduleSubWorkflowCommand)
-	if !ok {
-		return fmt.Errorf("previous workflow execution scheduled a sub workflow, not: %v", c.Type())
-	}
-
-	if a.Name != sswc.Name {
-		return fmt.Errorf("previous workflow execution scheduled different type of sub workflow: %s, %s", a.Name, sswc.Name)
+	if !ok || sswc.Name != a.Name || inputsHash(sswc.Inputs) != inputsHash(a.Inputs) {
+		return workflowerrors.NewNonDeterminismError(
+			event.ScheduleEventID, describeScheduled("sub-workflow", a.Name, a.Inputs), describeCommand(c))
 	}
 
 	// If we are replaying this event, the command will have generated a new instance ID. Ensure we use the same one as
@@ -530,7 +764,7 @@ func (e *executor) handleSubWorkflowFailed(event *history.Event, a *history.SubW
 		return errors.New("no pending future found for sub workflow failed event")
 	}
 
-	if err := f(nil, errors.New(a.Error)); err != nil {
+	if err := f(nil, failureError(e.converter, a.Error)); err != nil {
 		return fmt.Errorf("setting sub workflow failed result: %w", err)
 	}
 
@@ -576,6 +810,65 @@ func (e *executor) handleSubWorkflowCompleted(event *history.Event, a *history.S
 	return e.workflow.Continue()
 }
 
+func (e *executor) handleSignalWorkflowRequested(event *history.Event, a *history.SignalWorkflowRequestedAttributes) error {
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+
+	// Ensure the same workflow instance was signaled again
+	swc, ok := c.(*command.SignalWorkflowCommand)
+	if !ok || swc.Instance.InstanceID != a.InstanceID || swc.Name != a.Name {
+		return workflowerrors.NewNonDeterminismError(
+			event.ScheduleEventID, describeSignal(a.InstanceID, a.Name), describeCommand(c))
+	}
+
+	c.Commit()
+
+	return nil
+}
+
+func (e *executor) handleCancelWorkflowRequested(event *history.Event, a *history.CancelWorkflowRequestedAttributes) error {
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+
+	// Ensure the same workflow instance was canceled again
+	cwc, ok := c.(*command.CancelWorkflowCommand)
+	if !ok || cwc.Instance.InstanceID != a.InstanceID {
+		return workflowerrors.NewNonDeterminismError(
+			event.ScheduleEventID, describeCancel(a.InstanceID), describeCommand(c))
+	}
+
+	c.Commit()
+
+	return nil
+}
+
+// handleExternalWorkflowResult handles the result of a request to signal or cancel another workflow instance. err is
+// nil if the request has been delivered.
+func (e *executor) handleExternalWorkflowResult(event *history.Event, err *workflowerrors.Error) error {
+	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
+	if !ok {
+		return fmt.Errorf("no pending future found for %v event", event.Type)
+	}
+
+	var resultErr error
+	if err != nil {
+		resultErr = failureError(e.converter, err)
+	}
+
+	if err := f(nil, resultErr); err != nil {
+		return fmt.Errorf("setting %v result: %w", event.Type, err)
+	}
+
+	e.workflowState.RemoveFuture(event.ScheduleEventID)
+
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+	if c == nil {
+		return fmt.Errorf("previous workflow execution did not signal or cancel a workflow instance")
+	}
+
+	c.Done()
+
+	return e.workflow.Continue()
+}
+
 func (e *executor) handleSignalReceived(event *history.Event, a *history.SignalReceivedAttributes) error {
 	// Send signal to workflow channel
 	workflowstate.ReceiveSignal(e.workflowState, a.Name, a.Arg)
@@ -610,13 +903,168 @@ func (e *executor) handleSideEffectResult(event *history.Event, a *history.SideE
 	return e.workflow.Continue()
 }
 
+func (e *executor) handleLocalActivityResult(event *history.Event, a *history.LocalActivityResultAttributes) error {
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+
+	// Ensure the same local activity was executed again
+	lac, ok := c.(*command.LocalActivityCommand)
+	if !ok || lac.Name != a.Name || inputsHash(lac.Inputs) != inputsHash(a.Inputs) {
+		return workflowerrors.NewNonDeterminismError(
+			event.ScheduleEventID, describeScheduled("local activity", a.Name, a.Inputs), describeCommand(c))
+	}
+
+	lac.SetResult(a.Result, a.Error, a.Attempts, a.Deferred)
+	lac.Done()
+
+	f, ok := e.workflowState.FutureByScheduleEventID(event.ScheduleEventID)
+	if !ok {
+		return errors.New("no pending future found for local activity result event")
+	}
+
+	var activityErr error
+	if a.Error != nil {
+		activityErr = failureError(e.converter, a.Error)
+	}
+
+	if err := f(a.Result, activityErr); err != nil {
+		return fmt.Errorf("setting local activity result: %w", err)
+	}
+
+	e.workflowState.RemoveFuture(event.ScheduleEventID)
+
+	return e.workflow.Continue()
+}
+
+func (e *executor) handleVersionMarker(event *history.Event, a *history.VersionMarkerAttributes) error {
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+
+	// Ensure GetVersion was called for the same change again
+	vmc, ok := c.(*command.VersionMarkerCommand)
+	if !ok || vmc.ChangeID() != a.ChangeID {
+		return workflowerrors.NewNonDeterminismError(
+			event.ScheduleEventID, describeVersionMarker(a.ChangeID), describeCommand(c))
+	}
+
+	vmc.Done()
+
+	return e.workflow.Continue()
+}
+
+func (e *executor) handleSearchAttributesUpserted(event *history.Event) error {
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+
+	uc, ok := c.(*command.UpsertSearchAttributesCommand)
+	if !ok {
+		return workflowerrors.NewNonDeterminismError(event.ScheduleEventID, "search attributes upsert", describeCommand(c))
+	}
+
+	uc.Done()
+
+	return e.workflow.Continue()
+}
+
+func (e *executor) handleUpdateRequested(event *history.Event, a *history.UpdateRequestedAttributes) error {
+	var rejectErr error
+
+	handler, ok := e.workflowState.UpdateHandler(a.Name)
+	if e.workflow.Completed() {
+		rejectErr = workflowerrors.NewError("WorkflowCompleted", "workflow has already completed")
+	} else if !ok {
+		rejectErr = workflowerrors.NewError("UnknownUpdate", fmt.Sprintf("no handler registered for update %q", a.Name))
+	} else {
+		rejectErr = validateUpdate(handler, a.Arg)
+	}
+
+	if rejectErr != nil {
+		e.workflowState.AddCommand(command.NewRejectUpdateCommand(
+			e.workflowState.GetNextScheduleEventID(), a.UpdateID, workflowerrors.FromError(e.converter, rejectErr)))
+
+		return nil
+	}
+
+	e.workflowState.AddCommand(command.NewAcceptUpdateCommand(e.workflowState.GetNextScheduleEventID(), a.UpdateID))
+
+	// Run the handler alongside the workflow, it might wait for activities, timers, or other updates
+	e.workflow.Go(e.workflowCtx, func(ctx sync.Context) {
+		result, err := handler.Handle(ctx, a.Arg)
+
+		e.workflowState.AddCommand(command.NewCompleteUpdateCommand(
+			e.workflowState.GetNextScheduleEventID(), a.UpdateID, result, workflowerrors.FromError(e.converter, err)))
+	})
+
+	return e.workflow.Continue()
+}
+
+// validateUpdate invokes the validator of the given update handler. A panicking validator rejects the update.
+func validateUpdate(handler *workflowstate.UpdateHandler, arg payload.Payload) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handler.Validate(arg)
+}
+
+// handleUpdateRecorded handles the recorded outcome of an update request, which has to match the command of the
+// current execution.
+func (e *executor) handleUpdateRecorded(event *history.Event, updateID string) error {
+	c := e.workflowState.CommandByScheduleEventID(event.ScheduleEventID)
+
+	uc, ok := c.(*command.UpdateCommand)
+	if !ok || uc.EventType() != event.Type || uc.UpdateID != updateID {
+		return workflowerrors.NewNonDeterminismError(
+			event.ScheduleEventID, fmt.Sprintf("%v for update %s", event.Type, updateID), describeCommand(c))
+	}
+
+	uc.Done()
+
+	return nil
+}
+
 func (e *executor) workflowCompleted(result payload.Payload, err error) {
 	eventId := e.workflowState.GetNextScheduleEventID()
 
-	cmd := command.NewCompleteWorkflowCommand(eventId, e.workflowState.Instance(), result, err)
+	if canErr, ok := workflowerrors.AsContinueAsNewError(err); ok && e.workflowStarted != nil {
+		// Workflow requested to continue as new, start a new execution with the same name, metadata, queue,
+		// priority, and search attributes
+		searchAttributes := e.workflowStarted.SearchAttributes.Upsert(nil)
+		for _, c := range e.workflowState.Commands() {
+			if uc, ok := c.(*command.UpsertSearchAttributesCommand); ok {
+				searchAttributes = searchAttributes.Upsert(uc.Attributes())
+			}
+		}
+
+		cmd := command.NewContinueAsNewCommand(
+			eventId, e.workflowState.Instance(), e.workflowStarted.Name, canErr.Inputs, e.workflowStarted.Metadata)
+		cmd.Queue = e.workflowStarted.Queue
+		cmd.Priority = e.workflowStarted.Priority
+		cmd.SearchAttributes = searchAttributes.Upsert(canErr.SearchAttributes)
+		e.workflowState.AddCommand(cmd)
+
+		return
+	}
+
+	var timeoutErr *workflowerrors.ExecutionTimeoutError
+	if e.timedOut && err != nil && !errors.As(err, &timeoutErr) {
+		// Workflow has been canceled because it exceeded its execution timeout
+		err = workflowerrors.NewExecutionTimeoutError(err)
+	}
+
+	cmd := command.NewCompleteWorkflowCommand(
+		eventId, e.workflowState.Instance(), result, workflowerrors.FromError(e.converter, err))
 	e.workflowState.AddCommand(cmd)
 }
 
+// failureError reconstructs the error recorded for a failed activity or sub-workflow
+func failureError(cv converter.Converter, err *workflowerrors.Error) error {
+	if err == nil {
+		return errors.New("unknown error")
+	}
+
+	return workflowerrors.RecordedError(cv, err)
+}
+
 func (e *executor) nextSequenceID() int64 {
 	e.lastSequenceID++
 	return e.lastSequenceID
@@ -2,6 +2,8 @@ package workflow
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"testing"
 	"time"
@@ -17,6 +19,8 @@ import (
 	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/sync"
 	"github.com/cschleiden/go-workflows/internal/task"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	wf "github.com/cschleiden/go-workflows/workflow"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
@@ -35,7 +39,7 @@ func newExecutor(r *Registry, i *core.WorkflowInstance, historyProvider Workflow
 	logger := logger.NewDefaultLogger()
 	tracer := trace.NewNoopTracerProvider().Tracer("test")
 
-	e := NewExecutor(logger, tracer, r, converter.DefaultConverter, historyProvider, i, clock.New())
+	e := NewExecutor(logger, tracer, r, converter.DefaultConverter, historyProvider, i, clock.New(), NonDeterminismPolicyFail)
 
 	return e.(*executor)
 }
@@ -118,6 +122,33 @@ func Test_Executor(t *testing.T) {
 				require.Equal(t, []payload.Payload{inputs}, e.workflowState.Commands()[0].(*command.ScheduleActivityCommand).Inputs)
 			},
 		},
+		{
+			name: "Activities inherit the priority of the workflow instance",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx sync.Context) error {
+					priority := 0
+					wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42)
+					wf.ExecuteActivity[int](ctx, wf.ActivityOptions{Priority: &priority}, activity1, 42)
+
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflow)
+				r.RegisterActivity(activity1)
+
+				task := startWorkflowTask("instanceID", workflow)
+				task.NewEvents[0].Attributes.(*history.ExecutionStartedAttributes).Priority = 10
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.Len(t, result.ActivityEvents, 2)
+
+				require.Equal(t, 10, result.ActivityEvents[0].Attributes.(*history.ActivityScheduledAttributes).Priority)
+				require.Equal(t, 0, result.ActivityEvents[1].Attributes.(*history.ActivityScheduledAttributes).Priority)
+			},
+		},
 		{
 			name: "Workflow with activity replay",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
@@ -183,6 +214,453 @@ func Test_Executor(t *testing.T) {
 				require.Len(t, e.workflowState.Commands(), 2)
 			},
 		},
+		{
+			name: "Activity timeout",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var activityErr error
+				workflow := func(ctx wf.Context) error {
+					_, activityErr = wf.ExecuteActivity[int](ctx, wf.ActivityOptions{
+						RetryOptions:        wf.RetryOptions{MaxAttempts: 1},
+						StartToCloseTimeout: time.Second,
+					}, activity1, 42).Get(ctx)
+
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflow)
+				r.RegisterActivity(activity1)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+				require.Len(t, result.ActivityEvents, 1)
+
+				a := result.ActivityEvents[0].Attributes.(*history.ActivityScheduledAttributes)
+				require.Equal(t, time.Second, a.Timeouts.StartToClose)
+
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewActivityTimedOutEvent(time.Now(), 1, workflowerrors.TimeoutTypeStartToClose, nil),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+				require.NoError(t, err)
+
+				var timeoutErr *wf.ActivityTimeoutError
+				require.ErrorAs(t, activityErr, &timeoutErr)
+				require.Equal(t, wf.TimeoutTypeStartToClose, timeoutErr.TimeoutType)
+
+				// A late result for the timed out activity is ignored
+				activityResult, _ := converter.DefaultConverter.To(42)
+				signalArg, _ := converter.DefaultConverter.To("")
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{
+						Result: activityResult,
+					}, history.ScheduleEventID(1)),
+					history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{
+						Name: "signal",
+						Arg:  signalArg,
+					}),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+				require.NoError(t, e.workflow.err)
+			},
+		},
+		{
+			name: "Activity failure",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context) error {
+					_, err := wf.ExecuteActivity[int](ctx, wf.ActivityOptions{
+						RetryOptions: wf.RetryOptions{MaxAttempts: 1},
+					}, activity1, 42).Get(ctx)
+
+					var activityErr *wf.Error
+					if !errors.As(err, &activityErr) || activityErr.Type != "InvalidInput" {
+						return errors.New("unexpected activity error")
+					}
+
+					var details string
+					if err := activityErr.Details(&details); err != nil || details != "details" {
+						return errors.New("unexpected activity error details")
+					}
+
+					return fmt.Errorf("activity failed: %w", err)
+				}
+
+				r.RegisterWorkflow(workflow)
+				r.RegisterActivity(activity1)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+				require.Len(t, result.ActivityEvents, 1)
+
+				activityErr := wf.NewError("InvalidInput", "invalid input").WithDetails("details")
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityFailed, &history.ActivityFailedAttributes{
+						Error: workflowerrors.FromError(converter.DefaultConverter, activityErr),
+					}, history.ScheduleEventID(1)),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+
+				finished := result.Executed[len(result.Executed)-1]
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, finished.Type)
+
+				workflowErr := finished.Attributes.(*history.ExecutionCompletedAttributes).Error
+				require.Equal(t, "fmt.wrapError", workflowErr.Type)
+				require.Equal(t, "activity failed: invalid input", workflowErr.Message)
+				require.NotNil(t, workflowErr.Cause)
+				require.Equal(t, "InvalidInput", workflowErr.Cause.Type)
+			},
+		},
+		{
+			name: "GetVersion records version",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var versions []int
+				workflow := func(ctx wf.Context) error {
+					versions = append(versions, wf.GetVersion(ctx, "change", wf.DefaultVersion, 2))
+
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+
+					// Subsequent calls return the same version
+					versions = append(versions, wf.GetVersion(ctx, "change", wf.DefaultVersion, 3))
+
+					return err
+				}
+
+				r.RegisterWorkflow(workflow)
+				r.RegisterActivity(activity1)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+				require.Equal(t, []int{2}, versions)
+
+				var marker *history.Event
+				for _, event := range result.Executed {
+					if event.Type == history.EventType_VersionMarker {
+						marker = event
+					}
+				}
+				require.NotNil(t, marker)
+				require.Equal(t, &history.VersionMarkerAttributes{ChangeID: "change", Version: 2}, marker.Attributes)
+
+				require.Len(t, result.ActivityEvents, 1)
+				require.Equal(t, int64(2), result.ActivityEvents[0].ScheduleEventID)
+
+				// Replay the history with a new executor, the recorded version is returned
+				versions = nil
+				hp.history = result.Executed
+				e = newExecutor(r, i, hp)
+
+				activityResult, _ := converter.DefaultConverter.To(42)
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{
+						Result: activityResult,
+					}, history.ScheduleEventID(2)),
+				}, hp.history[len(hp.history)-1].SequenceID))
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.True(t, result.Completed)
+				require.Equal(t, []int{2, 2}, versions)
+			},
+		},
+		{
+			name: "GetVersion returns DefaultVersion for executions before the change",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				var version int
+				workflow := func(ctx wf.Context) error {
+					version = wf.GetVersion(ctx, "change", wf.DefaultVersion, 1)
+
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflow)
+				r.RegisterActivity(activity1)
+
+				inputs, _ := converter.DefaultConverter.To(42)
+				activityResult, _ := converter.DefaultConverter.To(42)
+
+				// History recorded by the workflow before GetVersion was added
+				hp.history = []*history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:   fn.Name(workflow),
+						Inputs: []payload.Payload{},
+					}),
+					history.NewHistoryEvent(2, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+						Name:   "activity1",
+						Inputs: []payload.Payload{inputs},
+					}, history.ScheduleEventID(1)),
+				}
+
+				result, err := e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{
+						Result: activityResult,
+					}, history.ScheduleEventID(1)),
+				}, 2))
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.True(t, result.Completed)
+				require.Equal(t, wf.DefaultVersion, version)
+			},
+		},
+		{
+			name: "SignalWorkflow replays signals delivered by activity",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context) error {
+					_, err := wf.SignalWorkflow(ctx, "other", "signal", 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				instanceID, _ := converter.DefaultConverter.To("other")
+				name, _ := converter.DefaultConverter.To("signal")
+				arg, _ := converter.DefaultConverter.To(42)
+
+				// History recorded by the workflow before signals were sent natively
+				hp.history = []*history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:   fn.Name(workflow),
+						Inputs: []payload.Payload{},
+					}),
+					history.NewHistoryEvent(2, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+						Name:   "DeliverWorkflowSignal",
+						Inputs: []payload.Payload{instanceID, name, arg},
+					}, history.ScheduleEventID(1)),
+				}
+
+				result, err := e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{}, history.ScheduleEventID(1)),
+				}, 2))
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.True(t, result.Completed)
+
+				// The signal is not sent again
+				require.Empty(t, result.WorkflowEvents)
+			},
+		},
+		{
+			name: "UpsertSearchAttributes records event",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context) error {
+					wf.UpsertSearchAttributes(ctx, wf.SearchAttributes{
+						"customer": wf.StringSearchAttribute("c1"),
+					})
+
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflow)
+				r.RegisterActivity(activity1)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+
+				var upserted *history.Event
+				for _, event := range result.Executed {
+					if event.Type == history.EventType_SearchAttributesUpserted {
+						upserted = event
+					}
+				}
+				require.NotNil(t, upserted)
+				require.Equal(t, &history.SearchAttributesUpsertedAttributes{
+					SearchAttributes: core.SearchAttributes{"customer": core.StringSearchAttribute("c1")},
+				}, upserted.Attributes)
+
+				// Replay the history with a new executor, the upsert is not recorded again
+				hp.history = result.Executed
+				e = newExecutor(r, i, hp)
+
+				activityResult, _ := converter.DefaultConverter.To(42)
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_ActivityCompleted, &history.ActivityCompletedAttributes{
+						Result: activityResult,
+					}, history.ScheduleEventID(2)),
+				}, hp.history[len(hp.history)-1].SequenceID))
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.True(t, result.Completed)
+
+				for _, event := range result.Executed {
+					require.NotEqual(t, history.EventType_SearchAttributesUpserted, event.Type)
+				}
+			},
+		},
+		{
+			name: "Non-determinism fails workflow",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context) error {
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflow)
+				r.RegisterActivity(activity1)
+
+				inputs, _ := converter.DefaultConverter.To(42)
+
+				// History recorded by a previous version of the workflow, which scheduled a different activity
+				hp.history = []*history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:   fn.Name(workflow),
+						Inputs: []payload.Payload{},
+					}),
+					history.NewHistoryEvent(2, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+						Name:   "activity2",
+						Inputs: []payload.Payload{inputs},
+					}, history.ScheduleEventID(1)),
+				}
+
+				result, err := e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{}, 2))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+
+				finished := result.Executed[len(result.Executed)-1]
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, finished.Type)
+
+				workflowErr := finished.Attributes.(*history.ExecutionCompletedAttributes).Error
+				require.Equal(t, "workflowerrors.NonDeterminismError", workflowErr.Type)
+				require.Contains(t, workflowErr.Message, `history contains activity "activity2"`)
+				require.Contains(t, workflowErr.Message, `workflow code produced activity "activity1"`)
+			},
+		},
+		{
+			name: "Non-determinism blocks task",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				e.nonDeterminismPolicy = NonDeterminismPolicyBlock
+
+				workflow := func(ctx wf.Context) error {
+					_, err := wf.ExecuteActivity[int](ctx, wf.DefaultActivityOptions, activity1, 42).Get(ctx)
+					return err
+				}
+
+				r.RegisterWorkflow(workflow)
+				r.RegisterActivity(activity1)
+
+				inputs, _ := converter.DefaultConverter.To(23)
+
+				// History recorded with different inputs for the activity
+				hp.history = []*history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:   fn.Name(workflow),
+						Inputs: []payload.Payload{},
+					}),
+					history.NewHistoryEvent(2, time.Now(), history.EventType_ActivityScheduled, &history.ActivityScheduledAttributes{
+						Name:   "activity1",
+						Inputs: []payload.Payload{inputs},
+					}, history.ScheduleEventID(1)),
+				}
+
+				_, err := e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{}, 2))
+
+				var ndErr *wf.NonDeterminismError
+				require.ErrorAs(t, err, &ndErr)
+				require.Equal(t, int64(1), ndErr.ScheduleEventID)
+				require.NotEqual(t, ndErr.Expected, ndErr.Actual)
+			},
+		},
+		{
+			name: "Non-determinism detects missing timer",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				e.nonDeterminismPolicy = NonDeterminismPolicyBlock
+
+				workflow := func(ctx wf.Context) error {
+					return nil
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				hp.history = []*history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:   fn.Name(workflow),
+						Inputs: []payload.Payload{},
+					}),
+					history.NewHistoryEvent(2, time.Now(), history.EventType_TimerScheduled, &history.TimerScheduledAttributes{
+						At: time.Now(),
+					}, history.ScheduleEventID(1)),
+				}
+
+				_, err := e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{}, 2))
+
+				var ndErr *wf.NonDeterminismError
+				require.ErrorAs(t, err, &ndErr)
+				require.Equal(t, "timer", ndErr.Expected)
+				require.Equal(t, "no command", ndErr.Actual)
+			},
+		},
+		{
+			name: "Non-determinism detects missing version marker",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				e.nonDeterminismPolicy = NonDeterminismPolicyBlock
+
+				// GetVersion has been removed from the workflow
+				workflow := func(ctx wf.Context) error {
+					return nil
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				hp.history = []*history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:   fn.Name(workflow),
+						Inputs: []payload.Payload{},
+					}),
+					history.NewHistoryEvent(2, time.Now(), history.EventType_VersionMarker, &history.VersionMarkerAttributes{
+						ChangeID: "change",
+						Version:  1,
+					}, history.ScheduleEventID(1)),
+				}
+
+				_, err := e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{}, 2))
+
+				var ndErr *wf.NonDeterminismError
+				require.ErrorAs(t, err, &ndErr)
+				require.Equal(t, `version marker for change "change"`, ndErr.Expected)
+				require.Equal(t, "no command", ndErr.Actual)
+			},
+		},
+		{
+			name: "Non-determinism detects version marker for different change",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				// The GetVersion calls have been reordered
+				workflow := func(ctx wf.Context) error {
+					wf.GetVersion(ctx, "other-change", wf.DefaultVersion, 1)
+					wf.GetVersion(ctx, "change", wf.DefaultVersion, 1)
+					return nil
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				hp.history = []*history.Event{
+					history.NewHistoryEvent(1, time.Now(), history.EventType_WorkflowExecutionStarted, &history.ExecutionStartedAttributes{
+						Name:   fn.Name(workflow),
+						Inputs: []payload.Payload{},
+					}),
+					history.NewHistoryEvent(2, time.Now(), history.EventType_VersionMarker, &history.VersionMarkerAttributes{
+						ChangeID: "change",
+						Version:  1,
+					}, history.ScheduleEventID(1)),
+					history.NewHistoryEvent(3, time.Now(), history.EventType_VersionMarker, &history.VersionMarkerAttributes{
+						ChangeID: "other-change",
+						Version:  1,
+					}, history.ScheduleEventID(2)),
+				}
+
+				result, err := e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{}, 3))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+
+				finished := result.Executed[len(result.Executed)-1]
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, finished.Type)
+
+				workflowErr := finished.Attributes.(*history.ExecutionCompletedAttributes).Error
+				require.Equal(t, "workflowerrors.NonDeterminismError", workflowErr.Type)
+				require.Contains(t, workflowErr.Message, `history contains version marker for change "change"`)
+				require.Contains(t, workflowErr.Message, `workflow code produced version marker for change "other-change"`)
+			},
+		},
 		{
 			name: "Workflow with new events",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
@@ -454,6 +932,139 @@ func Test_Executor(t *testing.T) {
 				require.Len(t, e.workflowState.Commands(), 1)
 			},
 		},
+		{
+			name: "Workflow with update",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithUpdate := func(ctx sync.Context) (int, error) {
+					total := 0
+
+					if err := wf.SetUpdateHandler(ctx, "add",
+						func(n int) error {
+							if n < 0 {
+								return errors.New("n must not be negative")
+							}
+
+							return nil
+						},
+						func(ctx wf.Context, n int) (int, error) {
+							total += n
+							return total, nil
+						}); err != nil {
+						return 0, err
+					}
+
+					wf.NewSignalChannel[string](ctx, "finish").Receive(ctx)
+
+					return total, nil
+				}
+
+				r.RegisterWorkflow(workflowWithUpdate)
+
+				updateEvent := func(updateID string, n int) *history.Event {
+					arg, err := converter.DefaultConverter.To(n)
+					require.NoError(t, err)
+
+					return history.NewPendingEvent(time.Now(), history.EventType_UpdateRequested, &history.UpdateRequestedAttributes{
+						UpdateID: updateID,
+						Name:     "add",
+						Arg:      arg,
+					})
+				}
+
+				task := startWorkflowTask("instanceID", workflowWithUpdate)
+				task.NewEvents = append(task.NewEvents, updateEvent("u1", 2), updateEvent("u2", -1))
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.False(t, result.Completed)
+
+				outcomes := make(map[history.EventType]*history.Event)
+				for _, event := range result.Executed {
+					outcomes[event.Type] = event
+				}
+
+				require.Equal(t, "u1", outcomes[history.EventType_UpdateAccepted].Attributes.(*history.UpdateAcceptedAttributes).UpdateID)
+
+				completed := outcomes[history.EventType_UpdateCompleted].Attributes.(*history.UpdateCompletedAttributes)
+				require.Equal(t, "u1", completed.UpdateID)
+				require.Nil(t, completed.Error)
+				var total int
+				require.NoError(t, converter.DefaultConverter.From(completed.Result, &total))
+				require.Equal(t, 2, total)
+
+				rejected := outcomes[history.EventType_UpdateRejected].Attributes.(*history.UpdateRejectedAttributes)
+				require.Equal(t, "u2", rejected.UpdateID)
+				require.Equal(t, "n must not be negative", rejected.Error.Message)
+
+				// Replay the history with a new executor, the update outcomes are not recorded again
+				hp.history = result.Executed
+				e = newExecutor(r, i, hp)
+
+				finish, _ := converter.DefaultConverter.To("")
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{
+						Name: "finish",
+						Arg:  finish,
+					}),
+				}, hp.history[len(hp.history)-1].SequenceID))
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.True(t, result.Completed)
+
+				for _, event := range result.Executed {
+					require.NotEqual(t, history.EventType_UpdateAccepted, event.Type)
+					require.NotEqual(t, history.EventType_UpdateRejected, event.Type)
+					require.NotEqual(t, history.EventType_UpdateCompleted, event.Type)
+				}
+
+				require.NoError(t, converter.DefaultConverter.From(e.workflow.Result(), &total))
+				require.Equal(t, 2, total)
+			},
+		},
+		{
+			name: "Workflow with update rejects update when validator panics",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflowWithUpdate := func(ctx sync.Context) (int, error) {
+					if err := wf.SetUpdateHandler(ctx, "add",
+						func(n int) error {
+							panic("validator panic")
+						},
+						func(ctx wf.Context, n int) (int, error) {
+							return n, nil
+						}); err != nil {
+						return 0, err
+					}
+
+					wf.NewSignalChannel[string](ctx, "finish").Receive(ctx)
+
+					return 0, nil
+				}
+
+				r.RegisterWorkflow(workflowWithUpdate)
+
+				arg, err := converter.DefaultConverter.To(1)
+				require.NoError(t, err)
+
+				task := startWorkflowTask("instanceID", workflowWithUpdate)
+				task.NewEvents = append(task.NewEvents, history.NewPendingEvent(time.Now(), history.EventType_UpdateRequested, &history.UpdateRequestedAttributes{
+					UpdateID: "u1",
+					Name:     "add",
+					Arg:      arg,
+				}))
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.NoError(t, e.workflow.err)
+				require.False(t, result.Completed)
+
+				e1 := result.Executed[len(result.Executed)-1]
+				require.Equal(t, history.EventType_UpdateRejected, e1.Type)
+				rejected := e1.Attributes.(*history.UpdateRejectedAttributes)
+				require.Equal(t, "u1", rejected.UpdateID)
+				require.Equal(t, "panic: validator panic", rejected.Error.Message)
+			},
+		},
 		{
 			name: "Completes workflow on unhandled error",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
@@ -488,6 +1099,222 @@ func Test_Executor(t *testing.T) {
 				require.True(t, r1.Completed)
 			},
 		},
+		{
+			name: "Execution timeout terminates workflow",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context) error {
+					wf.NewSignalChannel[string](ctx, "finish").Receive(ctx)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+				require.False(t, result.Completed)
+
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionTimedOut, &history.ExecutionTimedOutAttributes{
+						Action: core.ExecutionTimeoutActionTerminate,
+					}),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+
+				finished := result.Executed[len(result.Executed)-1]
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, finished.Type)
+
+				var timeoutErr *workflowerrors.ExecutionTimeoutError
+				require.ErrorAs(t, failureError(converter.DefaultConverter, finished.Attributes.(*history.ExecutionCompletedAttributes).Error), &timeoutErr)
+				require.Nil(t, timeoutErr.Cause)
+			},
+		},
+		{
+			name: "Execution timeout cancels workflow",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context) error {
+					return wf.Sleep(ctx, time.Hour)
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+				require.False(t, result.Completed)
+
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_WorkflowExecutionTimedOut, &history.ExecutionTimedOutAttributes{
+						Action: core.ExecutionTimeoutActionCancel,
+					}),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+
+				finished := result.Executed[len(result.Executed)-1]
+				require.Equal(t, history.EventType_WorkflowExecutionFinished, finished.Type)
+
+				var timeoutErr *workflowerrors.ExecutionTimeoutError
+				require.ErrorAs(t, failureError(converter.DefaultConverter, finished.Attributes.(*history.ExecutionCompletedAttributes).Error), &timeoutErr)
+				require.EqualError(t, timeoutErr.Cause, sync.Canceled.Error())
+			},
+		},
+		{
+			name: "Continue as new",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context, iteration int) error {
+					return wf.ContinueAsNew(ctx, iteration+1)
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				task := startWorkflowTask("instanceID", workflow, 1)
+
+				result, err := e.ExecuteTask(context.Background(), task)
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+				require.Len(t, e.workflowState.Commands(), 1)
+				require.IsType(t, &command.ContinueAsNewCommand{}, e.workflowState.Commands()[0])
+
+				require.Equal(t, history.EventType_WorkflowExecutionContinuedAsNew, result.Executed[len(result.Executed)-1].Type)
+
+				require.Len(t, result.WorkflowEvents, 1)
+				require.Equal(t, i.InstanceID, result.WorkflowEvents[0].WorkflowInstance.InstanceID)
+				require.NotEqual(t, i.ExecutionID, result.WorkflowEvents[0].WorkflowInstance.ExecutionID)
+				require.Equal(t, history.EventType_WorkflowExecutionStarted, result.WorkflowEvents[0].HistoryEvent.Type)
+
+				a := result.WorkflowEvents[0].HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
+				require.Equal(t, fn.Name(workflow), a.Name)
+
+				var iteration int
+				require.NoError(t, converter.DefaultConverter.From(a.Inputs[0], &iteration))
+				require.Equal(t, 2, iteration)
+			},
+		},
+		{
+			name: "Continue as new keeps search attributes",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context) error {
+					wf.UpsertSearchAttributes(ctx, wf.SearchAttributes{
+						"customer": wf.StringSearchAttribute("c1"),
+						"step":     wf.IntSearchAttribute(1),
+					})
+
+					return wf.ContinueAsNewWithOptions(ctx, wf.ContinueAsNewOptions{
+						SearchAttributes: wf.SearchAttributes{
+							"step": wf.IntSearchAttribute(2),
+						},
+					})
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+				require.True(t, result.Completed)
+
+				require.Len(t, result.WorkflowEvents, 1)
+				a := result.WorkflowEvents[0].HistoryEvent.Attributes.(*history.ExecutionStartedAttributes)
+				require.Equal(t, core.SearchAttributes{
+					"customer": core.StringSearchAttribute("c1"),
+					"step":     core.IntSearchAttribute(2),
+				}, a.SearchAttributes)
+			},
+		},
+		{
+			name: "Query",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context) error {
+					step := "waiting"
+					if err := wf.SetQueryHandler(ctx, "step", func(prefix string) (string, error) {
+						return prefix + step, nil
+					}); err != nil {
+						return err
+					}
+
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+
+					step = "signaled"
+
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+				require.False(t, result.Completed)
+				hp.history = append(hp.history, result.Executed...)
+
+				sarg, _ := converter.DefaultConverter.To("")
+				result, err = e.ExecuteTask(context.Background(), continueTask("instanceID", []*history.Event{
+					history.NewPendingEvent(time.Now(), history.EventType_SignalReceived, &history.SignalReceivedAttributes{
+						Name: "signal",
+						Arg:  sarg,
+					}),
+				}, result.Executed[len(result.Executed)-1].SequenceID))
+				require.NoError(t, err)
+				hp.history = append(hp.history, result.Executed...)
+
+				// Query using a new executor
+				qe := newExecutor(r, i, hp)
+				defer qe.Close()
+
+				arg, _ := converter.DefaultConverter.To("step: ")
+				qr, err := qe.Query(context.Background(), i, "step", []payload.Payload{arg})
+				require.NoError(t, err)
+
+				var step string
+				require.NoError(t, converter.DefaultConverter.From(qr, &step))
+				require.Equal(t, "step: signaled", step)
+
+				// Queries don't produce any commands
+				require.Empty(t, pendingCommands(qe.workflowState.Commands()))
+
+				qe = newExecutor(r, i, hp)
+				defer qe.Close()
+
+				names, err := qe.QueryNames(context.Background(), i)
+				require.NoError(t, err)
+				require.Equal(t, []string{"step"}, names)
+
+				qe = newExecutor(r, i, hp)
+				defer qe.Close()
+
+				_, err = qe.Query(context.Background(), i, "unknown", nil)
+				require.ErrorIs(t, err, workflowstate.ErrUnknownQuery)
+			},
+		},
+		{
+			name: "Query handler panics",
+			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
+				workflow := func(ctx wf.Context) error {
+					if err := wf.SetQueryHandler(ctx, "step", func() (string, error) {
+						panic("query panic")
+					}); err != nil {
+						return err
+					}
+
+					wf.NewSignalChannel[string](ctx, "signal").Receive(ctx)
+
+					return nil
+				}
+
+				r.RegisterWorkflow(workflow)
+
+				result, err := e.ExecuteTask(context.Background(), startWorkflowTask("instanceID", workflow))
+				require.NoError(t, err)
+				hp.history = append(hp.history, result.Executed...)
+
+				qe := newExecutor(r, i, hp)
+				defer qe.Close()
+
+				_, err = qe.Query(context.Background(), i, "step", nil)
+				require.EqualError(t, err, "panic: query panic")
+			},
+		},
 		{
 			name: "Schedule subworkflow",
 			f: func(t *testing.T, r *Registry, e *executor, i *core.WorkflowInstance, hp *testHistoryProvider) {
@@ -0,0 +1,81 @@
+package workflow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+type NonDeterminismPolicy int
+
+const (
+	// NonDeterminismPolicyFail fails the workflow instance with a NonDeterminismError.
+	NonDeterminismPolicyFail NonDeterminismPolicy = iota
+
+	// NonDeterminismPolicyBlock abandons the workflow task without completing it. The task is retried once its
+	// lock expires, which blocks the workflow instance until a worker with fixed workflow code picks it up.
+	NonDeterminismPolicyBlock
+)
+
+// describeCommand returns a description of the given command for NonDeterminismErrors
+func describeCommand(c command.Command) string {
+	switch c := c.(type) {
+	case nil:
+		return "no command"
+
+	case *command.ScheduleActivityCommand:
+		return describeScheduled("activity", c.Name, c.Inputs)
+
+	case *command.LocalActivityCommand:
+		return describeScheduled("local activity", c.Name, c.Inputs)
+
+	case *command.ScheduleSubWorkflowCommand:
+		return describeScheduled("sub-workflow", c.Name, c.Inputs)
+
+	case *command.ScheduleTimerCommand:
+		return "timer"
+
+	case *command.SignalWorkflowCommand:
+		return describeSignal(c.Instance.InstanceID, c.Name)
+
+	case *command.CancelWorkflowCommand:
+		return describeCancel(c.Instance.InstanceID)
+
+	case *command.VersionMarkerCommand:
+		return describeVersionMarker(c.ChangeID())
+
+	default:
+		return fmt.Sprintf("%s command", c.Type())
+	}
+}
+
+func describeScheduled(kind, name string, inputs []payload.Payload) string {
+	return fmt.Sprintf("%s %q with inputs %s", kind, name, inputsHash(inputs))
+}
+
+func describeSignal(instanceID, name string) string {
+	return fmt.Sprintf("signal %q to workflow instance %q", name, instanceID)
+}
+
+func describeCancel(instanceID string) string {
+	return fmt.Sprintf("cancellation of workflow instance %q", instanceID)
+}
+
+func describeVersionMarker(changeID string) string {
+	return fmt.Sprintf("version marker for change %q", changeID)
+}
+
+// inputsHash returns a short hash identifying the given inputs
+func inputsHash(inputs []payload.Payload) string {
+	h := sha256.New()
+	for _, input := range inputs {
+		// Prefix with the length, so that different splits of the same bytes hash differently
+		fmt.Fprintf(h, "%d:", len(input))
+		h.Write(input)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
@@ -85,6 +85,16 @@ func (w *workflow) Execute(ctx sync.Context, inputs []payload.Payload) error {
 	return w.s.Execute()
 }
 
+// Go starts a new coroutine alongside the workflow function. The workflow only completes once all coroutines have
+// finished.
+func (w *workflow) Go(ctx sync.Context, fn func(ctx sync.Context)) {
+	w.s.NewCoroutine(ctx, func(ctx sync.Context) error {
+		fn(ctx)
+
+		return nil
+	})
+}
+
 func (w *workflow) Continue() error {
 	return w.s.Execute()
 }
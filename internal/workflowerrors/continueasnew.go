@@ -0,0 +1,38 @@
+package workflowerrors
+
+import (
+	"errors"
+
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// ContinueAsNewError is returned by a workflow to complete the current execution and start a
+// new execution of the same workflow instance with the given inputs.
+type ContinueAsNewError struct {
+	Inputs []payload.Payload
+
+	// SearchAttributes are added to the search attributes the new execution keeps from the current one, or replace
+	// attributes with the same names
+	SearchAttributes core.SearchAttributes
+}
+
+func (e *ContinueAsNewError) Error() string {
+	return "workflow continued as new"
+}
+
+func NewContinueAsNewError(inputs []payload.Payload) *ContinueAsNewError {
+	return &ContinueAsNewError{
+		Inputs: inputs,
+	}
+}
+
+// AsContinueAsNewError returns the ContinueAsNewError in the chain of err, if any.
+func AsContinueAsNewError(err error) (*ContinueAsNewError, bool) {
+	var canErr *ContinueAsNewError
+	if errors.As(err, &canErr) {
+		return canErr, true
+	}
+
+	return nil, false
+}
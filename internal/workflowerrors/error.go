@@ -0,0 +1,238 @@
+package workflowerrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// Error is a serializable error. Errors returned from activities, sub-workflows, and workflows are converted
+// to an Error before they are stored in the history, and reconstructed as an Error on the receiving side, so
+// that type, message, details, and the chain of causes survive the round-trip.
+type Error struct {
+	// Type is the name of the type of the original error
+	Type string
+
+	// Message is the message of the original error
+	Message string
+
+	// Retryable indicates whether the failed operation can be retried
+	Retryable bool
+
+	// Cause is the error wrapped by this error, if any
+	Cause *Error
+
+	// details are the not yet serialized details attached to an error created in user code
+	details interface{}
+
+	// payload are the serialized details
+	payload payload.Payload
+
+	// converter is used to convert the serialized details
+	converter converter.Converter
+}
+
+// NewError creates a new retryable error with the given type and message.
+func NewError(errType, message string) *Error {
+	return &Error{
+		Type:      errType,
+		Message:   message,
+		Retryable: true,
+	}
+}
+
+// NewPermanentError converts the given error into an Error which is not retryable.
+func NewPermanentError(err error) *Error {
+	e := fromError(err)
+	if e == nil {
+		e = NewError("", "permanent error")
+	}
+
+	e.Retryable = false
+
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	if e.Cause == nil {
+		return nil
+	}
+
+	return e.Cause
+}
+
+// WithDetails attaches the given details to the error. Details are serialized using the configured converter
+// when the error is recorded.
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.details = details
+	e.payload = nil
+	return e
+}
+
+// WithCause sets the cause of the error.
+func (e *Error) WithCause(cause error) *Error {
+	e.Cause = fromError(cause)
+	return e
+}
+
+// HasDetails returns true if details are attached to the error.
+func (e *Error) HasDetails() bool {
+	return e.details != nil || e.payload != nil
+}
+
+// Details converts the details attached to the error into v. If no details are attached, v is not changed.
+func (e *Error) Details(v interface{}) error {
+	if e.payload == nil {
+		if e.details == nil {
+			return nil
+		}
+
+		// Details have not been serialized yet, round-trip them to get a consistent result
+		p, err := e.getConverter().To(e.details)
+		if err != nil {
+			return fmt.Errorf("converting error details: %w", err)
+		}
+
+		return e.getConverter().From(p, v)
+	}
+
+	return e.getConverter().From(e.payload, v)
+}
+
+func (e *Error) getConverter() converter.Converter {
+	if e.converter == nil {
+		return converter.DefaultConverter
+	}
+
+	return e.converter
+}
+
+type serializedError struct {
+	Type      string          `json:"type,omitempty"`
+	Message   string          `json:"message,omitempty"`
+	Retryable bool            `json:"retryable"`
+	Details   payload.Payload `json:"details,omitempty"`
+	Cause     *Error          `json:"cause,omitempty"`
+}
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&serializedError{
+		Type:      e.Type,
+		Message:   e.Message,
+		Retryable: e.Retryable,
+		Details:   e.payload,
+		Cause:     e.Cause,
+	})
+}
+
+func (e *Error) UnmarshalJSON(data []byte) error {
+	// Errors used to be stored as plain strings
+	var message string
+	if err := json.Unmarshal(data, &message); err == nil {
+		*e = Error{
+			Message:   message,
+			Retryable: true,
+		}
+
+		return nil
+	}
+
+	var se serializedError
+	if err := json.Unmarshal(data, &se); err != nil {
+		return err
+	}
+
+	*e = Error{
+		Type:      se.Type,
+		Message:   se.Message,
+		Retryable: se.Retryable,
+		Cause:     se.Cause,
+		payload:   se.Details,
+	}
+
+	return nil
+}
+
+// FromError converts the given error into a serializable Error. Details attached to errors in the chain are
+// serialized using the given converter. If details cannot be converted, they are dropped and a note is added
+// to the error message.
+func FromError(cv converter.Converter, err error) *Error {
+	e := fromError(err)
+	if e == nil {
+		return nil
+	}
+
+	for c := e; c != nil; c = c.Cause {
+		c.converter = cv
+
+		if c.details != nil && c.payload == nil {
+			p, err := c.getConverter().To(c.details)
+			if err != nil {
+				c.Message = fmt.Sprintf("%s (could not convert error details: %v)", c.Message, err)
+				c.details = nil
+			}
+
+			c.payload = p
+		}
+	}
+
+	return e
+}
+
+func fromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	// Heartbeat details are only kept for retrying activities, they are not part of the error chain
+	if herr, ok := err.(*heartbeatDetailsError); ok {
+		return fromError(herr.err)
+	}
+
+	var e *Error
+	if werr, ok := err.(*Error); ok {
+		// Copy the error, to not modify errors owned by user code
+		c := *werr
+		e = &c
+	} else {
+		e = &Error{
+			Type:      typeName(err),
+			Message:   err.Error(),
+			Retryable: true,
+		}
+	}
+
+	if e.Cause != nil {
+		e.Cause = fromError(e.Cause)
+	} else if cause := errors.Unwrap(err); cause != nil {
+		e.Cause = fromError(cause)
+	}
+
+	return e
+}
+
+// WithConverter sets the converter used to convert the details of err and all of its causes.
+func WithConverter(cv converter.Converter, err *Error) *Error {
+	for c := err; c != nil; c = c.Cause {
+		c.converter = cv
+	}
+
+	return err
+}
+
+func typeName(err error) string {
+	t := reflect.TypeOf(err)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.String()
+}
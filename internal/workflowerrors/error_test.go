@@ -0,0 +1,107 @@
+package workflowerrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/stretchr/testify/require"
+)
+
+type customError struct {
+	msg string
+}
+
+func (e *customError) Error() string {
+	return e.msg
+}
+
+func Test_FromError(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", NewError("InvalidInput", "invalid input").WithDetails(42).WithCause(&customError{"custom"}))
+
+	e := FromError(converter.DefaultConverter, err)
+	require.Equal(t, "fmt.wrapError", e.Type)
+	require.Equal(t, "wrapped: invalid input", e.Message)
+	require.True(t, e.Retryable)
+
+	require.NotNil(t, e.Cause)
+	require.Equal(t, "InvalidInput", e.Cause.Type)
+	require.Equal(t, "invalid input", e.Cause.Message)
+	require.True(t, e.Cause.HasDetails())
+
+	require.NotNil(t, e.Cause.Cause)
+	require.Equal(t, "workflowerrors.customError", e.Cause.Cause.Type)
+	require.Equal(t, "custom", e.Cause.Cause.Message)
+	require.Nil(t, e.Cause.Cause.Cause)
+
+	require.Nil(t, FromError(converter.DefaultConverter, nil))
+}
+
+func Test_FromError_SkipsHeartbeatDetails(t *testing.T) {
+	e := FromError(converter.DefaultConverter, WithHeartbeatDetails(NewError("InvalidInput", "invalid input"), []byte("1")))
+	require.Equal(t, "InvalidInput", e.Type)
+	require.Nil(t, e.Cause)
+}
+
+func Test_Error_RoundTrip(t *testing.T) {
+	err := NewError("InvalidInput", "invalid input").WithDetails(map[string]int{"count": 42}).WithCause(errors.New("cause"))
+
+	data, jerr := json.Marshal(FromError(converter.DefaultConverter, err))
+	require.NoError(t, jerr)
+
+	var e *Error
+	require.NoError(t, json.Unmarshal(data, &e))
+
+	require.Equal(t, "InvalidInput", e.Type)
+	require.Equal(t, "invalid input", e.Message)
+	require.True(t, e.Retryable)
+	require.Equal(t, "cause", e.Cause.Message)
+
+	var details map[string]int
+	require.NoError(t, e.Details(&details))
+	require.Equal(t, map[string]int{"count": 42}, details)
+
+	var target *Error
+	require.True(t, errors.As(fmt.Errorf("wrapped: %w", e), &target))
+	require.Equal(t, "InvalidInput", target.Type)
+}
+
+func Test_Error_UnmarshalLegacyString(t *testing.T) {
+	var e *Error
+	require.NoError(t, json.Unmarshal([]byte(`"some error"`), &e))
+
+	require.Equal(t, "some error", e.Error())
+	require.True(t, e.Retryable)
+	require.False(t, e.HasDetails())
+}
+
+func Test_NewPermanentError(t *testing.T) {
+	e := NewPermanentError(&customError{"custom"})
+	require.Equal(t, "workflowerrors.customError", e.Type)
+	require.Equal(t, "custom", e.Message)
+	require.False(t, e.Retryable)
+
+	data, err := json.Marshal(FromError(converter.DefaultConverter, e))
+	require.NoError(t, err)
+
+	var re *Error
+	require.NoError(t, json.Unmarshal(data, &re))
+	require.False(t, re.Retryable)
+}
+
+func Test_RecordedError_ExecutionTimeout(t *testing.T) {
+	err := RecordedError(converter.DefaultConverter, FromError(converter.DefaultConverter, NewExecutionTimeoutError(nil)))
+
+	var timeoutErr *ExecutionTimeoutError
+	require.True(t, errors.As(err, &timeoutErr))
+	require.Nil(t, timeoutErr.Cause)
+
+	err = RecordedError(converter.DefaultConverter, FromError(converter.DefaultConverter, NewExecutionTimeoutError(errors.New("canceled"))))
+	require.True(t, errors.As(err, &timeoutErr))
+	require.EqualError(t, timeoutErr.Cause, "canceled")
+
+	err = RecordedError(converter.DefaultConverter, NewError("InvalidInput", "invalid input"))
+	require.False(t, errors.As(err, &timeoutErr))
+}
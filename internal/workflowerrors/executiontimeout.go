@@ -0,0 +1,40 @@
+package workflowerrors
+
+import "github.com/cschleiden/go-workflows/internal/converter"
+
+// ExecutionTimeoutError is returned when a workflow instance exceeded its execution timeout. If the instance was
+// canceled on timeout, Cause is the error returned by the workflow.
+type ExecutionTimeoutError struct {
+	Cause error
+}
+
+func (e *ExecutionTimeoutError) Error() string {
+	return "workflow execution timed out"
+}
+
+func (e *ExecutionTimeoutError) Unwrap() error {
+	return e.Cause
+}
+
+func NewExecutionTimeoutError(cause error) *ExecutionTimeoutError {
+	return &ExecutionTimeoutError{
+		Cause: cause,
+	}
+}
+
+// RecordedError reconstructs the error recorded for a failed workflow instance. Execution timeouts are returned
+// as ExecutionTimeoutError, all other errors as Error.
+func RecordedError(cv converter.Converter, err *Error) error {
+	err = WithConverter(cv, err)
+
+	if err.Type == typeName(&ExecutionTimeoutError{}) {
+		var cause error
+		if err.Cause != nil {
+			cause = err.Cause
+		}
+
+		return NewExecutionTimeoutError(cause)
+	}
+
+	return err
+}
@@ -0,0 +1,39 @@
+package workflowerrors
+
+import (
+	"errors"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+// heartbeatDetailsError attaches the last heartbeat details of a failed activity attempt to its error
+type heartbeatDetailsError struct {
+	err     error
+	details payload.Payload
+}
+
+func (e *heartbeatDetailsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *heartbeatDetailsError) Unwrap() error {
+	return e.err
+}
+
+// WithHeartbeatDetails wraps the given activity error with the heartbeat details recorded by the activity
+func WithHeartbeatDetails(err error, details payload.Payload) error {
+	return &heartbeatDetailsError{
+		err:     err,
+		details: details,
+	}
+}
+
+// HeartbeatDetails returns the heartbeat details attached to the given error, or nil if there are none
+func HeartbeatDetails(err error) payload.Payload {
+	var herr *heartbeatDetailsError
+	if errors.As(err, &herr) {
+		return herr.details
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package workflowerrors
+
+import "fmt"
+
+// NonDeterminismError is returned when, during replay, the workflow code does not produce the command recorded
+// in the history of the workflow instance.
+type NonDeterminismError struct {
+	// ScheduleEventID is the schedule event id of the recorded event
+	ScheduleEventID int64
+
+	// Expected describes the command recorded in the history
+	Expected string
+
+	// Actual describes the command produced by the workflow code
+	Actual string
+}
+
+func (e *NonDeterminismError) Error() string {
+	return fmt.Sprintf(
+		"non-determinism detected at schedule event id %d: history contains %s, workflow code produced %s",
+		e.ScheduleEventID, e.Expected, e.Actual)
+}
+
+func NewNonDeterminismError(scheduleEventID int64, expected, actual string) *NonDeterminismError {
+	return &NonDeterminismError{
+		ScheduleEventID: scheduleEventID,
+		Expected:        expected,
+		Actual:          actual,
+	}
+}
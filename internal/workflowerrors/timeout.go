@@ -0,0 +1,33 @@
+package workflowerrors
+
+import "fmt"
+
+type TimeoutType string
+
+const (
+	// TimeoutTypeScheduleToClose indicates that an activity did not complete within its ScheduleToCloseTimeout,
+	// measured from the time it was first scheduled.
+	TimeoutTypeScheduleToClose TimeoutType = "ScheduleToClose"
+
+	// TimeoutTypeStartToClose indicates that a single attempt of an activity did not complete within its
+	// StartToCloseTimeout, measured from the time a worker started executing it.
+	TimeoutTypeStartToClose TimeoutType = "StartToClose"
+
+	// TimeoutTypeHeartbeat indicates that a running activity did not heartbeat within its HeartbeatTimeout.
+	TimeoutTypeHeartbeat TimeoutType = "Heartbeat"
+)
+
+// ActivityTimeoutError is returned when an activity exceeded one of its timeouts.
+type ActivityTimeoutError struct {
+	TimeoutType TimeoutType
+}
+
+func (e *ActivityTimeoutError) Error() string {
+	return fmt.Sprintf("activity timed out: %s", e.TimeoutType)
+}
+
+func NewActivityTimeoutError(timeoutType TimeoutType) *ActivityTimeoutError {
+	return &ActivityTimeoutError{
+		TimeoutType: timeoutType,
+	}
+}
@@ -0,0 +1,42 @@
+package workflowstate
+
+import "context"
+
+// SetTaskContext sets the context of the workflow task being executed. Local activities started during the task are
+// executed with it.
+func (wf *WfState) SetTaskContext(ctx context.Context) {
+	wf.taskCtx = ctx
+}
+
+// StartLocalActivity executes run outside of the workflow coroutines, with the context of the current workflow task.
+// The function returned by run is called by the executor once run has finished, to resume the workflow.
+func (wf *WfState) StartLocalActivity(run func(ctx context.Context) func()) {
+	ctx := wf.taskCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	done := make(chan func(), 1)
+	go func() {
+		done <- run(ctx)
+	}()
+
+	wf.localActivities = append(wf.localActivities, done)
+}
+
+// CompleteLocalActivity waits for the oldest running local activity and completes it. Local activities are completed
+// in the order they were started, which is the order their results are replayed in. Returns false if no local
+// activity is running.
+func (wf *WfState) CompleteLocalActivity() bool {
+	if len(wf.localActivities) == 0 {
+		return false
+	}
+
+	done := wf.localActivities[0]
+	wf.localActivities = wf.localActivities[1:]
+
+	complete := <-done
+	complete()
+
+	return true
+}
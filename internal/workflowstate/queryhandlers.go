@@ -0,0 +1,37 @@
+package workflowstate
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/cschleiden/go-workflows/internal/payload"
+)
+
+var ErrUnknownQuery = errors.New("unknown query")
+
+type QueryHandler func(args []payload.Payload) (payload.Payload, error)
+
+func (wf *WfState) SetQueryHandler(name string, handler QueryHandler) {
+	wf.queryHandlers[name] = handler
+}
+
+// QueryNames returns the names of all registered query handlers, sorted by name.
+func (wf *WfState) QueryNames() []string {
+	names := make([]string, 0, len(wf.queryHandlers))
+	for name := range wf.queryHandlers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func (wf *WfState) HandleQuery(name string, args []payload.Payload) (payload.Payload, error) {
+	handler, ok := wf.queryHandlers[name]
+	if !ok {
+		return nil, ErrUnknownQuery
+	}
+
+	return handler(args)
+}
@@ -0,0 +1,23 @@
+package workflowstate
+
+import (
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/sync"
+)
+
+type UpdateHandler struct {
+	// Validate is invoked before an update is accepted, an error rejects the update. It must not block.
+	Validate func(arg payload.Payload) error
+
+	// Handle is invoked in a new workflow coroutine after the update has been accepted
+	Handle func(ctx sync.Context, arg payload.Payload) (payload.Payload, error)
+}
+
+func (wf *WfState) SetUpdateHandler(name string, handler *UpdateHandler) {
+	wf.updateHandlers[name] = handler
+}
+
+func (wf *WfState) UpdateHandler(name string) (*UpdateHandler, bool) {
+	h, ok := wf.updateHandlers[name]
+	return h, ok
+}
@@ -0,0 +1,24 @@
+package workflowstate
+
+// AddRecordedVersion makes a version recorded in the history of the workflow instance available to GetVersion
+// calls, before the corresponding marker event is replayed.
+func (wf *WfState) AddRecordedVersion(changeID string, version int) {
+	wf.recordedVersions[changeID] = version
+}
+
+// RecordedVersion returns the version recorded in the history for the given change, if any.
+func (wf *WfState) RecordedVersion(changeID string) (int, bool) {
+	v, ok := wf.recordedVersions[changeID]
+	return v, ok
+}
+
+// SetVersion stores the version returned for the given change in the current execution.
+func (wf *WfState) SetVersion(changeID string, version int) {
+	wf.versions[changeID] = version
+}
+
+// Version returns the version previously returned for the given change in the current execution, if any.
+func (wf *WfState) Version(changeID string) (int, bool) {
+	v, ok := wf.versions[changeID]
+	return v, ok
+}
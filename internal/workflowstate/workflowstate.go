@@ -1,6 +1,7 @@
 package workflowstate
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -48,6 +49,8 @@ type signalChannel struct {
 
 type WfState struct {
 	instance        *core.WorkflowInstance
+	queue           core.Queue
+	priority        int
 	scheduleEventID int64
 	commands        []command.Command
 	pendingFutures  map[int64]DecodingSettable
@@ -56,6 +59,20 @@ type WfState struct {
 	pendingSignals map[string][]payload.Payload
 	signalChannels map[string]*signalChannel
 
+	queryHandlers map[string]QueryHandler
+
+	updateHandlers map[string]*UpdateHandler
+
+	recordedVersions map[string]int
+	versions         map[string]int
+
+	// taskCtx is the context of the workflow task being executed
+	taskCtx context.Context
+
+	// localActivities are the local activities running outside of the workflow coroutines, in the order they
+	// were started
+	localActivities []chan func()
+
 	logger log.Logger
 
 	clock clock.Clock
@@ -72,6 +89,13 @@ func NewWorkflowState(instance *core.WorkflowInstance, logger log.Logger, clock
 		pendingSignals: map[string][]payload.Payload{},
 		signalChannels: make(map[string]*signalChannel),
 
+		queryHandlers: make(map[string]QueryHandler),
+
+		updateHandlers: make(map[string]*UpdateHandler),
+
+		recordedVersions: map[string]int{},
+		versions:         map[string]int{},
+
 		clock: clock,
 	}
 
@@ -151,6 +175,26 @@ func (wf *WfState) Instance() *core.WorkflowInstance {
 	return wf.instance
 }
 
+// SetQueue sets the queue workflow tasks of the instance are routed to
+func (wf *WfState) SetQueue(queue core.Queue) {
+	wf.queue = queue
+}
+
+// Queue returns the queue workflow tasks of the instance are routed to
+func (wf *WfState) Queue() core.Queue {
+	return wf.queue.OrDefault()
+}
+
+// SetPriority sets the priority of workflow tasks of the instance
+func (wf *WfState) SetPriority(priority int) {
+	wf.priority = priority
+}
+
+// Priority returns the priority of workflow tasks of the instance
+func (wf *WfState) Priority() int {
+	return wf.priority
+}
+
 func (wf *WfState) Logger() log.Logger {
 	return wf.logger
 }
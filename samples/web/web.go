@@ -28,16 +28,21 @@ func main() {
 		panic("backend does not implement diag.Backend")
 	}
 
+	// Register workflows with the client to be able to query them
+	c := client.New(b)
+	c.RegisterWorkflow(Workflow1)
+	c.RegisterWorkflow(RunJob)
+	c.RegisterWorkflow(RunStep)
+
 	// Start diagnostic server under /diag
 	m := http.NewServeMux()
-	m.Handle("/diag/", http.StripPrefix("/diag", diag.NewServeMux(db)))
+	m.Handle("/diag/", http.StripPrefix("/diag", diag.NewServeMux(db, diag.WithClient(c))))
 	go http.ListenAndServe(":3000", m)
 
 	// Run worker
 	w := RunWorker(ctx, b)
 
 	// Start workflow via client
-	c := client.New(b)
 
 	runWorkflow(ctx, c)
 
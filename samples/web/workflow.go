@@ -17,6 +17,13 @@ func Workflow1(ctx workflow.Context, msg string, times int, inputs Inputs) (int,
 	logger.Debug("Entering Workflow1", "msg", msg, "times", times, "inputs", inputs)
 	defer logger.Debug("Leaving Workflow1")
 
+	completedJobs := 0
+	if err := workflow.SetQueryHandler(ctx, "completed-jobs", func() (int, error) {
+		return completedJobs, nil
+	}); err != nil {
+		return 0, err
+	}
+
 	wg := workflow.NewWaitGroup()
 
 	for i := 0; i < times; i++ {
@@ -29,6 +36,7 @@ func Workflow1(ctx workflow.Context, msg string, times int, inputs Inputs) (int,
 				panic("error getting subworkflow result")
 			}
 
+			completedJobs++
 			wg.Done()
 		})
 	}
@@ -43,6 +51,13 @@ func RunJob(ctx workflow.Context, count int) (int, error) {
 	logger.Debug("Entering RunJob")
 	defer logger.Debug("Leaving RunJob")
 
+	completedJobs := 0
+	if err := workflow.SetQueryHandler(ctx, "completed-jobs", func() (int, error) {
+		return completedJobs, nil
+	}); err != nil {
+		return 0, err
+	}
+
 	wg := workflow.NewWaitGroup()
 
 	for i := 0; i < count; i++ {
@@ -54,6 +69,7 @@ func RunJob(ctx workflow.Context, count int) (int, error) {
 				panic("error getting subworkflow result")
 			}
 
+			completedJobs++
 			wg.Done()
 		})
 	}
@@ -2,6 +2,7 @@ package tester
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
@@ -24,6 +25,7 @@ import (
 	"github.com/cschleiden/go-workflows/internal/signals"
 	"github.com/cschleiden/go-workflows/internal/task"
 	"github.com/cschleiden/go-workflows/internal/workflow"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
 	"github.com/cschleiden/go-workflows/log"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
@@ -88,6 +90,8 @@ type testWorkflow struct {
 	instance      *core.WorkflowInstance
 	history       []*history.Event
 	pendingEvents []*history.Event
+	finished      bool
+	terminated    bool
 }
 
 type options struct {
@@ -275,7 +279,7 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 		gotNewEvents := false
 
 		for _, tw := range wt.testWorkflows {
-			if len(tw.pendingEvents) == 0 {
+			if len(tw.pendingEvents) == 0 || tw.terminated {
 				// Nothing to process for this workflow
 				continue
 			}
@@ -285,7 +289,7 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 			tw.pendingEvents = tw.pendingEvents[:0]
 
 			// Execute task
-			e := workflow.NewExecutor(wt.logger, wt.tracer, wt.registry, converter.DefaultConverter, &testHistoryProvider{tw.history}, tw.instance, wt.clock)
+			e := workflow.NewExecutor(wt.logger, wt.tracer, wt.registry, converter.DefaultConverter, &testHistoryProvider{tw.history}, tw.instance, wt.clock, workflow.NonDeterminismPolicyFail)
 
 			result, err := e.ExecuteTask(context.Background(), t)
 			if err != nil {
@@ -302,12 +306,16 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 
 				switch event.Type {
 				case history.EventType_WorkflowExecutionFinished:
+					tw.finished = true
+
 					a := event.Attributes.(*history.ExecutionCompletedAttributes)
 
 					if !tw.instance.SubWorkflow() {
 						wt.workflowFinished = true
 						wt.workflowResult = a.Result
-						wt.workflowErr = a.Error
+						if a.Error != nil {
+							wt.workflowErr = a.Error.Error()
+						}
 					}
 
 				case history.EventType_TimerCanceled:
@@ -316,19 +324,50 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 			}
 
 			// Schedule sub-workflows and handle x-workflow events
+			var continuedEvent *history.WorkflowEvent
+
 			for _, workflowEvent := range result.WorkflowEvents {
 				gotNewEvents = true
 				wt.logger.Debug("Workflow event", "event_type", workflowEvent.HistoryEvent.Type)
 
 				switch workflowEvent.HistoryEvent.Type {
 				case history.EventType_WorkflowExecutionStarted:
+					if workflowEvent.WorkflowInstance.InstanceID == tw.instance.InstanceID {
+						// Workflow continued as new, start the new execution once this task has been processed
+						workflowEvent := workflowEvent
+						continuedEvent = &workflowEvent
+						continue
+					}
+
 					wt.scheduleSubWorkflow(workflowEvent)
 
+				case history.EventType_WorkflowExecutionTerminated:
+					// Sub-workflow terminated according to its parent close policy
+					wt.terminateWorkflow(workflowEvent.WorkflowInstance, workflowEvent.HistoryEvent)
+
+				case history.EventType_SignalReceived, history.EventType_WorkflowExecutionCanceled:
+					// Signals and cancellations are only delivered to active workflow instances
+					if wt.instanceActive(workflowEvent.WorkflowInstance) != nil {
+						continue
+					}
+
+					wt.sendEvent(workflowEvent.WorkflowInstance, workflowEvent.HistoryEvent)
+
 				default:
 					wt.sendEvent(workflowEvent.WorkflowInstance, workflowEvent.HistoryEvent)
 				}
 			}
 
+			// Complete requests to signal or cancel other workflow instances, once instances created by this task exist
+			for _, event := range result.Executed {
+				if instanceID, ok := history.ExternalWorkflowRequestTarget(event); ok {
+					gotNewEvents = true
+
+					err := wt.instanceActive(core.NewWorkflowInstance(instanceID, ""))
+					wt.sendEvent(tw.instance, history.NewExternalWorkflowResultEvent(wt.clock.Now(), event, err))
+				}
+			}
+
 			// Schedule activities
 			for _, event := range result.ActivityEvents {
 				gotNewEvents = true
@@ -346,6 +385,10 @@ func (wt *workflowTester[TResult]) Execute(args ...interface{}) {
 
 				wt.scheduleTimer(tw.instance, timerEvent)
 			}
+
+			if continuedEvent != nil {
+				wt.continueWorkflow(tw, continuedEvent)
+			}
 		}
 
 		for !wt.workflowFinished && !gotNewEvents {
@@ -422,6 +465,33 @@ func (wt *workflowTester[TResult]) sendEvent(wfi *core.WorkflowInstance, event *
 	w.pendingEvents = append(w.pendingEvents, event)
 }
 
+// instanceActive returns ErrInstanceNotFound or ErrInstanceNotActive if the given instance cannot receive events
+// from other workflow instances
+func (wt *workflowTester[TResult]) instanceActive(wfi *core.WorkflowInstance) error {
+	w := wt.getWorkflow(wfi)
+	if w == nil {
+		return backend.ErrInstanceNotFound
+	}
+
+	if w.finished || w.terminated {
+		return backend.ErrInstanceNotActive
+	}
+
+	return nil
+}
+
+func (wt *workflowTester[TResult]) terminateWorkflow(wfi *core.WorkflowInstance, event *history.Event) {
+	w := wt.getWorkflow(wfi)
+	if w == nil {
+		// Mocked workflows don't have to be terminated
+		return
+	}
+
+	w.history = append(w.history, event)
+	w.pendingEvents = w.pendingEvents[:0]
+	w.terminated = true
+}
+
 func (wt *workflowTester[TResult]) SignalWorkflow(name string, value interface{}) {
 	wt.SignalWorkflowInstance(wt.wfi, name, value)
 }
@@ -539,7 +609,11 @@ func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance,
 				Metadata:         &core.WorkflowMetadata{},
 				WorkflowInstance: wfi,
 				Event:            event,
-			})
+			}, nil)
+		}
+
+		if errors.Is(activityErr, activity.ErrResultPending) {
+			panic("Activity " + e.Name + " is completed asynchronously, which is not supported by the tester. Mock the activity instead.")
 		}
 
 		wt.callbacks <- func() *history.WorkflowEvent {
@@ -550,7 +624,7 @@ func (wt *workflowTester[TResult]) scheduleActivity(wfi *core.WorkflowInstance,
 					wt.clock.Now(),
 					history.EventType_ActivityFailed,
 					&history.ActivityFailedAttributes{
-						Reason: activityErr.Error(),
+						Error: workflowerrors.FromError(wt.converter, activityErr),
 					},
 					history.ScheduleEventID(event.ScheduleEventID),
 				)
@@ -609,6 +683,17 @@ func (wt *workflowTester[TResult]) cancelTimer(instance *core.WorkflowInstance,
 	}
 }
 
+// continueWorkflow replaces the current execution of the given workflow with a new execution
+func (wt *workflowTester[TResult]) continueWorkflow(tw *testWorkflow, event *history.WorkflowEvent) {
+	wt.mtw.Lock()
+	defer wt.mtw.Unlock()
+
+	tw.instance = event.WorkflowInstance
+	tw.history = make([]*history.Event, 0)
+	tw.pendingEvents = append(tw.pendingEvents, event.HistoryEvent)
+	tw.finished = false
+}
+
 func (wt *workflowTester[TResult]) getWorkflow(instance *core.WorkflowInstance) *testWorkflow {
 	wt.mtw.RLock()
 	defer wt.mtw.RUnlock()
@@ -694,7 +779,8 @@ func (wt *workflowTester[TResult]) scheduleSubWorkflow(event history.WorkflowEve
 	}
 
 	wt.callbacks <- func() *history.WorkflowEvent {
-		r := command.NewCompleteWorkflowCommand(0, event.WorkflowInstance, workflowResult, workflowErr).Execute(wt.clock)
+		r := command.NewCompleteWorkflowCommand(
+			0, event.WorkflowInstance, workflowResult, workflowerrors.FromError(converter.DefaultConverter, workflowErr)).Execute(wt.clock)
 
 		return &r.WorkflowEvents[0]
 	}
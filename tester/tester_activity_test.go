@@ -2,6 +2,7 @@ package tester
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -128,3 +129,79 @@ func Test_ActivityWithLogger(t *testing.T) {
 	require.True(t, tester.WorkflowFinished())
 	tester.AssertExpectations(t)
 }
+
+func Test_LocalActivity_Retries(t *testing.T) {
+	attempts := 0
+	localActivity := func(ctx context.Context, s string) (string, error) {
+		activity.Logger(ctx).Debug("executing local activity")
+
+		attempts++
+		if attempts < 3 {
+			return "", errors.New("not yet")
+		}
+
+		return s + " done", nil
+	}
+
+	wf := func(ctx workflow.Context) (string, error) {
+		return workflow.ExecuteLocalActivity[string](ctx, workflow.LocalActivityOptions{
+			RetryOptions: workflow.RetryOptions{
+				MaxAttempts:        3,
+				FirstRetryInterval: time.Millisecond,
+			},
+		}, localActivity, "work").Get(ctx)
+	}
+
+	tester := NewWorkflowTester[string](wf)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	wr, errStr := tester.WorkflowResult()
+	require.Empty(t, errStr)
+	require.Equal(t, "work done", wr)
+	require.Equal(t, 3, attempts)
+}
+
+func Test_LocalActivity_RetriesUsingTimer(t *testing.T) {
+	attempts := 0
+	localActivity := func() (int, error) {
+		attempts++
+		if attempts < 2 {
+			return 0, errors.New("not yet")
+		}
+
+		return attempts, nil
+	}
+
+	wf := func(ctx workflow.Context) (int, error) {
+		start := workflow.Now(ctx)
+
+		r, err := workflow.ExecuteLocalActivity[int](ctx, workflow.LocalActivityOptions{
+			RetryOptions: workflow.RetryOptions{
+				MaxAttempts:        2,
+				FirstRetryInterval: time.Minute,
+			},
+			LocalRetryThreshold: time.Second,
+		}, localActivity).Get(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		// The retry has been delayed using a timer
+		if workflow.Now(ctx).Sub(start) < time.Minute {
+			return 0, errors.New("retried without timer")
+		}
+
+		return r, nil
+	}
+
+	tester := NewWorkflowTester[int](wf)
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	wr, errStr := tester.WorkflowResult()
+	require.Empty(t, errStr)
+	require.Equal(t, 2, wr)
+}
@@ -240,3 +240,56 @@ func waitForSignal(ctx workflow.Context) (int, error) {
 
 	return 42, nil
 }
+
+func Test_CancelWorkflow(t *testing.T) {
+	tester := NewWorkflowTester[string](workflowCancelWorkflow)
+	require.NoError(t, tester.Registry().RegisterWorkflow(waitForCancellation))
+
+	tester.Execute()
+
+	require.True(t, tester.WorkflowFinished())
+	wfR, wfErr := tester.WorkflowResult()
+	require.Empty(t, wfErr)
+	require.Equal(t, "canceled", wfR)
+}
+
+func workflowCancelWorkflow(ctx workflow.Context) (string, error) {
+	sw := workflow.CreateSubWorkflowInstance[string](ctx, workflow.SubWorkflowOptions{
+		InstanceID: "subworkflow",
+	}, waitForCancellation)
+
+	if _, err := workflow.CancelWorkflow(ctx, "subworkflow").Get(ctx); err != nil {
+		return "", err
+	}
+
+	return sw.Get(ctx)
+}
+
+func waitForCancellation(ctx workflow.Context) (string, error) {
+	if err := workflow.Sleep(ctx, time.Hour); err != workflow.Canceled {
+		return "", err
+	}
+
+	return "canceled", nil
+}
+
+func Test_ContinueAsNew(t *testing.T) {
+	tester := NewWorkflowTester[int](workflowContinueAsNew)
+
+	tester.Execute(0)
+
+	require.True(t, tester.WorkflowFinished())
+	wr, _ := tester.WorkflowResult()
+	require.Equal(t, 3, wr)
+	tester.AssertExpectations(t)
+}
+
+func workflowContinueAsNew(ctx workflow.Context, iteration int) (int, error) {
+	if iteration >= 3 {
+		return iteration, nil
+	}
+
+	workflow.Sleep(ctx, time.Second)
+
+	return 0, workflow.ContinueAsNew(ctx, iteration+1)
+}
@@ -38,6 +38,14 @@ type Worker interface {
 
 	// WaitForCompletion
 	WaitForCompletion() error
+
+	// Shutdown stops the worker from polling for new tasks and waits for the tasks in flight to finish. If ctx is
+	// done before they have finished, the contexts of running activities are canceled, and the locks of the
+	// remaining tasks are released in the backend so that other workers can pick them up right away. Their results
+	// are discarded, and the error of ctx is returned.
+	//
+	// Shutdown replaces WaitForCompletion, don't call both.
+	Shutdown(ctx context.Context) error
 }
 
 type worker struct {
@@ -50,6 +58,7 @@ type worker struct {
 
 	workflowWorker *internal.WorkflowWorker
 	activityWorker *internal.ActivityWorker
+	scheduleWorker *internal.ScheduleWorker
 
 	workflows  map[string]interface{}
 	activities map[string]interface{}
@@ -59,6 +68,19 @@ type Options = internal.Options
 
 var DefaultWorkerOptions = internal.DefaultOptions
 
+type ActivityLimit = internal.ActivityLimit
+
+type NonDeterminismPolicy = workflowinternal.NonDeterminismPolicy
+
+const (
+	// NonDeterminismPolicyFail fails workflow instances whose workflow code does not match their history.
+	NonDeterminismPolicyFail = workflowinternal.NonDeterminismPolicyFail
+
+	// NonDeterminismPolicyBlock leaves workflow tasks of workflow instances whose workflow code does not match their
+	// history to be retried, until a worker with fixed workflow code processes them.
+	NonDeterminismPolicyBlock = workflowinternal.NonDeterminismPolicyBlock
+)
+
 func New(backend backend.Backend, options *Options) Worker {
 	if options == nil {
 		options = &internal.DefaultOptions
@@ -72,6 +94,18 @@ func New(backend backend.Backend, options *Options) Worker {
 		options.WorkflowExecutorCacheTTL = internal.DefaultOptions.WorkflowExecutorCacheTTL
 	}
 
+	if options.WorkflowTaskTimeout == 0 {
+		options.WorkflowTaskTimeout = internal.DefaultOptions.WorkflowTaskTimeout
+	}
+
+	if options.SchedulePollingInterval == 0 {
+		options.SchedulePollingInterval = internal.DefaultOptions.SchedulePollingInterval
+	}
+
+	if len(options.Queues) == 0 {
+		options.Queues = internal.DefaultOptions.Queues
+	}
+
 	registry := workflowinternal.NewRegistry()
 
 	// Register internal activities
@@ -85,6 +119,7 @@ func New(backend backend.Backend, options *Options) Worker {
 
 		workflowWorker: internal.NewWorkflowWorker(backend, registry, options),
 		activityWorker: internal.NewActivityWorker(backend, registry, clock.New(), options),
+		scheduleWorker: internal.NewScheduleWorker(backend, clock.New(), options),
 
 		registry: registry,
 	}
@@ -99,6 +134,10 @@ func (w *worker) Start(ctx context.Context) error {
 		return fmt.Errorf("starting activity worker: %w", err)
 	}
 
+	if err := w.scheduleWorker.Start(ctx); err != nil {
+		return fmt.Errorf("starting schedule worker: %w", err)
+	}
+
 	return nil
 }
 
@@ -111,9 +150,38 @@ func (w *worker) WaitForCompletion() error {
 		return err
 	}
 
+	if err := w.scheduleWorker.WaitForCompletion(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+func (w *worker) Shutdown(ctx context.Context) error {
+	// Stop polling for new tasks
+	w.workflowWorker.Stop()
+	w.activityWorker.Stop()
+	w.scheduleWorker.Stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- w.WaitForCompletion()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+
+	case <-ctx.Done():
+	}
+
+	// Use a fresh context, ctx is already done
+	w.workflowWorker.AbandonTasks(context.Background())
+	w.activityWorker.AbandonTasks(context.Background())
+
+	return ctx.Err()
+}
+
 func (w *worker) RegisterWorkflow(wf workflow.Workflow) error {
 	return w.registry.RegisterWorkflow(wf)
 }
@@ -2,13 +2,18 @@ package workflow
 
 import (
 	"fmt"
+	"time"
 
 	a "github.com/cschleiden/go-workflows/internal/args"
 	"github.com/cschleiden/go-workflows/internal/command"
 	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/core"
 	"github.com/cschleiden/go-workflows/internal/fn"
+	"github.com/cschleiden/go-workflows/internal/history"
+	"github.com/cschleiden/go-workflows/internal/payload"
 	"github.com/cschleiden/go-workflows/internal/sync"
 	"github.com/cschleiden/go-workflows/internal/tracing"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
 	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/internal/workflowtracer"
 	"go.opentelemetry.io/otel/attribute"
@@ -17,6 +22,27 @@ import (
 
 type ActivityOptions struct {
 	RetryOptions RetryOptions
+
+	// ScheduleToCloseTimeout is the maximum time from scheduling the activity until it completes, including
+	// time spent waiting for a worker and all retries. Zero means no timeout.
+	ScheduleToCloseTimeout time.Duration
+
+	// StartToCloseTimeout is the maximum time a single attempt of the activity may take once a worker started
+	// executing it. Attempts exceeding it are retried according to RetryOptions. Zero means no timeout.
+	StartToCloseTimeout time.Duration
+
+	// HeartbeatTimeout is the maximum time between heartbeats of a running activity. Activities with a heartbeat
+	// timeout have to report their liveness using activity.RecordHeartbeat, attempts which don't are timed out. Zero
+	// means no timeout, the worker then heartbeats on behalf of the activity while it's executing it.
+	HeartbeatTimeout time.Duration
+
+	// Queue is the queue the activity is routed to, only workers subscribed to it execute the activity. By default,
+	// activities are routed to the queue of the workflow instance.
+	Queue Queue
+
+	// Priority of the activity task, activity tasks with a higher priority are handed out to workers first. Must be
+	// between MinPriority and MaxPriority. By default, the priority of the workflow instance is used.
+	Priority *int
 }
 
 var DefaultActivityOptions = ActivityOptions{
@@ -25,12 +51,45 @@ var DefaultActivityOptions = ActivityOptions{
 
 // ExecuteActivity schedules the given activity to be executed
 func ExecuteActivity[TResult any](ctx Context, options ActivityOptions, activity interface{}, args ...interface{}) Future[TResult] {
+	// The schedule-to-close timeout spans all attempts, each attempt gets the remaining time
+	var deadline time.Time
+	if options.ScheduleToCloseTimeout > 0 {
+		deadline = Now(ctx).Add(options.ScheduleToCloseTimeout)
+	}
+
+	// Heartbeat details recorded by an attempt are passed to the next one
+	var lastAttempt Future[TResult]
+
 	return withRetries(ctx, options.RetryOptions, func(ctx sync.Context, attempt int) Future[TResult] {
-		return executeActivity[TResult](ctx, options, attempt, activity, args...)
+		attemptOptions := options
+
+		var heartbeatDetails payload.Payload
+		if lastAttempt != nil {
+			// The previous attempt has already finished, this does not block
+			if _, err := lastAttempt.Get(ctx); err != nil {
+				heartbeatDetails = workflowerrors.HeartbeatDetails(err)
+			}
+		}
+
+		if !deadline.IsZero() {
+			remaining := deadline.Sub(Now(ctx))
+			if remaining <= 0 {
+				f := sync.NewFuture[TResult]()
+				f.Set(*new(TResult), workflowerrors.NewActivityTimeoutError(workflowerrors.TimeoutTypeScheduleToClose))
+				return f
+			}
+
+			attemptOptions.ScheduleToCloseTimeout = remaining
+		}
+
+		lastAttempt = executeActivity[TResult](ctx, attemptOptions, attempt, heartbeatDetails, activity, args...)
+		return lastAttempt
 	})
 }
 
-func executeActivity[TResult any](ctx Context, options ActivityOptions, attempt int, activity interface{}, args ...interface{}) Future[TResult] {
+func executeActivity[TResult any](
+	ctx Context, options ActivityOptions, attempt int, heartbeatDetails payload.Payload, activity interface{}, args ...interface{},
+) Future[TResult] {
 	f := sync.NewFuture[TResult]()
 
 	if ctx.Err() != nil {
@@ -50,6 +109,14 @@ func executeActivity[TResult any](ctx Context, options ActivityOptions, attempt
 		return f
 	}
 
+	// Check priority
+	if options.Priority != nil {
+		if err := core.ValidatePriority(*options.Priority); err != nil {
+			f.Set(*new(TResult), err)
+			return f
+		}
+	}
+
 	cv := converter.GetConverter(ctx)
 	inputs, err := a.ArgsToInputs(cv, args...)
 	if err != nil {
@@ -60,8 +127,27 @@ func executeActivity[TResult any](ctx Context, options ActivityOptions, attempt
 	wfState := workflowstate.WorkflowState(ctx)
 	scheduleEventID := wfState.GetNextScheduleEventID()
 
+	var timeouts *history.ActivityTimeouts
+	if options.ScheduleToCloseTimeout > 0 || options.StartToCloseTimeout > 0 || options.HeartbeatTimeout > 0 {
+		timeouts = &history.ActivityTimeouts{
+			ScheduleToClose: options.ScheduleToCloseTimeout,
+			StartToClose:    options.StartToCloseTimeout,
+			Heartbeat:       options.HeartbeatTimeout,
+		}
+	}
+
 	name := fn.Name(activity)
-	cmd := command.NewScheduleActivityCommand(scheduleEventID, name, inputs)
+	queue := options.Queue
+	if queue == "" {
+		queue = wfState.Queue()
+	}
+
+	cmd := command.NewScheduleActivityCommand(scheduleEventID, name, inputs, timeouts, heartbeatDetails)
+	cmd.Queue = queue
+	cmd.Priority = wfState.Priority()
+	if options.Priority != nil {
+		cmd.Priority = *options.Priority
+	}
 	wfState.AddCommand(cmd)
 	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(cv, f))
 
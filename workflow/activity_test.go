@@ -28,7 +28,7 @@ func Test_executeActivity_ResultMismatch(t *testing.T) {
 	ctx = workflowtracer.WithWorkflowTracer(ctx, workflowtracer.New(trace.NewNoopTracerProvider().Tracer("test")))
 
 	c := sync.NewCoroutine(ctx, func(ctx sync.Context) error {
-		f := executeActivity[string](ctx, DefaultActivityOptions, 1, a)
+		f := executeActivity[string](ctx, DefaultActivityOptions, 1, nil, a)
 		_, err := f.Get(ctx)
 		require.Error(t, err)
 
@@ -52,7 +52,7 @@ func Test_executeActivity_ParamMismatch(t *testing.T) {
 	ctx = workflowtracer.WithWorkflowTracer(ctx, workflowtracer.New(trace.NewNoopTracerProvider().Tracer("test")))
 
 	c := sync.NewCoroutine(ctx, func(ctx sync.Context) error {
-		f := executeActivity[int](ctx, DefaultActivityOptions, 1, a)
+		f := executeActivity[int](ctx, DefaultActivityOptions, 1, nil, a)
 		_, err := f.Get(ctx)
 		require.Error(t, err)
 
@@ -62,3 +62,29 @@ func Test_executeActivity_ParamMismatch(t *testing.T) {
 	c.Execute()
 	require.True(t, c.Finished())
 }
+
+func Test_executeActivity_PriorityOutOfRange(t *testing.T) {
+	a := func(ctx Context) (int, error) {
+		return 42, nil
+	}
+
+	ctx := sync.Background()
+	ctx = converter.WithConverter(ctx, converter.DefaultConverter)
+	ctx = workflowstate.WithWorkflowState(
+		ctx,
+		workflowstate.NewWorkflowState(core.NewWorkflowInstance("a", ""), logger.NewDefaultLogger(), clock.New()),
+	)
+	ctx = workflowtracer.WithWorkflowTracer(ctx, workflowtracer.New(trace.NewNoopTracerProvider().Tracer("test")))
+
+	c := sync.NewCoroutine(ctx, func(ctx sync.Context) error {
+		priority := MaxPriority + 1
+		f := executeActivity[int](ctx, ActivityOptions{Priority: &priority}, 1, nil, a)
+		_, err := f.Get(ctx)
+		require.EqualError(t, err, "priority 11 is out of range, must be between -10 and 10")
+
+		return nil
+	})
+
+	c.Execute()
+	require.True(t, c.Finished())
+}
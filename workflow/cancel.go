@@ -0,0 +1,41 @@
+package workflow
+
+import (
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/tracing"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+	"github.com/cschleiden/go-workflows/internal/workflowtracer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CancelWorkflow requests cancellation of the given workflow instance. The returned future resolves once the
+// cancellation has been delivered, or with an error if the instance does not exist or is not active anymore. It
+// does not wait for the canceled instance to finish.
+func CancelWorkflow(ctx Context, instanceID string) Future[any] {
+	f := sync.NewFuture[any]()
+
+	// If the context is already canceled, return immediately.
+	if ctx.Err() != nil {
+		f.Set(nil, ctx.Err())
+		return f
+	}
+
+	wfState := workflowstate.WorkflowState(ctx)
+	scheduleEventID := wfState.GetNextScheduleEventID()
+
+	_, span := workflowtracer.Tracer(ctx).Start(ctx, "CancelWorkflow",
+		trace.WithAttributes(
+			attribute.String(tracing.WorkflowInstanceID, instanceID),
+			attribute.Int64(tracing.ScheduleEventID, scheduleEventID),
+		))
+	defer span.End()
+
+	cmd := command.NewCancelWorkflowCommand(scheduleEventID, instanceID)
+	wfState.AddCommand(cmd)
+	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(converter.GetConverter(ctx), f))
+
+	return f
+}
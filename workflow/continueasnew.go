@@ -0,0 +1,53 @@
+package workflow
+
+import (
+	a "github.com/cschleiden/go-workflows/internal/args"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+)
+
+type ContinueAsNewOptions struct {
+	// SearchAttributes are added to the search attributes of the new execution, or replace attributes with the same
+	// names
+	SearchAttributes SearchAttributes
+}
+
+// ContinueAsNew creates an error that, when returned from a workflow, completes the current execution
+// and atomically starts a new execution of the same workflow instance with the given arguments. The new
+// execution starts with a fresh history, but keeps the instance id, metadata, parent, and search attributes
+// of the current execution.
+//
+//	func Workflow(ctx workflow.Context, iteration int) (int, error) {
+//		// ...
+//		return 0, workflow.ContinueAsNew(ctx, iteration+1)
+//	}
+func ContinueAsNew(ctx Context, args ...interface{}) error {
+	return ContinueAsNewWithOptions(ctx, ContinueAsNewOptions{}, args...)
+}
+
+// ContinueAsNewWithOptions is like ContinueAsNew, and applies the given options to the new execution.
+//
+//	return 0, workflow.ContinueAsNewWithOptions(ctx, workflow.ContinueAsNewOptions{
+//		SearchAttributes: workflow.SearchAttributes{
+//			"Iteration": workflow.IntSearchAttribute(int64(iteration + 1)),
+//		},
+//	}, iteration+1)
+func ContinueAsNewWithOptions(ctx Context, options ContinueAsNewOptions, args ...interface{}) error {
+	inputs, err := a.ArgsToInputs(converter.GetConverter(ctx), args...)
+	if err != nil {
+		return err
+	}
+
+	for name := range options.SearchAttributes {
+		if name == "" {
+			panic("search attribute name is required")
+		}
+	}
+
+	canErr := workflowerrors.NewContinueAsNewError(inputs)
+
+	// Copy the attributes, the workflow might modify the map after the call
+	canErr.SearchAttributes = options.SearchAttributes.Upsert(nil)
+
+	return canErr
+}
@@ -0,0 +1,50 @@
+package workflow
+
+import "github.com/cschleiden/go-workflows/internal/workflowerrors"
+
+// ActivityTimeoutError is returned when an activity exceeded one of the timeouts configured in its ActivityOptions.
+// Use errors.As to check for it:
+//
+//	var timeoutErr *workflow.ActivityTimeoutError
+//	if errors.As(err, &timeoutErr) && timeoutErr.TimeoutType == workflow.TimeoutTypeHeartbeat {
+//		// ...
+//	}
+type ActivityTimeoutError = workflowerrors.ActivityTimeoutError
+
+type TimeoutType = workflowerrors.TimeoutType
+
+// ExecutionTimeoutError is returned when a workflow instance exceeded the ExecutionTimeout configured when it was
+// created. If the instance was canceled on timeout, Cause is the error returned by the workflow.
+type ExecutionTimeoutError = workflowerrors.ExecutionTimeoutError
+
+// NonDeterminismError is returned when, during replay, the workflow code does not produce the activities, timers,
+// and sub-workflows recorded in the history of the workflow instance.
+type NonDeterminismError = workflowerrors.NonDeterminismError
+
+const (
+	TimeoutTypeScheduleToClose = workflowerrors.TimeoutTypeScheduleToClose
+	TimeoutTypeStartToClose    = workflowerrors.TimeoutTypeStartToClose
+	TimeoutTypeHeartbeat       = workflowerrors.TimeoutTypeHeartbeat
+)
+
+// Error is the error returned for failed activities, sub-workflows, and workflows. It preserves the type name,
+// message, details, and chain of causes of the original error. Use errors.As to check for it:
+//
+//	var werr *workflow.Error
+//	if errors.As(err, &werr) && werr.Type == "InvalidInput" {
+//		// ...
+//	}
+type Error = workflowerrors.Error
+
+// NewError creates a new error with the given type and message. Details can be attached with WithDetails, they
+// are serialized using the configured converter.
+func NewError(errType, message string) *Error {
+	return workflowerrors.NewError(errType, message)
+}
+
+// NewPermanentError marks the given error as not retryable. When an activity or a sub-workflow fails with a
+// permanent error, it's not retried regardless of its RetryOptions. The type, message, and details of err are
+// preserved.
+func NewPermanentError(err error) *Error {
+	return workflowerrors.NewPermanentError(err)
+}
@@ -0,0 +1,310 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	internalactivity "github.com/cschleiden/go-workflows/internal/activity"
+	a "github.com/cschleiden/go-workflows/internal/args"
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/fn"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/tracing"
+	"github.com/cschleiden/go-workflows/internal/workflowerrors"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+	"github.com/cschleiden/go-workflows/internal/workflowtracer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type LocalActivityOptions struct {
+	RetryOptions RetryOptions
+
+	// StartToCloseTimeout is the maximum time a single attempt of the local activity may take. The context of the
+	// activity is canceled once it expires. Zero means no timeout.
+	StartToCloseTimeout time.Duration
+
+	// LocalRetryThreshold is the maximum time attempts and retry delays of the local activity may take within a
+	// single workflow task. If the delay before the next retry would exceed it, the retry is delayed using a timer
+	// instead and executed in a later workflow task. Defaults to 10 seconds.
+	LocalRetryThreshold time.Duration
+}
+
+var DefaultLocalActivityOptions = LocalActivityOptions{
+	RetryOptions:        DefaultRetryOptions,
+	LocalRetryThreshold: 10 * time.Second,
+}
+
+// ExecuteLocalActivity executes the given activity in the workflow worker, during the current workflow task, and
+// records its result in the history of the workflow instance. This avoids the round trip through the backend for
+// short activities. When replaying, the recorded result is returned and the activity is not executed again.
+//
+// Local activities don't need to be registered with the worker. They delay the completion of the workflow task while
+// they are executing, so they should only be used for activities which complete quickly. Attempts still executing
+// when the workflow task runs out of time are canceled and count as failed attempts. The local activity is then
+// retried in a later workflow task, after a timer.
+func ExecuteLocalActivity[TResult any](ctx Context, options LocalActivityOptions, activity interface{}, args ...interface{}) Future[TResult] {
+	maxAttempts := options.RetryOptions.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var retryExpiration time.Time
+	if options.RetryOptions.RetryTimeout != 0 {
+		retryExpiration = Now(ctx).Add(options.RetryOptions.RetryTimeout)
+	}
+
+	r := sync.NewFuture[TResult]()
+
+	// Retries within the local retry threshold are executed as part of one local activity execution. Longer
+	// retry delays are waited for using a timer, before the local activity is executed again.
+	sync.Go(ctx, func(ctx sync.Context) {
+		attempts := 0
+
+		for {
+			f, cmd := executeLocalActivity[TResult](ctx, options, attempts, maxAttempts, retryExpiration, activity, args...)
+
+			result, err := f.Get(ctx)
+			if cmd == nil {
+				r.Set(result, err)
+				return
+			}
+
+			attempts += cmd.Attempts()
+
+			if cmd.Deferred() && err == nil {
+				// The workflow task ran out of time before an attempt could be started, continue in the next task
+				if err := Sleep(ctx, 0); err != nil {
+					r.Set(*new(TResult), err)
+					return
+				}
+
+				continue
+			}
+
+			if err == nil {
+				r.Set(result, err)
+				return
+			}
+
+			if attempts >= maxAttempts || !retryable(err, options.RetryOptions.NonRetryableErrorTypes) {
+				r.Set(result, err)
+				return
+			}
+
+			if err := Sleep(ctx, retryBackoff(options.RetryOptions, attempts-1)); err != nil {
+				r.Set(*new(TResult), err)
+				return
+			}
+
+			if !retryExpiration.IsZero() && Now(ctx).After(retryExpiration) {
+				// Reached maximum retry time, abort retries
+				r.Set(result, err)
+				return
+			}
+		}
+	})
+
+	return r
+}
+
+// executeLocalActivity starts executing the local activity, unless its result is being replayed. The returned
+// command is nil if the local activity could not be started.
+func executeLocalActivity[TResult any](
+	ctx Context, options LocalActivityOptions, attempts, maxAttempts int, retryExpiration time.Time, activity interface{}, args ...interface{},
+) (Future[TResult], *command.LocalActivityCommand) {
+	f := sync.NewFuture[TResult]()
+
+	if ctx.Err() != nil {
+		f.Set(*new(TResult), ctx.Err())
+		return f, nil
+	}
+
+	// Check return type
+	if err := a.ReturnTypeMatch[TResult](activity); err != nil {
+		f.Set(*new(TResult), err)
+		return f, nil
+	}
+
+	// Check arguments
+	if err := a.ParamsMatch(activity, args...); err != nil {
+		f.Set(*new(TResult), err)
+		return f, nil
+	}
+
+	cv := converter.GetConverter(ctx)
+	inputs, err := a.ArgsToInputs(cv, args...)
+	if err != nil {
+		f.Set(*new(TResult), fmt.Errorf("converting activity input: %w", err))
+		return f, nil
+	}
+
+	wfState := workflowstate.WorkflowState(ctx)
+	scheduleEventID := wfState.GetNextScheduleEventID()
+
+	name := fn.Name(activity)
+	cmd := command.NewLocalActivityCommand(scheduleEventID, name, inputs)
+	wfState.AddCommand(cmd)
+
+	settable := workflowstate.AsDecodingSettable(cv, f)
+	wfState.TrackFuture(scheduleEventID, settable)
+
+	_, span := workflowtracer.Tracer(ctx).Start(ctx,
+		fmt.Sprintf("ExecuteLocalActivity: %s", name),
+		trace.WithAttributes(
+			attribute.String("name", name),
+			attribute.Int64(tracing.ScheduleEventID, scheduleEventID),
+			attribute.Int("attempt", attempts+1),
+		))
+
+	if Replaying(ctx) {
+		// The result is set when the recorded result is replayed
+		span.End()
+		return f, cmd
+	}
+
+	threshold := options.LocalRetryThreshold
+	if threshold <= 0 {
+		threshold = DefaultLocalActivityOptions.LocalRetryThreshold
+	}
+
+	if !retryExpiration.IsZero() {
+		if remaining := retryExpiration.Sub(Now(ctx)); remaining < threshold {
+			threshold = remaining
+		}
+	}
+
+	as := internalactivity.NewActivityState(strconv.FormatInt(scheduleEventID, 10), wfState.Instance(), wfState.Logger())
+	as.Converter = cv
+
+	// The activity is executed outside of the workflow coroutines, the workflow is resumed with its result once it
+	// has finished
+	wfState.StartLocalActivity(func(taskCtx context.Context) func() {
+		result, activityErr, n, deferred := runLocalActivity(taskCtx, options, as, attempts, maxAttempts, threshold, activity, inputs)
+
+		return func() {
+			defer span.End()
+
+			cmd.SetResult(result, activityErr, n, deferred)
+
+			// Return the error as it's reconstructed when replaying the result
+			var err error
+			if activityErr != nil {
+				err = workflowerrors.RecordedError(cv, activityErr)
+			}
+
+			if serr := settable(result, err); serr != nil {
+				f.Set(*new(TResult), serr)
+			}
+
+			wfState.RemoveFuture(scheduleEventID)
+		}
+	})
+
+	return f, cmd
+}
+
+var errLocalActivityCutOff = errors.New("local activity attempt did not complete within the workflow task")
+
+// runLocalActivity executes attempts of the local activity until one succeeds, the activity fails permanently or
+// runs out of attempts, or until the delay before the next retry would exceed the local retry threshold or the
+// deadline of the workflow task. It returns the result of the last attempt and the number of attempts executed.
+// If the workflow task runs out of time, the local activity is deferred. An attempt cut off by the deadline of the
+// workflow task is stopped, and counts as a failed attempt.
+func runLocalActivity(
+	taskCtx context.Context, options LocalActivityOptions, as *internalactivity.ActivityState, attempts, maxAttempts int,
+	threshold time.Duration, activity interface{}, inputs []payload.Payload,
+) (payload.Payload, *workflowerrors.Error, int, bool) {
+	deadline := time.Now().Add(threshold)
+	if taskDeadline, ok := taskCtx.Deadline(); ok && taskDeadline.Before(deadline) {
+		deadline = taskDeadline
+	}
+
+	for n := 1; ; n++ {
+		if taskCtx.Err() != nil {
+			return nil, nil, n - 1, true
+		}
+
+		result, err := invokeLocalActivity(taskCtx, options, as, activity, inputs)
+		if taskCtx.Err() != nil {
+			// The attempt did not complete within the workflow task
+			return nil, workflowerrors.FromError(as.Converter, errLocalActivityCutOff), n, true
+		}
+
+		if err == nil {
+			return result, nil, n, false
+		}
+
+		if errors.Is(err, internalactivity.ErrResultPending) {
+			err = NewPermanentError(errors.New("local activities cannot be completed asynchronously"))
+		}
+
+		activityErr := workflowerrors.FromError(as.Converter, err)
+
+		if attempts+n >= maxAttempts || !retryable(activityErr, options.RetryOptions.NonRetryableErrorTypes) {
+			return nil, activityErr, n, false
+		}
+
+		backoff := retryBackoff(options.RetryOptions, attempts+n-1)
+		if time.Now().Add(backoff).After(deadline) {
+			// Retry using a timer
+			return nil, activityErr, n, false
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-taskCtx.Done():
+			t.Stop()
+
+			// Retry using a timer
+			return nil, activityErr, n, false
+		case <-t.C:
+		}
+	}
+}
+
+// invokeLocalActivity executes a single attempt of the local activity. Once its context is done, the context of the
+// attempt is canceled, and the attempt is waited for to return, so that it doesn't keep running while the local
+// activity is retried.
+func invokeLocalActivity(
+	taskCtx context.Context, options LocalActivityOptions, as *internalactivity.ActivityState, activity interface{}, inputs []payload.Payload,
+) (payload.Payload, error) {
+	activityCtx, cancel := context.WithCancel(taskCtx)
+	if options.StartToCloseTimeout > 0 {
+		activityCtx, cancel = context.WithTimeout(taskCtx, options.StartToCloseTimeout)
+	}
+	defer cancel()
+
+	type attemptResult struct {
+		result payload.Payload
+		err    error
+	}
+
+	done := make(chan attemptResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- attemptResult{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+
+		result, err := internalactivity.Invoke(activityCtx, as, activity, inputs)
+		done <- attemptResult{result, err}
+	}()
+
+	select {
+	case <-activityCtx.Done():
+		// Stop the attempt
+		cancel()
+		<-done
+
+		return nil, activityCtx.Err()
+	case r := <-done:
+		return r.result, r.err
+	}
+}
@@ -0,0 +1,10 @@
+package workflow
+
+import "github.com/cschleiden/go-workflows/internal/core"
+
+// MinPriority and MaxPriority bound the priorities of workflow instances, sub-workflows, and activities. Priorities
+// outside of this range are rejected.
+const (
+	MinPriority = core.MinPriority
+	MaxPriority = core.MaxPriority
+)
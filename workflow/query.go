@@ -0,0 +1,77 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	a "github.com/cschleiden/go-workflows/internal/args"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// SetQueryHandler registers a handler for the query with the given name. Queries allow callers to inspect the state of
+// a workflow instance without changing it. The handler has to be a function returning (result, error) and must not
+// accept a context. It must not block and must not modify the workflow state, it's invoked after the history of the
+// workflow instance has been replayed. If the handler panics, the query fails with an error.
+//
+//	step := "start"
+//	workflow.SetQueryHandler(ctx, "current-step", func() (string, error) {
+//		return step, nil
+//	})
+func SetQueryHandler(ctx Context, name string, handler interface{}) error {
+	fn := reflect.ValueOf(handler)
+	if err := validateQueryHandler(fn.Type()); err != nil {
+		return fmt.Errorf("invalid query handler %q: %w", name, err)
+	}
+
+	cv := converter.GetConverter(ctx)
+
+	wfState := workflowstate.WorkflowState(ctx)
+	wfState.SetQueryHandler(name, func(inputs []payload.Payload) (result payload.Payload, err error) {
+		args, _, err := a.InputsToArgs(cv, fn, inputs)
+		if err != nil {
+			return nil, err
+		}
+
+		// A panicking handler fails the query, not the worker
+		defer func() {
+			if r := recover(); r != nil {
+				result, err = nil, fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		r := fn.Call(args)
+
+		if errResult := r[1]; !errResult.IsNil() {
+			return nil, errResult.Interface().(error)
+		}
+
+		return cv.To(r[0].Interface())
+	})
+
+	return nil
+}
+
+func validateQueryHandler(fnType reflect.Type) error {
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return errors.New("handler is not a function")
+	}
+
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	if fnType.NumIn() > 0 && (a.IsOwnContext(fnType.In(0)) || fnType.In(0).Implements(contextType)) {
+		return errors.New("handler must not accept a context")
+	}
+
+	if fnType.NumOut() != 2 {
+		return errors.New("handler must return (result, error)")
+	}
+
+	if fnType.Out(1) != reflect.TypeOf((*error)(nil)).Elem() {
+		return fmt.Errorf("handler must return error, got %s", fnType.Out(1))
+	}
+
+	return nil
+}
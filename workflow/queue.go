@@ -0,0 +1,10 @@
+package workflow
+
+import "github.com/cschleiden/go-workflows/internal/core"
+
+// Queue is the name of a queue workflow and activity tasks are routed to. Workers only process tasks from the queues
+// they subscribe to.
+type Queue = core.Queue
+
+// QueueDefault is the queue of workflow instances and activities which don't specify a queue
+const QueueDefault = core.QueueDefault
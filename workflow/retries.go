@@ -1,6 +1,7 @@
 package workflow
 
 import (
+	"errors"
 	"math"
 	"time"
 
@@ -22,6 +23,11 @@ type RetryOptions struct {
 
 	// Timeout after which retries are aborted
 	RetryTimeout time.Duration
+
+	// NonRetryableErrorTypes are the types of errors which are not retried. The types are matched against the
+	// Type of any *Error in the chain of the returned error, for example "InvalidInput" for errors created with
+	// NewError("InvalidInput", ...) or "errors.errorString" for errors created with errors.New.
+	NonRetryableErrorTypes []string
 }
 
 var DefaultRetryOptions = RetryOptions{
@@ -63,12 +69,17 @@ func withRetries[T any](ctx sync.Context, retryOptions RetryOptions, fn func(ctx
 				break
 			}
 
-			backoffDuration := time.Duration(float64(retryOptions.FirstRetryInterval) * math.Pow(retryOptions.BackoffCoefficient, float64(attempt)))
-			if retryOptions.MaxRetryInterval > 0 {
-				backoffDuration = time.Duration(math.Min(float64(backoffDuration), float64(retryOptions.MaxRetryInterval)))
+			if !retryable(err, retryOptions.NonRetryableErrorTypes) {
+				break
+			}
+
+			// The schedule-to-close timeout covers all attempts, don't retry once it has been exceeded
+			var timeoutErr *ActivityTimeoutError
+			if errors.As(err, &timeoutErr) && timeoutErr.TimeoutType == TimeoutTypeScheduleToClose {
+				break
 			}
 
-			if err := Sleep(ctx, backoffDuration); err != nil {
+			if err := Sleep(ctx, retryBackoff(retryOptions, attempt)); err != nil {
 				r.Set(*new(T), err)
 				return
 			}
@@ -91,3 +102,36 @@ func withRetries[T any](ctx sync.Context, retryOptions RetryOptions, fn func(ctx
 
 	return r
 }
+
+// retryBackoff returns the delay before the retry following the given, zero-based, retry attempt
+func retryBackoff(retryOptions RetryOptions, attempt int) time.Duration {
+	backoffDuration := time.Duration(float64(retryOptions.FirstRetryInterval) * math.Pow(retryOptions.BackoffCoefficient, float64(attempt)))
+	if retryOptions.MaxRetryInterval > 0 {
+		backoffDuration = time.Duration(math.Min(float64(backoffDuration), float64(retryOptions.MaxRetryInterval)))
+	}
+
+	return backoffDuration
+}
+
+// retryable determines whether an operation that failed with the given error should be retried. Errors of failed
+// activities and sub-workflows are reconstructed from the history, so the decision is the same during replay.
+func retryable(err error, nonRetryableErrorTypes []string) bool {
+	var werr *Error
+	if !errors.As(err, &werr) {
+		return true
+	}
+
+	for e := werr; e != nil; e = e.Cause {
+		if !e.Retryable {
+			return false
+		}
+
+		for _, t := range nonRetryableErrorTypes {
+			if e.Type == t {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,32 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_retryable(t *testing.T) {
+	tests := []struct {
+		name                   string
+		err                    error
+		nonRetryableErrorTypes []string
+		want                   bool
+	}{
+		{"plain error", errors.New("error"), nil, true},
+		{"retryable error", NewError("InvalidInput", "invalid input"), nil, true},
+		{"permanent error", NewPermanentError(errors.New("error")), nil, false},
+		{"wrapped permanent error", fmt.Errorf("wrapped: %w", NewPermanentError(errors.New("error"))), nil, false},
+		{"non-retryable type", NewError("InvalidInput", "invalid input"), []string{"InvalidInput"}, false},
+		{"non-retryable cause type", NewError("Outer", "outer").WithCause(NewError("InvalidInput", "invalid input")), []string{"InvalidInput"}, false},
+		{"other type", NewError("Transient", "transient"), []string{"InvalidInput"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, retryable(tt.err, tt.nonRetryableErrorTypes))
+		})
+	}
+}
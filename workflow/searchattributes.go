@@ -0,0 +1,60 @@
+package workflow
+
+import (
+	"time"
+
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/core"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+type (
+	SearchAttributes     = core.SearchAttributes
+	SearchAttributeValue = core.SearchAttributeValue
+)
+
+func StringSearchAttribute(v string) SearchAttributeValue {
+	return core.StringSearchAttribute(v)
+}
+
+func IntSearchAttribute(v int64) SearchAttributeValue {
+	return core.IntSearchAttribute(v)
+}
+
+func TimeSearchAttribute(v time.Time) SearchAttributeValue {
+	return core.TimeSearchAttribute(v)
+}
+
+func BoolSearchAttribute(v bool) SearchAttributeValue {
+	return core.BoolSearchAttribute(v)
+}
+
+// KeywordsSearchAttribute returns a keyword list. Instances match a keyword list filter if they have all of its
+// keywords.
+func KeywordsSearchAttribute(v ...string) SearchAttributeValue {
+	return core.KeywordsSearchAttribute(v...)
+}
+
+// UpsertSearchAttributes adds the given search attributes to the workflow instance, or replaces existing attributes
+// with the same names. The upsert is recorded in the history of the workflow instance, and applied once the
+// workflow task completes.
+//
+//	workflow.UpsertSearchAttributes(ctx, workflow.SearchAttributes{
+//		"Status": workflow.StringSearchAttribute("shipped"),
+//	})
+func UpsertSearchAttributes(ctx Context, attributes SearchAttributes) {
+	if len(attributes) == 0 {
+		return
+	}
+
+	for name := range attributes {
+		if name == "" {
+			panic("search attribute name is required")
+		}
+	}
+
+	wfState := workflowstate.WorkflowState(ctx)
+
+	// Copy the attributes, the workflow might modify the map after the call
+	wfState.AddCommand(command.NewUpsertSearchAttributesCommand(wfState.GetNextScheduleEventID(), attributes.Upsert(nil)))
+}
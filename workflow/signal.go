@@ -1,9 +1,16 @@
 package workflow
 
 import (
-	"github.com/cschleiden/go-workflows/internal/signals"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/tracing"
 	"github.com/cschleiden/go-workflows/internal/workflowstate"
 	"github.com/cschleiden/go-workflows/internal/workflowtracer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func NewSignalChannel[T any](ctx Context, name string) Channel[T] {
@@ -11,14 +18,38 @@ func NewSignalChannel[T any](ctx Context, name string) Channel[T] {
 	return workflowstate.GetSignalChannel[T](ctx, wfState, name)
 }
 
+// SignalWorkflow sends a signal to the given workflow instance. The returned future resolves once the signal has
+// been delivered, or with an error if the instance does not exist or is not active anymore.
 func SignalWorkflow[T any](ctx Context, instanceID string, name string, arg T) Future[any] {
-	ctx, span := workflowtracer.Tracer(ctx).Start(ctx, "SignalWorkflow")
+	f := sync.NewFuture[any]()
+
+	// If the context is already canceled, return immediately.
+	if ctx.Err() != nil {
+		f.Set(nil, ctx.Err())
+		return f
+	}
+
+	cv := converter.GetConverter(ctx)
+	input, err := cv.To(arg)
+	if err != nil {
+		f.Set(nil, fmt.Errorf("converting signal argument: %w", err))
+		return f
+	}
+
+	wfState := workflowstate.WorkflowState(ctx)
+	scheduleEventID := wfState.GetNextScheduleEventID()
+
+	_, span := workflowtracer.Tracer(ctx).Start(ctx, "SignalWorkflow",
+		trace.WithAttributes(
+			attribute.String(tracing.WorkflowInstanceID, instanceID),
+			attribute.String("signal", name),
+			attribute.Int64(tracing.ScheduleEventID, scheduleEventID),
+		))
 	defer span.End()
 
-	var a *signals.Activities
-	return ExecuteActivity[any](ctx, ActivityOptions{
-		RetryOptions: RetryOptions{
-			MaxAttempts: 1,
-		},
-	}, a.DeliverWorkflowSignal, instanceID, name, arg)
+	cmd := command.NewSignalWorkflowCommand(scheduleEventID, instanceID, name, input)
+	wfState.AddCommand(cmd)
+	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(cv, f))
+
+	return f
 }
@@ -2,6 +2,7 @@ package workflow
 
 import (
 	"fmt"
+	"time"
 
 	a "github.com/cschleiden/go-workflows/internal/args"
 	"github.com/cschleiden/go-workflows/internal/command"
@@ -20,8 +21,40 @@ type SubWorkflowOptions struct {
 	InstanceID string
 
 	RetryOptions RetryOptions
+
+	// ExecutionTimeout bounds the time the sub-workflow instance can run. When it's exceeded, the instance is
+	// terminated or canceled depending on ExecutionTimeoutAction, and fails with an ExecutionTimeoutError.
+	ExecutionTimeout       time.Duration
+	ExecutionTimeoutAction ExecutionTimeoutAction
+
+	// ParentClosePolicy determines what happens to the sub-workflow instance if it's still running when the workflow
+	// completes, fails, or continues as new. By default, the sub-workflow instance keeps running.
+	ParentClosePolicy ParentClosePolicy
+
+	// Queue is the queue workflow tasks of the sub-workflow instance are routed to. By default, the sub-workflow
+	// instance uses the queue of the workflow instance.
+	Queue Queue
+
+	// Priority of workflow tasks of the sub-workflow instance. Must be between MinPriority and MaxPriority. By
+	// default, the sub-workflow instance uses the priority of the workflow instance.
+	Priority *int
 }
 
+type ExecutionTimeoutAction = core.ExecutionTimeoutAction
+
+const (
+	ExecutionTimeoutActionTerminate = core.ExecutionTimeoutActionTerminate
+	ExecutionTimeoutActionCancel    = core.ExecutionTimeoutActionCancel
+)
+
+type ParentClosePolicy = core.ParentClosePolicy
+
+const (
+	ParentClosePolicyAbandon       = core.ParentClosePolicyAbandon
+	ParentClosePolicyTerminate     = core.ParentClosePolicyTerminate
+	ParentClosePolicyRequestCancel = core.ParentClosePolicyRequestCancel
+)
+
 var (
 	DefaultSubWorkflowRetryOptions = RetryOptions{
 		// Disable retries by default for sub-workflows
@@ -60,6 +93,14 @@ func createSubWorkflowInstance[TResult any](ctx sync.Context, options SubWorkflo
 		return f
 	}
 
+	// Check priority
+	if options.Priority != nil {
+		if err := core.ValidatePriority(*options.Priority); err != nil {
+			f.Set(*new(TResult), err)
+			return f
+		}
+	}
+
 	name := fn.Name(wf)
 
 	cv := converter.GetConverter(ctx)
@@ -85,6 +126,17 @@ func createSubWorkflowInstance[TResult any](ctx sync.Context, options SubWorkflo
 	span.Marshal(metadata)
 
 	cmd := command.NewScheduleSubWorkflowCommand(scheduleEventID, wfState.Instance(), options.InstanceID, name, inputs, metadata)
+	cmd.ExecutionTimeout = options.ExecutionTimeout
+	cmd.ExecutionTimeoutAction = options.ExecutionTimeoutAction
+	cmd.ParentClosePolicy = options.ParentClosePolicy
+	cmd.Queue = options.Queue
+	if cmd.Queue == "" {
+		cmd.Queue = wfState.Queue()
+	}
+	cmd.Priority = wfState.Priority()
+	if options.Priority != nil {
+		cmd.Priority = *options.Priority
+	}
 	wfState.AddCommand(cmd)
 	wfState.TrackFuture(scheduleEventID, workflowstate.AsDecodingSettable(cv, f))
 
@@ -0,0 +1,82 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/converter"
+	"github.com/cschleiden/go-workflows/internal/payload"
+	"github.com/cschleiden/go-workflows/internal/sync"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// SetUpdateHandler registers a handler for updates with the given name. Updates are sent using client.UpdateWorkflow,
+// which waits until the update has been handled and returns its result.
+//
+// The validator is optional. It's invoked when an update is received, before it's recorded as accepted, and rejects
+// the update by returning an error or by panicking. It must not block and must not modify the workflow state.
+// Accepted updates are passed to the handler, which runs in its own workflow goroutine and may execute activities,
+// timers, or sub-workflows. Updates received before a handler has been registered for them are rejected, so handlers
+// should be registered at the beginning of the workflow.
+//
+//	workflow.SetUpdateHandler(ctx, "approve",
+//		func(approver string) error {
+//			if approver == "" {
+//				return errors.New("approver is required")
+//			}
+//
+//			return nil
+//		},
+//		func(ctx workflow.Context, approver string) (bool, error) {
+//			approved = true
+//			return approved, nil
+//		})
+func SetUpdateHandler[TArg, TResult any](
+	ctx Context, name string, validator func(arg TArg) error, handler func(ctx Context, arg TArg) (TResult, error),
+) error {
+	if handler == nil {
+		return errors.New("update handler is required")
+	}
+
+	cv := converter.GetConverter(ctx)
+
+	convertArg := func(arg payload.Payload) (TArg, error) {
+		var a TArg
+		if err := cv.From(arg, &a); err != nil {
+			return a, fmt.Errorf("converting update argument: %w", err)
+		}
+
+		return a, nil
+	}
+
+	wfState := workflowstate.WorkflowState(ctx)
+	wfState.SetUpdateHandler(name, &workflowstate.UpdateHandler{
+		Validate: func(arg payload.Payload) error {
+			a, err := convertArg(arg)
+			if err != nil {
+				return err
+			}
+
+			if validator == nil {
+				return nil
+			}
+
+			return validator(a)
+		},
+		Handle: func(ctx sync.Context, arg payload.Payload) (payload.Payload, error) {
+			a, err := convertArg(arg)
+			if err != nil {
+				return nil, err
+			}
+
+			r, err := handler(ctx, a)
+			if err != nil {
+				return nil, err
+			}
+
+			return cv.To(r)
+		},
+	})
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/cschleiden/go-workflows/internal/command"
+	"github.com/cschleiden/go-workflows/internal/workflowstate"
+)
+
+// DefaultVersion is returned by GetVersion for workflow executions which reached the change before GetVersion was
+// added to the workflow code.
+const DefaultVersion = -1
+
+// GetVersion allows to safely change the code of workflows with running instances. On the first execution, it
+// records maxSupported in the history of the workflow instance and returns it. When the workflow is replayed, the
+// recorded version is returned. Executions which reached this point before GetVersion was added return
+// DefaultVersion. Subsequent calls with the same changeID return the same version.
+//
+// If the version is not within [minSupported, maxSupported], GetVersion panics and the workflow execution fails.
+//
+//	v := workflow.GetVersion(ctx, "use-activity-2", workflow.DefaultVersion, 1)
+//	if v == workflow.DefaultVersion {
+//		workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, Activity1)
+//	} else {
+//		workflow.ExecuteActivity[int](ctx, workflow.DefaultActivityOptions, Activity2)
+//	}
+func GetVersion(ctx Context, changeID string, minSupported, maxSupported int) int {
+	wfState := workflowstate.WorkflowState(ctx)
+
+	version, ok := wfState.Version(changeID)
+	if !ok {
+		if v, recorded := wfState.RecordedVersion(changeID); recorded {
+			// The marker will be replayed from the history
+			version = v
+			wfState.AddCommand(command.NewVersionMarkerCommand(wfState.GetNextScheduleEventID(), changeID, version))
+		} else if Replaying(ctx) {
+			// This point was reached before the change was made
+			version = DefaultVersion
+		} else {
+			version = maxSupported
+			wfState.AddCommand(command.NewVersionMarkerCommand(wfState.GetNextScheduleEventID(), changeID, version))
+		}
+
+		wfState.SetVersion(changeID, version)
+	}
+
+	if version < minSupported || version > maxSupported {
+		panic(fmt.Sprintf(
+			"version %d of change %q is not supported, supported versions: [%d, %d]", version, changeID, minSupported, maxSupported))
+	}
+
+	return version
+}